@@ -0,0 +1,275 @@
+// Package ast_dump prints regexp_ast trees in a human-readable form for
+// debugging and golden files, like go/ast.Fprint.
+//
+// The default form is an indented tree with one node per line:
+//
+//	Pattern @0-7
+//	  Alternative @0-7
+//	    Quantifier{0,∞ greedy} @0-2 → Character 'a' @0-1
+//	    CharacterClass @2-7
+//	      CharacterClassRange 'b'-'c' @3-6
+//
+// SExpr prints the same tree as one S-expression.
+package ast_dump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+type Mode uint
+
+const (
+	// Print an S-expression instead of an indented tree
+	SExpr Mode = 1 << iota
+	// Omit the `@start-end` offsets
+	NoOffsets
+)
+
+func Fprint(w io.Writer, node regexp_ast.Node, mode Mode) error {
+	bw := bufio.NewWriter(w)
+	p := &printer{w: bw, mode: mode}
+	if mode&SExpr != 0 {
+		p.sexpr(node)
+		bw.WriteByte('\n')
+	} else {
+		p.tree(node, 0, false)
+	}
+	return bw.Flush()
+}
+
+func Sprint(node regexp_ast.Node, mode Mode) string {
+	var sb strings.Builder
+	Fprint(&sb, node, mode)
+	return sb.String()
+}
+
+type printer struct {
+	w    *bufio.Writer
+	mode Mode
+}
+
+// tree prints node and its children. If inline is true, node continues the
+// line of its parent.
+func (p *printer) tree(node regexp_ast.Node, depth int, inline bool) {
+	if !inline {
+		p.w.WriteString(strings.Repeat("  ", depth))
+	}
+	p.w.WriteString(label(node))
+	p.offsets(node)
+
+	switch n := node.(type) {
+	case *regexp_ast.Pattern:
+		p.w.WriteByte('\n')
+		for _, alt := range n.Alternatives {
+			p.tree(alt, depth+1, false)
+		}
+	case *regexp_ast.Alternative:
+		p.w.WriteByte('\n')
+		for _, el := range n.Elements {
+			p.tree(el.(regexp_ast.Node), depth+1, false)
+		}
+	case *regexp_ast.CharacterClass:
+		p.w.WriteByte('\n')
+		for _, el := range n.Elements {
+			p.tree(el.(regexp_ast.Node), depth+1, false)
+		}
+	case *regexp_ast.Group:
+		p.w.WriteByte('\n')
+		for _, alt := range n.Alternatives {
+			p.tree(alt, depth+1, false)
+		}
+	case *regexp_ast.CapturingGroup:
+		p.w.WriteByte('\n')
+		for _, alt := range n.Alternatives {
+			p.tree(alt, depth+1, false)
+		}
+	case *regexp_ast.LookaroundAssertion:
+		p.w.WriteByte('\n')
+		for _, alt := range n.Alternatives {
+			p.tree(alt, depth+1, false)
+		}
+	case *regexp_ast.Quantifier:
+		p.w.WriteString(" → ")
+		p.tree(n.Element.(regexp_ast.Node), depth, true)
+	case *regexp_ast.ExpressionCharacterClass:
+		p.w.WriteByte('\n')
+		p.tree(n.Expression.(regexp_ast.Node), depth+1, false)
+	case *regexp_ast.ClassIntersection:
+		p.w.WriteByte('\n')
+		p.tree(n.Left.(regexp_ast.Node), depth+1, false)
+		p.tree(n.Right.(regexp_ast.Node), depth+1, false)
+	case *regexp_ast.ClassSubtraction:
+		p.w.WriteByte('\n')
+		p.tree(n.Left.(regexp_ast.Node), depth+1, false)
+		p.tree(n.Right.(regexp_ast.Node), depth+1, false)
+	case *regexp_ast.ClassStringDisjunction:
+		p.w.WriteByte('\n')
+		for _, alt := range n.Alternatives {
+			p.tree(alt, depth+1, false)
+		}
+	case *regexp_ast.StringAlternative:
+		p.w.WriteByte('\n')
+		for _, c := range n.Elements {
+			p.tree(c, depth+1, false)
+		}
+	default:
+		p.w.WriteByte('\n')
+	}
+}
+
+func (p *printer) sexpr(node regexp_ast.Node) {
+	p.w.WriteByte('(')
+	p.w.WriteString(label(node))
+	p.offsets(node)
+
+	var children []regexp_ast.Node
+	switch n := node.(type) {
+	case *regexp_ast.Pattern:
+		for _, alt := range n.Alternatives {
+			children = append(children, alt)
+		}
+	case *regexp_ast.Alternative:
+		for _, el := range n.Elements {
+			children = append(children, el.(regexp_ast.Node))
+		}
+	case *regexp_ast.CharacterClass:
+		for _, el := range n.Elements {
+			children = append(children, el.(regexp_ast.Node))
+		}
+	case *regexp_ast.Group:
+		for _, alt := range n.Alternatives {
+			children = append(children, alt)
+		}
+	case *regexp_ast.CapturingGroup:
+		for _, alt := range n.Alternatives {
+			children = append(children, alt)
+		}
+	case *regexp_ast.LookaroundAssertion:
+		for _, alt := range n.Alternatives {
+			children = append(children, alt)
+		}
+	case *regexp_ast.Quantifier:
+		children = append(children, n.Element.(regexp_ast.Node))
+	case *regexp_ast.ExpressionCharacterClass:
+		children = append(children, n.Expression.(regexp_ast.Node))
+	case *regexp_ast.ClassIntersection:
+		children = append(children, n.Left.(regexp_ast.Node), n.Right.(regexp_ast.Node))
+	case *regexp_ast.ClassSubtraction:
+		children = append(children, n.Left.(regexp_ast.Node), n.Right.(regexp_ast.Node))
+	case *regexp_ast.ClassStringDisjunction:
+		for _, alt := range n.Alternatives {
+			children = append(children, alt)
+		}
+	case *regexp_ast.StringAlternative:
+		for _, c := range n.Elements {
+			children = append(children, c)
+		}
+	}
+	for _, child := range children {
+		p.w.WriteByte(' ')
+		p.sexpr(child)
+	}
+	p.w.WriteByte(')')
+}
+
+func (p *printer) offsets(node regexp_ast.Node) {
+	if p.mode&NoOffsets != 0 {
+		return
+	}
+	loc := node.GetLoc()
+	fmt.Fprintf(p.w, " @%d-%d", loc.Start, loc.End)
+}
+
+func label(node regexp_ast.Node) string {
+	switch n := node.(type) {
+	case *regexp_ast.Pattern:
+		return "Pattern"
+	case *regexp_ast.Alternative:
+		return "Alternative"
+	case *regexp_ast.Character:
+		return "Character " + quote(n.Value)
+	case *regexp_ast.CharacterClass:
+		if n.Negate {
+			return "CharacterClass negate"
+		}
+		return "CharacterClass"
+	case *regexp_ast.AnyCharacterSet:
+		return "AnyCharacterSet"
+	case *regexp_ast.EscapeCharacterSet:
+		return withNegate("EscapeCharacterSet "+string(n.Kind), n.Negate)
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		property := n.Key
+		if n.Value != "" {
+			property += "=" + n.Value
+		}
+		return withNegate("UnicodePropertyCharacterSet "+property, n.Negate)
+	case *regexp_ast.Group:
+		return "Group"
+	case *regexp_ast.CapturingGroup:
+		if n.Name != "" {
+			return "CapturingGroup <" + n.Name + ">"
+		}
+		return "CapturingGroup"
+	case *regexp_ast.LookaroundAssertion:
+		return withNegate("LookaroundAssertion "+string(n.Kind), n.Negate)
+	case *regexp_ast.EdgeAssertion:
+		return "EdgeAssertion " + string(n.Kind)
+	case *regexp_ast.WordBoundaryAssertion:
+		return withNegate("WordBoundaryAssertion", n.Negate)
+	case *regexp_ast.Backreference:
+		if n.Name != "" {
+			return "Backreference <" + n.Name + ">"
+		}
+		return "Backreference " + strconv.Itoa(n.Number)
+	case *regexp_ast.Quantifier:
+		max := "∞"
+		if n.Max != math.MaxInt {
+			max = strconv.Itoa(n.Max)
+		}
+		greedy := "greedy"
+		if !n.Greety {
+			greedy = "lazy"
+		}
+		return fmt.Sprintf("Quantifier{%d,%s %s}", n.Min, max, greedy)
+	case *regexp_ast.CharacterClassRange:
+		return fmt.Sprintf("CharacterClassRange %s-%s", quote(n.Min.Value), quote(n.Max.Value))
+	case *regexp_ast.ExpressionCharacterClass:
+		if n.Negate {
+			return "ExpressionCharacterClass negate"
+		}
+		return "ExpressionCharacterClass"
+	case *regexp_ast.ClassIntersection:
+		return "ClassIntersection"
+	case *regexp_ast.ClassSubtraction:
+		return "ClassSubtraction"
+	case *regexp_ast.ClassStringDisjunction:
+		return "ClassStringDisjunction"
+	case *regexp_ast.StringAlternative:
+		return "StringAlternative"
+	default:
+		return fmt.Sprintf("%T", node)
+	}
+}
+
+func withNegate(label string, negate bool) string {
+	if negate {
+		return label + " negate"
+	}
+	return label
+}
+
+// quote returns the code point as a Go rune literal, or as U+XXXX if it is a
+// surrogate, which can't be written in a rune literal.
+func quote(cp int) string {
+	if cp >= 0xd800 && cp <= 0xdfff {
+		return fmt.Sprintf("U+%04X", cp)
+	}
+	return strconv.QuoteRune(rune(cp))
+}
@@ -0,0 +1,92 @@
+package ast_dump_test
+
+import (
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/ast_dump"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+)
+
+func TestSprint(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputU     bool
+		inputV     bool
+		inputMode  ast_dump.Mode
+		wantOutput string
+	}{
+		{
+			name:      "インデントされた木を出力する",
+			inputS:    "a*[b-c]|.+?",
+			inputU:    true,
+			inputMode: 0,
+			wantOutput: `Pattern @0-11
+  Alternative @0-7
+    Quantifier{0,∞ greedy} @0-2 → Character 'a' @0-1
+    CharacterClass @2-7
+      CharacterClassRange 'b'-'c' @3-6
+  Alternative @8-11
+    Quantifier{1,∞ lazy} @8-11 → AnyCharacterSet @8-9
+`,
+		},
+		{
+			name:       "S 式を出力する",
+			inputS:     "a{2,5}[^\n]",
+			inputU:     true,
+			inputMode:  ast_dump.SExpr | ast_dump.NoOffsets,
+			wantOutput: "(Pattern (Alternative (Quantifier{2,5 greedy} (Character 'a')) (CharacterClass negate (Character '\\n'))))\n",
+		},
+		{
+			name:       "サロゲートを U+XXXX として出力する",
+			inputS:     "𠮟",
+			inputU:     false,
+			inputMode:  ast_dump.SExpr,
+			wantOutput: "(Pattern @0-2 (Alternative @0-2 (Character U+D842 @0-1) (Character U+DF9F @1-2)))\n",
+		},
+		{
+			name:      "グループ, アサーション, 後方参照, エスケープを出力する",
+			inputS:    `^(?<y>\d)(?:\k<y>|\1)(?<!\B\P{Script=Hira})$`,
+			inputU:    true,
+			inputMode: 0,
+			wantOutput: `Pattern @0-44
+  Alternative @0-44
+    EdgeAssertion start @0-1
+    CapturingGroup <y> @1-9
+      Alternative @6-8
+        EscapeCharacterSet digit @6-8
+    Group @9-21
+      Alternative @12-17
+        Backreference <y> @12-17
+      Alternative @18-20
+        Backreference 1 @18-20
+    LookaroundAssertion lookbehind negate @21-43
+      Alternative @25-42
+        WordBoundaryAssertion negate @25-27
+        UnicodePropertyCharacterSet Script=Hira negate @27-42
+    EdgeAssertion end @43-44
+`,
+		},
+		{
+			name:       "v フラグのクラスを S 式として出力する",
+			inputS:     `[\q{ab}&&[\w]]`,
+			inputV:     true,
+			inputMode:  ast_dump.SExpr | ast_dump.NoOffsets,
+			wantOutput: "(Pattern (Alternative (ExpressionCharacterClass (ClassIntersection (ClassStringDisjunction (StringAlternative (Character 'a') (Character 'b'))) (CharacterClass (EscapeCharacterSet word))))))\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParserWithOptions(tt.inputS, tt.inputU, parser.Options{UnicodeSets: tt.inputV})
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := ast_dump.Sprint(pattern, tt.inputMode)
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected output\nexpected:\n%s\nactual:\n%s", tt.wantOutput, o)
+			}
+		})
+	}
+}
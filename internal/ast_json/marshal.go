@@ -0,0 +1,491 @@
+// Package ast_json converts regexp_ast trees to and from the JSON format of
+// mysticatea/regexpp's AST, so that they can be exchanged with JS tooling.
+//
+// Every node has `type`, `parent`, `start`, `end` and `raw` followed by its
+// own fields, in the same order as regexpp. Like regexpp's test fixtures,
+// `parent` is written as a relative path such as "♻️../.." (null for the
+// root), and an infinite Quantifier max is written as "$$Infinity". The
+// `references` of a CapturingGroup and the `resolved` of a Backreference are
+// relative paths of the same kind.
+package ast_json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+const (
+	parentPrefix = "♻️"
+	infinity     = "$$Infinity"
+)
+
+type patternJSON struct {
+	Type         string            `json:"type"`
+	Parent       *string           `json:"parent"`
+	Start        int               `json:"start"`
+	End          int               `json:"end"`
+	Raw          string            `json:"raw"`
+	Alternatives []json.RawMessage `json:"alternatives"`
+}
+
+type alternativeJSON struct {
+	Type     string            `json:"type"`
+	Parent   *string           `json:"parent"`
+	Start    int               `json:"start"`
+	End      int               `json:"end"`
+	Raw      string            `json:"raw"`
+	Elements []json.RawMessage `json:"elements"`
+}
+
+type groupJSON struct {
+	Type         string            `json:"type"`
+	Parent       *string           `json:"parent"`
+	Start        int               `json:"start"`
+	End          int               `json:"end"`
+	Raw          string            `json:"raw"`
+	Alternatives []json.RawMessage `json:"alternatives"`
+}
+
+type capturingGroupJSON struct {
+	Type         string            `json:"type"`
+	Parent       *string           `json:"parent"`
+	Start        int               `json:"start"`
+	End          int               `json:"end"`
+	Raw          string            `json:"raw"`
+	Name         *string           `json:"name"`
+	Alternatives []json.RawMessage `json:"alternatives"`
+	References   []*string         `json:"references"`
+}
+
+type lookaroundAssertionJSON struct {
+	Type         string            `json:"type"`
+	Parent       *string           `json:"parent"`
+	Start        int               `json:"start"`
+	End          int               `json:"end"`
+	Raw          string            `json:"raw"`
+	Kind         string            `json:"kind"`
+	Negate       bool              `json:"negate"`
+	Alternatives []json.RawMessage `json:"alternatives"`
+}
+
+type edgeAssertionJSON struct {
+	Type   string  `json:"type"`
+	Parent *string `json:"parent"`
+	Start  int     `json:"start"`
+	End    int     `json:"end"`
+	Raw    string  `json:"raw"`
+	Kind   string  `json:"kind"`
+}
+
+type wordBoundaryAssertionJSON struct {
+	Type   string  `json:"type"`
+	Parent *string `json:"parent"`
+	Start  int     `json:"start"`
+	End    int     `json:"end"`
+	Raw    string  `json:"raw"`
+	Kind   string  `json:"kind"`
+	Negate bool    `json:"negate"`
+}
+
+// ref is the number of \1 or the name of \k<name>.
+type backreferenceJSON struct {
+	Type     string      `json:"type"`
+	Parent   *string     `json:"parent"`
+	Start    int         `json:"start"`
+	End      int         `json:"end"`
+	Raw      string      `json:"raw"`
+	Ref      interface{} `json:"ref"`
+	Resolved *string     `json:"resolved"`
+}
+
+type characterJSON struct {
+	Type   string  `json:"type"`
+	Parent *string `json:"parent"`
+	Start  int     `json:"start"`
+	End    int     `json:"end"`
+	Raw    string  `json:"raw"`
+	Value  int     `json:"value"`
+}
+
+// unicodeSets is only written for a class with the v flag, so that the
+// output without it stays the same as regexpp v3.
+type characterClassJSON struct {
+	Type        string            `json:"type"`
+	Parent      *string           `json:"parent"`
+	Start       int               `json:"start"`
+	End         int               `json:"end"`
+	Raw         string            `json:"raw"`
+	UnicodeSets bool              `json:"unicodeSets,omitempty"`
+	Negate      bool              `json:"negate"`
+	Elements    []json.RawMessage `json:"elements"`
+}
+
+type expressionCharacterClassJSON struct {
+	Type       string          `json:"type"`
+	Parent     *string         `json:"parent"`
+	Start      int             `json:"start"`
+	End        int             `json:"end"`
+	Raw        string          `json:"raw"`
+	Negate     bool            `json:"negate"`
+	Expression json.RawMessage `json:"expression"`
+}
+
+// The JSON of ClassIntersection and ClassSubtraction
+type classSetOperationJSON struct {
+	Type   string          `json:"type"`
+	Parent *string         `json:"parent"`
+	Start  int             `json:"start"`
+	End    int             `json:"end"`
+	Raw    string          `json:"raw"`
+	Left   json.RawMessage `json:"left"`
+	Right  json.RawMessage `json:"right"`
+}
+
+type classStringDisjunctionJSON struct {
+	Type         string            `json:"type"`
+	Parent       *string           `json:"parent"`
+	Start        int               `json:"start"`
+	End          int               `json:"end"`
+	Raw          string            `json:"raw"`
+	Alternatives []json.RawMessage `json:"alternatives"`
+}
+
+type characterSetJSON struct {
+	Type   string  `json:"type"`
+	Parent *string `json:"parent"`
+	Start  int     `json:"start"`
+	End    int     `json:"end"`
+	Raw    string  `json:"raw"`
+	Kind   string  `json:"kind"`
+}
+
+type escapeCharacterSetJSON struct {
+	Type   string  `json:"type"`
+	Parent *string `json:"parent"`
+	Start  int     `json:"start"`
+	End    int     `json:"end"`
+	Raw    string  `json:"raw"`
+	Kind   string  `json:"kind"`
+	Negate bool    `json:"negate"`
+}
+
+// strings is only written for a property of strings, like unicodeSets.
+type unicodePropertyCharacterSetJSON struct {
+	Type    string  `json:"type"`
+	Parent  *string `json:"parent"`
+	Start   int     `json:"start"`
+	End     int     `json:"end"`
+	Raw     string  `json:"raw"`
+	Kind    string  `json:"kind"`
+	Strings bool    `json:"strings,omitempty"`
+	Key     string  `json:"key"`
+	Value   *string `json:"value"`
+	Negate  bool    `json:"negate"`
+}
+
+type quantifierJSON struct {
+	Type    string          `json:"type"`
+	Parent  *string         `json:"parent"`
+	Start   int             `json:"start"`
+	End     int             `json:"end"`
+	Raw     string          `json:"raw"`
+	Min     int             `json:"min"`
+	Max     interface{}     `json:"max"`
+	Greedy  bool            `json:"greedy"`
+	Element json.RawMessage `json:"element"`
+}
+
+type characterClassRangeJSON struct {
+	Type   string          `json:"type"`
+	Parent *string         `json:"parent"`
+	Start  int             `json:"start"`
+	End    int             `json:"end"`
+	Raw    string          `json:"raw"`
+	Min    json.RawMessage `json:"min"`
+	Max    json.RawMessage `json:"max"`
+}
+
+// Marshal returns the regexpp JSON of the tree rooted at node. source is the
+// pattern source the tree was parsed from, used for `raw`; the Locs must be
+// in UTF-16 code units, which is the default of the parser.
+func Marshal(node regexp_ast.Node, source string) ([]byte, error) {
+	m := &marshaler{source: source, converter: offset.NewConverter(source), paths: map[regexp_ast.Node][]string{}}
+	m.index(node, nil)
+	return m.marshal(node, nil)
+}
+
+// MarshalIndent is like Marshal but indents the output like json.MarshalIndent.
+func MarshalIndent(node regexp_ast.Node, source string, prefix string, indent string) ([]byte, error) {
+	b, err := Marshal(node, source)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type marshaler struct {
+	source    string
+	converter *offset.Converter
+	// The paths from the root to the groups and the backreferences, to write
+	// the references between them
+	paths map[regexp_ast.Node][]string
+}
+
+// index records the path of every group and backreference below node, whose
+// path is path. Character classes can't contain either, so they are skipped.
+func (m *marshaler) index(node regexp_ast.Node, path []string) {
+	indexAlternatives := func(alts []*regexp_ast.Alternative) {
+		for i, alt := range alts {
+			m.index(alt, appendPath(path, "alternatives", strconv.Itoa(i)))
+		}
+	}
+	switch n := node.(type) {
+	case *regexp_ast.Pattern:
+		indexAlternatives(n.Alternatives)
+	case *regexp_ast.Alternative:
+		for i, e := range n.Elements {
+			m.index(e.(regexp_ast.Node), appendPath(path, "elements", strconv.Itoa(i)))
+		}
+	case *regexp_ast.Group:
+		indexAlternatives(n.Alternatives)
+	case *regexp_ast.CapturingGroup:
+		m.paths[n] = path
+		indexAlternatives(n.Alternatives)
+	case *regexp_ast.LookaroundAssertion:
+		indexAlternatives(n.Alternatives)
+	case *regexp_ast.Quantifier:
+		m.index(n.Element.(regexp_ast.Node), appendPath(path, "element"))
+	case *regexp_ast.Backreference:
+		m.paths[n] = path
+	}
+}
+
+// ref returns the relative path from one node to another, or nil if to is
+// not in the tree.
+func (m *marshaler) ref(from, to regexp_ast.Node) *string {
+	fromPath, ok := m.paths[from]
+	if !ok {
+		return nil
+	}
+	toPath, ok := m.paths[to]
+	if !ok {
+		return nil
+	}
+	common := 0
+	for common < len(fromPath) && common < len(toPath) && fromPath[common] == toPath[common] {
+		common++
+	}
+	parts := make([]string, 0, len(fromPath)-common+len(toPath)-common)
+	for i := common; i < len(fromPath); i++ {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, toPath[common:]...)
+	s := parentPrefix + strings.Join(parts, "/")
+	return &s
+}
+
+// marshal encodes node. parent is the path from node to its parent, or nil
+// for the root.
+func (m *marshaler) marshal(node regexp_ast.Node, parent *string) ([]byte, error) {
+	loc := node.GetLoc()
+	start, end := loc.Start, loc.End
+	raw := m.raw(loc)
+
+	// Children of an array are two levels below their parent, a child in a
+	// property is one level below.
+	inArray := parentPath(2)
+	inProperty := parentPath(1)
+
+	switch n := node.(type) {
+	case *regexp_ast.Pattern:
+		alts, err := m.marshalNodes(len(n.Alternatives), func(i int) regexp_ast.Node { return n.Alternatives[i] }, inArray)
+		if err != nil {
+			return nil, err
+		}
+		return encode(patternJSON{"Pattern", parent, start, end, raw, alts})
+	case *regexp_ast.Alternative:
+		elements, err := m.marshalNodes(len(n.Elements), func(i int) regexp_ast.Node { return n.Elements[i].(regexp_ast.Node) }, inArray)
+		if err != nil {
+			return nil, err
+		}
+		return encode(alternativeJSON{"Alternative", parent, start, end, raw, elements})
+	case *regexp_ast.Group:
+		alts, err := m.marshalNodes(len(n.Alternatives), func(i int) regexp_ast.Node { return n.Alternatives[i] }, inArray)
+		if err != nil {
+			return nil, err
+		}
+		return encode(groupJSON{"Group", parent, start, end, raw, alts})
+	case *regexp_ast.CapturingGroup:
+		alts, err := m.marshalNodes(len(n.Alternatives), func(i int) regexp_ast.Node { return n.Alternatives[i] }, inArray)
+		if err != nil {
+			return nil, err
+		}
+		var name *string
+		if n.Name != "" {
+			name = &n.Name
+		}
+		references := make([]*string, 0, len(n.References))
+		for _, r := range n.References {
+			references = append(references, m.ref(n, r))
+		}
+		return encode(capturingGroupJSON{"CapturingGroup", parent, start, end, raw, name, alts, references})
+	case *regexp_ast.LookaroundAssertion:
+		alts, err := m.marshalNodes(len(n.Alternatives), func(i int) regexp_ast.Node { return n.Alternatives[i] }, inArray)
+		if err != nil {
+			return nil, err
+		}
+		return encode(lookaroundAssertionJSON{"Assertion", parent, start, end, raw, string(n.Kind), n.Negate, alts})
+	case *regexp_ast.EdgeAssertion:
+		return encode(edgeAssertionJSON{"Assertion", parent, start, end, raw, string(n.Kind)})
+	case *regexp_ast.WordBoundaryAssertion:
+		return encode(wordBoundaryAssertionJSON{"Assertion", parent, start, end, raw, "word", n.Negate})
+	case *regexp_ast.Backreference:
+		var ref interface{} = n.Number
+		if n.Name != "" {
+			ref = n.Name
+		}
+		var resolved *string
+		if n.Resolved != nil {
+			resolved = m.ref(n, n.Resolved)
+		}
+		return encode(backreferenceJSON{"Backreference", parent, start, end, raw, ref, resolved})
+	case *regexp_ast.Character:
+		return encode(characterJSON{"Character", parent, start, end, raw, n.Value})
+	case *regexp_ast.CharacterClass:
+		elements, err := m.marshalNodes(len(n.Elements), func(i int) regexp_ast.Node { return n.Elements[i].(regexp_ast.Node) }, inArray)
+		if err != nil {
+			return nil, err
+		}
+		return encode(characterClassJSON{"CharacterClass", parent, start, end, raw, n.UnicodeSets, n.Negate, elements})
+	case *regexp_ast.AnyCharacterSet:
+		return encode(characterSetJSON{"CharacterSet", parent, start, end, raw, "any"})
+	case *regexp_ast.EscapeCharacterSet:
+		return encode(escapeCharacterSetJSON{"CharacterSet", parent, start, end, raw, string(n.Kind), n.Negate})
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		var value *string
+		if n.Value != "" {
+			value = &n.Value
+		}
+		return encode(unicodePropertyCharacterSetJSON{"CharacterSet", parent, start, end, raw, "property", n.Strings, n.Key, value, n.Negate})
+	case *regexp_ast.Quantifier:
+		element, err := m.marshal(n.Element.(regexp_ast.Node), inProperty)
+		if err != nil {
+			return nil, err
+		}
+		var max interface{} = n.Max
+		if n.Max == math.MaxInt {
+			max = infinity
+		}
+		return encode(quantifierJSON{"Quantifier", parent, start, end, raw, n.Min, max, n.Greety, element})
+	case *regexp_ast.CharacterClassRange:
+		min, err := m.marshal(n.Min, inProperty)
+		if err != nil {
+			return nil, err
+		}
+		max, err := m.marshal(n.Max, inProperty)
+		if err != nil {
+			return nil, err
+		}
+		return encode(characterClassRangeJSON{"CharacterClassRange", parent, start, end, raw, min, max})
+	case *regexp_ast.ExpressionCharacterClass:
+		expression, err := m.marshal(n.Expression.(regexp_ast.Node), inProperty)
+		if err != nil {
+			return nil, err
+		}
+		return encode(expressionCharacterClassJSON{"ExpressionCharacterClass", parent, start, end, raw, n.Negate, expression})
+	case *regexp_ast.ClassIntersection:
+		left, right, err := m.marshalOperands(n.Left, n.Right, inProperty)
+		if err != nil {
+			return nil, err
+		}
+		return encode(classSetOperationJSON{"ClassIntersection", parent, start, end, raw, left, right})
+	case *regexp_ast.ClassSubtraction:
+		left, right, err := m.marshalOperands(n.Left, n.Right, inProperty)
+		if err != nil {
+			return nil, err
+		}
+		return encode(classSetOperationJSON{"ClassSubtraction", parent, start, end, raw, left, right})
+	case *regexp_ast.ClassStringDisjunction:
+		alts, err := m.marshalNodes(len(n.Alternatives), func(i int) regexp_ast.Node { return n.Alternatives[i] }, inArray)
+		if err != nil {
+			return nil, err
+		}
+		return encode(classStringDisjunctionJSON{"ClassStringDisjunction", parent, start, end, raw, alts})
+	case *regexp_ast.StringAlternative:
+		elements, err := m.marshalNodes(len(n.Elements), func(i int) regexp_ast.Node { return n.Elements[i] }, inArray)
+		if err != nil {
+			return nil, err
+		}
+		return encode(alternativeJSON{"StringAlternative", parent, start, end, raw, elements})
+	default:
+		return nil, fmt.Errorf("ast_json: unknown node %T", node)
+	}
+}
+
+func (m *marshaler) marshalNodes(n int, at func(int) regexp_ast.Node, parent *string) ([]json.RawMessage, error) {
+	out := make([]json.RawMessage, 0, n)
+	for i := 0; i < n; i++ {
+		b, err := m.marshal(at(i), parent)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+func (m *marshaler) marshalOperands(left, right regexp_ast.ClassSetOperand, parent *string) (json.RawMessage, json.RawMessage, error) {
+	l, err := m.marshal(left.(regexp_ast.Node), parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := m.marshal(right.(regexp_ast.Node), parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+func (m *marshaler) raw(loc regexp_ast.Loc) string {
+	start := m.converter.Convert(loc.Start, offset.UTF16, offset.UTF8)
+	end := m.converter.ConvertEnd(loc.End, offset.UTF16, offset.UTF8)
+	if start > end {
+		return ""
+	}
+	return m.source[start:end]
+}
+
+// appendPath returns path with parts appended, without sharing the backing
+// array of path.
+func appendPath(path []string, parts ...string) []string {
+	out := make([]string, 0, len(path)+len(parts))
+	return append(append(out, path...), parts...)
+}
+
+func parentPath(depth int) *string {
+	s := parentPrefix + strings.TrimSuffix(strings.Repeat("../", depth), "/")
+	return &s
+}
+
+// encode is json.Marshal without HTML escaping, so that `<` and `>` in raw
+// are written as is, like JSON.stringify does.
+func encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,76 @@
+package ast_json_test
+
+import (
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/ast_json"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputV     bool
+		wantOutput string
+	}{
+		{
+			name:       "Character の type, start, end, raw, value を出力する",
+			inputS:     "a",
+			wantOutput: `{"type":"Pattern","parent":null,"start":0,"end":1,"raw":"a","alternatives":[{"type":"Alternative","parent":"♻️../..","start":0,"end":1,"raw":"a","elements":[{"type":"Character","parent":"♻️../..","start":0,"end":1,"raw":"a","value":97}]}]}`,
+		},
+		{
+			name:       "無限の max を $$Infinity として出力する",
+			inputS:     "<+?",
+			wantOutput: `{"type":"Pattern","parent":null,"start":0,"end":3,"raw":"<+?","alternatives":[{"type":"Alternative","parent":"♻️../..","start":0,"end":3,"raw":"<+?","elements":[{"type":"Quantifier","parent":"♻️../..","start":0,"end":3,"raw":"<+?","min":1,"max":"$$Infinity","greedy":false,"element":{"type":"Character","parent":"♻️..","start":0,"end":1,"raw":"<","value":60}}]}]}`,
+		},
+		{
+			name:       "ドットを kind が any の CharacterSet として出力する",
+			inputS:     "𠮟.",
+			wantOutput: `{"type":"Pattern","parent":null,"start":0,"end":3,"raw":"𠮟.","alternatives":[{"type":"Alternative","parent":"♻️../..","start":0,"end":3,"raw":"𠮟.","elements":[{"type":"Character","parent":"♻️../..","start":0,"end":2,"raw":"𠮟","value":134047},{"type":"CharacterSet","parent":"♻️../..","start":2,"end":3,"raw":".","kind":"any"}]}]}`,
+		},
+		{
+			name:       "CharacterClassRange の min と max を出力する",
+			inputS:     "[^a-b]",
+			wantOutput: `{"type":"Pattern","parent":null,"start":0,"end":6,"raw":"[^a-b]","alternatives":[{"type":"Alternative","parent":"♻️../..","start":0,"end":6,"raw":"[^a-b]","elements":[{"type":"CharacterClass","parent":"♻️../..","start":0,"end":6,"raw":"[^a-b]","negate":true,"elements":[{"type":"CharacterClassRange","parent":"♻️../..","start":2,"end":5,"raw":"a-b","min":{"type":"Character","parent":"♻️..","start":2,"end":3,"raw":"a","value":97},"max":{"type":"Character","parent":"♻️..","start":4,"end":5,"raw":"b","value":98}}]}]}]}`,
+		},
+		{
+			name:       "CapturingGroup の references と Backreference の resolved を相対パスとして出力する",
+			inputS:     `\1(?:(a))`,
+			wantOutput: `{"type":"Pattern","parent":null,"start":0,"end":9,"raw":"\\1(?:(a))","alternatives":[{"type":"Alternative","parent":"♻️../..","start":0,"end":9,"raw":"\\1(?:(a))","elements":[{"type":"Backreference","parent":"♻️../..","start":0,"end":2,"raw":"\\1","ref":1,"resolved":"♻️../1/alternatives/0/elements/0"},{"type":"Group","parent":"♻️../..","start":2,"end":9,"raw":"(?:(a))","alternatives":[{"type":"Alternative","parent":"♻️../..","start":5,"end":8,"raw":"(a)","elements":[{"type":"CapturingGroup","parent":"♻️../..","start":5,"end":8,"raw":"(a)","name":null,"alternatives":[{"type":"Alternative","parent":"♻️../..","start":6,"end":7,"raw":"a","elements":[{"type":"Character","parent":"♻️../..","start":6,"end":7,"raw":"a","value":97}]}],"references":["♻️../../../../../0"]}]}]}]}]}`,
+		},
+		{
+			name:       "Assertion の kind と CharacterSet の kind, key, value, negate を出力する",
+			inputS:     `^(?<!\b)\d\P{L}$`,
+			wantOutput: `{"type":"Pattern","parent":null,"start":0,"end":16,"raw":"^(?<!\\b)\\d\\P{L}$","alternatives":[{"type":"Alternative","parent":"♻️../..","start":0,"end":16,"raw":"^(?<!\\b)\\d\\P{L}$","elements":[{"type":"Assertion","parent":"♻️../..","start":0,"end":1,"raw":"^","kind":"start"},{"type":"Assertion","parent":"♻️../..","start":1,"end":8,"raw":"(?<!\\b)","kind":"lookbehind","negate":true,"alternatives":[{"type":"Alternative","parent":"♻️../..","start":5,"end":7,"raw":"\\b","elements":[{"type":"Assertion","parent":"♻️../..","start":5,"end":7,"raw":"\\b","kind":"word","negate":false}]}]},{"type":"CharacterSet","parent":"♻️../..","start":8,"end":10,"raw":"\\d","kind":"digit","negate":false},{"type":"CharacterSet","parent":"♻️../..","start":10,"end":15,"raw":"\\P{L}","kind":"property","key":"General_Category","value":"L","negate":true},{"type":"Assertion","parent":"♻️../..","start":15,"end":16,"raw":"$","kind":"end"}]}]}`,
+		},
+		{
+			name:       "名前だけのプロパティの value を null として出力する",
+			inputS:     `\p{Alphabetic}`,
+			wantOutput: `{"type":"Pattern","parent":null,"start":0,"end":14,"raw":"\\p{Alphabetic}","alternatives":[{"type":"Alternative","parent":"♻️../..","start":0,"end":14,"raw":"\\p{Alphabetic}","elements":[{"type":"CharacterSet","parent":"♻️../..","start":0,"end":14,"raw":"\\p{Alphabetic}","kind":"property","key":"Alphabetic","value":null,"negate":false}]}]}`,
+		},
+		{
+			name:       "v フラグのクラスの unicodeSets, left, right, alternatives, strings を出力する",
+			inputS:     `[\q{a|}--[\p{RGI_Emoji}]]`,
+			inputV:     true,
+			wantOutput: `{"type":"Pattern","parent":null,"start":0,"end":25,"raw":"[\\q{a|}--[\\p{RGI_Emoji}]]","alternatives":[{"type":"Alternative","parent":"♻️../..","start":0,"end":25,"raw":"[\\q{a|}--[\\p{RGI_Emoji}]]","elements":[{"type":"ExpressionCharacterClass","parent":"♻️../..","start":0,"end":25,"raw":"[\\q{a|}--[\\p{RGI_Emoji}]]","negate":false,"expression":{"type":"ClassSubtraction","parent":"♻️..","start":1,"end":24,"raw":"\\q{a|}--[\\p{RGI_Emoji}]","left":{"type":"ClassStringDisjunction","parent":"♻️..","start":1,"end":7,"raw":"\\q{a|}","alternatives":[{"type":"StringAlternative","parent":"♻️../..","start":4,"end":5,"raw":"a","elements":[{"type":"Character","parent":"♻️../..","start":4,"end":5,"raw":"a","value":97}]},{"type":"StringAlternative","parent":"♻️../..","start":6,"end":6,"raw":"","elements":[]}]},"right":{"type":"CharacterClass","parent":"♻️..","start":9,"end":24,"raw":"[\\p{RGI_Emoji}]","unicodeSets":true,"negate":false,"elements":[{"type":"CharacterSet","parent":"♻️../..","start":10,"end":23,"raw":"\\p{RGI_Emoji}","kind":"property","strings":true,"key":"RGI_Emoji","value":null,"negate":false}]}}}]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParserWithOptions(tt.inputS, true, parser.Options{UnicodeSets: tt.inputV})
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ast_json.Marshal(pattern, tt.inputS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.wantOutput {
+				t.Errorf("Unexpected JSON\nexpected: %s\nactual:   %s", tt.wantOutput, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,487 @@
+package ast_json
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+// Unmarshal decodes the regexpp JSON produced by Marshal back into a tree.
+// Node types are chosen by the `type` field. Parent pointers are rebuilt from
+// the structure of the JSON, so the `parent` fields are ignored, and so is
+// `raw`. The `resolved` path of a Backreference is followed to link it to its
+// CapturingGroup, which also rebuilds the References of the group.
+func Unmarshal(data []byte) (regexp_ast.Node, error) {
+	u := &unmarshaler{nodes: map[string]regexp_ast.Node{}}
+	node, err := u.unmarshal(data, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := u.link(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// UnmarshalPattern is like Unmarshal but requires the root to be a Pattern.
+func UnmarshalPattern(data []byte) (*regexp_ast.Pattern, error) {
+	node, err := Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	pattern, ok := node.(*regexp_ast.Pattern)
+	if !ok {
+		return nil, fmt.Errorf("ast_json: expected Pattern, got %T", node)
+	}
+	return pattern, nil
+}
+
+type unmarshaler struct {
+	// The groups and the backreferences by their path from the root
+	nodes map[string]regexp_ast.Node
+	// The backreferences with their path and their `resolved`, linked after
+	// the whole tree is decoded since a group may come after them
+	backreferences []pendingBackreference
+}
+
+type pendingBackreference struct {
+	node     *regexp_ast.Backreference
+	path     string
+	resolved *string
+}
+
+// unmarshal decodes data, whose parent is parent and whose path from the root
+// is path, e.g. "alternatives/0/elements/1".
+func (u *unmarshaler) unmarshal(data []byte, parent regexp_ast.Node, path string) (regexp_ast.Node, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	switch header.Type {
+	case "Pattern":
+		var v patternJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.Pattern{
+			Loc: regexp_ast.Loc{Start: v.Start, End: v.End},
+		}
+		alts, err := u.unmarshalAlternatives(v.Alternatives, n, path)
+		if err != nil {
+			return nil, err
+		}
+		n.Alternatives = alts
+		return n, nil
+	case "Alternative":
+		var v alternativeJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.Alternative{
+			Parent:   parent,
+			Loc:      regexp_ast.Loc{Start: v.Start, End: v.End},
+			Elements: []regexp_ast.Element{},
+		}
+		for i, raw := range v.Elements {
+			child, err := u.unmarshal(raw, n, joinPath(path, "elements", strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			el, ok := child.(regexp_ast.Element)
+			if !ok {
+				return nil, fmt.Errorf("ast_json: %T can't be an element of Alternative", child)
+			}
+			n.Elements = append(n.Elements, el)
+		}
+		return n, nil
+	case "Group":
+		var v groupJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.Group{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+		}
+		alts, err := u.unmarshalAlternatives(v.Alternatives, n, path)
+		if err != nil {
+			return nil, err
+		}
+		n.Alternatives = alts
+		return n, nil
+	case "CapturingGroup":
+		var v capturingGroupJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.CapturingGroup{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+		}
+		if v.Name != nil {
+			n.Name = *v.Name
+		}
+		alts, err := u.unmarshalAlternatives(v.Alternatives, n, path)
+		if err != nil {
+			return nil, err
+		}
+		n.Alternatives = alts
+		u.nodes[path] = n
+		return n, nil
+	case "Assertion":
+		var v lookaroundAssertionJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		loc := regexp_ast.Loc{Start: v.Start, End: v.End}
+		switch v.Kind {
+		case "lookahead", "lookbehind":
+			n := &regexp_ast.LookaroundAssertion{
+				Parent: parent,
+				Loc:    loc,
+				Kind:   regexp_ast.LookaroundKind(v.Kind),
+				Negate: v.Negate,
+			}
+			alts, err := u.unmarshalAlternatives(v.Alternatives, n, path)
+			if err != nil {
+				return nil, err
+			}
+			n.Alternatives = alts
+			return n, nil
+		case "start", "end":
+			return &regexp_ast.EdgeAssertion{Parent: parent, Loc: loc, Kind: regexp_ast.EdgeKind(v.Kind)}, nil
+		case "word":
+			return &regexp_ast.WordBoundaryAssertion{Parent: parent, Loc: loc, Negate: v.Negate}, nil
+		}
+		return nil, fmt.Errorf("ast_json: unknown Assertion kind %q", v.Kind)
+	case "Backreference":
+		var v backreferenceJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.Backreference{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+		}
+		switch ref := v.Ref.(type) {
+		case float64:
+			n.Number = int(ref)
+		case string:
+			n.Name = ref
+		default:
+			return nil, fmt.Errorf("ast_json: invalid Backreference ref %v", v.Ref)
+		}
+		u.backreferences = append(u.backreferences, pendingBackreference{n, path, v.Resolved})
+		return n, nil
+	case "Character":
+		var v characterJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return &regexp_ast.Character{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+			Value:  v.Value,
+		}, nil
+	case "CharacterClass":
+		var v characterClassJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.CharacterClass{
+			Parent:      parent,
+			Loc:         regexp_ast.Loc{Start: v.Start, End: v.End},
+			Negate:      v.Negate,
+			UnicodeSets: v.UnicodeSets,
+			Elements:    []regexp_ast.CharacterClassElement{},
+		}
+		for i, raw := range v.Elements {
+			child, err := u.unmarshal(raw, n, joinPath(path, "elements", strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			el, ok := child.(regexp_ast.CharacterClassElement)
+			if !ok {
+				return nil, fmt.Errorf("ast_json: %T can't be an element of CharacterClass", child)
+			}
+			n.Elements = append(n.Elements, el)
+		}
+		return n, nil
+	case "CharacterSet":
+		// The JSON of a property has every field of the other kinds
+		var v unicodePropertyCharacterSetJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		loc := regexp_ast.Loc{Start: v.Start, End: v.End}
+		switch v.Kind {
+		case "any":
+			return &regexp_ast.AnyCharacterSet{Parent: parent, Loc: loc}, nil
+		case "digit", "space", "word":
+			return &regexp_ast.EscapeCharacterSet{Parent: parent, Loc: loc, Kind: regexp_ast.EscapeKind(v.Kind), Negate: v.Negate}, nil
+		case "property":
+			n := &regexp_ast.UnicodePropertyCharacterSet{
+				Parent:  parent,
+				Loc:     loc,
+				Key:     v.Key,
+				Negate:  v.Negate,
+				Strings: v.Strings,
+			}
+			if v.Value != nil {
+				n.Value = *v.Value
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("ast_json: unsupported CharacterSet kind %q", v.Kind)
+	case "Quantifier":
+		var v quantifierJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		max, err := decodeMax(v.Max)
+		if err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.Quantifier{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+			Min:    v.Min,
+			Max:    max,
+			Greety: v.Greedy,
+		}
+		child, err := u.unmarshal(v.Element, n, joinPath(path, "element"))
+		if err != nil {
+			return nil, err
+		}
+		el, ok := child.(regexp_ast.QuantifiableElement)
+		if !ok {
+			return nil, fmt.Errorf("ast_json: %T can't be quantified", child)
+		}
+		n.Element = el
+		return n, nil
+	case "CharacterClassRange":
+		var v characterClassRangeJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.CharacterClassRange{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+		}
+		min, err := u.unmarshal(v.Min, n, joinPath(path, "min"))
+		if err != nil {
+			return nil, err
+		}
+		max, err := u.unmarshal(v.Max, n, joinPath(path, "max"))
+		if err != nil {
+			return nil, err
+		}
+		minChar, ok1 := min.(*regexp_ast.Character)
+		maxChar, ok2 := max.(*regexp_ast.Character)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("ast_json: the min and max of CharacterClassRange must be Character")
+		}
+		n.Min = minChar
+		n.Max = maxChar
+		return n, nil
+	case "ExpressionCharacterClass":
+		var v expressionCharacterClassJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.ExpressionCharacterClass{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+			Negate: v.Negate,
+		}
+		child, err := u.unmarshal(v.Expression, n, joinPath(path, "expression"))
+		if err != nil {
+			return nil, err
+		}
+		expression, ok := child.(regexp_ast.ClassSetExpression)
+		if !ok {
+			return nil, fmt.Errorf("ast_json: %T can't be the expression of ExpressionCharacterClass", child)
+		}
+		n.Expression = expression
+		return n, nil
+	case "ClassIntersection":
+		var v classSetOperationJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.ClassIntersection{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+		}
+		left, right, err := u.unmarshalOperands(v, n, path)
+		if err != nil {
+			return nil, err
+		}
+		n.Left, n.Right = left, right
+		return n, nil
+	case "ClassSubtraction":
+		var v classSetOperationJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.ClassSubtraction{
+			Parent: parent,
+			Loc:    regexp_ast.Loc{Start: v.Start, End: v.End},
+		}
+		left, right, err := u.unmarshalOperands(v, n, path)
+		if err != nil {
+			return nil, err
+		}
+		n.Left, n.Right = left, right
+		return n, nil
+	case "ClassStringDisjunction":
+		var v classStringDisjunctionJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.ClassStringDisjunction{
+			Parent:       parent,
+			Loc:          regexp_ast.Loc{Start: v.Start, End: v.End},
+			Alternatives: []*regexp_ast.StringAlternative{},
+		}
+		for i, raw := range v.Alternatives {
+			child, err := u.unmarshal(raw, n, joinPath(path, "alternatives", strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			alt, ok := child.(*regexp_ast.StringAlternative)
+			if !ok {
+				return nil, fmt.Errorf("ast_json: %T can't be an alternative of ClassStringDisjunction", child)
+			}
+			n.Alternatives = append(n.Alternatives, alt)
+		}
+		return n, nil
+	case "StringAlternative":
+		var v alternativeJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		n := &regexp_ast.StringAlternative{
+			Parent:   parent,
+			Loc:      regexp_ast.Loc{Start: v.Start, End: v.End},
+			Elements: []*regexp_ast.Character{},
+		}
+		for i, raw := range v.Elements {
+			child, err := u.unmarshal(raw, n, joinPath(path, "elements", strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			c, ok := child.(*regexp_ast.Character)
+			if !ok {
+				return nil, fmt.Errorf("ast_json: %T can't be an element of StringAlternative", child)
+			}
+			n.Elements = append(n.Elements, c)
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("ast_json: unknown node type %q", header.Type)
+	}
+}
+
+func (u *unmarshaler) unmarshalAlternatives(raws []json.RawMessage, parent regexp_ast.Node, path string) ([]*regexp_ast.Alternative, error) {
+	alts := []*regexp_ast.Alternative{}
+	for i, raw := range raws {
+		child, err := u.unmarshal(raw, parent, joinPath(path, "alternatives", strconv.Itoa(i)))
+		if err != nil {
+			return nil, err
+		}
+		alt, ok := child.(*regexp_ast.Alternative)
+		if !ok {
+			return nil, fmt.Errorf("ast_json: %T can't be an alternative of %T", child, parent)
+		}
+		alts = append(alts, alt)
+	}
+	return alts, nil
+}
+
+func (u *unmarshaler) unmarshalOperands(v classSetOperationJSON, parent regexp_ast.Node, path string) (regexp_ast.ClassSetOperand, regexp_ast.ClassSetOperand, error) {
+	var operands [2]regexp_ast.ClassSetOperand
+	keys := [2]string{"left", "right"}
+	for i, raw := range []json.RawMessage{v.Left, v.Right} {
+		child, err := u.unmarshal(raw, parent, joinPath(path, keys[i]))
+		if err != nil {
+			return nil, nil, err
+		}
+		operand, ok := child.(regexp_ast.ClassSetOperand)
+		if !ok {
+			return nil, nil, fmt.Errorf("ast_json: %T can't be an operand of %s", child, v.Type)
+		}
+		operands[i] = operand
+	}
+	return operands[0], operands[1], nil
+}
+
+// link resolves the backreferences in the order they appear, so that the
+// References of each group are in the same order as the parser's.
+func (u *unmarshaler) link() error {
+	for _, b := range u.backreferences {
+		if b.resolved == nil {
+			continue
+		}
+		target, ok := resolvePath(b.path, *b.resolved)
+		if !ok {
+			return fmt.Errorf("ast_json: invalid Backreference resolved %q", *b.resolved)
+		}
+		group, ok := u.nodes[target].(*regexp_ast.CapturingGroup)
+		if !ok {
+			return fmt.Errorf("ast_json: Backreference resolved %q is not a CapturingGroup", *b.resolved)
+		}
+		b.node.Resolved = group
+		group.References = append(group.References, b.node)
+	}
+	return nil
+}
+
+// resolvePath returns the path that the relative path ref written at from
+// points to.
+func resolvePath(from, ref string) (string, bool) {
+	if !strings.HasPrefix(ref, parentPrefix) {
+		return "", false
+	}
+	parts := strings.Split(from, "/")
+	if from == "" {
+		parts = nil
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(ref, parentPrefix), "/") {
+		if part != ".." {
+			parts = append(parts, part)
+			continue
+		}
+		if len(parts) == 0 {
+			return "", false
+		}
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, "/"), true
+}
+
+func joinPath(path string, parts ...string) string {
+	if path == "" {
+		return strings.Join(parts, "/")
+	}
+	return path + "/" + strings.Join(parts, "/")
+}
+
+func decodeMax(v interface{}) (int, error) {
+	switch max := v.(type) {
+	case float64:
+		return int(max), nil
+	case string:
+		if max == infinity {
+			return math.MaxInt, nil
+		}
+	}
+	return 0, fmt.Errorf("ast_json: invalid Quantifier max %v", v)
+}
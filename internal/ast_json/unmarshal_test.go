@@ -0,0 +1,69 @@
+package ast_json_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/ast_json"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+)
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputS string
+		inputU bool
+		inputV bool
+	}{
+		{name: "Alternative", inputS: "a|bc", inputU: true},
+		{name: "Quantifier", inputS: "a{11,}.+?b{0,20}?c{5}?", inputU: true},
+		{name: "CharacterClass", inputS: "[A-Za-z0-9_-]|[^a]", inputU: true},
+		{name: "非ユニコードモードのサロゲートペア", inputS: "あい𠮟", inputU: false},
+		{name: "Group と Backreference", inputS: `\k<a>(?:(?<a>x)|(y)\2)*\1`, inputU: true},
+		{name: "Assertion", inputS: `^(?=a)(?<!b)\b\B$`, inputU: true},
+		{name: "CharacterSet", inputS: `[\d\S]\w\p{L}\P{Script=Hira}\p{ASCII}`, inputU: true},
+		{name: "v フラグのクラス", inputS: `[\q{ab|}--[\p{RGI_Emoji}a]][^[a-z]&&\w]`, inputV: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParserWithOptions(tt.inputS, tt.inputU, parser.Options{UnicodeSets: tt.inputV})
+			want, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ast_json.Marshal(want, tt.inputS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ast_json.UnmarshalPattern(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// DeepEqual follows the Parent pointers too
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Round trip of %q returned a different AST", tt.inputS)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "未知の type", input: `{"type":"Flags"}`},
+		{name: "CapturingGroup ではない resolved", input: `{"type":"Alternative","start":0,"end":3,"elements":[{"type":"Character","start":0,"end":1,"value":97},{"type":"Backreference","start":1,"end":3,"ref":1,"resolved":"♻️../0"}]}`},
+		{name: "Pattern 直下の Character", input: `{"type":"Pattern","start":0,"end":1,"alternatives":[{"type":"Character","start":0,"end":1,"value":97}]}`},
+		{name: "不正な max", input: `{"type":"Quantifier","min":0,"max":"Infinity","greedy":true,"element":{"type":"Character","value":97}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ast_json.Unmarshal([]byte(tt.input)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package batch parses many patterns concurrently.
+package batch
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/cache"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+type Input struct {
+	Source string
+	U      bool
+}
+
+type Result struct {
+	Pattern *regexp_ast.Pattern
+	Err     error
+}
+
+type Options struct {
+	// The number of goroutines parsing patterns. Defaults to GOMAXPROCS.
+	Workers int
+
+	// Passed to every parser. Arena is ignored since an Arena can't be
+	// shared between goroutines.
+	Parser parser.Options
+
+	// If set, results are looked up in and stored to the cache.
+	Cache *cache.Cache
+}
+
+type Stats struct {
+	// The number of inputs that were parsed before returning
+	Count int
+	// The number of parsed inputs that had errors
+	Failures int
+	// Wall-clock time of the whole batch
+	Duration time.Duration
+}
+
+// ParseAll parses every input and returns the results in input order. If ctx
+// is canceled, the inputs that were not parsed yet get ctx.Err() as their
+// error and ParseAll returns ctx.Err() too. If ctx is canceled only after
+// every input was parsed, ParseAll returns no error.
+func ParseAll(ctx context.Context, inputs []Input, opts Options) ([]Result, Stats, error) {
+	start := time.Now()
+	results := make([]Result, len(inputs))
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+	parserOpts := opts.Parser
+	parserOpts.Arena = nil
+
+	// The index of the next input to parse
+	var next int64 = -1
+	var count, failures, canceled int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := parser.NewParserWithOptions("", false, parserOpts)
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(inputs) {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					atomic.AddInt64(&canceled, 1)
+					continue
+				}
+				in := inputs[i]
+				var pattern *regexp_ast.Pattern
+				var err error
+				if opts.Cache != nil {
+					pattern, err = opts.Cache.Parse(in.Source, in.U, parserOpts)
+				} else {
+					p.Reset(in.Source, in.U, parserOpts)
+					pattern, err = p.ParsePattern()
+				}
+				results[i] = Result{Pattern: pattern, Err: err}
+				atomic.AddInt64(&count, 1)
+				if err != nil {
+					atomic.AddInt64(&failures, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := Stats{
+		Count:    int(count),
+		Failures: int(failures),
+		Duration: time.Since(start),
+	}
+	if canceled > 0 {
+		return results, stats, ctx.Err()
+	}
+	return results, stats, nil
+}
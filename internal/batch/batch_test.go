@@ -0,0 +1,106 @@
+package batch_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/batch"
+	"github.com/sosukesuzuki/regexpp-go/internal/cache"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+)
+
+func TestParseAll(t *testing.T) {
+	var inputs []batch.Input
+	for i := 0; i < 100; i++ {
+		inputs = append(inputs, batch.Input{Source: fmt.Sprintf("a{%d}|[b-c]", i), U: i%2 == 0})
+	}
+	inputs = append(inputs, batch.Input{Source: "[a", U: true})
+
+	for _, opts := range []batch.Options{
+		{Workers: 1},
+		{Workers: 4},
+		{Workers: 4, Cache: cache.New(16)},
+	} {
+		results, stats, err := batch.ParseAll(context.Background(), inputs, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Count != len(inputs) || stats.Failures != 1 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+		for i, in := range inputs {
+			p := parser.NewParser(in.Source, in.U)
+			want, wantErr := p.ParsePattern()
+			if !reflect.DeepEqual(results[i].Pattern, want) || (results[i].Err == nil) != (wantErr == nil) {
+				t.Errorf("Unexpected result for %q", in.Source)
+			}
+		}
+	}
+}
+
+func TestParseAllCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	inputs := []batch.Input{{Source: "a"}, {Source: "b"}}
+	results, stats, err := batch.ParseAll(ctx, inputs, batch.Options{Workers: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if stats.Count != 0 {
+		t.Errorf("Unexpected count: %d", stats.Count)
+	}
+	for _, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("Unexpected result error: %v", r.Err)
+		}
+	}
+}
+
+// lateContext is canceled after its Err has been called n times.
+type lateContext struct {
+	context.Context
+	n int64
+}
+
+func (c *lateContext) Err() error {
+	if atomic.AddInt64(&c.n, -1) < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestParseAllCanceledAfterEveryInput(t *testing.T) {
+	inputs := []batch.Input{{Source: "a"}, {Source: "b"}}
+	ctx := &lateContext{Context: context.Background(), n: int64(len(inputs))}
+	results, stats, err := batch.ParseAll(ctx, inputs, batch.Options{Workers: 1})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if stats.Count != len(inputs) {
+		t.Errorf("Unexpected count: %d", stats.Count)
+	}
+	for _, r := range results {
+		if r.Err != nil || r.Pattern == nil {
+			t.Errorf("Unexpected result: %+v", r)
+		}
+	}
+}
+
+func BenchmarkParseAll(b *testing.B) {
+	var inputs []batch.Input
+	for i := 0; i < 10_000; i++ {
+		inputs = append(inputs, batch.Input{Source: fmt.Sprintf("word%d[a-z0-9]+|x{1,%d}?", i, i), U: true})
+	}
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				batch.ParseAll(context.Background(), inputs, batch.Options{Workers: workers})
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package builder constructs regexp_ast trees programmatically, e.g.
+//
+//	b := builder.New(true)
+//	pattern := b.Pattern(b.Alt(b.Char('a'), b.Star(b.Class(b.Range('0', '9')))))
+//
+// Every constructor sets the Parent of the nodes it is given, and Pattern
+// gives every node the Loc it would have if the printed pattern were parsed
+// and links the backreferences to their groups. A node must be passed to at
+// most one constructor.
+package builder
+
+import (
+	"math"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+type Builder struct {
+	u bool
+}
+
+// New returns a builder for patterns in unicode mode if u is true.
+func New(u bool) *Builder {
+	return &Builder{u: u}
+}
+
+// Pattern returns a pattern of the alternatives, lays out the Locs of the
+// whole tree and resolves the backreferences. A backreference to a group that
+// doesn't exist is left unresolved.
+func (b *Builder) Pattern(alts ...*regexp_ast.Alternative) *regexp_ast.Pattern {
+	n := &regexp_ast.Pattern{}
+	n.Alternatives = b.alternatives(n, alts)
+	printer.Layout(n, b.u)
+	resolve(n)
+	return n
+}
+
+// resolve links the backreferences below pattern to their groups, numbering
+// the groups in the order of their `(`, like the parser does.
+func resolve(pattern *regexp_ast.Pattern) {
+	var groups []*regexp_ast.CapturingGroup
+	var refs []*regexp_ast.Backreference
+	regexp_ast.Inspect(pattern, func(node regexp_ast.Node) bool {
+		switch n := node.(type) {
+		case *regexp_ast.CapturingGroup:
+			groups = append(groups, n)
+		case *regexp_ast.Backreference:
+			refs = append(refs, n)
+		}
+		return true
+	})
+	for _, ref := range refs {
+		for i, g := range groups {
+			if (ref.Name != "" && ref.Name == g.Name) || (ref.Name == "" && ref.Number == i+1) {
+				ref.Resolved = g
+				g.References = append(g.References, ref)
+				break
+			}
+		}
+	}
+}
+
+// Alt returns a sequence of elements.
+func (b *Builder) Alt(elements ...regexp_ast.Element) *regexp_ast.Alternative {
+	n := &regexp_ast.Alternative{Elements: []regexp_ast.Element{}}
+	for _, el := range elements {
+		el.(regexp_ast.Node).SetParent(n)
+		n.Elements = append(n.Elements, el)
+	}
+	return n
+}
+
+func (b *Builder) Char(cp rune) *regexp_ast.Character {
+	return &regexp_ast.Character{Value: int(cp)}
+}
+
+// Chars returns one Character per code point of s.
+func (b *Builder) Chars(s string) []regexp_ast.Element {
+	var elements []regexp_ast.Element
+	for _, r := range s {
+		elements = append(elements, b.Char(r))
+	}
+	return elements
+}
+
+// Any returns `.`.
+func (b *Builder) Any() *regexp_ast.AnyCharacterSet {
+	return &regexp_ast.AnyCharacterSet{}
+}
+
+// Escape returns `\d`, `\s` or `\w`, or `\D`, `\S` or `\W` if negate is true.
+func (b *Builder) Escape(kind regexp_ast.EscapeKind, negate bool) *regexp_ast.EscapeCharacterSet {
+	return &regexp_ast.EscapeCharacterSet{Kind: kind, Negate: negate}
+}
+
+// Property returns `\p{key=value}`, or `\p{key}` if value is empty, or `\P`
+// if negate is true. It needs unicode mode.
+func (b *Builder) Property(key string, value string, negate bool) *regexp_ast.UnicodePropertyCharacterSet {
+	return &regexp_ast.UnicodePropertyCharacterSet{Key: key, Value: value, Negate: negate}
+}
+
+// Group returns `(?:...)`.
+func (b *Builder) Group(alts ...*regexp_ast.Alternative) *regexp_ast.Group {
+	n := &regexp_ast.Group{}
+	n.Alternatives = b.alternatives(n, alts)
+	return n
+}
+
+// Capture returns `(...)`.
+func (b *Builder) Capture(alts ...*regexp_ast.Alternative) *regexp_ast.CapturingGroup {
+	return b.NamedCapture("", alts...)
+}
+
+// NamedCapture returns `(?<name>...)`.
+func (b *Builder) NamedCapture(name string, alts ...*regexp_ast.Alternative) *regexp_ast.CapturingGroup {
+	n := &regexp_ast.CapturingGroup{Name: name}
+	n.Alternatives = b.alternatives(n, alts)
+	return n
+}
+
+// Lookahead returns `(?=...)`, or `(?!...)` if negate is true.
+func (b *Builder) Lookahead(negate bool, alts ...*regexp_ast.Alternative) *regexp_ast.LookaroundAssertion {
+	n := &regexp_ast.LookaroundAssertion{Kind: regexp_ast.Lookahead, Negate: negate}
+	n.Alternatives = b.alternatives(n, alts)
+	return n
+}
+
+// Lookbehind returns `(?<=...)`, or `(?<!...)` if negate is true.
+func (b *Builder) Lookbehind(negate bool, alts ...*regexp_ast.Alternative) *regexp_ast.LookaroundAssertion {
+	n := &regexp_ast.LookaroundAssertion{Kind: regexp_ast.Lookbehind, Negate: negate}
+	n.Alternatives = b.alternatives(n, alts)
+	return n
+}
+
+// Start returns `^`.
+func (b *Builder) Start() *regexp_ast.EdgeAssertion {
+	return &regexp_ast.EdgeAssertion{Kind: regexp_ast.EdgeStart}
+}
+
+// End returns `$`.
+func (b *Builder) End() *regexp_ast.EdgeAssertion {
+	return &regexp_ast.EdgeAssertion{Kind: regexp_ast.EdgeEnd}
+}
+
+// WordBoundary returns `\b`, or `\B` if negate is true.
+func (b *Builder) WordBoundary(negate bool) *regexp_ast.WordBoundaryAssertion {
+	return &regexp_ast.WordBoundaryAssertion{Negate: negate}
+}
+
+// Backref returns `\number`.
+func (b *Builder) Backref(number int) *regexp_ast.Backreference {
+	return &regexp_ast.Backreference{Number: number}
+}
+
+// NamedBackref returns `\k<name>`.
+func (b *Builder) NamedBackref(name string) *regexp_ast.Backreference {
+	return &regexp_ast.Backreference{Name: name}
+}
+
+// alternatives sets the Parent of alts to parent, adding an empty
+// alternative if there is none.
+func (b *Builder) alternatives(parent regexp_ast.Node, alts []*regexp_ast.Alternative) []*regexp_ast.Alternative {
+	out := []*regexp_ast.Alternative{}
+	for _, alt := range alts {
+		alt.Parent = parent
+		out = append(out, alt)
+	}
+	if len(out) == 0 {
+		out = append(out, &regexp_ast.Alternative{Parent: parent, Elements: []regexp_ast.Element{}})
+	}
+	return out
+}
+
+// Class returns `[...]`.
+func (b *Builder) Class(elements ...regexp_ast.CharacterClassElement) *regexp_ast.CharacterClass {
+	n := &regexp_ast.CharacterClass{Elements: []regexp_ast.CharacterClassElement{}}
+	for _, el := range elements {
+		el.(regexp_ast.Node).SetParent(n)
+		n.Elements = append(n.Elements, el)
+	}
+	return n
+}
+
+// NegatedClass returns `[^...]`.
+func (b *Builder) NegatedClass(elements ...regexp_ast.CharacterClassElement) *regexp_ast.CharacterClass {
+	n := b.Class(elements...)
+	n.Negate = true
+	return n
+}
+
+// Range returns `min-max` to be used in a class.
+func (b *Builder) Range(min rune, max rune) *regexp_ast.CharacterClassRange {
+	n := &regexp_ast.CharacterClassRange{}
+	n.Min = &regexp_ast.Character{Parent: n, Value: int(min)}
+	n.Max = &regexp_ast.Character{Parent: n, Value: int(max)}
+	return n
+}
+
+// Star returns `el*`.
+func (b *Builder) Star(el regexp_ast.QuantifiableElement) *regexp_ast.Quantifier {
+	return b.Repeat(el, 0, math.MaxInt)
+}
+
+// Plus returns `el+`.
+func (b *Builder) Plus(el regexp_ast.QuantifiableElement) *regexp_ast.Quantifier {
+	return b.Repeat(el, 1, math.MaxInt)
+}
+
+// Optional returns `el?`.
+func (b *Builder) Optional(el regexp_ast.QuantifiableElement) *regexp_ast.Quantifier {
+	return b.Repeat(el, 0, 1)
+}
+
+// Repeat returns `el{min,max}`. Use math.MaxInt as max for `el{min,}`.
+func (b *Builder) Repeat(el regexp_ast.QuantifiableElement, min int, max int) *regexp_ast.Quantifier {
+	n := &regexp_ast.Quantifier{Min: min, Max: max, Greety: true, Element: el}
+	el.(regexp_ast.Node).SetParent(n)
+	return n
+}
+
+// Lazy makes q non-greedy and returns it.
+func (b *Builder) Lazy(q *regexp_ast.Quantifier) *regexp_ast.Quantifier {
+	q.Greety = false
+	return q
+}
@@ -0,0 +1,93 @@
+package builder_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/builder"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputU     bool
+		inputBuild func(b *builder.Builder) *regexp_ast.Pattern
+		wantOutput string
+	}{
+		{
+			name:   "文字と量指定子と文字クラス",
+			inputU: true,
+			inputBuild: func(b *builder.Builder) *regexp_ast.Pattern {
+				return b.Pattern(b.Alt(b.Char('a'), b.Star(b.Class(b.Range('0', '9')))))
+			},
+			wantOutput: "a[0-9]*",
+		},
+		{
+			name:   "選択と否定の文字クラスと非貪欲な量指定子",
+			inputU: true,
+			inputBuild: func(b *builder.Builder) *regexp_ast.Pattern {
+				return b.Pattern(
+					b.Alt(b.Chars("a.b")...),
+					b.Alt(b.Lazy(b.Repeat(b.Any(), 2, 5)), b.NegatedClass(b.Char('-'), b.Char(']'))),
+				)
+			},
+			wantOutput: `a\.b|.{2,5}?[^-\]]`,
+		},
+		{
+			name:   "サロゲートペアの文字",
+			inputU: true,
+			inputBuild: func(b *builder.Builder) *regexp_ast.Pattern {
+				return b.Pattern(b.Alt(b.Char('𠮟'), b.Optional(b.Char('x'))))
+			},
+			wantOutput: "𠮟x?",
+		},
+		{
+			name:   "グループとアサーションと後方参照",
+			inputU: true,
+			inputBuild: func(b *builder.Builder) *regexp_ast.Pattern {
+				return b.Pattern(b.Alt(
+					b.Start(),
+					b.NamedBackref("y"),
+					b.Capture(b.Alt(b.Escape(regexp_ast.EscapeDigit, false)), b.Alt()),
+					b.Star(b.Group(b.Alt(b.Backref(1), b.WordBoundary(true)))),
+					b.NamedCapture("y", b.Alt(b.Property("Script", "Hira", true))),
+					b.Lookahead(true, b.Alt(b.Char('a'))),
+					b.Lookbehind(false),
+					b.End(),
+				))
+			},
+			wantOutput: `^\k<y>(\d|)(?:\1\B)*(?<y>\P{Script=Hira})(?!a)(?<=)$`,
+		},
+		{
+			name:   "空のパターン",
+			inputU: false,
+			inputBuild: func(b *builder.Builder) *regexp_ast.Pattern {
+				return b.Pattern()
+			},
+			wantOutput: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := tt.inputBuild(builder.New(tt.inputU))
+			o := printer.Print(pattern, tt.inputU)
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+
+			// 親と位置を含めて、パースした結果と同じ木になる
+			p := parser.NewParser(o, tt.inputU)
+			want, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pattern, want) {
+				t.Errorf("Built AST differs from the parsed one")
+			}
+		})
+	}
+}
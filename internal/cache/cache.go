@@ -0,0 +1,144 @@
+// Package cache memoizes parse results for patterns that are parsed over and
+// over, e.g. user-supplied patterns in a long-running service.
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+// Cache is a bounded LRU cache of parse results, safe for concurrent use.
+//
+// The same *regexp_ast.Pattern is returned to every caller asking for the
+// same pattern, so returned trees are shared and MUST NOT be mutated. Clone a
+// tree before editing it.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[key]*list.Element
+	// Front is the most recently used
+	lru *list.List
+	// Counters, guarded by mu
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Len       int
+}
+
+type key struct {
+	source       string
+	u            bool
+	unicodeSets  bool
+	unit         offset.Unit
+	validateOnly bool
+	// The host is keyed by identity, so that a lookup doesn't read the
+	// whole host text
+	host     *parser.HostSource
+	hostBase int
+}
+
+type entry struct {
+	key     key
+	pattern *regexp_ast.Pattern
+	err     error
+}
+
+// New returns a cache holding at most capacity results.
+func New(capacity int) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache{
+		capacity: capacity,
+		entries:  make(map[key]*list.Element, capacity),
+		lru:      list.New(),
+	}
+}
+
+// Parse returns the cached result for the pattern, or parses it and caches
+// the result. opts.Arena is ignored, since arena memory is recycled and
+// cannot outlive the batch it belongs to.
+//
+// opts.Host is compared by pointer and BaseOffset, so reuse one HostSource
+// for the patterns of a host, and don't modify its Text or LineStarts after
+// passing it.
+func (c *Cache) Parse(source string, u bool, opts parser.Options) (*regexp_ast.Pattern, error) {
+	k := newKey(source, u, opts)
+
+	c.mu.Lock()
+	if el, ok := c.entries[k]; ok {
+		c.hits++
+		c.lru.MoveToFront(el)
+		e := el.Value.(*entry)
+		c.mu.Unlock()
+		return e.pattern, e.err
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// Parse without holding the lock so that misses don't serialize
+	opts.Arena = nil
+	p := parser.NewParserWithOptions(source, u, opts)
+	pattern, err := p.ParsePattern()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[k]; ok {
+		// Another goroutine parsed the same pattern meanwhile. Share its
+		// result so that all callers see the same tree.
+		e := el.Value.(*entry)
+		return e.pattern, e.err
+	}
+	c.entries[k] = c.lru.PushFront(&entry{key: k, pattern: pattern, err: err})
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+		c.evictions++
+	}
+	return pattern, err
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Len:       c.lru.Len(),
+	}
+}
+
+// Purge drops every cached result. The counters are kept.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[key]*list.Element, c.capacity)
+	c.lru.Init()
+}
+
+func newKey(source string, u bool, opts parser.Options) key {
+	k := key{
+		source:       source,
+		u:            u,
+		unicodeSets:  opts.UnicodeSets,
+		unit:         opts.OffsetUnit,
+		validateOnly: opts.ValidateOnly,
+	}
+	if host := opts.Host; host != nil {
+		k.host = host
+		k.hostBase = host.BaseOffset
+	}
+	return k
+}
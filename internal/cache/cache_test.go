@@ -0,0 +1,114 @@
+package cache_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/cache"
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+)
+
+func TestParse(t *testing.T) {
+	c := cache.New(2)
+
+	p1, err := c.Parse("a+", true, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, _ := c.Parse("a+", true, parser.Options{})
+	if p1 != p2 {
+		t.Error("Expected the cached Pattern to be returned")
+	}
+	p3, _ := c.Parse("a+", false, parser.Options{})
+	if p1 == p3 {
+		t.Error("Expected a different Pattern for different flags")
+	}
+	p4, _ := c.Parse("a+", true, parser.Options{OffsetUnit: offset.UTF8})
+	if p1 == p4 {
+		t.Error("Expected a different Pattern for different options")
+	}
+
+	s := c.Stats()
+	if s.Hits != 1 || s.Misses != 3 || s.Evictions != 1 || s.Len != 2 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+}
+
+func TestParseHost(t *testing.T) {
+	c := cache.New(4)
+	host := &parser.HostSource{Text: "x = /a+/\ny = /a+/"}
+
+	host.BaseOffset = 5
+	p1, err := c.Parse("a+", true, parser.Options{Host: host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p2, _ := c.Parse("a+", true, parser.Options{Host: host}); p1 != p2 {
+		t.Error("Expected the cached Pattern to be returned for the same host")
+	}
+	host.BaseOffset = 14
+	p3, _ := c.Parse("a+", true, parser.Options{Host: host})
+	if p1 == p3 {
+		t.Error("Expected a different Pattern for a different base offset")
+	}
+	if pos := p3.Loc.StartPos; pos == nil || pos.Line != 2 {
+		t.Errorf("Unexpected position of the second pattern: %+v", pos)
+	}
+	if p4, _ := c.Parse("a+", true, parser.Options{}); p4 == p1 || p4 == p3 {
+		t.Error("Expected a different Pattern without a host")
+	}
+}
+
+func TestParseUnicodeSets(t *testing.T) {
+	c := cache.New(4)
+	// Valid with u, but not with v
+	if _, err := c.Parse("[(]", true, parser.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Parse("[(]", true, parser.Options{UnicodeSets: true}); err == nil {
+		t.Error("Expected an error for the pattern parsed with the v flag")
+	}
+}
+
+func TestLRU(t *testing.T) {
+	c := cache.New(2)
+	a, _ := c.Parse("a", true, parser.Options{})
+	c.Parse("b", true, parser.Options{})
+	// `a` を使うことで `b` が最も古くなる
+	c.Parse("a", true, parser.Options{})
+	c.Parse("c", true, parser.Options{})
+
+	if a2, _ := c.Parse("a", true, parser.Options{}); a2 != a {
+		t.Error("Expected `a` to stay in the cache")
+	}
+	before := c.Stats().Misses
+	c.Parse("b", true, parser.Options{})
+	if c.Stats().Misses != before+1 {
+		t.Error("Expected `b` to be evicted")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	c := cache.New(8)
+	sources := []string{"a+", "[A-Z]|b", "a{2,3}?", "."}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := c.Parse(sources[j%len(sources)], true, parser.Options{}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s := c.Stats()
+	if s.Hits+s.Misses != 1600 || s.Len != len(sources) {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+}
@@ -0,0 +1,359 @@
+// Package case_expansion removes the `i` flag from patterns by writing the
+// case variants of every character out.
+//
+// Two characters are case variants if the ECMAScript Canonicalize maps them
+// to the same character: simple case folding with `u` or `v`, and
+// toUppercase without them, where a character is left alone if its uppercase
+// is several characters or if it is non-ASCII and its uppercase is ASCII.
+// So `/s/iu` matches U+017F (ſ) and `/k/iu` matches U+212A (Kelvin sign), but
+// `/s/i` and `/k/i` don't.
+//
+// A class matches a character if its Canonicalize is the Canonicalize of an
+// element, so a negated class excludes the variants of its elements as well:
+// `/[^a]/i` becomes `/[^aA]/`. An escape or a property gets the variants of
+// its characters the same way, except where `i` changes the characters
+// themselves: `\w` with `i` and `u` matches ſ and the Kelvin sign, so `\W`
+// doesn't, and `\b` sees them as word characters. Such a `\W` is written as
+// the ranges of its characters, and such a `\b` as lookarounds.
+//
+// With `v`, set operations are evaluated on case folded characters, so
+// expanding every operand gives the same result: `/[A&&a]/iv` becomes
+// `/[[Aa]&&[aA]]/v`. A class that matches strings becomes an alternation
+// that tries its strings longest first, each character a class of its
+// variants, then its characters: `/[\q{ab}c]/iv` becomes
+// `/(?:[aA][bB]|[cC])/v`. A `\q{…}` in a set operation is replaced with every
+// combination of the variants of its characters instead, which is reported
+// if there are more than maxStrings.
+//
+// A backreference matches case-insensitively with `i`, which can't be
+// written without it, so it is reported.
+package case_expansion
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/builder"
+	"github.com/sosukesuzuki/regexpp-go/internal/case_folding"
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+// The most strings that a `\q{…}` in a set operation is expanded into
+const maxStrings = 1000
+
+// Expand returns a copy of pattern that matches without `i` what pattern
+// matches with flags, and flags without `i`. The Locs of the copy are the
+// ones of its printed source. pattern itself is left untouched. If a part of
+// pattern can't be expanded, the error is a diagnostic.List with one
+// Diagnostic per node, and the returned pattern is nil.
+func Expand(pattern *regexp_ast.Pattern, flags regexp_ast.Flags) (*regexp_ast.Pattern, regexp_ast.Flags, error) {
+	p := regexp_ast.Clone(pattern).(*regexp_ast.Pattern)
+	if !flags.IgnoreCase {
+		return p, flags, nil
+	}
+	e := &expander{unicode: flags.Unicode || flags.UnicodeSets, unicodeSets: flags.UnicodeSets}
+	e.alternatives(p.Alternatives)
+	if err := e.diags.Err(); err != nil {
+		return nil, regexp_ast.Flags{}, err
+	}
+	printer.Layout(p, e.unicode)
+	flags.IgnoreCase = false
+	return p, flags, nil
+}
+
+type expander struct {
+	unicode bool
+	// Whether the classes are parsed with `v`
+	unicodeSets bool
+	diags       diagnostic.List
+}
+
+func (e *expander) alternatives(alts []*regexp_ast.Alternative) {
+	for _, alt := range alts {
+		for i, el := range alt.Elements {
+			alt.Elements[i] = e.element(el)
+			alt.Elements[i].(regexp_ast.Node).SetParent(alt)
+		}
+	}
+}
+
+func (e *expander) element(el regexp_ast.Element) regexp_ast.Element {
+	switch n := el.(type) {
+	case *regexp_ast.Character:
+		return e.character(n)
+	case *regexp_ast.CharacterClass:
+		if n.UnicodeSets {
+			return e.classSet(n)
+		}
+		e.characterClass(n)
+	case *regexp_ast.ExpressionCharacterClass:
+		return e.classSet(n)
+	case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+		return e.characterSet(el.(regexp_ast.CharacterClassElement))
+	case *regexp_ast.Quantifier:
+		inner := e.element(n.Element.(regexp_ast.Element))
+		n.Element = inner.(regexp_ast.QuantifiableElement)
+		inner.(regexp_ast.Node).SetParent(n)
+	case *regexp_ast.Group:
+		e.alternatives(n.Alternatives)
+	case *regexp_ast.CapturingGroup:
+		e.alternatives(n.Alternatives)
+	case *regexp_ast.LookaroundAssertion:
+		e.alternatives(n.Alternatives)
+	case *regexp_ast.WordBoundaryAssertion:
+		if e.unicode {
+			return e.wordBoundary(n)
+		}
+	case *regexp_ast.Backreference:
+		e.diags.Add(n, "a backreference can't match case-insensitively without the i flag")
+	}
+	return el
+}
+
+// character returns c, or a class of c and its case variants.
+func (e *expander) character(c *regexp_ast.Character) regexp_ast.Element {
+	variants := case_folding.Variants(c.Value, e.unicode)
+	if len(variants) < 2 {
+		return c
+	}
+	cc := &regexp_ast.CharacterClass{UnicodeSets: e.unicodeSets, Elements: []regexp_ast.CharacterClassElement{c}}
+	c.Parent = cc
+	for _, v := range variants {
+		if v != c.Value {
+			cc.Elements = append(cc.Elements, &regexp_ast.Character{Parent: cc, Value: v})
+		}
+	}
+	return cc
+}
+
+// characterSet returns an escape or a property, or a class of it and the
+// case variants of its characters.
+func (e *expander) characterSet(el regexp_ast.CharacterClassElement) regexp_ast.Element {
+	cc := &regexp_ast.CharacterClass{UnicodeSets: e.unicodeSets, Elements: []regexp_ast.CharacterClassElement{el}}
+	el.(regexp_ast.Node).SetParent(cc)
+	var expanded regexp_ast.Element = cc
+	if e.unicodeSets {
+		expanded = e.classSet(cc)
+	} else {
+		e.characterClass(cc)
+	}
+	if expanded == regexp_ast.Element(cc) && len(cc.Elements) == 1 && cc.Elements[0] == el {
+		return el.(regexp_ast.Element)
+	}
+	return expanded
+}
+
+// characterClass appends the case variants that cc doesn't have yet to its
+// elements, keeping the elements it has, and expands its nested classes and
+// strings. An escape or a property that matches characters without `i` that
+// it doesn't match with `i` is replaced with the ranges of what it matches.
+func (e *expander) characterClass(cc *regexp_ast.CharacterClass) {
+	flags := regexp_ast.Flags{Unicode: e.unicode, UnicodeSets: cc.UnicodeSets}
+	folded := flags
+	folded.IgnoreCase = true
+	var elements []regexp_ast.CharacterClassElement
+	var chars charset.Set
+	for _, el := range cc.Elements {
+		switch n := el.(type) {
+		case *regexp_ast.CharacterClass:
+			e.characterClass(n)
+		case *regexp_ast.ExpressionCharacterClass:
+			e.expression(n.Expression)
+		case *regexp_ast.ClassStringDisjunction:
+			e.stringDisjunction(n)
+		case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+			s, ok := charset.OfNode(n.(regexp_ast.Node), flags)
+			variants, okFolded := charset.OfNode(n.(regexp_ast.Node), folded)
+			if !ok || !okFolded {
+				e.diags.Add(n.(regexp_ast.Node), "the characters of the property are unknown")
+				break
+			}
+			if !s.Difference(variants).IsEmpty() {
+				elements = append(elements, ranges(variants, cc)...)
+				chars = chars.Union(variants)
+				continue
+			}
+			chars = chars.Union(s)
+		default:
+			s, _ := charset.OfNode(n.(regexp_ast.Node), flags)
+			chars = chars.Union(s)
+		}
+		elements = append(elements, el)
+	}
+	cc.Elements = append(elements, ranges(charset.CaseClosure(chars, e.unicode).Difference(chars), cc)...)
+}
+
+// ranges returns the elements of a class with the parent cc that match s.
+func ranges(s charset.Set, cc *regexp_ast.CharacterClass) []regexp_ast.CharacterClassElement {
+	var elements []regexp_ast.CharacterClassElement
+	for _, r := range s.Ranges() {
+		switch {
+		case r.Min == r.Max:
+			elements = append(elements, &regexp_ast.Character{Parent: cc, Value: r.Min})
+		case r.Min+1 == r.Max:
+			elements = append(elements,
+				&regexp_ast.Character{Parent: cc, Value: r.Min},
+				&regexp_ast.Character{Parent: cc, Value: r.Max},
+			)
+		default:
+			n := &regexp_ast.CharacterClassRange{Parent: cc}
+			n.Min = &regexp_ast.Character{Parent: n, Value: r.Min}
+			n.Max = &regexp_ast.Character{Parent: n, Value: r.Max}
+			elements = append(elements, n)
+		}
+	}
+	return elements
+}
+
+// classSet returns a class with `v` expanded in place, or an alternation of
+// its strings and its characters if it matches strings.
+func (e *expander) classSet(node regexp_ast.Element) regexp_ast.Element {
+	c, ok := charset.ClassSetOf(node.(regexp_ast.Node), regexp_ast.Flags{UnicodeSets: true, IgnoreCase: true})
+	if !ok {
+		e.diags.Add(node.(regexp_ast.Node), "the characters of a property of the class are unknown")
+		return node
+	}
+	if c.MayContainStrings() {
+		return e.alternation(c)
+	}
+	switch n := node.(type) {
+	case *regexp_ast.CharacterClass:
+		e.characterClass(n)
+	case *regexp_ast.ExpressionCharacterClass:
+		e.expression(n.Expression)
+	}
+	return node
+}
+
+// alternation returns `(?:…)` of the strings of c by descending length,
+// with a class of the variants of each character, then a class of the
+// characters of c and the empty string.
+func (e *expander) alternation(c charset.ClassSet) *regexp_ast.Group {
+	strs := c.Strings()
+	sort.SliceStable(strs, func(i, j int) bool {
+		return utf8.RuneCountInString(strs[i]) > utf8.RuneCountInString(strs[j])
+	})
+	g := &regexp_ast.Group{}
+	for _, s := range strs {
+		if s == "" {
+			continue
+		}
+		var elements []regexp_ast.Element
+		for _, r := range s {
+			elements = append(elements, e.character(&regexp_ast.Character{Value: int(r)}))
+		}
+		g.Alternatives = append(g.Alternatives, &regexp_ast.Alternative{Parent: g, Elements: elements})
+	}
+	if !c.Chars.IsEmpty() {
+		cc := &regexp_ast.CharacterClass{UnicodeSets: true}
+		cc.Elements = ranges(c.Chars, cc)
+		g.Alternatives = append(g.Alternatives, &regexp_ast.Alternative{Parent: g, Elements: []regexp_ast.Element{cc}})
+	}
+	if strs[len(strs)-1] == "" {
+		g.Alternatives = append(g.Alternatives, &regexp_ast.Alternative{Parent: g, Elements: []regexp_ast.Element{}})
+	}
+	for _, alt := range g.Alternatives {
+		for _, el := range alt.Elements {
+			el.(regexp_ast.Node).SetParent(alt)
+		}
+	}
+	return g
+}
+
+// expression expands the operands of a set operation.
+func (e *expander) expression(x regexp_ast.ClassSetExpression) {
+	switch n := x.(type) {
+	case *regexp_ast.ClassIntersection:
+		n.Left = e.operand(n.Left, n)
+		n.Right = e.operand(n.Right, n)
+	case *regexp_ast.ClassSubtraction:
+		n.Left = e.operand(n.Left, n)
+		n.Right = e.operand(n.Right, n)
+	}
+}
+
+// operand returns op expanded. A character with case variants and an escape
+// or a property become nested classes.
+func (e *expander) operand(op regexp_ast.ClassSetOperand, parent regexp_ast.Node) regexp_ast.ClassSetOperand {
+	switch n := op.(type) {
+	case *regexp_ast.Character, *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+		el := n.(regexp_ast.CharacterClassElement)
+		cc := &regexp_ast.CharacterClass{Parent: parent, UnicodeSets: true, Elements: []regexp_ast.CharacterClassElement{el}}
+		el.(regexp_ast.Node).SetParent(cc)
+		e.characterClass(cc)
+		if len(cc.Elements) == 1 && cc.Elements[0] == el {
+			el.(regexp_ast.Node).SetParent(parent)
+			return op
+		}
+		return cc
+	case *regexp_ast.CharacterClass:
+		e.characterClass(n)
+	case *regexp_ast.ExpressionCharacterClass:
+		e.expression(n.Expression)
+	case *regexp_ast.ClassStringDisjunction:
+		e.stringDisjunction(n)
+	case regexp_ast.ClassSetExpression:
+		e.expression(n)
+	}
+	return op
+}
+
+// stringDisjunction replaces the strings of d with every combination of the
+// case variants of their characters, or reports d if there are more than
+// maxStrings.
+func (e *expander) stringDisjunction(d *regexp_ast.ClassStringDisjunction) {
+	count := 0
+	for _, alt := range d.Alternatives {
+		n := 1
+		for _, c := range alt.Elements {
+			if n *= len(case_folding.Variants(c.Value, true)); n > maxStrings {
+				break
+			}
+		}
+		if count += n; count > maxStrings {
+			e.diags.Add(d, "the case variants of the strings are more than %d", maxStrings)
+			return
+		}
+	}
+	var alts []*regexp_ast.StringAlternative
+	for _, alt := range d.Alternatives {
+		strs := [][]int{nil}
+		for _, c := range alt.Elements {
+			var next [][]int
+			for _, s := range strs {
+				for _, v := range case_folding.Variants(c.Value, true) {
+					next = append(next, append(s[:len(s):len(s)], v))
+				}
+			}
+			strs = next
+		}
+		for _, s := range strs {
+			a := &regexp_ast.StringAlternative{Parent: d, Elements: []*regexp_ast.Character{}}
+			for _, cp := range s {
+				a.Elements = append(a.Elements, &regexp_ast.Character{Parent: a, Value: cp})
+			}
+			alts = append(alts, a)
+		}
+	}
+	d.Alternatives = alts
+}
+
+// wordBoundary returns `\b` or `\B` with the word characters of `i` and `u`,
+// which are the ones of `\w`, ſ and the Kelvin sign, as lookarounds:
+// `(?:(?<=\w)(?!\w)|(?<!\w)(?=\w))` for `\b`, and the other two combinations
+// for `\B`.
+func (e *expander) wordBoundary(a *regexp_ast.WordBoundaryAssertion) regexp_ast.Element {
+	b := builder.New(e.unicode)
+	word := func() *regexp_ast.CharacterClass {
+		cc := b.Class(b.Escape(regexp_ast.EscapeWord, false), b.Char(0x017f), b.Char(0x212a))
+		cc.UnicodeSets = e.unicodeSets
+		return cc
+	}
+	return b.Group(
+		b.Alt(b.Lookbehind(false, b.Alt(word())), b.Lookahead(!a.Negate, b.Alt(word()))),
+		b.Alt(b.Lookbehind(true, b.Alt(word())), b.Lookahead(a.Negate, b.Alt(word()))),
+	)
+}
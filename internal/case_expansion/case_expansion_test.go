@@ -0,0 +1,284 @@
+package case_expansion_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/case_expansion"
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func parse(t *testing.T, source string, flags string) (*regexp_ast.Pattern, regexp_ast.Flags) {
+	t.Helper()
+	f, err := parser.ParseFlags(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewParserWithOptions(source, f.Unicode, parser.Options{UnicodeSets: f.UnicodeSets})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pattern, f
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name            string
+		inputS          string
+		inputFlags      string
+		wantOutput      string
+		wantOutputFlags string
+	}{
+		{
+			name:            "i フラグがなければそのまま",
+			inputS:          `a[b]`,
+			inputFlags:      "u",
+			wantOutput:      `a[b]`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "文字を大文字と小文字の文字クラスにする",
+			inputS:          `a1B+`,
+			inputFlags:      "gi",
+			wantOutput:      `[aA]1[Bb]+`,
+			wantOutputFlags: "g",
+		},
+		{
+			name:            "u フラグの s と k は ſ と K にマッチする",
+			inputS:          `sk`,
+			inputFlags:      "iu",
+			wantOutput:      "[sSſ][kK\u212A]",
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "u フラグなしの s と k は ſ と K にマッチしない",
+			inputS:          "skſ\u212A",
+			inputFlags:      "i",
+			wantOutput:      "[sS][kK]ſ\u212A",
+			wantOutputFlags: "",
+		},
+		{
+			name:            "u フラグなしの複数の文字になる大文字",
+			inputS:          `ßᾳ`,
+			inputFlags:      "i",
+			wantOutput:      `ßᾳ`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "u フラグの ß は ẞ にマッチする",
+			inputS:          `ß`,
+			inputFlags:      "iu",
+			wantOutput:      `[ßẞ]`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "文字クラスの範囲に足りない文字を加える",
+			inputS:          `[a-zA-F]`,
+			inputFlags:      "i",
+			wantOutput:      `[a-zA-FG-Z]`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "否定の文字クラス",
+			inputS:          `[^ab]`,
+			inputFlags:      "i",
+			wantOutput:      `[^abAB]`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "アストラル文字",
+			inputS:          `𐐀[𐐁]`,
+			inputFlags:      "iv",
+			wantOutput:      `[𐐀𐐨][𐐁𐐩]`,
+			wantOutputFlags: "v",
+		},
+		{
+			name:            "グループと先読みの中も展開する",
+			inputS:          `(a)\b(?:b|c)(?=d)`,
+			inputFlags:      "i",
+			wantOutput:      `([aA])\b(?:[bB]|[cC])(?=[dD])`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "iu フラグの \\w と \\W",
+			inputS:          `\w\W`,
+			inputFlags:      "iu",
+			wantOutput:      "[\\w\u017f\u212a][\\x00-/:-@[-^`{-\u017e\u0180-\u2129\u212b-\\u{10FFFF}]",
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "iu フラグの単語境界",
+			inputS:          `\b`,
+			inputFlags:      "iu",
+			wantOutput:      "(?:(?<=[\\w\u017f\u212a])(?![\\w\u017f\u212a])|(?<![\\w\u017f\u212a])(?=[\\w\u017f\u212a]))",
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "文字列を含む v フラグの文字クラスは選択にする",
+			inputS:          `[\q{ab|c}[k]]`,
+			inputFlags:      "iv",
+			wantOutput:      "(?:[aA][bB]|[CKck\u212a])",
+			wantOutputFlags: "v",
+		},
+		{
+			name:            "集合演算の中の文字列",
+			inputS:          `[\w--\q{ab}]`,
+			inputFlags:      "iv",
+			wantOutput:      "[[\\w\u017f\u212a]--\\q{AB|Ab|aB|ab}]",
+			wantOutputFlags: "v",
+		},
+		{
+			name:            "空文字列",
+			inputS:          `[\q{}a]+`,
+			inputFlags:      "iv",
+			wantOutput:      `(?:[Aa]|)+`,
+			wantOutputFlags: "v",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			before := printer.Print(pattern, flags.Unicode)
+			p, f, err := case_expansion.Expand(pattern, flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o := printer.Print(p, f.Unicode || f.UnicodeSets); o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+			if f.String() != tt.wantOutputFlags {
+				t.Errorf("Unexpected flags, expected %s, actual %s", tt.wantOutputFlags, f)
+			}
+			if err := regexp_ast.Verify(p); err != nil {
+				t.Error(err)
+			}
+			if after := printer.Print(pattern, flags.Unicode); after != before {
+				t.Errorf("The input was modified from %s to %s", before, after)
+			}
+		})
+	}
+}
+
+func TestExpandError(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputFlags string
+		wantLocs   []regexp_ast.Loc
+	}{
+		{
+			name:       "後方参照",
+			inputS:     `(a)\1`,
+			inputFlags: "i",
+			wantLocs:   []regexp_ast.Loc{{Start: 3, End: 5}},
+		},
+		{
+			name:       "文字列の大文字小文字の組み合わせが多すぎる",
+			inputS:     `[\w--\q{aaaaaaaaaaa}]`,
+			inputFlags: "iv",
+			wantLocs:   []regexp_ast.Loc{{Start: 5, End: 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			p, _, err := case_expansion.Expand(pattern, flags)
+			if p != nil {
+				t.Errorf("Expected no output, actual %s", printer.Print(p, flags.Unicode))
+			}
+			var diags diagnostic.List
+			if !errors.As(err, &diags) {
+				t.Fatalf("Expected a diagnostic.List, actual %v", err)
+			}
+			if len(diags) != len(tt.wantLocs) {
+				t.Fatalf("Unexpected number of diagnostics, expected %d, actual %d: %v", len(tt.wantLocs), len(diags), diags)
+			}
+			for i, d := range diags {
+				if d.Loc.Start != tt.wantLocs[i].Start || d.Loc.End != tt.wantLocs[i].End {
+					t.Errorf("Unexpected Loc, expected %v, actual %v", tt.wantLocs[i], d.Loc)
+				}
+			}
+		})
+	}
+}
+
+// The expanded patterns match the same strings without `i`. The test needs
+// node and is skipped without it.
+func TestExpandNode(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	tests := []struct {
+		inputS     string
+		inputFlags string
+	}{
+		{inputS: `[a-z]+`, inputFlags: "i"},
+		{inputS: `[a-z]+`, inputFlags: "iu"},
+		{inputS: `[^a-z]+`, inputFlags: "i"},
+		{inputS: `[^a-z]+`, inputFlags: "iu"},
+		{inputS: `[\0-\u{10ffff}]`, inputFlags: "iu"},
+		{inputS: `[^ß-ÿ]`, inputFlags: "i"},
+		{inputS: `[^ß-ÿ]`, inputFlags: "iu"},
+		{inputS: `[ͅ-Ϗ]+`, inputFlags: "i"},
+		{inputS: `[ͅ-Ϗ]+`, inputFlags: "iu"},
+		{inputS: `[ᲀ-ᲈ]`, inputFlags: "i"},
+		{inputS: `[ᲀ-ᲈ]`, inputFlags: "iu"},
+		{inputS: `\w|\W|[\W]|[^\W]`, inputFlags: "iu"},
+		{inputS: `\P{sc=Cyrl}|\p{Lt}`, inputFlags: "iu"},
+		{inputS: `[^[a-z]&&[^k]]`, inputFlags: "iv"},
+		{inputS: `[\q{ss|st|k}\u017f]+`, inputFlags: "iv"},
+		{inputS: `(a|b)c|\bx\B`, inputFlags: "i"},
+		{inputS: `.\b.|.\B.`, inputFlags: "iu"},
+	}
+
+	type testCase struct {
+		Source, Flags, Expanded, ExpandedFlags string
+	}
+	var cases []testCase
+	for _, tt := range tests {
+		pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+		p, f, err := case_expansion.Expand(pattern, flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, testCase{tt.inputS, tt.inputFlags, printer.Print(p, f.Unicode || f.UnicodeSets), f.String()})
+	}
+	input, _ := json.Marshal(cases)
+	// Every BMP character, a few astral ones and a few pairs are tried one by one
+	cmd := exec.Command(node, "-e", `
+const cases = JSON.parse(require("fs").readFileSync(0, "utf8"));
+const inputs = [];
+for (let cp = 0; cp <= 0xffff; cp++) inputs.push(String.fromCharCode(cp));
+inputs.push("\u{10400}", "\u{10428}", "\u{1e900}", "\u{1e922}");
+inputs.push("ss", "sS", "\u017fs", "st", "\u017fT", "ac", "BC", "kk", "k\u212a", "a\u017f", "\u212a-", "- ");
+console.log(JSON.stringify(cases.map((c) => {
+  const a = new RegExp("^(?:" + c.Source + ")$", c.Flags);
+  const b = new RegExp("^(?:" + c.Expanded + ")$", c.ExpandedFlags);
+  return inputs.filter((s) => a.test(s) !== b.test(s)).slice(0, 5).map((s) => s.codePointAt(0));
+})));
+`)
+	cmd.Stdin = strings.NewReader(string(input))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("node failed: %v", err)
+	}
+	var results [][]int
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range cases {
+		if len(results[i]) > 0 {
+			t.Errorf("/%s/%s as /%s/%s differs on %U", c.Source, c.Flags, c.Expanded, c.ExpandedFlags, results[i])
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package case_folding implements the Canonicalize abstract operation of
+// ECMAScript, which decides which characters match each other under the `i`
+// flag.
+//
+// https://tc39.es/ecma262/multipage/text-processing.html#sec-runtime-semantics-canonicalize-ch
+package case_folding
+
+import (
+	"sort"
+	"sync"
+	"unicode"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// Canonicalize returns the canonical form of cp. With unicode, which is true
+// for the `u` and `v` flags, it is the simple case folding of cp. Otherwise
+// it is the uppercase of cp, unless that takes more than one code unit or
+// maps a non-ASCII character to an ASCII one.
+func Canonicalize(cp int, unicode bool) int {
+	if unicode {
+		return simpleFold(cp)
+	}
+	return toUppercase(cp)
+}
+
+// Variants returns every character that canonicalizes to the same character
+// as cp, cp included, in ascending order. Without unicode, only code units
+// are considered.
+func Variants(cp int, unicode bool) []int {
+	if unicode {
+		return orbit(cp)
+	}
+	if cp > 0xffff {
+		return []int{cp}
+	}
+	legacyOnce.Do(initLegacy)
+	if v, ok := legacyVariants[toUppercase(cp)]; ok {
+		return v
+	}
+	return []int{cp}
+}
+
+// simpleFold returns the simple case folding (the C and S mappings of
+// CaseFolding.txt) of cp.
+func simpleFold(cp int) int {
+	if cp < 0 || cp > unicode_consts.MaxCodePoint {
+		return cp
+	}
+	// Folding maps most characters to their lowercase, including ones that
+	// only fold through their uppercase like U+017F (ſ) to U+0073 (s).
+	folded := int(unicode.ToLower(unicode.ToUpper(rune(cp))))
+	if !contains(orbit(cp), folded) {
+		// E.g. U+0130 (İ) only has a full or Turkic folding
+		return cp
+	}
+	// Characters without case mappings that fold to a lookalike
+	switch cp {
+	case 0x1fd3:
+		return 0x390
+	case 0x1fe3:
+		return 0x3b0
+	case 0xfb05:
+		return 0xfb06
+	}
+	// Cherokee is the exception that folds to uppercase
+	if isCherokeeSmall(folded) {
+		return int(unicode.ToUpper(rune(folded)))
+	}
+	return folded
+}
+
+func isCherokeeSmall(cp int) bool {
+	return (cp >= 0xab70 && cp <= 0xabbf) || (cp >= 0x13f8 && cp <= 0x13fd)
+}
+
+// orbit returns the characters that have the same simple case folding as cp.
+func orbit(cp int) []int {
+	if cp < 0 || cp > unicode_consts.MaxCodePoint {
+		return []int{cp}
+	}
+	o := []int{cp}
+	for r := unicode.SimpleFold(rune(cp)); int(r) != cp; r = unicode.SimpleFold(r) {
+		o = append(o, int(r))
+	}
+	sort.Ints(o)
+	return o
+}
+
+// toUppercase is the legacy Canonicalize.
+func toUppercase(cp int) int {
+	if cp < 0 || cp > 0xffff || hasMultiCharUppercase(cp) {
+		return cp
+	}
+	u := int(unicode.ToUpper(rune(cp)))
+	if u > 0xffff || (cp >= 128 && u < 128) {
+		return cp
+	}
+	return u
+}
+
+// hasMultiCharUppercase returns whether the full uppercase of cp in
+// SpecialCasing.txt has more than one character while its simple uppercase,
+// which unicode.ToUpper returns, is a single different character. The Greek
+// letters with ypogegrammeni are the only such characters.
+func hasMultiCharUppercase(cp int) bool {
+	return (cp >= 0x1f80 && cp <= 0x1f87) ||
+		(cp >= 0x1f90 && cp <= 0x1f97) ||
+		(cp >= 0x1fa0 && cp <= 0x1fa7) ||
+		cp == 0x1fb3 || cp == 0x1fc3 || cp == 0x1ff3
+}
+
+var (
+	legacyOnce sync.Once
+	// The characters canonicalizing to each key, for the keys that more
+	// than one code unit canonicalizes to
+	legacyVariants map[int][]int
+)
+
+func initLegacy() {
+	all := map[int][]int{}
+	for cp := 0; cp <= 0xffff; cp++ {
+		if u := toUppercase(cp); u != cp {
+			all[u] = append(all[u], cp)
+		}
+	}
+	legacyVariants = map[int][]int{}
+	for u, cps := range all {
+		if toUppercase(u) == u {
+			cps = append(cps, u)
+		}
+		sort.Ints(cps)
+		legacyVariants[u] = cps
+	}
+}
+
+func contains(s []int, v int) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package case_folding_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/case_folding"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputCP    int
+		inputU     bool
+		wantOutput int
+	}{
+		{name: "ユニコードモードで、大文字を小文字に畳み込む", inputCP: 'A', inputU: true, wantOutput: 'a'},
+		{name: "ユニコードモードで、ſ を s に畳み込む", inputCP: 0x17f, inputU: true, wantOutput: 's'},
+		{name: "ユニコードモードで、ケルビン記号を k に畳み込む", inputCP: 0x212a, inputU: true, wantOutput: 'k'},
+		{name: "ユニコードモードで、ς を σ に畳み込む", inputCP: 0x3c2, inputU: true, wantOutput: 0x3c3},
+		{name: "ユニコードモードで、İ は単純な畳み込みを持たない", inputCP: 0x130, inputU: true, wantOutput: 0x130},
+		{name: "ユニコードモードで、チェロキー文字は大文字に畳み込む", inputCP: 0xab70, inputU: true, wantOutput: 0x13a0},
+		{name: "ユニコードモードで、アストラルの文字を畳み込む", inputCP: 0x10400, inputU: true, wantOutput: 0x10428},
+		{name: "非ユニコードモードで、小文字を大文字にする", inputCP: 'a', inputU: false, wantOutput: 'A'},
+		{name: "非ユニコードモードで、ſ は ASCII の S にならない", inputCP: 0x17f, inputU: false, wantOutput: 0x17f},
+		{name: "非ユニコードモードで、ケルビン記号はそのまま", inputCP: 0x212a, inputU: false, wantOutput: 0x212a},
+		{name: "非ユニコードモードで、ß は2文字の大文字になるのでそのまま", inputCP: 0xdf, inputU: false, wantOutput: 0xdf},
+		{name: "非ユニコードモードで、ᾳ は2文字の大文字になるのでそのまま", inputCP: 0x1fb3, inputU: false, wantOutput: 0x1fb3},
+		{name: "非ユニコードモードで、µ を Μ にする", inputCP: 0xb5, inputU: false, wantOutput: 0x39c},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if o := case_folding.Canonicalize(tt.inputCP, tt.inputU); o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %U, actual %U", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputCP    int
+		inputU     bool
+		wantOutput []int
+	}{
+		{name: "ユニコードモードで、k の変種にケルビン記号を含む", inputCP: 'k', inputU: true, wantOutput: []int{'K', 'k', 0x212a}},
+		{name: "ユニコードモードで、s の変種に ſ を含む", inputCP: 'S', inputU: true, wantOutput: []int{'S', 's', 0x17f}},
+		{name: "非ユニコードモードで、k の変種にケルビン記号を含まない", inputCP: 'k', inputU: false, wantOutput: []int{'K', 'k'}},
+		{name: "非ユニコードモードで、σ の変種に ς を含む", inputCP: 0x3c3, inputU: false, wantOutput: []int{0x3a3, 0x3c2, 0x3c3}},
+		{name: "大文字小文字のない文字", inputCP: '1', inputU: false, wantOutput: []int{'1'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if o := case_folding.Variants(tt.inputCP, tt.inputU); !reflect.DeepEqual(o, tt.wantOutput) {
+				t.Errorf("Unexpected output, expected %U, actual %U", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+// Two characters are variants of each other exactly when they canonicalize
+// to the same character.
+func TestVariantsCanonicalize(t *testing.T) {
+	for _, u := range []bool{false, true} {
+		max := 0xffff
+		if u {
+			max = 0x10ffff
+		}
+		for cp := 0; cp <= max; cp++ {
+			for _, v := range case_folding.Variants(cp, u) {
+				if case_folding.Canonicalize(v, u) != case_folding.Canonicalize(cp, u) {
+					t.Fatalf("%U and its variant %U canonicalize differently (u=%v)", cp, v, u)
+				}
+			}
+		}
+	}
+}
@@ -2,19 +2,44 @@ package char_code_utils
 
 import (
 	"unicode/utf16"
+	"unicode/utf8"
 )
 
+// Both implementations index the source by UTF-16 code units, like JavaScript
+// does. They differ only in whether a surrogate pair is read as one code
+// point.
+//
+// At re-encodes the whole string on every call, so it is only meant for one-off
+// lookups. Decode the source once with Encode and use AtUnits when reading it
+// character by character.
 type CharCodeUtils interface {
 	At(s string, i int) int
+	AtUnits(units []uint16, i int) int
 	Width(c int) int
 }
 
+// Encode appends the UTF-16 code units of s to buf and returns the extended
+// buffer. Invalid UTF-8 bytes are encoded as U+FFFD.
+func Encode(s string, buf []uint16) []uint16 {
+	for _, r := range s {
+		if r > 0xffff {
+			r1, r2 := utf16.EncodeRune(r)
+			buf = append(buf, uint16(r1), uint16(r2))
+		} else {
+			buf = append(buf, uint16(r))
+		}
+	}
+	return buf
+}
+
 type Legacy struct{}
 
 func (u *Legacy) At(s string, i int) int {
-	runes := utf16.Encode([]rune(s))
-	if i >= 0 && i < len(runes) {
-		return int(utf16.Encode([]rune(s))[i])
+	return u.AtUnits(Encode(s, nil), i)
+}
+func (u *Legacy) AtUnits(units []uint16, i int) int {
+	if i >= 0 && i < len(units) {
+		return int(units[i])
 	}
 	return -1
 }
@@ -25,9 +50,16 @@ func (u *Legacy) Width(c int) int {
 type Unicode struct{}
 
 func (u *Unicode) At(s string, i int) int {
-	runes := []rune(s)
-	if i >= 0 && i < len(runes) {
-		return int([]rune(s)[i])
+	return u.AtUnits(Encode(s, nil), i)
+}
+func (u *Unicode) AtUnits(units []uint16, i int) int {
+	if i >= 0 && i < len(units) {
+		if i+1 < len(units) {
+			if r := utf16.DecodeRune(rune(units[i]), rune(units[i+1])); r != utf8.RuneError {
+				return int(r)
+			}
+		}
+		return int(units[i])
 	}
 	return -1
 }
@@ -1,6 +1,7 @@
 package char_code_utils_test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/sosukesuzuki/regexpp-go/internal/char_code_utils"
@@ -126,3 +127,45 @@ func TestUnicodeAt(t *testing.T) {
 		})
 	}
 }
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		wantOutput []uint16
+	}{
+		{
+			name:       "BMP の文字はそのままコードユニットになる",
+			inputS:     "aあ",
+			wantOutput: []uint16{0x61, 0x3042},
+		},
+		{
+			name:       "`𠮟`はサロゲートペアになる",
+			inputS:     "a𠮟",
+			wantOutput: []uint16{0x61, 0xd842, 0xdf9f},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			units := char_code_utils.Encode(tt.inputS, nil)
+			if !reflect.DeepEqual(units, tt.wantOutput) {
+				t.Errorf("Unexpected units, expected %v, actual %v", tt.wantOutput, units)
+			}
+		})
+	}
+}
+
+func TestUnicodeAtUnits(t *testing.T) {
+	units := char_code_utils.Encode("あ𠮟", nil)
+	u := char_code_utils.Unicode{}
+	if c := u.AtUnits(units, 1); c != 0x20b9f {
+		t.Errorf("Unexpected at, expected %d, actual %d", 0x20b9f, c)
+	}
+	if c := u.AtUnits(units, 2); c != 0xdf9f {
+		t.Errorf("Unexpected at, expected %d, actual %d", 0xdf9f, c)
+	}
+	if c := u.AtUnits(units, 3); c != -1 {
+		t.Errorf("Unexpected at, expected %d, actual %d", -1, c)
+	}
+}
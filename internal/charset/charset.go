@@ -0,0 +1,263 @@
+// Package charset implements immutable sets of code points, stored as sorted
+// and non-overlapping ranges over 0..0x10FFFF.
+//
+// Every operation returns a new Set and leaves its operands untouched, so
+// sets can be shared freely. The zero Set is empty.
+package charset
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// Range is the code points from Min to Max, inclusive.
+type Range struct {
+	Min int
+	Max int
+}
+
+// Set is a set of code points.
+type Set struct {
+	// Sorted, non-overlapping and non-adjacent
+	ranges []Range
+}
+
+// New returns the set of the code points in ranges. The ranges may come in
+// any order and overlap; parts outside of 0..0x10FFFF and ranges whose Min
+// is greater than their Max are ignored.
+func New(ranges ...Range) Set {
+	rs := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Min < 0 {
+			r.Min = 0
+		}
+		if r.Max > unicode_consts.MaxCodePoint {
+			r.Max = unicode_consts.MaxCodePoint
+		}
+		if r.Min <= r.Max {
+			rs = append(rs, r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Min < rs[j].Min
+	})
+	return Set{merge(rs)}
+}
+
+// Of returns the set of cps.
+func Of(cps ...int) Set {
+	rs := make([]Range, len(cps))
+	for i, cp := range cps {
+		rs[i] = Range{cp, cp}
+	}
+	return New(rs...)
+}
+
+// All returns the set of every code point.
+func All() Set {
+	return Set{[]Range{{0, unicode_consts.MaxCodePoint}}}
+}
+
+// merge merges the overlapping and adjacent ranges of the sorted rs in place.
+func merge(rs []Range) []Range {
+	merged := rs[:0]
+	for _, r := range rs {
+		if last := len(merged) - 1; last >= 0 && r.Min <= merged[last].Max+1 {
+			if r.Max > merged[last].Max {
+				merged[last].Max = r.Max
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// Ranges returns the ranges of s in ascending order. They neither overlap
+// nor touch.
+func (s Set) Ranges() []Range {
+	rs := make([]Range, len(s.ranges))
+	copy(rs, s.ranges)
+	return rs
+}
+
+// IsEmpty returns whether s has no code point.
+func (s Set) IsEmpty() bool {
+	return len(s.ranges) == 0
+}
+
+// Len returns the number of code points in s.
+func (s Set) Len() int {
+	n := 0
+	for _, r := range s.ranges {
+		n += r.Max - r.Min + 1
+	}
+	return n
+}
+
+// Contains returns whether cp is in s.
+func (s Set) Contains(cp int) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].Max >= cp
+	})
+	return i < len(s.ranges) && s.ranges[i].Min <= cp
+}
+
+// Equal returns whether s and t have the same code points.
+func (s Set) Equal(t Set) bool {
+	if len(s.ranges) != len(t.ranges) {
+		return false
+	}
+	for i := range s.ranges {
+		if s.ranges[i] != t.ranges[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Each calls f with every code point of s in ascending order, until f
+// returns false.
+func (s Set) Each(f func(cp int) bool) {
+	for _, r := range s.ranges {
+		for cp := r.Min; cp <= r.Max; cp++ {
+			if !f(cp) {
+				return
+			}
+		}
+	}
+}
+
+// Union returns the code points that are in s or t.
+func (s Set) Union(t Set) Set {
+	rs := make([]Range, 0, len(s.ranges)+len(t.ranges))
+	i, j := 0, 0
+	for i < len(s.ranges) || j < len(t.ranges) {
+		if j == len(t.ranges) || (i < len(s.ranges) && s.ranges[i].Min < t.ranges[j].Min) {
+			rs = append(rs, s.ranges[i])
+			i++
+		} else {
+			rs = append(rs, t.ranges[j])
+			j++
+		}
+	}
+	return Set{merge(rs)}
+}
+
+// Intersect returns the code points that are in both s and t.
+func (s Set) Intersect(t Set) Set {
+	var rs []Range
+	i, j := 0, 0
+	for i < len(s.ranges) && j < len(t.ranges) {
+		a, b := s.ranges[i], t.ranges[j]
+		if lo, hi := maxInt(a.Min, b.Min), minInt(a.Max, b.Max); lo <= hi {
+			rs = append(rs, Range{lo, hi})
+		}
+		if a.Max < b.Max {
+			i++
+		} else {
+			j++
+		}
+	}
+	return Set{rs}
+}
+
+// Difference returns the code points that are in s but not in t.
+func (s Set) Difference(t Set) Set {
+	return s.Intersect(t.Complement())
+}
+
+// Complement returns the code points that are not in s.
+func (s Set) Complement() Set {
+	var rs []Range
+	next := 0
+	for _, r := range s.ranges {
+		if r.Min > next {
+			rs = append(rs, Range{next, r.Min - 1})
+		}
+		next = r.Max + 1
+	}
+	if next <= unicode_consts.MaxCodePoint {
+		rs = append(rs, Range{next, unicode_consts.MaxCodePoint})
+	}
+	return Set{rs}
+}
+
+// String returns s like `[U+0041-U+005A U+005F]`, for debugging.
+func (s Set) String() string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, r := range s.ranges {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if r.Min == r.Max {
+			fmt.Fprintf(&sb, "%U", r.Min)
+		} else {
+			fmt.Fprintf(&sb, "%U-%U", r.Min, r.Max)
+		}
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// FromRangeTable returns the code points of t.
+func FromRangeTable(t *unicode.RangeTable) Set {
+	var rs []Range
+	add := func(lo int, hi int, stride int) {
+		if stride == 1 {
+			rs = append(rs, Range{lo, hi})
+			return
+		}
+		for cp := lo; cp <= hi; cp += stride {
+			rs = append(rs, Range{cp, cp})
+		}
+	}
+	for _, r := range t.R16 {
+		add(int(r.Lo), int(r.Hi), int(r.Stride))
+	}
+	for _, r := range t.R32 {
+		add(int(r.Lo), int(r.Hi), int(r.Stride))
+	}
+	return New(rs...)
+}
+
+// RangeTable returns a unicode.RangeTable of s, for the functions of the
+// unicode package like unicode.Is.
+func (s Set) RangeTable() *unicode.RangeTable {
+	t := &unicode.RangeTable{}
+	for _, r := range s.ranges {
+		if r.Min <= 0xffff {
+			hi := minInt(r.Max, 0xffff)
+			t.R16 = append(t.R16, unicode.Range16{Lo: uint16(r.Min), Hi: uint16(hi), Stride: 1})
+			if hi <= unicode.MaxLatin1 {
+				t.LatinOffset++
+			}
+		}
+		if r.Max > 0xffff {
+			t.R32 = append(t.R32, unicode.Range32{Lo: uint32(maxInt(r.Min, 0x10000)), Hi: uint32(r.Max), Stride: 1})
+		}
+	}
+	return t
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,154 @@
+package charset_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"unicode"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []charset.Range
+		wantOutput string
+	}{
+		{name: "空", input: nil, wantOutput: "[]"},
+		{name: "並べ替えて重なりと隣接をまとめる", input: []charset.Range{{'x', 'z'}, {'a', 'c'}, {'b', 'd'}, {'e', 'e'}}, wantOutput: "[U+0061-U+0065 U+0078-U+007A]"},
+		{name: "範囲外を切り捨てる", input: []charset.Range{{-5, 1}, {0x10fffe, 0x110005}}, wantOutput: "[U+0000-U+0001 U+10FFFE-U+10FFFF]"},
+		{name: "逆順の範囲は無視する", input: []charset.Range{{'b', 'a'}}, wantOutput: "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if o := charset.New(tt.input...).String(); o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestOperations(t *testing.T) {
+	a := charset.New(charset.Range{'a', 'f'}, charset.Range{'x', 'z'})
+	b := charset.New(charset.Range{'d', 'y'})
+	tests := []struct {
+		name       string
+		input      charset.Set
+		wantOutput string
+	}{
+		{name: "和集合", input: a.Union(b), wantOutput: "[U+0061-U+007A]"},
+		{name: "共通部分", input: a.Intersect(b), wantOutput: "[U+0064-U+0066 U+0078-U+0079]"},
+		{name: "差集合", input: a.Difference(b), wantOutput: "[U+0061-U+0063 U+007A]"},
+		{name: "補集合", input: a.Complement(), wantOutput: "[U+0000-U+0060 U+0067-U+0077 U+007B-U+10FFFF]"},
+		{name: "空の補集合", input: charset.Set{}.Complement(), wantOutput: "[U+0000-U+10FFFF]"},
+		{name: "全体の補集合", input: charset.All().Complement(), wantOutput: "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if o := tt.input.String(); o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+		})
+	}
+	if a.String() != "[U+0061-U+0066 U+0078-U+007A]" || b.String() != "[U+0064-U+0079]" {
+		t.Errorf("The operands were modified: %s, %s", a, b)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	s := charset.Of('a', 'b', 'c', 0x1f600)
+	if s.Len() != 4 {
+		t.Errorf("Unexpected Len, expected 4, actual %d", s.Len())
+	}
+	if !s.Contains('b') || !s.Contains(0x1f600) || s.Contains('d') || s.Contains(-1) {
+		t.Errorf("Unexpected Contains of %s", s)
+	}
+	if s.IsEmpty() || !(charset.Set{}).IsEmpty() {
+		t.Errorf("Unexpected IsEmpty")
+	}
+	var cps []int
+	s.Each(func(cp int) bool {
+		cps = append(cps, cp)
+		return cp < 'b'
+	})
+	if !reflect.DeepEqual(cps, []int{'a', 'b'}) {
+		t.Errorf("Unexpected Each, expected [97 98], actual %v", cps)
+	}
+	if !s.Equal(charset.New(charset.Range{'a', 'c'}, charset.Range{0x1f600, 0x1f600})) || s.Equal(charset.Of('a')) {
+		t.Errorf("Unexpected Equal of %s", s)
+	}
+	rs := s.Ranges()
+	rs[0].Max = 'z'
+	if s.Contains('z') {
+		t.Errorf("Ranges shares its result with the set")
+	}
+}
+
+// The operations agree with a map of the code points for random sets of a
+// small range.
+func TestRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() (charset.Set, map[int]bool) {
+		var rs []charset.Range
+		for i := r.Intn(5); i > 0; i-- {
+			min := r.Intn(64)
+			rs = append(rs, charset.Range{Min: min, Max: min + r.Intn(8)})
+		}
+		m := map[int]bool{}
+		for _, rr := range rs {
+			for cp := rr.Min; cp <= rr.Max; cp++ {
+				m[cp] = true
+			}
+		}
+		return charset.New(rs...), m
+	}
+	for i := 0; i < 1000; i++ {
+		a, ma := random()
+		b, mb := random()
+		union, intersect, difference, complement := a.Union(b), a.Intersect(b), a.Difference(b), a.Complement()
+		for cp := 0; cp < 80; cp++ {
+			if union.Contains(cp) != (ma[cp] || mb[cp]) ||
+				intersect.Contains(cp) != (ma[cp] && mb[cp]) ||
+				difference.Contains(cp) != (ma[cp] && !mb[cp]) ||
+				complement.Contains(cp) != !ma[cp] {
+				t.Fatalf("Unexpected operations on %s and %s at %U", a, b, cp)
+			}
+		}
+		for _, s := range []charset.Set{union, intersect, difference, complement} {
+			if !charset.New(s.Ranges()...).Equal(s) {
+				t.Fatalf("%s is not normalized", s)
+			}
+		}
+		if a.Len() != len(ma) {
+			t.Fatalf("Unexpected Len of %s, expected %d, actual %d", a, len(ma), a.Len())
+		}
+	}
+}
+
+func TestRangeTable(t *testing.T) {
+	for _, table := range []*unicode.RangeTable{unicode.Greek, unicode.Lu, unicode.White_Space, unicode.Deseret} {
+		s := charset.FromRangeTable(table)
+		rt := s.RangeTable()
+		for cp := 0; cp <= unicode.MaxRune; cp++ {
+			want := unicode.Is(table, rune(cp))
+			if s.Contains(cp) != want || unicode.Is(rt, rune(cp)) != want {
+				t.Fatalf("Unexpected membership of %U", cp)
+			}
+		}
+		if !charset.FromRangeTable(rt).Equal(s) {
+			t.Errorf("The RangeTable of %s doesn't round-trip", s)
+		}
+	}
+	rt := charset.New(charset.Range{0x41, 0x5a}, charset.Range{0xf0, 0x10010}).RangeTable()
+	want := &unicode.RangeTable{
+		R16:         []unicode.Range16{{Lo: 0x41, Hi: 0x5a, Stride: 1}, {Lo: 0xf0, Hi: 0xffff, Stride: 1}},
+		R32:         []unicode.Range32{{Lo: 0x10000, Hi: 0x10010, Stride: 1}},
+		LatinOffset: 1,
+	}
+	if !reflect.DeepEqual(rt, want) {
+		t.Errorf("Unexpected RangeTable, expected %v, actual %v", want, rt)
+	}
+}
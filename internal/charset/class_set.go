@@ -0,0 +1,136 @@
+package charset
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ClassSet is what a class matches with `v`: single characters, and a finite
+// set of strings from `\q{…}` and properties of strings like `\p{RGI_Emoji}`.
+// The strings are never of a single code point, which belong to Chars, but
+// may be empty, since `\q{}` matches the empty string.
+//
+// A ClassSet is immutable like a Set. The zero ClassSet is empty.
+//
+// ClassSetOf evaluates the classes of the AST into ClassSets. The parser
+// rejects a negated class that may contain strings, and Complement reports
+// it as an error for the sets built by hand.
+type ClassSet struct {
+	Chars Set
+	// Sorted and unique
+	strings []string
+}
+
+// NewClassSet returns the class set of chars and strs. Strings of a single
+// code point are added to the characters.
+func NewClassSet(chars Set, strs ...string) ClassSet {
+	var ss []string
+	var cps []int
+	for _, s := range strs {
+		if r, size := utf8.DecodeRuneInString(s); size > 0 && size == len(s) {
+			cps = append(cps, int(r))
+			continue
+		}
+		ss = append(ss, s)
+	}
+	return ClassSet{chars.Union(Of(cps...)), unique(ss)}
+}
+
+// unique sorts ss and removes its duplicates in place.
+func unique(ss []string) []string {
+	sort.Strings(ss)
+	u := ss[:0]
+	for i, s := range ss {
+		if i == 0 || s != ss[i-1] {
+			u = append(u, s)
+		}
+	}
+	if len(u) == 0 {
+		return nil
+	}
+	return u
+}
+
+// Strings returns the strings of c in ascending order.
+func (c ClassSet) Strings() []string {
+	ss := make([]string, len(c.strings))
+	copy(ss, c.strings)
+	return ss
+}
+
+// IsEmpty returns whether c matches nothing.
+func (c ClassSet) IsEmpty() bool {
+	return c.Chars.IsEmpty() && len(c.strings) == 0
+}
+
+// MayContainStrings returns whether c has strings, which a class can only
+// have if it isn't negated.
+func (c ClassSet) MayContainStrings() bool {
+	return len(c.strings) > 0
+}
+
+// Equal returns whether c and d match the same characters and strings.
+func (c ClassSet) Equal(d ClassSet) bool {
+	if !c.Chars.Equal(d.Chars) || len(c.strings) != len(d.strings) {
+		return false
+	}
+	for i := range c.strings {
+		if c.strings[i] != d.strings[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Union returns what c or d matches, like `[cd]` with `v`.
+func (c ClassSet) Union(d ClassSet) ClassSet {
+	ss := append(c.Strings(), d.strings...)
+	return ClassSet{c.Chars.Union(d.Chars), unique(ss)}
+}
+
+// Intersect returns what both c and d match, like `[c&&d]`.
+func (c ClassSet) Intersect(d ClassSet) ClassSet {
+	return ClassSet{c.Chars.Intersect(d.Chars), c.filter(d, true)}
+}
+
+// Difference returns what c matches but d doesn't, like `[c--d]`.
+func (c ClassSet) Difference(d ClassSet) ClassSet {
+	return ClassSet{c.Chars.Difference(d.Chars), c.filter(d, false)}
+}
+
+// filter returns the strings of c that are in d if in is true, and those
+// that aren't otherwise.
+func (c ClassSet) filter(d ClassSet, in bool) []string {
+	var ss []string
+	for _, s := range c.strings {
+		i := sort.SearchStrings(d.strings, s)
+		if (i < len(d.strings) && d.strings[i] == s) == in {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// Complement returns the characters that c doesn't match, like `[^c]`. It
+// reports an error if c has strings, since the strings that a class doesn't
+// match aren't finite.
+func (c ClassSet) Complement() (ClassSet, error) {
+	if c.MayContainStrings() {
+		return ClassSet{}, fmt.Errorf("charset: negated class %s may contain strings", c)
+	}
+	return ClassSet{Chars: c.Chars.Complement()}, nil
+}
+
+// String returns c like `[U+0061 "" "ab"]`, for debugging.
+func (c ClassSet) String() string {
+	parts := []string{strings.TrimSuffix(strings.TrimPrefix(c.Chars.String(), "["), "]")}
+	if parts[0] == "" {
+		parts = nil
+	}
+	for _, s := range c.strings {
+		parts = append(parts, fmt.Sprintf("%q", s))
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
@@ -0,0 +1,69 @@
+package charset_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+)
+
+func TestClassSet(t *testing.T) {
+	// [a-c\q{ab|bc|d|}] and [b-z\q{bc|cd}]
+	a := charset.NewClassSet(charset.New(charset.Range{'a', 'c'}), "ab", "bc", "d", "", "ab")
+	b := charset.NewClassSet(charset.New(charset.Range{'b', 'z'}), "bc", "cd")
+	tests := []struct {
+		name       string
+		input      charset.ClassSet
+		wantOutput string
+	}{
+		{name: "一文字の文字列は文字になる", input: a, wantOutput: `[U+0061-U+0064 "" "ab" "bc"]`},
+		{name: "和集合", input: a.Union(b), wantOutput: `[U+0061-U+007A "" "ab" "bc" "cd"]`},
+		{name: "共通部分", input: a.Intersect(b), wantOutput: `[U+0062-U+0064 "bc"]`},
+		{name: "差集合", input: a.Difference(b), wantOutput: `[U+0061 "" "ab"]`},
+		{name: "文字列だけ", input: charset.NewClassSet(charset.Set{}, "😀👍"), wantOutput: `["😀👍"]`},
+		{name: "空", input: charset.ClassSet{}, wantOutput: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if o := tt.input.String(); o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+		})
+	}
+	if !reflect.DeepEqual(a.Strings(), []string{"", "ab", "bc"}) {
+		t.Errorf("Unexpected Strings, expected [ ab bc], actual %v", a.Strings())
+	}
+	if !a.Intersect(b).Equal(charset.NewClassSet(charset.New(charset.Range{'b', 'd'}), "bc")) || a.Equal(b) {
+		t.Errorf("Unexpected Equal")
+	}
+	if !(charset.ClassSet{}).IsEmpty() || charset.NewClassSet(charset.Set{}, "").IsEmpty() {
+		t.Errorf("Unexpected IsEmpty")
+	}
+}
+
+func TestClassSetComplement(t *testing.T) {
+	// [^\q{a|b}] has no strings
+	c, err := charset.NewClassSet(charset.Set{}, "a", "b").Complement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[U+0000-U+0060 U+0063-U+10FFFF]"; c.String() != want {
+		t.Errorf("Unexpected output, expected %s, actual %s", want, c)
+	}
+
+	// [^\q{ab}] and [^\q{}] may contain strings
+	for _, s := range []string{"ab", ""} {
+		c := charset.NewClassSet(charset.Of('x'), s)
+		if !c.MayContainStrings() {
+			t.Errorf("%s doesn't contain strings", c)
+		}
+		if _, err := c.Complement(); err == nil {
+			t.Errorf("The complement of %s isn't reported", c)
+		}
+	}
+	_, err = charset.NewClassSet(charset.Set{}, "ab").Complement()
+	if want := `charset: negated class ["ab"] may contain strings`; err == nil || err.Error() != want {
+		t.Errorf("Unexpected error, expected %s, actual %v", want, err)
+	}
+}
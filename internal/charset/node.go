@@ -0,0 +1,373 @@
+package charset
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/case_folding"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// OfNode returns the characters that node matches under flags, and whether
+// node matches a single character at all. Characters are code points with
+// `u` or `v` and UTF-16 code units without them, so the set is within
+// 0..0xFFFF then.
+//
+// With `i`, the set has every character whose Canonicalize is the one of a
+// character of node, so a negated class excludes the case variants of its
+// elements as well: `[^a]` with `i` matches neither `a` nor `A`.
+//
+// A class with `v` matches a single character unless it may match a string,
+// e.g. `[\q{ab}c]`; use ClassSetOf to get its strings too. A property that
+// Go's unicode package has no table of, like `\p{Emoji}`, has no set either.
+func OfNode(node regexp_ast.Node, flags regexp_ast.Flags) (Set, bool) {
+	if isClassSetNode(node) {
+		c, ok := ClassSetOf(node, flags)
+		if !ok || c.MayContainStrings() {
+			return Set{}, false
+		}
+		return c.Chars, true
+	}
+
+	u := flags.Unicode || flags.UnicodeSets
+	universe := All()
+	if !u {
+		universe = New(Range{0, 0xffff})
+	}
+
+	var s Set
+	switch n := node.(type) {
+	case *regexp_ast.Character:
+		s = Of(n.Value)
+	case *regexp_ast.CharacterClassRange:
+		s = New(Range{n.Min.Value, n.Max.Value})
+	case *regexp_ast.CharacterClass:
+		var rs []Range
+		for _, el := range n.Elements {
+			switch el := el.(type) {
+			case *regexp_ast.Character:
+				rs = append(rs, Range{el.Value, el.Value})
+			case *regexp_ast.CharacterClassRange:
+				rs = append(rs, Range{el.Min.Value, el.Max.Value})
+			default:
+				e, ok := characterSet(el.(regexp_ast.Node), flags, universe)
+				if !ok {
+					return Set{}, false
+				}
+				rs = append(rs, e.Ranges()...)
+			}
+		}
+		s = New(rs...)
+		if flags.IgnoreCase {
+			s = CaseClosure(s, u)
+		}
+		if n.Negate {
+			s = s.Complement()
+		}
+		return s.Intersect(universe), true
+	case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+		var ok bool
+		if s, ok = characterSet(n, flags, universe); !ok {
+			return Set{}, false
+		}
+	case *regexp_ast.AnyCharacterSet:
+		if flags.DotAll {
+			return universe, true
+		}
+		return universe.Difference(New(
+			Range{unicode_consts.LineFeed, unicode_consts.LineFeed},
+			Range{unicode_consts.CarriageReturn, unicode_consts.CarriageReturn},
+			Range{unicode_consts.LineSeparator, unicode_consts.ParagraphSeparator},
+		)), true
+	default:
+		return Set{}, false
+	}
+	if flags.IgnoreCase {
+		s = CaseClosure(s, u)
+	}
+	return s.Intersect(universe), true
+}
+
+// characterSet returns the characters of an escape like `\d` or a property
+// like `\p{L}` before the case closure of `i`, so that a negated one is the
+// complement within universe of the characters that it doesn't negate.
+func characterSet(node regexp_ast.Node, flags regexp_ast.Flags, universe Set) (Set, bool) {
+	var s Set
+	var negate bool
+	switch n := node.(type) {
+	case *regexp_ast.EscapeCharacterSet:
+		s = escapeSet(n.Kind, flags.IgnoreCase && (flags.Unicode || flags.UnicodeSets))
+		negate = n.Negate
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		var ok bool
+		if s, ok = propertySet(n); !ok {
+			return Set{}, false
+		}
+		negate = n.Negate
+	default:
+		return Set{}, false
+	}
+	if negate {
+		return universe.Difference(s), true
+	}
+	return s, true
+}
+
+// escapeSet returns the characters of `\d`, `\s` or `\w`. With fold, that is
+// with both `i` and `u`, `\w` has ſ and the Kelvin sign as well, since they
+// fold into `s` and `k`.
+func escapeSet(kind regexp_ast.EscapeKind, fold bool) Set {
+	switch kind {
+	case regexp_ast.EscapeDigit:
+		return New(Range{'0', '9'})
+	case regexp_ast.EscapeSpace:
+		return spaces()
+	default:
+		s := New(Range{'0', '9'}, Range{'A', 'Z'}, Range{'_', '_'}, Range{'a', 'z'})
+		if fold {
+			s = s.Union(Of(0x017f, 0x212a))
+		}
+		return s
+	}
+}
+
+var (
+	spacesOnce sync.Once
+	spacesSet  Set
+)
+
+// spaces returns the WhiteSpace and LineTerminator characters that `\s`
+// matches.
+func spaces() Set {
+	spacesOnce.Do(func() {
+		spacesSet = FromRangeTable(unicode.Zs).Union(New(
+			Range{unicode_consts.CharacterTabulation, unicode_consts.CarriageReturn},
+			Range{unicode_consts.LineSeparator, unicode_consts.ParagraphSeparator},
+			Range{0xfeff, 0xfeff},
+		))
+	})
+	return spacesSet
+}
+
+// propertySet returns the characters of n regardless of Negate, and false if
+// it is a property of strings or Go has no table of it.
+func propertySet(n *regexp_ast.UnicodePropertyCharacterSet) (Set, bool) {
+	if n.Strings {
+		return Set{}, false
+	}
+	tables, ok := unicode_consts.PropertyTables(n.Key, n.Value)
+	if !ok {
+		return Set{}, false
+	}
+	var s Set
+	for _, t := range tables {
+		s = s.Union(FromRangeTable(t))
+	}
+	return s, true
+}
+
+// ClassSetOf returns what node matches under flags as a class set, and
+// whether node is a part of a class or matches a single character. The
+// nodes that only exist in a class with `v`, nested classes, set operations
+// and `\q{…}`, are evaluated into strings as well as characters.
+//
+// With `i`, the strings are in simple case folded form, and a string matches
+// every string whose characters fold to the same ones.
+func ClassSetOf(node regexp_ast.Node, flags regexp_ast.Flags) (ClassSet, bool) {
+	if !isClassSetNode(node) {
+		s, ok := OfNode(node, flags)
+		return ClassSet{Chars: s}, ok
+	}
+	c, ok := classSet(node, flags.IgnoreCase)
+	if !ok {
+		return ClassSet{}, false
+	}
+	if flags.IgnoreCase {
+		c.Chars = CaseClosure(c.Chars, true)
+	}
+	return c, true
+}
+
+// isClassSetNode returns whether node is evaluated as a class set, that is,
+// it is in a class with `v` or is one.
+func isClassSetNode(node regexp_ast.Node) bool {
+	switch n := node.(type) {
+	case *regexp_ast.CharacterClass:
+		return n.UnicodeSets
+	case *regexp_ast.ExpressionCharacterClass, *regexp_ast.ClassIntersection, *regexp_ast.ClassSubtraction,
+		*regexp_ast.ClassStringDisjunction, *regexp_ast.StringAlternative:
+		return true
+	case *regexp_ast.Character:
+		return isClassSetNode(n.Parent)
+	case *regexp_ast.CharacterClassRange:
+		return isClassSetNode(n.Parent)
+	case *regexp_ast.EscapeCharacterSet:
+		return isClassSetNode(n.Parent)
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		return isClassSetNode(n.Parent)
+	default:
+		return false
+	}
+}
+
+// classSet evaluates node like the ECMAScript CompileToCharSet. With fold,
+// every character and string is simple case folded and a complement is
+// within the folded characters, so that `[^\q{a}]` with `iv` excludes `A`.
+func classSet(node regexp_ast.Node, fold bool) (ClassSet, bool) {
+	switch n := node.(type) {
+	case *regexp_ast.Character:
+		return ClassSet{Chars: foldSet(Of(n.Value), fold)}, true
+	case *regexp_ast.CharacterClassRange:
+		return ClassSet{Chars: foldSet(New(Range{n.Min.Value, n.Max.Value}), fold)}, true
+	case *regexp_ast.EscapeCharacterSet:
+		return complement(ClassSet{Chars: foldSet(escapeSet(n.Kind, fold), fold)}, n.Negate, fold)
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		s, ok := propertySet(n)
+		if !ok {
+			return ClassSet{}, false
+		}
+		return complement(ClassSet{Chars: foldSet(s, fold)}, n.Negate, fold)
+	case *regexp_ast.CharacterClass:
+		var c ClassSet
+		for _, el := range n.Elements {
+			e, ok := classSet(el.(regexp_ast.Node), fold)
+			if !ok {
+				return ClassSet{}, false
+			}
+			c = c.Union(e)
+		}
+		return complement(c, n.Negate, fold)
+	case *regexp_ast.ExpressionCharacterClass:
+		c, ok := classSet(n.Expression.(regexp_ast.Node), fold)
+		if !ok {
+			return ClassSet{}, false
+		}
+		return complement(c, n.Negate, fold)
+	case *regexp_ast.ClassIntersection:
+		l, r, ok := operands(n.Left, n.Right, fold)
+		return l.Intersect(r), ok
+	case *regexp_ast.ClassSubtraction:
+		l, r, ok := operands(n.Left, n.Right, fold)
+		return l.Difference(r), ok
+	case *regexp_ast.ClassStringDisjunction:
+		var c ClassSet
+		for _, alt := range n.Alternatives {
+			e, _ := classSet(alt, fold)
+			c = c.Union(e)
+		}
+		return c, true
+	case *regexp_ast.StringAlternative:
+		var sb strings.Builder
+		for _, el := range n.Elements {
+			cp := el.Value
+			if fold {
+				cp = case_folding.Canonicalize(cp, true)
+			}
+			sb.WriteRune(rune(cp))
+		}
+		return NewClassSet(Set{}, sb.String()), true
+	default:
+		return ClassSet{}, false
+	}
+}
+
+func operands(left, right regexp_ast.ClassSetOperand, fold bool) (ClassSet, ClassSet, bool) {
+	l, ok1 := classSet(left.(regexp_ast.Node), fold)
+	r, ok2 := classSet(right.(regexp_ast.Node), fold)
+	return l, r, ok1 && ok2
+}
+
+// complement returns c, or its complement within the folded characters if
+// fold is true, when negate is true.
+func complement(c ClassSet, negate bool, fold bool) (ClassSet, bool) {
+	if !negate {
+		return c, true
+	}
+	c, err := c.Complement()
+	if err != nil {
+		return ClassSet{}, false
+	}
+	if fold {
+		c.Chars = c.Chars.Difference(unfolded())
+	}
+	return c, true
+}
+
+// foldSet returns the simple case folding of the characters of s if fold is
+// true, and s otherwise.
+func foldSet(s Set, fold bool) Set {
+	if !fold {
+		return s
+	}
+	changed := s.Intersect(unfolded())
+	if changed.IsEmpty() {
+		return s
+	}
+	var cps []int
+	changed.Each(func(cp int) bool {
+		cps = append(cps, case_folding.Canonicalize(cp, true))
+		return true
+	})
+	return s.Difference(changed).Union(Of(cps...))
+}
+
+var (
+	unfoldedOnce sync.Once
+	unfoldedSet  Set
+)
+
+// unfolded returns the characters that simple case folding changes.
+func unfolded() Set {
+	unfoldedOnce.Do(func() {
+		var cps []int
+		for cp := 0; cp <= unicode_consts.MaxCodePoint; cp++ {
+			if case_folding.Canonicalize(cp, true) != cp {
+				cps = append(cps, cp)
+			}
+		}
+		unfoldedSet = Of(cps...)
+	})
+	return unfoldedSet
+}
+
+// CaseClosure returns s with the case variants of its characters under the
+// `i` flag, by the simple case folding if unicode is true and by the legacy
+// uppercase rules otherwise.
+func CaseClosure(s Set, unicode bool) Set {
+	rs := s.Ranges()
+	s.Intersect(cased(unicode)).Each(func(cp int) bool {
+		for _, v := range case_folding.Variants(cp, unicode) {
+			if v != cp {
+				rs = append(rs, Range{v, v})
+			}
+		}
+		return true
+	})
+	return New(rs...)
+}
+
+var (
+	casedOnce [2]sync.Once
+	casedSets [2]Set
+)
+
+// cased returns the characters that have a case variant other than
+// themselves, so that a closure only looks at those.
+func cased(unicode bool) Set {
+	i := 0
+	if unicode {
+		i = 1
+	}
+	casedOnce[i].Do(func() {
+		var cps []int
+		for cp := 0; cp <= unicode_consts.MaxCodePoint; cp++ {
+			if len(case_folding.Variants(cp, unicode)) > 1 {
+				cps = append(cps, cp)
+			}
+		}
+		casedSets[i] = Of(cps...)
+	})
+	return casedSets[i]
+}
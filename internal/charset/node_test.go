@@ -0,0 +1,195 @@
+package charset_test
+
+import (
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/case_folding"
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func TestOfNode(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputFlags string
+		wantOutput string
+	}{
+		{name: "文字", inputS: "a", inputFlags: "", wantOutput: "[U+0061]"},
+		{name: "i の文字は大文字と小文字", inputS: "a", inputFlags: "i", wantOutput: "[U+0041 U+0061]"},
+		{name: "u と i の s は ſ を含む", inputS: "s", inputFlags: "iu", wantOutput: "[U+0053 U+0073 U+017F]"},
+		{name: "u のない i の s は ſ を含まない", inputS: "s", inputFlags: "i", wantOutput: "[U+0053 U+0073]"},
+		{name: "u のない i の k はケルビン記号を含まない", inputS: "k", inputFlags: "i", wantOutput: "[U+004B U+006B]"},
+		{name: "v は u と同じ", inputS: "k", inputFlags: "iv", wantOutput: "[U+004B U+006B U+212A]"},
+		{name: "文字クラス", inputS: "[a-cx]", inputFlags: "", wantOutput: "[U+0061-U+0063 U+0078]"},
+		{name: "否定の文字クラスは u がなければコード単位", inputS: "[^a]", inputFlags: "", wantOutput: "[U+0000-U+0060 U+0062-U+FFFF]"},
+		{name: "否定の文字クラスは u があればコードポイント", inputS: "[^a]", inputFlags: "u", wantOutput: "[U+0000-U+0060 U+0062-U+10FFFF]"},
+		{name: "i の否定の文字クラスは大文字も除く", inputS: "[^a]", inputFlags: "i", wantOutput: "[U+0000-U+0040 U+0042-U+0060 U+0062-U+FFFF]"},
+		{name: "ドット", inputS: ".", inputFlags: "", wantOutput: "[U+0000-U+0009 U+000B-U+000C U+000E-U+2027 U+202A-U+FFFF]"},
+		{name: "s のドット", inputS: ".", inputFlags: "su", wantOutput: "[U+0000-U+10FFFF]"},
+		{name: "数字のエスケープ", inputS: `\d`, inputFlags: "", wantOutput: "[U+0030-U+0039]"},
+		{name: "u と i の \\w は ſ とケルビン記号を含む", inputS: `\w`, inputFlags: "iu", wantOutput: "[U+0030-U+0039 U+0041-U+005A U+005F U+0061-U+007A U+017F U+212A]"},
+		{name: "u と i の \\W は ſ とケルビン記号を除く", inputS: `[\W]`, inputFlags: "iu", wantOutput: "[U+0000-U+002F U+003A-U+0040 U+005B-U+005E U+0060 U+007B-U+017E U+0180-U+2129 U+212B-U+10FFFF]"},
+		{name: "否定の文字クラスの空白文字", inputS: `[^\S]`, inputFlags: "", wantOutput: "[U+0009-U+000D U+0020 U+00A0 U+1680 U+2000-U+200A U+2028-U+2029 U+202F U+205F U+3000 U+FEFF]"},
+		{name: "プロパティ", inputS: `\p{sc=Hira}`, inputFlags: "u", wantOutput: "[U+3041-U+3096 U+309D-U+309F U+1B001-U+1B11F U+1B132 U+1B150-U+1B152 U+1F200]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := parser.ParseFlags(tt.inputFlags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := parser.NewParser(tt.inputS, flags.Unicode || flags.UnicodeSets)
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, ok := charset.OfNode(pattern.Alternatives[0].Elements[0].(regexp_ast.Node), flags)
+			if !ok {
+				t.Fatalf("%s doesn't match a single character", tt.inputS)
+			}
+			if o := s.String(); o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestOfNodeRange(t *testing.T) {
+	p := parser.NewParser("[a-c]", false)
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass).Elements[0].(regexp_ast.Node)
+	s, ok := charset.OfNode(r, regexp_ast.Flags{IgnoreCase: true})
+	if want := "[U+0041-U+0043 U+0061-U+0063]"; !ok || s.String() != want {
+		t.Errorf("Unexpected output, expected %s, actual %s", want, s)
+	}
+}
+
+func TestOfNodeNotCharacter(t *testing.T) {
+	p := parser.NewParser("a+", false)
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []regexp_ast.Node{pattern, pattern.Alternatives[0], pattern.Alternatives[0].Elements[0].(regexp_ast.Node)} {
+		if _, ok := charset.OfNode(n, regexp_ast.Flags{}); ok {
+			t.Errorf("%T matches a single character", n)
+		}
+	}
+}
+
+func TestClassSetOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputFlags string
+		wantOutput string
+	}{
+		{name: "文字列の選言", inputS: `[\q{abc|d|}x]`, inputFlags: "v", wantOutput: `[U+0064 U+0078 "" "abc"]`},
+		{name: "積", inputS: `[[a-z]&&[^aeiou]&&[a-d]]`, inputFlags: "v", wantOutput: "[U+0062-U+0064]"},
+		{name: "差は文字列も除く", inputS: `[\q{ab|cd|e}--\q{cd}--e]`, inputFlags: "v", wantOutput: `["ab"]`},
+		{name: "入れ子の否定クラス", inputS: `[[^\q{a}]&&[a-c]]`, inputFlags: "v", wantOutput: "[U+0062-U+0063]"},
+		{name: "i の文字列は畳み込む", inputS: `[\q{AB}]`, inputFlags: "iv", wantOutput: `["ab"]`},
+		{name: "i の積は畳み込んだ文字で計算する", inputS: `[A&&a]`, inputFlags: "iv", wantOutput: "[U+0041 U+0061]"},
+		{name: "エスケープとの差", inputS: `[\w--[a-z_]]`, inputFlags: "v", wantOutput: "[U+0030-U+0039 U+0041-U+005A]"},
+		{name: "v と i の否定のプロパティは畳み込んだ文字で補集合をとる", inputS: `[\P{Ll}&&[a-cA]]`, inputFlags: "iv", wantOutput: "[]"},
+		{name: "i の否定クラスは大文字も除く", inputS: `[^[a-z]--b]`, inputFlags: "iv", wantOutput: "[U+0000-U+0040 U+0042 U+005B-U+0060 U+0062 U+007B-U+017E U+0180-U+2129 U+212B-U+10FFFF]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := parser.ParseFlags(tt.inputFlags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := parser.NewParserWithOptions(tt.inputS, true, parser.Options{UnicodeSets: flags.UnicodeSets})
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, ok := charset.ClassSetOf(pattern.Alternatives[0].Elements[0].(regexp_ast.Node), flags)
+			if !ok {
+				t.Fatalf("%s is not a class", tt.inputS)
+			}
+			if o := c.String(); o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestOfNodeStrings(t *testing.T) {
+	p := parser.NewParserWithOptions(`[\q{ab}c][\q{a}c]`, true, parser.Options{UnicodeSets: true})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := regexp_ast.Flags{UnicodeSets: true}
+	if _, ok := charset.OfNode(pattern.Alternatives[0].Elements[0].(regexp_ast.Node), flags); ok {
+		t.Error("A class with a string matches a single character")
+	}
+	s, ok := charset.OfNode(pattern.Alternatives[0].Elements[1].(regexp_ast.Node), flags)
+	if want := "[U+0061 U+0063]"; !ok || s.String() != want {
+		t.Errorf("Unexpected output, expected %s, actual %s", want, s)
+	}
+}
+
+func TestOfNodeNegatedProperty(t *testing.T) {
+	p := parser.NewParser(`\P{Ll}`, true)
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A lowercase letter with an uppercase one outside of Ll matches
+	s, ok := charset.OfNode(pattern.Alternatives[0].Elements[0].(regexp_ast.Node), regexp_ast.Flags{IgnoreCase: true, Unicode: true})
+	if !ok || !s.Contains('a') || s.Contains('ĸ') {
+		t.Errorf("Unexpected output %s", s)
+	}
+}
+
+func TestOfNodeNoTable(t *testing.T) {
+	p := parser.NewParserWithOptions(`\p{Emoji}[\p{RGI_Emoji}--\q{x}]`, true, parser.Options{UnicodeSets: true})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, el := range pattern.Alternatives[0].Elements {
+		if _, ok := charset.ClassSetOf(el.(regexp_ast.Node), regexp_ast.Flags{UnicodeSets: true}); ok {
+			t.Errorf("%T has a set", el)
+		}
+	}
+}
+
+// CaseClosure only looks at the cased characters, and must add the same
+// variants as looking at every character.
+func TestCaseClosure(t *testing.T) {
+	for _, s := range []charset.Set{
+		charset.New(charset.Range{Min: 0, Max: 0x7f}),
+		charset.New(charset.Range{Min: 0x100, Max: 0x2fff}, charset.Range{Min: 0x10400, Max: 0x1e943}),
+		charset.Of('k', 0x17f, 0x1c80, 0xfb00),
+		charset.All(),
+	} {
+		for _, unicode := range []bool{false, true} {
+			want := s
+			s.Each(func(cp int) bool {
+				want = want.Union(charset.Of(case_folding.Variants(cp, unicode)...))
+				return true
+			})
+			if o := charset.CaseClosure(s, unicode); !o.Equal(want) {
+				t.Errorf("Unexpected closure of %s, expected %s, actual %s", s, want, o)
+			}
+		}
+	}
+}
+
+func BenchmarkCaseClosure(b *testing.B) {
+	s := charset.New(charset.Range{Min: 0, Max: 0x10ffff})
+	for i := 0; i < b.N; i++ {
+		charset.CaseClosure(s, true)
+	}
+}
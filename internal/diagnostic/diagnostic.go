@@ -0,0 +1,52 @@
+// Package diagnostic reports the constructs of a pattern that a translator
+// can't express in its target, pointing at the nodes that contain them.
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+type Diagnostic struct {
+	Loc     regexp_ast.Loc
+	Message string
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%d-%d: %s", d.Loc.Start, d.Loc.End, d.Message)
+}
+
+// List is the error returned by a translator that found at least one
+// diagnostic. errors.As finds each Diagnostic in it.
+type List []*Diagnostic
+
+func (l List) Error() string {
+	msgs := make([]string, len(l))
+	for i, d := range l {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l List) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, d := range l {
+		errs[i] = d
+	}
+	return errs
+}
+
+// Add appends a diagnostic at the Loc of node.
+func (l *List) Add(node regexp_ast.Node, format string, args ...any) {
+	*l = append(*l, &Diagnostic{Loc: node.GetLoc(), Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns l as an error, or nil if it is empty.
+func (l List) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
@@ -0,0 +1,863 @@
+// Package dialect translates ECMAScript patterns into the syntax of other
+// regular expression engines, keeping the ECMAScript semantics:
+//
+//   - `.` doesn't match line terminators (\n, \r, U+2028 and U+2029) unless
+//     the `s` flag is set,
+//   - `i` follows the ECMAScript Canonicalize, either with the inline flag of
+//     the dialect where its case folding is the same or by expanding every
+//     character into its case variants,
+//   - `[]` matches nothing and `[^]` matches any character,
+//   - a surrogate pair written as two `\uXXXX` escapes without `u` matches
+//     one code point.
+//
+// Every target matches code points, so without `u`, `.` and negated classes
+// match whole code points where ECMAScript matches UTF-16 code units. This
+// only differs for characters outside the BMP.
+//
+// Where a dialect only tells whether a pattern matches, as in databases and
+// search engines, the output keeps which strings match but not which part of
+// them: Lucene patterns are wrapped to match anywhere in a term, and lazy
+// quantifiers are written as greedy ones where they aren't supported.
+//
+// Escapes like `\d` and `\s` and properties like `\p{L}` are written as
+// classes of their characters, since the targets differ in what their
+// escapes match and in their Unicode versions. A class with `v` matches its
+// strings as alternatives, longest first.
+//
+// `^` and `$` are written as the assertions of the start and the end of the
+// input, and with `m` as lookarounds on the ECMAScript line terminators.
+// `\b` and `\B` are written as lookarounds on the ECMAScript word characters
+// unless the dialect's own match them. A named group is written with the
+// named-group syntax of the dialect, or as a numbered group where there is
+// none, and every backreference refers to its group by number. A
+// backreference to a group that may not have matched yet is written as a
+// conditional, since ECMAScript matches the empty string there while the
+// targets fail.
+//
+// The flags g, d and y concern the RegExp API rather than the pattern and are
+// ignored. What a dialect can't express, like a lookbehind of a length it
+// doesn't allow, is reported.
+package dialect
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/case_folding"
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/source_map"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// Dialect describes the syntax and the semantic gaps of a target engine.
+type Dialect struct {
+	Name string
+
+	// Written at the start and at the end of every pattern
+	prefix string
+	suffix string
+	// The inline flag whose case folding is the simple case folding of
+	// the `u` flag, or "" to expand characters into their case variants
+	foldCase string
+	// The characters to escape with a backslash outside of and inside
+	// classes
+	meta      string
+	classMeta string
+	// escape returns the escape sequence of a character that can't be
+	// written literally, or is nil if every character is written literally
+	escape func(cp int) string
+	// Classes matching any character and no character, or "" if the
+	// dialect can't match no character
+	anyCharacter string
+	noCharacter  string
+	// The largest repeat count, or 0 if there is no limit
+	maxRepeat int
+	// Whether lazy quantifiers are supported. Without them the dialect
+	// must only tell whether a pattern matches, where laziness doesn't
+	// matter, so they are written as greedy quantifiers.
+	lazy bool
+	// Whether lone surrogates can be matched
+	loneSurrogates bool
+	// The opening of a group, or "" for `(?:`
+	group string
+	// The opening of a named group with %s for the name, or "" to write
+	// named groups as numbered ones
+	namedGroup string
+	// groupName returns whether the dialect accepts a group name, or is
+	// nil if it accepts every ECMAScript one
+	groupName func(name string) bool
+	// The backreference to the group %d, or "" if there are none
+	backreference string
+	// The backreference to the group %d if it has matched, and the empty
+	// string otherwise, or "" if there are no conditionals
+	conditionalBackreference string
+	// Whether lookaheads are supported
+	lookahead bool
+	// The lookbehinds that are supported
+	lookbehind lookbehind
+	// Whether groups in lookarounds don't capture, so that backreferences
+	// can't refer to them
+	nonCapturingLookarounds bool
+	// The assertions of the start and the end of the input, or "" if there
+	// are none
+	start string
+	end   string
+	// Whether `\b` and `\B` see the ASCII word characters only, like the
+	// ECMAScript ones without `i` and `u`
+	asciiWordBoundary bool
+}
+
+// lookbehind is how long a lookbehind of a dialect may be.
+type lookbehind int
+
+const (
+	noLookbehind lookbehind = iota
+	// Every alternative of a lookbehind has a fixed length, which may
+	// differ between alternatives
+	fixedAlternativesLookbehind
+	// The whole lookbehind has a fixed length
+	fixedLookbehind
+	// The lookbehind has a maximum length
+	boundedLookbehind
+	// The lookbehind may have any length
+	anyLookbehind
+)
+
+// Emit returns the source of pattern in the dialect. If a part of pattern
+// can't be expressed, the error is a diagnostic.List with one Diagnostic per
+// unsupported node, and the returned string is empty.
+func (d *Dialect) Emit(pattern *regexp_ast.Pattern, flags regexp_ast.Flags) (string, error) {
+	return d.emit(pattern, flags, nil)
+}
+
+// EmitWithMap is like Emit, and also returns the Loc of the node behind each
+// part of the output, e.g. to report an error that the engine reports in the
+// output at the original pattern.
+func (d *Dialect) EmitWithMap(pattern *regexp_ast.Pattern, flags regexp_ast.Flags) (string, *source_map.Map, error) {
+	sm := &source_map.Map{}
+	s, err := d.emit(pattern, flags, sm)
+	if err != nil {
+		return "", nil, err
+	}
+	return s, sm, nil
+}
+
+func (d *Dialect) emit(pattern *regexp_ast.Pattern, flags regexp_ast.Flags, sm *source_map.Map) (string, error) {
+	e := &emitter{d: d, flags: flags, sm: sm, groups: map[*regexp_ast.CapturingGroup]int{}}
+	regexp_ast.Inspect(pattern, func(n regexp_ast.Node) bool {
+		switch n := n.(type) {
+		case *regexp_ast.CapturingGroup:
+			e.groups[n] = len(e.groups) + 1
+		case *regexp_ast.LookaroundAssertion:
+			return !d.nonCapturingLookarounds
+		}
+		return true
+	})
+	e.sb.WriteString(d.prefix)
+	if e.foldCase() {
+		e.sb.WriteString(d.foldCase)
+	}
+	e.pattern(pattern)
+	e.sb.WriteString(d.suffix)
+	if err := e.diags.Err(); err != nil {
+		return "", err
+	}
+	return e.sb.String(), nil
+}
+
+type emitter struct {
+	d     *Dialect
+	flags regexp_ast.Flags
+	sb    strings.Builder
+	diags diagnostic.List
+	// The map of the output, or nil
+	sm *source_map.Map
+	// The number of every group that captures in the dialect
+	groups map[*regexp_ast.CapturingGroup]int
+}
+
+// record maps the output written since start to loc.
+func (e *emitter) record(loc regexp_ast.Loc, start int) {
+	e.sm.Add(start, e.sb.Len(), loc)
+}
+
+func (e *emitter) unicode() bool {
+	return e.flags.Unicode || e.flags.UnicodeSets
+}
+
+// foldCase returns whether the inline flag of the dialect has the semantics
+// of the `i` flag.
+func (e *emitter) foldCase() bool {
+	return e.flags.IgnoreCase && e.unicode() && e.d.foldCase != ""
+}
+
+// expandCase returns whether characters have to be expanded into their case
+// variants.
+func (e *emitter) expandCase() bool {
+	return e.flags.IgnoreCase && !e.foldCase()
+}
+
+func (e *emitter) pattern(pattern *regexp_ast.Pattern) {
+	defer e.record(pattern.Loc, e.sb.Len())
+	e.alternatives(pattern.Alternatives)
+}
+
+func (e *emitter) alternatives(alts []*regexp_ast.Alternative) {
+	for i, alt := range alts {
+		if i > 0 {
+			e.sb.WriteByte('|')
+		}
+		e.alternative(alt)
+	}
+}
+
+func (e *emitter) alternative(alt *regexp_ast.Alternative) {
+	defer e.record(alt.Loc, e.sb.Len())
+	for i := 0; i < len(alt.Elements); i++ {
+		switch lead, trail := regexp_ast.SurrogatePairAt(alt.Elements, i); {
+		case trail != nil:
+			start := e.sb.Len()
+			e.character(lead, unicode_consts.CombineSurrogatePair(lead.Value, trail.Value))
+			e.record(regexp_ast.Loc{Start: lead.Loc.Start, End: trail.Loc.End}, start)
+			i++
+		case lead != nil:
+			e.diags.Add(alt.Elements[i+1].(regexp_ast.Node), "%s can't quantify the second half of a surrogate pair", e.d.Name)
+			i++
+		default:
+			e.element(alt.Elements[i])
+		}
+	}
+}
+
+func (e *emitter) element(el regexp_ast.Element) {
+	defer e.record(el.(regexp_ast.Node).GetLoc(), e.sb.Len())
+	switch n := el.(type) {
+	case *regexp_ast.Character:
+		e.character(n, n.Value)
+	case *regexp_ast.CharacterClass:
+		if n.UnicodeSets {
+			e.unicodeSetsClass(n)
+		} else {
+			e.characterClass(n)
+		}
+	case *regexp_ast.ExpressionCharacterClass:
+		e.unicodeSetsClass(n)
+	case *regexp_ast.EscapeCharacterSet:
+		e.characterSet(n)
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		e.characterSet(n)
+	case *regexp_ast.AnyCharacterSet:
+		if e.flags.DotAll {
+			e.sb.WriteString(e.d.anyCharacter)
+		} else {
+			e.class(true, lineTerminators)
+		}
+	case *regexp_ast.Quantifier:
+		e.quantifier(n)
+	case *regexp_ast.Group:
+		e.group()
+		e.alternatives(n.Alternatives)
+		e.sb.WriteByte(')')
+	case *regexp_ast.CapturingGroup:
+		e.capturingGroup(n)
+	case *regexp_ast.LookaroundAssertion:
+		e.lookaround(n)
+	case *regexp_ast.EdgeAssertion:
+		e.edge(n)
+	case *regexp_ast.WordBoundaryAssertion:
+		e.wordBoundary(n)
+	case *regexp_ast.Backreference:
+		e.backreference(n)
+	default:
+		e.diags.Add(el.(regexp_ast.Node), "%T is not supported by %s", el, e.d.Name)
+	}
+}
+
+func (e *emitter) group() {
+	if e.d.group == "" {
+		e.sb.WriteString("(?:")
+	} else {
+		e.sb.WriteString(e.d.group)
+	}
+}
+
+func (e *emitter) capturingGroup(g *regexp_ast.CapturingGroup) {
+	switch {
+	case e.groups[g] == 0:
+		// A group in a lookaround that doesn't capture
+		e.group()
+	case g.Name == "" || e.d.namedGroup == "":
+		e.sb.WriteByte('(')
+	case e.d.groupName != nil && !e.d.groupName(g.Name):
+		e.diags.Add(g, "%s doesn't accept the group name %s", e.d.Name, g.Name)
+	default:
+		fmt.Fprintf(&e.sb, e.d.namedGroup, g.Name)
+	}
+	e.alternatives(g.Alternatives)
+	e.sb.WriteByte(')')
+}
+
+func (e *emitter) lookaround(a *regexp_ast.LookaroundAssertion) {
+	if a.Kind == regexp_ast.Lookahead {
+		if !e.d.lookahead {
+			e.diags.Add(a, "%s doesn't support lookaheads", e.d.Name)
+			return
+		}
+		e.sb.WriteString(map[bool]string{false: "(?=", true: "(?!"}[a.Negate])
+	} else {
+		if !e.checkLookbehind(a) {
+			return
+		}
+		e.sb.WriteString(map[bool]string{false: "(?<=", true: "(?<!"}[a.Negate])
+	}
+	e.alternatives(a.Alternatives)
+	e.sb.WriteByte(')')
+}
+
+// checkLookbehind reports a and returns false if its length isn't one that
+// the dialect allows.
+func (e *emitter) checkLookbehind(a *regexp_ast.LookaroundAssertion) bool {
+	// Whether every alternative has a fixed length, and whether they
+	// all have the same one
+	fixedAlternatives, fixed, bounded := true, true, true
+	for i, alt := range a.Alternatives {
+		min, max := width(alt)
+		if min != max {
+			fixedAlternatives, fixed = false, false
+		}
+		if max < 0 {
+			bounded = false
+		}
+		if first, _ := width(a.Alternatives[0]); i > 0 && max != first {
+			fixed = false
+		}
+	}
+	switch {
+	case e.d.lookbehind == noLookbehind:
+		e.diags.Add(a, "%s doesn't support lookbehinds", e.d.Name)
+	case e.d.lookbehind == fixedAlternativesLookbehind && !fixedAlternatives:
+		e.diags.Add(a, "%s only supports lookbehinds whose alternatives have fixed lengths", e.d.Name)
+	case e.d.lookbehind == fixedLookbehind && !fixed:
+		e.diags.Add(a, "%s only supports lookbehinds of a fixed length", e.d.Name)
+	case e.d.lookbehind == boundedLookbehind && !bounded:
+		e.diags.Add(a, "%s only supports lookbehinds of a bounded length", e.d.Name)
+	default:
+		return true
+	}
+	return false
+}
+
+// width returns the minimum and the maximum number of characters that node
+// matches, where the maximum is -1 if there is none.
+func width(node regexp_ast.Node) (int, int) {
+	switch n := node.(type) {
+	case *regexp_ast.Alternative:
+		min, max := 0, 0
+		for _, el := range n.Elements {
+			elMin, elMax := width(el.(regexp_ast.Node))
+			min += elMin
+			if max >= 0 {
+				max += elMax
+			}
+			if elMax < 0 {
+				max = -1
+			}
+		}
+		return min, max
+	case *regexp_ast.Group:
+		return widthOf(n.Alternatives)
+	case *regexp_ast.CapturingGroup:
+		return widthOf(n.Alternatives)
+	case *regexp_ast.Quantifier:
+		min, max := width(n.Element.(regexp_ast.Node))
+		switch {
+		case max == 0 || n.Max == 0:
+			return min * n.Min, 0
+		case max < 0 || n.Max == math.MaxInt:
+			return min * n.Min, -1
+		}
+		return min * n.Min, max * n.Max
+	case *regexp_ast.LookaroundAssertion, *regexp_ast.EdgeAssertion, *regexp_ast.WordBoundaryAssertion:
+		return 0, 0
+	case *regexp_ast.Backreference:
+		return 0, -1
+	case *regexp_ast.CharacterClass, *regexp_ast.ExpressionCharacterClass:
+		c, ok := charset.ClassSetOf(n, regexp_ast.Flags{UnicodeSets: true})
+		if !ok || !c.MayContainStrings() {
+			return 1, 1
+		}
+		min, max := 1, 1
+		if c.Chars.IsEmpty() {
+			min = math.MaxInt
+		}
+		for _, s := range c.Strings() {
+			l := utf8.RuneCountInString(s)
+			if l < min {
+				min = l
+			}
+			if l > max {
+				max = l
+			}
+		}
+		return min, max
+	default:
+		return 1, 1
+	}
+}
+
+// widthOf returns the width of a disjunction of alts.
+func widthOf(alts []*regexp_ast.Alternative) (int, int) {
+	min, max := math.MaxInt, 0
+	for _, alt := range alts {
+		altMin, altMax := width(alt)
+		if altMin < min {
+			min = altMin
+		}
+		if altMax < 0 || max < 0 {
+			max = -1
+		} else if altMax > max {
+			max = altMax
+		}
+	}
+	return min, max
+}
+
+// The ECMAScript line terminators, which `.` doesn't match and `^` and `$`
+// see with `m`
+var lineTerminators = []interval{
+	{unicode_consts.LineFeed, unicode_consts.LineFeed},
+	{unicode_consts.CarriageReturn, unicode_consts.CarriageReturn},
+	{unicode_consts.LineSeparator, unicode_consts.ParagraphSeparator},
+}
+
+func (e *emitter) edge(a *regexp_ast.EdgeAssertion) {
+	switch {
+	case !e.flags.Multiline:
+		assertion := e.d.start
+		if a.Kind == regexp_ast.EdgeEnd {
+			assertion = e.d.end
+		}
+		if assertion == "" {
+			e.diags.Add(a, "%s has no assertion of the %s of the input", e.d.Name, a.Kind)
+			return
+		}
+		e.sb.WriteString(assertion)
+	case a.Kind == regexp_ast.EdgeStart && e.d.lookbehind != noLookbehind:
+		e.sb.WriteString("(?<!")
+		e.class(true, lineTerminators)
+		e.sb.WriteByte(')')
+	case a.Kind == regexp_ast.EdgeEnd && e.d.lookahead:
+		e.sb.WriteString("(?!")
+		e.class(true, lineTerminators)
+		e.sb.WriteByte(')')
+	default:
+		e.diags.Add(a, "%s can't match the %s of a line with `m`", e.d.Name, a.Kind)
+	}
+}
+
+func (e *emitter) wordBoundary(a *regexp_ast.WordBoundaryAssertion) {
+	if e.d.asciiWordBoundary && !(e.flags.IgnoreCase && e.unicode()) {
+		e.sb.WriteString(map[bool]string{false: `\b`, true: `\B`}[a.Negate])
+		return
+	}
+	if !e.d.lookahead || e.d.lookbehind == noLookbehind {
+		e.diags.Add(a, "%s can't match a word boundary like ECMAScript", e.d.Name)
+		return
+	}
+	// `\b` is (?<=\w)(?!\w)|(?<!\w)(?=\w), and `\B` is the other two
+	// combinations, with the word characters of `\w` under the flags
+	w, _ := charset.OfNode(&regexp_ast.EscapeCharacterSet{Kind: regexp_ast.EscapeWord}, e.setFlags())
+	word := e.intervalsOfSet(w)
+	after := map[bool]string{false: "(?!", true: "(?="}[a.Negate]
+	e.sb.WriteString("(?:(?<=")
+	e.class(false, word)
+	e.sb.WriteString(")" + after)
+	e.class(false, word)
+	e.sb.WriteString(")|(?<!")
+	e.class(false, word)
+	e.sb.WriteString(")" + map[bool]string{false: "(?=", true: "(?!"}[a.Negate])
+	e.class(false, word)
+	e.sb.WriteString("))")
+}
+
+func (e *emitter) backreference(b *regexp_ast.Backreference) {
+	if e.d.backreference == "" {
+		e.diags.Add(b, "%s doesn't support backreferences", e.d.Name)
+		return
+	}
+	if e.expandCase() {
+		e.diags.Add(b, "%s can't match a backreference case-insensitively like ECMAScript", e.d.Name)
+		return
+	}
+	n := e.groups[b.Resolved]
+	switch {
+	case n == 0:
+		e.diags.Add(b, "%s doesn't capture groups in lookarounds", e.d.Name)
+	case e.d.nonCapturingLookarounds && inLookaround(b):
+		e.diags.Add(b, "%s doesn't support backreferences in lookarounds", e.d.Name)
+	case hasMatched(b.Resolved, b):
+		fmt.Fprintf(&e.sb, e.d.backreference, n)
+	case e.d.conditionalBackreference != "":
+		fmt.Fprintf(&e.sb, e.d.conditionalBackreference, n)
+	default:
+		e.diags.Add(b, "%s fails a backreference to a group that hasn't matched, where ECMAScript matches the empty string", e.d.Name)
+	}
+}
+
+// hasMatched returns whether g has always matched when b is reached: g ends
+// before b, and neither an optional quantifier, an alternation nor a
+// lookaround between g and the alternative that has both of them can skip g.
+func hasMatched(g *regexp_ast.CapturingGroup, b *regexp_ast.Backreference) bool {
+	if g == nil || g.Loc.End > b.Loc.Start {
+		return false
+	}
+	ancestors := map[regexp_ast.Node]bool{}
+	for n := regexp_ast.Node(b); n != nil; n = n.GetParent() {
+		ancestors[n] = true
+	}
+	for n := g.GetParent(); n != nil; n = n.GetParent() {
+		if ancestors[n] {
+			_, ok := n.(*regexp_ast.Alternative)
+			return ok
+		}
+		switch n := n.(type) {
+		case *regexp_ast.Quantifier:
+			if n.Min == 0 {
+				return false
+			}
+		case *regexp_ast.Group:
+			if len(n.Alternatives) > 1 {
+				return false
+			}
+		case *regexp_ast.CapturingGroup:
+			if len(n.Alternatives) > 1 {
+				return false
+			}
+		case *regexp_ast.LookaroundAssertion:
+			return false
+		}
+	}
+	return false
+}
+
+func inLookaround(node regexp_ast.Node) bool {
+	for n := node.GetParent(); n != nil; n = n.GetParent() {
+		if _, ok := n.(*regexp_ast.LookaroundAssertion); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// setFlags returns the flags to get the characters of an escape, a property
+// or a class with `v` by charset. They are code points even without `u`,
+// like all the characters that the output matches, and have their case
+// variants with `i` and `u`, since the complement of a negated one depends
+// on them.
+func (e *emitter) setFlags() regexp_ast.Flags {
+	return regexp_ast.Flags{Unicode: true, IgnoreCase: e.flags.IgnoreCase && e.unicode(), UnicodeSets: e.flags.UnicodeSets}
+}
+
+// characterSet writes an escape or a property as a class of its characters.
+func (e *emitter) characterSet(node regexp_ast.Node) {
+	s, ok := charset.OfNode(node, e.setFlags())
+	if !ok {
+		e.diags.Add(node, "the characters of the property are unknown")
+		return
+	}
+	set := e.intervalsOfSet(s)
+	if e.expandCase() && !e.unicode() {
+		set = closure(set, false)
+	}
+	e.class(false, set)
+}
+
+// unicodeSetsClass writes a class with `v`, whose strings are alternatives
+// before its characters, longest first since the class matches the longest
+// one that it can.
+func (e *emitter) unicodeSetsClass(node regexp_ast.Node) {
+	c, ok := charset.ClassSetOf(node, e.setFlags())
+	if !ok {
+		e.diags.Add(node, "the characters of a property of the class are unknown")
+		return
+	}
+	regexp_ast.Inspect(node, func(n regexp_ast.Node) bool {
+		if s, ok := n.(*regexp_ast.StringAlternative); ok {
+			for _, el := range s.Elements {
+				if isSurrogate(el.Value) {
+					e.diags.Add(el, "%s can't match a lone surrogate in a string", e.d.Name)
+				}
+			}
+		}
+		return true
+	})
+	chars := e.intervalsOfSet(c.Chars)
+	strs := c.Strings()
+	if len(strs) == 0 {
+		if len(chars) == 0 && e.d.noCharacter == "" {
+			e.diags.Add(node, "%s can't match an empty class", e.d.Name)
+			return
+		}
+		e.class(false, chars)
+		return
+	}
+	sort.SliceStable(strs, func(i, j int) bool {
+		return utf8.RuneCountInString(strs[i]) > utf8.RuneCountInString(strs[j])
+	})
+	e.sb.WriteString("(?:")
+	n := 0
+	next := func() {
+		if n > 0 {
+			e.sb.WriteByte('|')
+		}
+		n++
+	}
+	for _, s := range strs {
+		if s != "" {
+			next()
+			for _, r := range s {
+				e.character(node, int(r))
+			}
+		}
+	}
+	if len(chars) > 0 {
+		next()
+		e.class(false, chars)
+	}
+	if strs[len(strs)-1] == "" {
+		next()
+	}
+	e.sb.WriteByte(')')
+}
+
+// intervalsOfSet returns the intervals of s, without surrogates unless the
+// dialect can match them.
+func (e *emitter) intervalsOfSet(s charset.Set) []interval {
+	var set []interval
+	for _, r := range s.Ranges() {
+		iv := interval{r.Min, r.Max}
+		if e.d.loneSurrogates {
+			set = append(set, iv)
+		} else {
+			set = append(set, withoutSurrogates(iv)...)
+		}
+	}
+	return set
+}
+
+func (e *emitter) quantifier(q *regexp_ast.Quantifier) {
+	if max := e.d.maxRepeat; max > 0 && (q.Min > max || (q.Max != math.MaxInt && q.Max > max)) {
+		e.diags.Add(q, "%s doesn't support repeat counts above %d", e.d.Name, max)
+		return
+	}
+	e.element(q.Element.(regexp_ast.Element))
+	switch {
+	case q.Min == 0 && q.Max == math.MaxInt:
+		e.sb.WriteByte('*')
+	case q.Min == 1 && q.Max == math.MaxInt:
+		e.sb.WriteByte('+')
+	case q.Min == 0 && q.Max == 1:
+		e.sb.WriteByte('?')
+	case q.Max == math.MaxInt:
+		fmt.Fprintf(&e.sb, "{%d,}", q.Min)
+	case q.Min == q.Max:
+		fmt.Fprintf(&e.sb, "{%d}", q.Min)
+	default:
+		fmt.Fprintf(&e.sb, "{%d,%d}", q.Min, q.Max)
+	}
+	if !q.Greety && e.d.lazy {
+		e.sb.WriteByte('?')
+	}
+}
+
+func (e *emitter) character(node regexp_ast.Node, cp int) {
+	if isSurrogate(cp) && !e.d.loneSurrogates {
+		e.diags.Add(node, "%s can't match a lone surrogate", e.d.Name)
+		return
+	}
+	if e.expandCase() {
+		if variants := case_folding.Variants(cp, e.unicode()); len(variants) > 1 {
+			e.class(false, intervalsOf(variants))
+			return
+		}
+	}
+	e.literal(cp, e.d.meta)
+}
+
+// characterClass writes cc. Without lone surrogates, surrogates are left out
+// of the class, which only fails a class that isn't negated and has an
+// element of surrogates alone.
+func (e *emitter) characterClass(cc *regexp_ast.CharacterClass) {
+	var set []interval
+	reported := false
+	for _, el := range cc.Elements {
+		switch n := el.(type) {
+		case *regexp_ast.Character:
+			if isSurrogate(n.Value) && !e.d.loneSurrogates {
+				if !cc.Negate {
+					e.diags.Add(n, "%s can't match a lone surrogate", e.d.Name)
+					reported = true
+				}
+				continue
+			}
+			set = append(set, interval{n.Value, n.Value})
+		case *regexp_ast.CharacterClassRange:
+			iv := interval{n.Min.Value, n.Max.Value}
+			if e.d.loneSurrogates {
+				set = append(set, iv)
+				continue
+			}
+			if isSurrogate(iv.min) && isSurrogate(iv.max) {
+				if !cc.Negate {
+					e.diags.Add(n, "%s can't match a range of surrogates", e.d.Name)
+					reported = true
+				}
+				continue
+			}
+			set = append(set, withoutSurrogates(iv)...)
+		case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+			s, ok := charset.OfNode(n.(regexp_ast.Node), e.setFlags())
+			if !ok {
+				e.diags.Add(n.(regexp_ast.Node), "the characters of the property are unknown")
+				reported = true
+				continue
+			}
+			set = append(set, e.intervalsOfSet(s)...)
+		default:
+			e.diags.Add(el.(regexp_ast.Node), "%T is not supported by %s", el, e.d.Name)
+			reported = true
+		}
+	}
+	if e.expandCase() {
+		set = closure(set, e.unicode())
+	} else {
+		set = normalize(set)
+	}
+	if len(set) == 0 && !cc.Negate && e.d.noCharacter == "" && !reported {
+		e.diags.Add(cc, "%s can't match an empty class", e.d.Name)
+		return
+	}
+	e.class(cc.Negate, set)
+}
+
+// class writes a class matching set, or its complement if negate.
+func (e *emitter) class(negate bool, set []interval) {
+	if len(set) == 0 {
+		if negate {
+			e.sb.WriteString(e.d.anyCharacter)
+		} else {
+			e.sb.WriteString(e.d.noCharacter)
+		}
+		return
+	}
+	e.sb.WriteByte('[')
+	if negate {
+		e.sb.WriteByte('^')
+	}
+	for _, iv := range set {
+		e.literal(iv.min, e.d.classMeta)
+		if iv.max == iv.min+1 {
+			e.literal(iv.max, e.d.classMeta)
+		} else if iv.max != iv.min {
+			e.sb.WriteByte('-')
+			e.literal(iv.max, e.d.classMeta)
+		}
+	}
+	e.sb.WriteByte(']')
+}
+
+func (e *emitter) literal(cp int, meta string) {
+	switch {
+	case cp < unicode.MaxASCII && strings.ContainsRune(meta, rune(cp)):
+		e.sb.WriteByte('\\')
+		e.sb.WriteRune(rune(cp))
+	case e.d.escape == nil:
+		e.sb.WriteRune(rune(cp))
+	case cp == '\t':
+		e.sb.WriteString(`\t`)
+	case cp == '\n':
+		e.sb.WriteString(`\n`)
+	case cp == '\f':
+		e.sb.WriteString(`\f`)
+	case cp == '\r':
+		e.sb.WriteString(`\r`)
+	case isSurrogate(cp) || !unicode.IsPrint(rune(cp)):
+		e.sb.WriteString(e.d.escape(cp))
+	default:
+		e.sb.WriteRune(rune(cp))
+	}
+}
+
+type interval struct {
+	min int
+	max int
+}
+
+func intervalsOf(cps []int) []interval {
+	set := make([]interval, len(cps))
+	for i, cp := range cps {
+		set[i] = interval{cp, cp}
+	}
+	return normalize(set)
+}
+
+// closure adds the case variants of every character in set.
+func closure(set []interval, unicode bool) []interval {
+	rs := make([]charset.Range, len(set))
+	for i, iv := range set {
+		rs[i] = charset.Range{Min: iv.min, Max: iv.max}
+	}
+	c := []interval{}
+	for _, r := range charset.CaseClosure(charset.New(rs...), unicode).Ranges() {
+		c = append(c, interval{r.Min, r.Max})
+	}
+	return c
+}
+
+// normalize sorts set and merges overlapping and adjacent intervals.
+func normalize(set []interval) []interval {
+	sort.Slice(set, func(i, j int) bool {
+		return set[i].min < set[j].min
+	})
+	merged := []interval{}
+	for _, iv := range set {
+		if last := len(merged) - 1; last >= 0 && iv.min <= merged[last].max+1 {
+			if iv.max > merged[last].max {
+				merged[last].max = iv.max
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// withoutSurrogates returns the parts of iv below and above the surrogates.
+func withoutSurrogates(iv interval) []interval {
+	var set []interval
+	if iv.min < unicode_consts.MinLeadSurrogate {
+		max := iv.max
+		if max >= unicode_consts.MinLeadSurrogate {
+			max = unicode_consts.MinLeadSurrogate - 1
+		}
+		set = append(set, interval{iv.min, max})
+	}
+	if iv.max > unicode_consts.MaxTrailSurrogate {
+		min := iv.min
+		if min <= unicode_consts.MaxTrailSurrogate {
+			min = unicode_consts.MaxTrailSurrogate + 1
+		}
+		set = append(set, interval{min, iv.max})
+	}
+	return set
+}
+
+func isSurrogate(cp int) bool {
+	return cp >= unicode_consts.MinLeadSurrogate && cp <= unicode_consts.MaxTrailSurrogate
+}
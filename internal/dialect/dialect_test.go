@@ -0,0 +1,603 @@
+package dialect_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/dialect"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func parse(t *testing.T, source string, flags string) (*regexp_ast.Pattern, regexp_ast.Flags) {
+	t.Helper()
+	f, err := parser.ParseFlags(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewParserWithOptions(source, f.Unicode, parser.Options{UnicodeSets: f.UnicodeSets})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pattern, f
+}
+
+func TestEmit(t *testing.T) {
+	tests := []struct {
+		name         string
+		inputS       string
+		inputFlags   string
+		inputDialect *dialect.Dialect
+		wantOutput   string
+	}{
+		{
+			name:         "PCRE2 の文字と量指定子",
+			inputS:       `a+b{2,3}?\.|c`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)a+b{2,3}?\.|c`,
+		},
+		{
+			name:         "PCRE2 のドット",
+			inputS:       `.`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)[^\n\r\x{2028}\x{2029}]`,
+		},
+		{
+			name:         "PCRE2 の s フラグのドットと空の文字クラス",
+			inputS:       `.[^][]`,
+			inputFlags:   "su",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)[\s\S][\s\S][^\s\S]`,
+		},
+		{
+			name:         "PCRE2 の u フラグの i はインラインフラグにする",
+			inputS:       `k[a-c]`,
+			inputFlags:   "iu",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)(?i)k[a-c]`,
+		},
+		{
+			name:         "PCRE2 の u フラグなしの i は展開する",
+			inputS:       `k`,
+			inputFlags:   "i",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)[Kk]`,
+		},
+		{
+			name:         "PCRE2 の \\v はエスケープで書く",
+			inputS:       `\v\t`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)\x{B}\t`,
+		},
+		{
+			name:         "Python の文字クラスの集合演算の記号をエスケープする",
+			inputS:       `[&&~~||]`,
+			inputFlags:   "u",
+			inputDialect: dialect.Python,
+			wantOutput:   `[\&\|\~]`,
+		},
+		{
+			name:         "Python の u フラグの i は展開する",
+			inputS:       `ks`,
+			inputFlags:   "iu",
+			inputDialect: dialect.Python,
+			wantOutput:   `[KkK][Ssſ]`,
+		},
+		{
+			name:         "Python の孤立したサロゲートと制御文字",
+			inputS:       `\ud800\0\u{10ffff}`,
+			inputFlags:   "u",
+			inputDialect: dialect.Python,
+			wantOutput:   `\ud800\x00\U0010ffff`,
+		},
+		{
+			name:         "Python の u フラグなしのサロゲートペア",
+			inputS:       `😀`,
+			inputFlags:   "",
+			inputDialect: dialect.Python,
+			wantOutput:   `😀`,
+		},
+		{
+			name:         "Java の文字クラスの && をエスケープする",
+			inputS:       `[a&&[b]`,
+			inputFlags:   "",
+			inputDialect: dialect.Java,
+			wantOutput:   `[\&\[ab]`,
+		},
+		{
+			name:         "Java の否定の文字クラスとドット",
+			inputS:       `[^a-z].`,
+			inputFlags:   "u",
+			inputDialect: dialect.Java,
+			wantOutput:   `[^a-z][^\n\r\x{2028}\x{2029}]`,
+		},
+		{
+			name:         "PostgreSQL のエスケープと遅延量指定子",
+			inputS:       `\0\u{1f600}*?.`,
+			inputFlags:   "u",
+			inputDialect: dialect.PostgreSQL,
+			wantOutput:   `\u0000😀*?[^\n\r\u2028\u2029]`,
+		},
+		{
+			name:         "PostgreSQL の任意の文字",
+			inputS:       `.[^]`,
+			inputFlags:   "s",
+			inputDialect: dialect.PostgreSQL,
+			wantOutput:   `..`,
+		},
+		{
+			name:         "PostgreSQL の u フラグの i は展開する",
+			inputS:       `k`,
+			inputFlags:   "iu",
+			inputDialect: dialect.PostgreSQL,
+			wantOutput:   `[KkK]`,
+		},
+		{
+			name:         "MySQL の文字クラスと空の文字クラス",
+			inputS:       `[-&a]+[]`,
+			inputFlags:   "u",
+			inputDialect: dialect.MySQL,
+			wantOutput:   `[\&\-a]+[^\s\S]`,
+		},
+		{
+			name:         "PCRE2 ではサロゲートを跨ぐ範囲からサロゲートを除く",
+			inputS:       `[\u0080-\uFFFF][^\u0080-\u{10FFFF}]`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)[\x{80}-\x{D7FF}\x{E000}-\x{FFFF}][^\x{80}-\x{D7FF}\x{E000}-\x{10FFFF}]`,
+		},
+		{
+			name:         "PCRE2 のエスケープは文字クラスで書く",
+			inputS:       `\d\w[^\D_]`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)[0-9][0-9A-Z_a-z][^\x{0}-/:-\x{D7FF}\x{E000}-\x{10FFFF}]`,
+		},
+		{
+			name:         "PCRE2 の iu フラグの \\w は ſ と K を含む",
+			inputS:       `\w`,
+			inputFlags:   "iu",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)(?i)[0-9A-Z_a-zſK]`,
+		},
+		{
+			name:         "Python の v フラグの文字列は長い順の選択にする",
+			inputS:       `[\q{a|bc|}d]`,
+			inputFlags:   "v",
+			inputDialect: dialect.Python,
+			wantOutput:   `(?:bc|[ad]|)`,
+		},
+		{
+			name:         "Java の v フラグの i は文字列の文字も展開する",
+			inputS:       `[\q{ab}--\q{c}]`,
+			inputFlags:   "iv",
+			inputDialect: dialect.Java,
+			wantOutput:   `(?:[Aa][Bb])`,
+		},
+		{
+			name:         "PCRE2 のグループと名前付きグループと後方参照",
+			inputS:       `(?:a)(?<x>b)(c)\k<x>\2`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)(?:a)(?<x>b)(c)\g{1}\g{2}`,
+		},
+		{
+			name:         "Python の名前付きグループと数字が続く後方参照",
+			inputS:       `(?<x>a)\1 0`,
+			inputFlags:   "",
+			inputDialect: dialect.Python,
+			wantOutput:   `(?P<x>a)(?:\1) 0`,
+		},
+		{
+			name:         "一致していないかもしれないグループへの後方参照は条件式にする",
+			inputS:       `(a)?\1|\2(b)|(c|d)\3`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)(a)?(?(1)\g{1})|(?(2)\g{2})(b)|(c|d)\g{3}`,
+		},
+		{
+			name:         "PCRE2 の先読みと後読み",
+			inputS:       `(?=a)(?!b)(?<=cd|e)(?<!f)`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)(?=a)(?!b)(?<=cd|e)(?<!f)`,
+		},
+		{
+			name:         "Java は長さに上限のある後読みを扱える",
+			inputS:       `(?<=a{1,3}|bc)`,
+			inputFlags:   "u",
+			inputDialect: dialect.Java,
+			wantOutput:   `(?<=a{1,3}|bc)`,
+		},
+		{
+			name:         "PCRE2 の ^ と $ と \\b",
+			inputS:       `^\ba\B$`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)^\ba\B\z`,
+		},
+		{
+			name:         "Python の m フラグの ^ と $ は行末文字の先読みと後読みにする",
+			inputS:       `^a$`,
+			inputFlags:   "m",
+			inputDialect: dialect.Python,
+			wantOutput:   `(?<![^\n\r\u2028\u2029])a(?![^\n\r\u2028\u2029])`,
+		},
+		{
+			name:         "Java の \\b は先読みと後読みにする",
+			inputS:       `\b`,
+			inputFlags:   "",
+			inputDialect: dialect.Java,
+			wantOutput:   `(?:(?<=[0-9A-Z_a-z])(?![0-9A-Z_a-z])|(?<![0-9A-Z_a-z])(?=[0-9A-Z_a-z]))`,
+		},
+		{
+			name:         "PCRE2 の iu フラグの \\B は ſ と K を単語文字とする",
+			inputS:       `\B`,
+			inputFlags:   "iu",
+			inputDialect: dialect.PCRE2,
+			wantOutput:   `(*UTF)(?i)(?:(?<=[0-9A-Z_a-zſK])(?=[0-9A-Z_a-zſK])|(?<![0-9A-Z_a-zſK])(?![0-9A-Z_a-zſK]))`,
+		},
+		{
+			name:         "MySQL ではサロゲートを跨ぐ範囲からサロゲートを除く",
+			inputS:       `[ -\u{10FFFF}]`,
+			inputFlags:   "u",
+			inputDialect: dialect.MySQL,
+			wantOutput:   `[ -\x{D7FF}\x{E000}-\x{10FFFF}]`,
+		},
+		{
+			name:         "PostgreSQL ではサロゲートを跨ぐ範囲からサロゲートを除く",
+			inputS:       `[ -\u{10FFFF}][^\ud800]`,
+			inputFlags:   "u",
+			inputDialect: dialect.PostgreSQL,
+			wantOutput:   `[ -\uD7FF\uE000-\U0010FFFF].`,
+		},
+		{
+			name:         "PostgreSQL の後方参照と後読みの中のグループ",
+			inputS:       `(a)(?<=(b)c*)\1$`,
+			inputFlags:   "u",
+			inputDialect: dialect.PostgreSQL,
+			wantOutput:   `(a)(?<=(?:b)c*)(?:\1)$`,
+		},
+		{
+			name:         "PostgreSQL の名前付きグループは番号付きにする",
+			inputS:       `^(?<x>a)\k<x>`,
+			inputFlags:   "u",
+			inputDialect: dialect.PostgreSQL,
+			wantOutput:   `^(a)(?:\1)`,
+		},
+		{
+			name:         "PostgreSQL の \\b と m フラグの ^",
+			inputS:       `^\b`,
+			inputFlags:   "m",
+			inputDialect: dialect.PostgreSQL,
+			wantOutput:   `(?<![^\n\r\u2028\u2029])(?:(?<=[0-9A-Z_a-z])(?![0-9A-Z_a-z])|(?<![0-9A-Z_a-z])(?=[0-9A-Z_a-z]))`,
+		},
+		{
+			name:         "MySQL の名前付きグループと後読みと $",
+			inputS:       `(?<x>a)(?<=b{1,2})\k<x>$`,
+			inputFlags:   "u",
+			inputDialect: dialect.MySQL,
+			wantOutput:   `(?<x>a)(?<=b{1,2})(?:\1)\z`,
+		},
+		{
+			name:         "MySQL の v フラグの文字クラス",
+			inputS:       `[\q{ab}\d]`,
+			inputFlags:   "v",
+			inputDialect: dialect.MySQL,
+			wantOutput:   `(?:ab|[0-9])`,
+		},
+		{
+			name:         "Lucene のグループとエスケープ",
+			inputS:       `(?:a|(b))\d`,
+			inputFlags:   "u",
+			inputDialect: dialect.Lucene,
+			wantOutput:   `.*((a|(b))[0-9]).*`,
+		},
+		{
+			name:         "Lucene は全体にマッチさせるため囲む",
+			inputS:       `a|b`,
+			inputFlags:   "",
+			inputDialect: dialect.Lucene,
+			wantOutput:   `.*(a|b).*`,
+		},
+		{
+			name:         "Lucene の演算子をエスケープし遅延量指定子を欲張りにする",
+			inputS:       `"@#&<>~\$+?`,
+			inputFlags:   "u",
+			inputDialect: dialect.Lucene,
+			wantOutput:   `.*(\"\@\#\&\<\>\~$+).*`,
+		},
+		{
+			name:         "Lucene の文字は文字どおりに書く",
+			inputS:       `\t[\n-\r]`,
+			inputFlags:   "u",
+			inputDialect: dialect.Lucene,
+			wantOutput:   ".*(\t[\n-\r]).*",
+		},
+		{
+			name:         "Lucene のドット",
+			inputS:       `.[^]`,
+			inputFlags:   "su",
+			inputDialect: dialect.Lucene,
+			wantOutput:   `.*(..).*`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			o, err := tt.inputDialect.Emit(pattern, flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestEmitError(t *testing.T) {
+	tests := []struct {
+		name         string
+		inputS       string
+		inputFlags   string
+		inputDialect *dialect.Dialect
+		wantLocs     []regexp_ast.Loc
+	}{
+		{
+			name:         "PCRE2 は孤立したサロゲートにマッチできない",
+			inputS:       `a\ud800[\udc00-\udfff]`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantLocs:     []regexp_ast.Loc{{Start: 1, End: 7}, {Start: 8, End: 21}},
+		},
+		{
+			name:         "PCRE2 は 65535 を超える繰り返し回数を扱えない",
+			inputS:       `a{65536}`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 8}},
+		},
+		{
+			name:         "サロゲートペアの後半への量指定子",
+			inputS:       `😀+`,
+			inputFlags:   "",
+			inputDialect: dialect.Java,
+			wantLocs:     []regexp_ast.Loc{{Start: 1, End: 3}},
+		},
+		{
+			name:         "Java は一致していないかもしれないグループへの後方参照を扱えない",
+			inputS:       `(a)?\1(b)\2`,
+			inputFlags:   "u",
+			inputDialect: dialect.Java,
+			wantLocs:     []regexp_ast.Loc{{Start: 4, End: 6}},
+		},
+		{
+			name:         "Python は長さの決まらない後読みを扱えない",
+			inputS:       `(?<=ab|c)(?<=a+)(?<=de|fg)`,
+			inputFlags:   "u",
+			inputDialect: dialect.Python,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 9}, {Start: 9, End: 16}},
+		},
+		{
+			name:         "PCRE2 は長さの決まらない選択肢のある後読みを扱えない",
+			inputS:       `(?<=ab|c)(?<=a(?:b|cd))`,
+			inputFlags:   "u",
+			inputDialect: dialect.PCRE2,
+			wantLocs:     []regexp_ast.Loc{{Start: 9, End: 23}},
+		},
+		{
+			name:         "Java は上限のない後読みを扱えない",
+			inputS:       `(?<=a*)(?<=(a)\1)`,
+			inputFlags:   "u",
+			inputDialect: dialect.Java,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 7}, {Start: 7, End: 17}},
+		},
+		{
+			name:         "受け付けないグループ名",
+			inputS:       `(?<a_b>x)`,
+			inputFlags:   "u",
+			inputDialect: dialect.Java,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 9}},
+		},
+		{
+			name:         "Python は $ を含むグループ名を受け付けない",
+			inputS:       `(?<$>x)`,
+			inputFlags:   "u",
+			inputDialect: dialect.Python,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 7}},
+		},
+		{
+			name:         "i フラグを展開するときの後方参照",
+			inputS:       `(a)\1`,
+			inputFlags:   "iu",
+			inputDialect: dialect.Python,
+			wantLocs:     []regexp_ast.Loc{{Start: 3, End: 5}},
+		},
+		{
+			name:         "PCRE2 は文字列の中の孤立したサロゲートにマッチできない",
+			inputS:       `[\q{a\ud800}]`,
+			inputFlags:   "v",
+			inputDialect: dialect.PCRE2,
+			wantLocs:     []regexp_ast.Loc{{Start: 5, End: 11}},
+		},
+		{
+			name:         "PostgreSQL は 255 を超える繰り返し回数と空の文字クラスを扱えない",
+			inputS:       `a{256}[]|[^]`,
+			inputFlags:   "u",
+			inputDialect: dialect.PostgreSQL,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 6}, {Start: 6, End: 8}},
+		},
+		{
+			name:         "MySQL は孤立したサロゲートにマッチできない",
+			inputS:       `\udc00`,
+			inputFlags:   "",
+			inputDialect: dialect.MySQL,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 6}},
+		},
+		{
+			name:         "PostgreSQL はサロゲートだけの文字クラスを空の文字クラスとして重ねて報告しない",
+			inputS:       `[\ud800-\udfff]`,
+			inputFlags:   "u",
+			inputDialect: dialect.PostgreSQL,
+			wantLocs:     []regexp_ast.Loc{{Start: 1, End: 14}},
+		},
+		{
+			name:         "PostgreSQL は後読みの中のグループと後読みの中の後方参照を扱えない",
+			inputS:       `(?=(a))\1(b)(?!\2)`,
+			inputFlags:   "u",
+			inputDialect: dialect.PostgreSQL,
+			wantLocs:     []regexp_ast.Loc{{Start: 7, End: 9}, {Start: 15, End: 17}},
+		},
+		{
+			name:         "MySQL は上限のない後読みと受け付けないグループ名を扱えない",
+			inputS:       `(?<=a+)(?<a_1>b)`,
+			inputFlags:   "u",
+			inputDialect: dialect.MySQL,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 7}, {Start: 7, End: 16}},
+		},
+		{
+			name:         "Lucene は表明と後方参照を扱えない",
+			inputS:       `^(a)\1\b(?=b)$`,
+			inputFlags:   "u",
+			inputDialect: dialect.Lucene,
+			wantLocs:     []regexp_ast.Loc{{Start: 0, End: 1}, {Start: 4, End: 6}, {Start: 6, End: 8}, {Start: 8, End: 13}, {Start: 13, End: 14}},
+		},
+		{
+			name:         "Lucene は空の文字クラスを扱えない",
+			inputS:       `a[]`,
+			inputFlags:   "u",
+			inputDialect: dialect.Lucene,
+			wantLocs:     []regexp_ast.Loc{{Start: 1, End: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			o, err := tt.inputDialect.Emit(pattern, flags)
+			if o != "" {
+				t.Errorf("Expected no output, actual %s", o)
+			}
+			var diags diagnostic.List
+			if !errors.As(err, &diags) {
+				t.Fatalf("Expected a diagnostic.List, actual %v", err)
+			}
+			if len(diags) != len(tt.wantLocs) {
+				t.Fatalf("Unexpected number of diagnostics, expected %d, actual %d: %v", len(tt.wantLocs), len(diags), diags)
+			}
+			for i, d := range diags {
+				if d.Loc.Start != tt.wantLocs[i].Start || d.Loc.End != tt.wantLocs[i].End {
+					t.Errorf("Unexpected Loc, expected %v, actual %v", tt.wantLocs[i], d.Loc)
+				}
+			}
+		})
+	}
+}
+
+func TestEmitWithMap(t *testing.T) {
+	pattern, flags := parse(t, `a|[b]*`, "u")
+	o, sm, err := dialect.Lucene.EmitWithMap(pattern, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		inputAt string
+		wantLoc regexp_ast.Loc
+	}{
+		{name: "文字", inputAt: "a", wantLoc: regexp_ast.Loc{Start: 0, End: 1}},
+		{name: "文字クラス", inputAt: "[", wantLoc: regexp_ast.Loc{Start: 2, End: 5}},
+		{name: "量指定子", inputAt: "*)", wantLoc: regexp_ast.Loc{Start: 2, End: 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, ok := sm.Lookup(strings.Index(o, tt.inputAt))
+			if !ok || loc.Start != tt.wantLoc.Start || loc.End != tt.wantLoc.End {
+				t.Errorf("Unexpected Loc of %q in %s, expected %v, actual %v", tt.inputAt, o, tt.wantLoc, loc)
+			}
+		})
+	}
+	if _, ok := sm.Lookup(0); ok {
+		t.Errorf("Expected no Loc for the wrapping of %s", o)
+	}
+}
+
+// The Python output matches the same strings as the ECMAScript pattern. The
+// test needs python3 and is skipped without it.
+func TestEmitPython(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	tests := []struct {
+		inputS       string
+		inputFlags   string
+		wantMatch    []string
+		wantNotMatch []string
+	}{
+		{inputS: `a.c`, inputFlags: "u", wantMatch: []string{"abc", "a😀c"}, wantNotMatch: []string{"a\nc", "a c"}},
+		{inputS: `a.c`, inputFlags: "su", wantMatch: []string{"a\nc", "a c"}, wantNotMatch: []string{"ac"}},
+		{inputS: `k+`, inputFlags: "iu", wantMatch: []string{"kKK"}, wantNotMatch: []string{"x"}},
+		{inputS: `k+`, inputFlags: "i", wantMatch: []string{"kK"}, wantNotMatch: []string{"K"}},
+		{inputS: `[^a-c&]|[]`, inputFlags: "u", wantMatch: []string{"d", "\n"}, wantNotMatch: []string{"b", "&", ""}},
+		{inputS: `😀{2}`, inputFlags: "u", wantMatch: []string{"😀😀"}, wantNotMatch: []string{"😀"}},
+		{inputS: `\d+\s\w`, inputFlags: "u", wantMatch: []string{"12\ufeffa", "0 _"}, wantNotMatch: []string{"١ a", "1\x85a", "1 é"}},
+		{inputS: `\w`, inputFlags: "iu", wantMatch: []string{"ſ", "K"}, wantNotMatch: []string{"é"}},
+		{inputS: `[\W]`, inputFlags: "iu", wantMatch: []string{"-"}, wantNotMatch: []string{"ſ", "s"}},
+		{inputS: `\P{Ll}`, inputFlags: "iu", wantMatch: []string{"a", "A", "1"}, wantNotMatch: []string{"ĸ"}},
+		{inputS: `[\q{abc|d}--\q{d}]x`, inputFlags: "iv", wantMatch: []string{"aBcx"}, wantNotMatch: []string{"dx", "x"}},
+		{inputS: `(?<x>a)?b\k<x>`, inputFlags: "u", wantMatch: []string{"b", "aba"}, wantNotMatch: []string{"ab"}},
+		{inputS: `(a)\1 0`, inputFlags: "", wantMatch: []string{"aa 0"}, wantNotMatch: []string{"a"}},
+		{inputS: `.*(?<=b|c)(?<!ab)`, inputFlags: "u", wantMatch: []string{"b", "ac"}, wantNotMatch: []string{"ab", "a"}},
+		{inputS: `a$\n^b`, inputFlags: "m", wantMatch: []string{"a\nb"}, wantNotMatch: []string{}},
+		{inputS: `.*\bé`, inputFlags: "u", wantMatch: []string{"aé"}, wantNotMatch: []string{" é"}},
+		{inputS: `a\B.`, inputFlags: "iu", wantMatch: []string{"aſ", "a\u212a"}, wantNotMatch: []string{"a-"}},
+	}
+
+	type testCase struct {
+		Pattern string
+		Inputs  []string
+	}
+	var cases []testCase
+	for _, tt := range tests {
+		pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+		o, err := dialect.Python.Emit(pattern, flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, testCase{Pattern: o, Inputs: append(append([]string{}, tt.wantMatch...), tt.wantNotMatch...)})
+	}
+	input, _ := json.Marshal(cases)
+	cmd := exec.Command(python, "-c", `
+import json, re, sys
+cases = json.load(sys.stdin)
+print(json.dumps([[re.fullmatch(c["Pattern"], s) is not None for s in c["Inputs"]] for c in cases]))
+`)
+	cmd.Stdin = strings.NewReader(string(input))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("python3 failed: %v", err)
+	}
+	var results [][]bool
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		for j, matched := range results[i] {
+			if want := j < len(tt.wantMatch); matched != want {
+				t.Errorf("/%s/%s as %s: %q matched = %v", tt.inputS, tt.inputFlags, cases[i].Pattern, cases[i].Inputs[j], matched)
+			}
+		}
+	}
+}
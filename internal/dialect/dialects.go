@@ -0,0 +1,191 @@
+package dialect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PCRE2 targets the 8-bit library of PCRE2 in UTF mode, which `(*UTF)`
+// turns on. Its caseless matching in UTF mode is the simple case folding of
+// the `u` flag.
+var PCRE2 = &Dialect{
+	Name:         "PCRE2",
+	prefix:       "(*UTF)",
+	foldCase:     "(?i)",
+	meta:         `\^$.|?*+()[]{}`,
+	classMeta:    `\]^-[`,
+	escape:       braceEscape,
+	anyCharacter: `[\s\S]`,
+	noCharacter:  `[^\s\S]`,
+	maxRepeat:    65535,
+	lazy:         true,
+	namedGroup:   "(?<%s>",
+	groupName:    asciiGroupName,
+	// `\g{N}` isn't continued by a digit that follows it
+	backreference:            `\g{%d}`,
+	conditionalBackreference: `(?(%[1]d)\g{%[1]d})`,
+	lookahead:                true,
+	lookbehind:               fixedAlternativesLookbehind,
+	start:                    "^",
+	end:                      `\z`,
+	asciiWordBoundary:        true,
+}
+
+// Python targets the `re` module with str patterns. re.IGNORECASE doesn't
+// match by simple case folding exactly, so characters are expanded.
+// Python strings can hold lone surrogates, so they can be matched.
+var Python = &Dialect{
+	Name: "Python",
+	meta: `\^$.|?*+()[]{}`,
+	// `[[`, `--`, `&&`, `~~` and `||` in sets are reserved for set
+	// operations
+	classMeta:      `\]^-[&~|`,
+	escape:         pythonEscape,
+	anyCharacter:   `[\s\S]`,
+	noCharacter:    `[^\s\S]`,
+	loneSurrogates: true,
+	lazy:           true,
+	namedGroup:     "(?P<%s>",
+	groupName:      pythonGroupName,
+	// `(?:)` keeps `\N` from being continued by a digit that follows it
+	backreference:            `(?:\%d)`,
+	conditionalBackreference: `(?(%[1]d)\%[1]d)`,
+	lookahead:                true,
+	lookbehind:               fixedLookbehind,
+	start:                    "^",
+	end:                      `\Z`,
+}
+
+// Java targets java.util.regex. CASE_INSENSITIVE with UNICODE_CASE compares
+// both the upper and the lower case of characters, which matches more than
+// simple case folding, so characters are expanded. Java strings are UTF-16,
+// so lone surrogates can be matched.
+var Java = &Dialect{
+	Name: "Java",
+	meta: `\^$.|?*+()[]{}`,
+	// `[` nests classes and `&&` intersects them
+	classMeta:      `\]^-[&`,
+	escape:         braceEscape,
+	anyCharacter:   `[\s\S]`,
+	noCharacter:    `[^\s\S]`,
+	loneSurrogates: true,
+	lazy:           true,
+	namedGroup:     "(?<%s>",
+	groupName:      alphanumericGroupName,
+	backreference:  `(?:\%d)`,
+	lookahead:      true,
+	lookbehind:     boundedLookbehind,
+	start:          "^",
+	end:            `\z`,
+}
+
+// PostgreSQL targets the advanced regular expressions (AREs) of PostgreSQL
+// in a UTF8 database, as used by `~` and the regexp_* functions. The case
+// folding of `~*` and the `i` option depends on the collation, so characters
+// are expanded. `\S` is not allowed in bracket expressions, and `.` matches
+// newlines by default, so `.` matches any character; there is no way to match
+// no character. Lookbehinds need PostgreSQL 9.6 or later. Groups in
+// lookarounds don't capture, and there are no named groups.
+var PostgreSQL = &Dialect{
+	Name:                    "PostgreSQL",
+	meta:                    `\^$.|?*+()[]{}`,
+	classMeta:               `\]^-[`,
+	escape:                  postgresEscape,
+	anyCharacter:            ".",
+	maxRepeat:               255,
+	lazy:                    true,
+	backreference:           `(?:\%d)`,
+	lookahead:               true,
+	lookbehind:              anyLookbehind,
+	nonCapturingLookarounds: true,
+	start:                   "^",
+	end:                     "$",
+}
+
+// MySQL targets the ICU regular expressions of MySQL 8 with utf8mb4, as used
+// by REGEXP and the REGEXP_* functions. The case folding of the `i` match
+// type depends on the collation, so characters are expanded. The output is
+// the pattern itself; backslashes still have to be doubled in an SQL string
+// literal.
+var MySQL = &Dialect{
+	Name: "MySQL",
+	meta: `\^$.|?*+()[]{}`,
+	// `[` nests sets, and `&&` and `--` combine them
+	classMeta:     `\]^-[&`,
+	escape:        braceEscape,
+	anyCharacter:  `[\s\S]`,
+	noCharacter:   `[^\s\S]`,
+	lazy:          true,
+	namedGroup:    "(?<%s>",
+	groupName:     alphanumericGroupName,
+	backreference: `(?:\%d)`,
+	lookahead:     true,
+	lookbehind:    boundedLookbehind,
+	start:         "^",
+	end:           `\z`,
+}
+
+// Lucene targets the regexp syntax of Lucene, as used by the regexp query of
+// Elasticsearch and OpenSearch, with all optional operators enabled. Lucene
+// patterns match whole terms, so the pattern is wrapped in `.*(` and `).*` to
+// find it anywhere. Lucene has no escapes other than a backslash before a
+// character, and no `\d` or `\s`, so characters are written literally and
+// classes are written as ranges. Lazy quantifiers are written as greedy ones.
+// Groups are written as `(`, since they are only for precedence; there are no
+// assertions or backreferences.
+var Lucene = &Dialect{
+	Name:   "Lucene",
+	prefix: ".*(",
+	suffix: ").*",
+	group:  "(",
+	// `#`, `@`, `&`, `<`, `>` and `~` are the optional operators
+	meta:         `\.?+*|{}[]()"#@&<>~`,
+	classMeta:    `\]^-[`,
+	anyCharacter: ".",
+}
+
+// asciiGroupName returns whether name is made of ASCII letters, digits and
+// underscores and doesn't start with a digit.
+func asciiGroupName(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// alphanumericGroupName returns whether name is made of ASCII letters and
+// digits and starts with a letter, as Java and ICU require.
+func alphanumericGroupName(name string) bool {
+	return asciiGroupName(name) && !strings.Contains(name, "_")
+}
+
+// pythonGroupName returns whether name is a Python identifier, which an
+// ECMAScript group name is unless it has `$`, ZWNJ or ZWJ.
+func pythonGroupName(name string) bool {
+	return !strings.ContainsAny(name, "$\u200c\u200d")
+}
+
+// braceEscape returns `\x{H}`, the escape of PCRE2, Java and ICU.
+func braceEscape(cp int) string {
+	return fmt.Sprintf(`\x{%X}`, cp)
+}
+
+func pythonEscape(cp int) string {
+	switch {
+	case cp <= 0xff:
+		return fmt.Sprintf(`\x%02x`, cp)
+	case cp <= 0xffff:
+		return fmt.Sprintf(`\u%04x`, cp)
+	default:
+		return fmt.Sprintf(`\U%08x`, cp)
+	}
+}
+
+func postgresEscape(cp int) string {
+	if cp <= 0xffff {
+		return fmt.Sprintf(`\u%04X`, cp)
+	}
+	return fmt.Sprintf(`\U%08X`, cp)
+}
@@ -0,0 +1,621 @@
+// Package downlevel rewrites patterns for engines that implement an older
+// edition of ECMAScript, in the manner of regexpu.
+//
+// The `u` flag is lowered by writing astral characters as surrogate pairs:
+// a class becomes an alternation of a class of BMP characters and pairs of a
+// lead and a trail class, with lone lead surrogates guarded by a lookahead.
+// With `i`, the case variants of `u` are expanded and the flag is dropped,
+// since the case folding without `u` differs. The `s` flag is lowered by
+// writing `.` as `[^]`. The `v` flag is lowered to `u` by evaluating every
+// class into its characters and strings: a class without strings becomes a
+// class, and one with strings becomes an alternation that tries the longest
+// strings first, as `v` does, then the characters and the empty string.
+//
+// Below ES2018, property escapes like `\p{L}` are written as classes of their
+// characters, and named groups as numbered ones, with backreferences by
+// number; Result.Names keeps the index of every name. Lookbehinds can't be
+// lowered: they are written as they are and listed in Result.Lookbehinds, so
+// the output needs an engine that has them if there are any.
+//
+// A lone trail surrogate in a lowered class can still match the second half
+// of a surrogate pair if a match starts there, which `u` never does, and
+// after an empty match a global search advances by a code unit rather than a
+// code point. `\b`, `\B` and backreferences see case variants under `i` and
+// `u` that they don't see without them, so they can't be lowered together
+// with both flags. Every other construct keeps its semantics.
+package downlevel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/source_map"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// The first editions that support each flag. ES5 is 5, later editions are
+// numbered by year like the ecmaVersion of regexpp.
+const (
+	ES5    = 5
+	ES2015 = 2015 // u, y
+	ES2018 = 2018 // s, lookbehinds, named groups and property escapes
+	ES2022 = 2022 // d
+	ES2024 = 2024 // v
+)
+
+// Result is a pattern rewritten for an older edition.
+type Result struct {
+	Source string
+	Flags  regexp_ast.Flags
+	// The index of every named group, which is all that is left of the
+	// name below ES2018, or nil if there are none
+	Names map[string]int
+	// The lookbehinds that are written as they are below ES2018
+	Lookbehinds []*regexp_ast.LookaroundAssertion
+}
+
+// Transpile returns a pattern that matches what pattern matches under flags
+// and only uses the syntax of ecmaVersion. Any version below ES2015 is
+// treated as ES5. The flags y and d can't be lowered, nor can some
+// constructs under `i` and `u`; if they are needed, the error is a
+// diagnostic.List with a Diagnostic at each, and the returned Result is
+// empty.
+func Transpile(pattern *regexp_ast.Pattern, flags regexp_ast.Flags, ecmaVersion int) (Result, error) {
+	return transpile(pattern, flags, ecmaVersion, nil)
+}
+
+// TranspileWithMap is like Transpile, and also returns the Loc of the node
+// behind each part of the output, e.g. to report a SyntaxError of an older
+// engine at the original pattern.
+func TranspileWithMap(pattern *regexp_ast.Pattern, flags regexp_ast.Flags, ecmaVersion int) (Result, *source_map.Map, error) {
+	sm := &source_map.Map{}
+	r, err := transpile(pattern, flags, ecmaVersion, sm)
+	if err != nil {
+		return Result{}, nil, err
+	}
+	return r, sm, nil
+}
+
+func transpile(pattern *regexp_ast.Pattern, flags regexp_ast.Flags, ecmaVersion int, sm *source_map.Map) (Result, error) {
+	t := &transpiler{flags: flags, out: flags, sm: sm, groups: map[*regexp_ast.CapturingGroup]int{}}
+	regexp_ast.Inspect(pattern, func(n regexp_ast.Node) bool {
+		if g, ok := n.(*regexp_ast.CapturingGroup); ok {
+			t.groups[g] = len(t.groups) + 1
+			if g.Name != "" {
+				if t.result.Names == nil {
+					t.result.Names = map[string]int{}
+				}
+				t.result.Names[g.Name] = t.groups[g]
+			}
+		}
+		return true
+	})
+	t.lowerES2018 = ecmaVersion < ES2018
+	if flags.UnicodeSets && ecmaVersion < ES2024 {
+		t.lowerUnicodeSets = true
+		t.out.UnicodeSets = false
+		t.out.Unicode = true
+	}
+	if t.out.Unicode && ecmaVersion < ES2015 {
+		t.lowerUnicode = true
+		t.out.Unicode = false
+		t.out.IgnoreCase = false
+	}
+	if flags.DotAll && ecmaVersion < ES2018 {
+		t.lowerDotAll = true
+		t.out.DotAll = false
+	}
+	if flags.Sticky && ecmaVersion < ES2015 {
+		t.diags.Add(pattern, "the y flag can't be lowered below ES2015")
+	}
+	if flags.HasIndices && ecmaVersion < ES2022 {
+		t.diags.Add(pattern, "the d flag can't be lowered below ES2022")
+	}
+	t.pattern(pattern)
+	if err := t.diags.Err(); err != nil {
+		return Result{}, err
+	}
+	t.result.Source = t.sb.String()
+	t.result.Flags = t.out
+	return t.result, nil
+}
+
+type transpiler struct {
+	flags            regexp_ast.Flags
+	out              regexp_ast.Flags
+	lowerUnicodeSets bool
+	lowerUnicode     bool
+	lowerDotAll      bool
+	// Whether named groups and property escapes are lowered
+	lowerES2018 bool
+	sb          strings.Builder
+	diags       diagnostic.List
+	// The map of the output, or nil
+	sm *source_map.Map
+	// The number of every capturing group
+	groups map[*regexp_ast.CapturingGroup]int
+	result Result
+}
+
+// record maps the output written since start to loc.
+func (t *transpiler) record(loc regexp_ast.Loc, start int) {
+	t.sm.Add(start, t.sb.Len(), loc)
+}
+
+func (t *transpiler) pattern(pattern *regexp_ast.Pattern) {
+	defer t.record(pattern.Loc, t.sb.Len())
+	t.alternatives(pattern.Alternatives)
+}
+
+func (t *transpiler) alternatives(alts []*regexp_ast.Alternative) {
+	for i, alt := range alts {
+		if i > 0 {
+			t.sb.WriteByte('|')
+		}
+		start := t.sb.Len()
+		for _, el := range alt.Elements {
+			t.element(el)
+		}
+		t.record(alt.Loc, start)
+	}
+}
+
+func (t *transpiler) element(el regexp_ast.Element) {
+	defer t.record(el.(regexp_ast.Node).GetLoc(), t.sb.Len())
+	switch n := el.(type) {
+	case *regexp_ast.CharacterClass, *regexp_ast.ExpressionCharacterClass:
+		if t.lowerUnicodeSets {
+			t.classSet(el.(regexp_ast.Node))
+			return
+		}
+		t.single(el)
+	case *regexp_ast.Character, *regexp_ast.AnyCharacterSet, *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+		t.single(el)
+	case *regexp_ast.Quantifier:
+		t.quantifier(n)
+	case *regexp_ast.Group:
+		t.sb.WriteString("(?:")
+		t.alternatives(n.Alternatives)
+		t.sb.WriteByte(')')
+	case *regexp_ast.CapturingGroup:
+		if n.Name != "" && !t.lowerES2018 {
+			t.sb.WriteString("(?<" + n.Name + ">")
+		} else {
+			t.sb.WriteByte('(')
+		}
+		t.alternatives(n.Alternatives)
+		t.sb.WriteByte(')')
+	case *regexp_ast.LookaroundAssertion:
+		t.lookaround(n)
+	case *regexp_ast.EdgeAssertion:
+		if n.Kind == regexp_ast.EdgeStart {
+			t.sb.WriteByte('^')
+		} else {
+			t.sb.WriteByte('$')
+		}
+	case *regexp_ast.WordBoundaryAssertion:
+		if t.lowerUnicode && t.flags.IgnoreCase {
+			t.diags.Add(n, "a word boundary can't be lowered with the i and u flags")
+			return
+		}
+		t.sb.WriteString(printer.Print(n, t.flags.Unicode))
+	case *regexp_ast.Backreference:
+		t.backreference(n)
+	default:
+		t.diags.Add(el.(regexp_ast.Node), "%T can't be lowered", el)
+	}
+}
+
+func (t *transpiler) lookaround(a *regexp_ast.LookaroundAssertion) {
+	switch {
+	case a.Kind == regexp_ast.Lookahead && a.Negate:
+		t.sb.WriteString("(?!")
+	case a.Kind == regexp_ast.Lookahead:
+		t.sb.WriteString("(?=")
+	case a.Negate:
+		t.sb.WriteString("(?<!")
+	default:
+		t.sb.WriteString("(?<=")
+	}
+	if a.Kind == regexp_ast.Lookbehind && t.lowerES2018 {
+		t.result.Lookbehinds = append(t.result.Lookbehinds, a)
+	}
+	t.alternatives(a.Alternatives)
+	t.sb.WriteByte(')')
+}
+
+func (t *transpiler) backreference(b *regexp_ast.Backreference) {
+	if t.lowerUnicode && t.flags.IgnoreCase {
+		t.diags.Add(b, "a backreference can't be lowered with the i and u flags")
+		return
+	}
+	if b.Name != "" && !t.lowerES2018 {
+		t.sb.WriteString(`\k<` + b.Name + ">")
+		return
+	}
+	// `(?:)` keeps a digit that follows from continuing the number
+	if followedByDigit(b) {
+		fmt.Fprintf(&t.sb, `(?:\%d)`, t.groups[b.Resolved])
+	} else {
+		fmt.Fprintf(&t.sb, `\%d`, t.groups[b.Resolved])
+	}
+}
+
+// followedByDigit returns whether the output of the element after b may
+// start with a digit.
+func followedByDigit(b *regexp_ast.Backreference) bool {
+	alt, ok := b.Parent.(*regexp_ast.Alternative)
+	if !ok {
+		return false
+	}
+	for i, el := range alt.Elements {
+		if el != regexp_ast.Element(b) || i+1 == len(alt.Elements) {
+			continue
+		}
+		next := alt.Elements[i+1]
+		if q, ok := next.(*regexp_ast.Quantifier); ok {
+			next = q.Element.(regexp_ast.Element)
+		}
+		c, ok := next.(*regexp_ast.Character)
+		return ok && '0' <= c.Value && c.Value <= '9'
+	}
+	return false
+}
+
+// single writes a node that matches a single character.
+func (t *transpiler) single(el regexp_ast.Element) {
+	node := el.(regexp_ast.Node)
+	if t.lowerUnicode || t.lowerES2018 && hasProperty(node) {
+		set, ok := charset.OfNode(node, t.flags)
+		if !ok {
+			t.diags.Add(node, "%T can't be lowered", el)
+			return
+		}
+		t.chars(set)
+		return
+	}
+	t.character(el)
+}
+
+// hasProperty returns whether node has a property escape.
+func hasProperty(node regexp_ast.Node) bool {
+	found := false
+	regexp_ast.Inspect(node, func(n regexp_ast.Node) bool {
+		if _, ok := n.(*regexp_ast.UnicodePropertyCharacterSet); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// character writes a node of a single character, for an output in the same
+// mode as the input.
+func (t *transpiler) character(el regexp_ast.Element) {
+	switch n := el.(type) {
+	case *regexp_ast.Character:
+		t.literal(n.Value, meta)
+	case *regexp_ast.CharacterClass:
+		t.class(n)
+	case *regexp_ast.ExpressionCharacterClass:
+		t.expressionClass(n)
+	case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+		t.sb.WriteString(printer.Print(n.(regexp_ast.Node), t.out.Unicode || t.out.UnicodeSets))
+	case *regexp_ast.AnyCharacterSet:
+		switch {
+		case t.lowerDotAll:
+			t.sb.WriteString("[^]")
+		default:
+			t.sb.WriteByte('.')
+		}
+	}
+}
+
+func (t *transpiler) quantifier(q *regexp_ast.Quantifier) {
+	t.element(q.Element.(regexp_ast.Element))
+	switch {
+	case q.Min == 0 && q.Max == math.MaxInt:
+		t.sb.WriteByte('*')
+	case q.Min == 1 && q.Max == math.MaxInt:
+		t.sb.WriteByte('+')
+	case q.Min == 0 && q.Max == 1:
+		t.sb.WriteByte('?')
+	case q.Max == math.MaxInt:
+		fmt.Fprintf(&t.sb, "{%d,}", q.Min)
+	case q.Min == q.Max:
+		fmt.Fprintf(&t.sb, "{%d}", q.Min)
+	default:
+		fmt.Fprintf(&t.sb, "{%d,%d}", q.Min, q.Max)
+	}
+	if !q.Greety {
+		t.sb.WriteByte('?')
+	}
+}
+
+// class writes cc as it is, for an output in the same mode as the input.
+func (t *transpiler) class(cc *regexp_ast.CharacterClass) {
+	classMeta := classMeta
+	if t.out.UnicodeSets {
+		classMeta = classSetMeta
+	}
+	t.sb.WriteByte('[')
+	if cc.Negate {
+		t.sb.WriteByte('^')
+	}
+	for _, el := range cc.Elements {
+		switch n := el.(type) {
+		case *regexp_ast.Character:
+			t.literal(n.Value, classMeta)
+		case *regexp_ast.CharacterClassRange:
+			t.literal(n.Min.Value, classMeta)
+			t.sb.WriteByte('-')
+			t.literal(n.Max.Value, classMeta)
+		case *regexp_ast.CharacterClass, *regexp_ast.ExpressionCharacterClass, *regexp_ast.ClassStringDisjunction:
+			t.operand(n.(regexp_ast.ClassSetOperand))
+		case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+			t.sb.WriteString(printer.Print(n.(regexp_ast.Node), t.out.Unicode || t.out.UnicodeSets))
+		default:
+			t.diags.Add(el.(regexp_ast.Node), "%T can't be lowered", el)
+		}
+	}
+	t.sb.WriteByte(']')
+}
+
+// expressionClass writes cc as it is, for an output with `v`.
+func (t *transpiler) expressionClass(cc *regexp_ast.ExpressionCharacterClass) {
+	t.sb.WriteByte('[')
+	if cc.Negate {
+		t.sb.WriteByte('^')
+	}
+	t.operand(cc.Expression.(regexp_ast.ClassSetOperand))
+	t.sb.WriteByte(']')
+}
+
+func (t *transpiler) operand(op regexp_ast.ClassSetOperand) {
+	switch n := op.(type) {
+	case *regexp_ast.Character:
+		t.literal(n.Value, classSetMeta)
+	case *regexp_ast.CharacterClass:
+		t.class(n)
+	case *regexp_ast.ExpressionCharacterClass:
+		t.expressionClass(n)
+	case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+		t.sb.WriteString(printer.Print(n.(regexp_ast.Node), true))
+	case *regexp_ast.ClassIntersection:
+		t.operand(n.Left)
+		t.sb.WriteString("&&")
+		t.operand(n.Right)
+	case *regexp_ast.ClassSubtraction:
+		t.operand(n.Left)
+		t.sb.WriteString("--")
+		t.operand(n.Right)
+	case *regexp_ast.ClassStringDisjunction:
+		t.sb.WriteString(`\q{`)
+		for i, alt := range n.Alternatives {
+			if i > 0 {
+				t.sb.WriteByte('|')
+			}
+			for _, c := range alt.Elements {
+				t.literal(c.Value, classSetMeta)
+			}
+		}
+		t.sb.WriteByte('}')
+	}
+}
+
+// classSet writes a class with `v` for an output without it: a class of its
+// characters, or an alternation that tries its strings by descending length,
+// then its characters, then the empty string. With `i` the strings are
+// folded, which `u` matches the same way unless the `u` is lowered as well.
+func (t *transpiler) classSet(node regexp_ast.Node) {
+	c, ok := charset.ClassSetOf(node, t.flags)
+	if !ok {
+		t.diags.Add(node, "%T can't be lowered", node)
+		return
+	}
+	strs := c.Strings()
+	if len(strs) == 0 {
+		t.chars(c.Chars)
+		return
+	}
+	sort.SliceStable(strs, func(i, j int) bool {
+		return utf8.RuneCountInString(strs[i]) > utf8.RuneCountInString(strs[j])
+	})
+	t.sb.WriteString("(?:")
+	sep := ""
+	for _, s := range strs {
+		if s == "" {
+			continue
+		}
+		t.sb.WriteString(sep)
+		sep = "|"
+		for _, r := range s {
+			if !t.lowerUnicode {
+				t.literal(int(r), meta)
+				continue
+			}
+			set := charset.Of(int(r))
+			if t.flags.IgnoreCase {
+				set = charset.CaseClosure(set, true)
+			}
+			if set.Len() > 1 {
+				t.set(set)
+				continue
+			}
+			for _, u := range utf16.Encode([]rune{r}) {
+				writeLiteral(&t.sb, int(u), meta, false)
+			}
+		}
+	}
+	if !c.Chars.IsEmpty() {
+		t.sb.WriteString(sep)
+		sep = "|"
+		t.chars(c.Chars)
+	}
+	if strs[len(strs)-1] == "" && sep != "" {
+		t.sb.WriteByte('|')
+	}
+	t.sb.WriteByte(')')
+}
+
+// chars writes a class of set, for an output without `v`.
+func (t *transpiler) chars(set charset.Set) {
+	if t.lowerUnicode {
+		t.set(set)
+		return
+	}
+	t.sb.WriteString(unitClass(set, true))
+}
+
+// set writes an alternation that matches the code points in set with `u`,
+// as UTF-16 code units without `u`. A non-empty set is written as a single
+// atom, so that it can be quantified.
+func (t *transpiler) set(set charset.Set) {
+	units := set.Intersect(charset.New(
+		charset.Range{Min: 0, Max: unicode_consts.MinLeadSurrogate - 1},
+		charset.Range{Min: unicode_consts.MinTrailSurrogate, Max: 0xffff},
+	))
+	leads := set.Intersect(charset.New(charset.Range{Min: unicode_consts.MinLeadSurrogate, Max: unicode_consts.MaxLeadSurrogate}))
+	astral := set.Intersect(charset.New(charset.Range{Min: 0x10000, Max: unicode_consts.MaxCodePoint}))
+
+	var alts []string
+	if !units.IsEmpty() {
+		alts = append(alts, unitClass(units, false))
+	}
+	for _, p := range pairs(astral) {
+		alts = append(alts, unitClass(charset.New(p.leads), false)+unitClass(p.trails, false))
+	}
+	if !leads.IsEmpty() {
+		alts = append(alts, unitClass(leads, false)+`(?![\uDC00-\uDFFF])`)
+	}
+	switch len(alts) {
+	case 0:
+		t.sb.WriteString("[]")
+	case 1:
+		if !units.IsEmpty() {
+			t.sb.WriteString(alts[0])
+			break
+		}
+		fallthrough
+	default:
+		t.sb.WriteString("(?:")
+		t.sb.WriteString(strings.Join(alts, "|"))
+		t.sb.WriteByte(')')
+	}
+}
+
+type pair struct {
+	leads  charset.Range
+	trails charset.Set
+}
+
+// pairs returns the surrogate pairs of the astral code points in set. Lead
+// surrogates with the same trail surrogates are merged.
+func pairs(set charset.Set) []pair {
+	type run struct {
+		lead   int
+		trails []charset.Range
+	}
+	var runs []run
+	for _, r := range set.Ranges() {
+		for cp := r.Min; cp <= r.Max; {
+			lead := unicode_consts.MinLeadSurrogate + (cp-0x10000)>>10
+			trail := unicode_consts.MinTrailSurrogate + (cp-0x10000)&0x3ff
+			end := cp + unicode_consts.MaxTrailSurrogate - trail
+			if end > r.Max {
+				end = r.Max
+			}
+			trails := charset.Range{Min: trail, Max: trail + end - cp}
+			if last := len(runs) - 1; last >= 0 && runs[last].lead == lead {
+				runs[last].trails = append(runs[last].trails, trails)
+			} else {
+				runs = append(runs, run{lead, []charset.Range{trails}})
+			}
+			cp = end + 1
+		}
+	}
+	var ps []pair
+	for _, r := range runs {
+		trails := charset.New(r.trails...)
+		if last := len(ps) - 1; last >= 0 && ps[last].leads.Max+1 == r.lead && ps[last].trails.Equal(trails) {
+			ps[last].leads.Max = r.lead
+			continue
+		}
+		ps = append(ps, pair{charset.Range{Min: r.lead, Max: r.lead}, trails})
+	}
+	return ps
+}
+
+// unitClass returns a class of the code units in set, or a single code unit.
+// With u, the set may have code points and is written for an output with
+// `u`.
+func unitClass(set charset.Set, u bool) string {
+	var sb strings.Builder
+	if set.Len() == 1 {
+		writeLiteral(&sb, set.Ranges()[0].Min, meta, u)
+		return sb.String()
+	}
+	sb.WriteByte('[')
+	for _, r := range set.Ranges() {
+		writeLiteral(&sb, r.Min, classMeta, u)
+		if r.Max == r.Min+1 {
+			writeLiteral(&sb, r.Max, classMeta, u)
+		} else if r.Max != r.Min {
+			sb.WriteByte('-')
+			writeLiteral(&sb, r.Max, classMeta, u)
+		}
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+const (
+	meta      = `\^$.*+?()[]{}|/`
+	classMeta = `\]^-[/`
+	// Escaping every ClassSetSyntaxCharacter and ClassSetReservedPunctuator
+	// keeps `v` from reading set operations or reserved double punctuators
+	classSetMeta = `\]^-[/(){}|&!#$%*+,.:;<=>?@` + "`~"
+)
+
+func (t *transpiler) literal(cp int, meta string) {
+	writeLiteral(&t.sb, cp, meta, t.out.Unicode || t.out.UnicodeSets)
+}
+
+// writeLiteral writes cp so that it is read back as one character in both
+// a regex literal and a RegExp source. With u, astral characters and
+// surrogates are written as `\u{…}`, which keeps a lone surrogate from being
+// paired with the next escape.
+func writeLiteral(sb *strings.Builder, cp int, meta string, u bool) {
+	switch {
+	case cp < unicode.MaxASCII && strings.ContainsRune(meta, rune(cp)):
+		sb.WriteByte('\\')
+		sb.WriteRune(rune(cp))
+	case cp == '\t':
+		sb.WriteString(`\t`)
+	case cp == '\n':
+		sb.WriteString(`\n`)
+	case cp == '\v':
+		sb.WriteString(`\v`)
+	case cp == '\f':
+		sb.WriteString(`\f`)
+	case cp == '\r':
+		sb.WriteString(`\r`)
+	case cp >= ' ' && cp < unicode.MaxASCII:
+		sb.WriteRune(rune(cp))
+	case cp <= 0xff:
+		fmt.Fprintf(sb, `\x%02X`, cp)
+	case u && (cp > 0xffff || unicode_consts.IsLeadSurrogate(cp) || unicode_consts.IsTrailSurrogate(cp)):
+		fmt.Fprintf(sb, `\u{%X}`, cp)
+	default:
+		fmt.Fprintf(sb, `\u%04X`, cp)
+	}
+}
@@ -0,0 +1,460 @@
+package downlevel_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/downlevel"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func parse(t *testing.T, source string, flags string) (*regexp_ast.Pattern, regexp_ast.Flags) {
+	t.Helper()
+	f, err := parser.ParseFlags(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewParserWithOptions(source, f.Unicode, parser.Options{UnicodeSets: f.UnicodeSets})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pattern, f
+}
+
+func TestTranspile(t *testing.T) {
+	tests := []struct {
+		name            string
+		inputS          string
+		inputFlags      string
+		inputVersion    int
+		wantOutput      string
+		wantOutputFlags string
+	}{
+		{
+			name:            "BMP の文字はそのまま",
+			inputS:          `a+[b-d]`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `a+[b-d]`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "アストラル文字はサロゲートペアにする",
+			inputS:          `😀+`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `(?:\uD83D\uDE00)+`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "アストラル文字を含む文字クラス",
+			inputS:          `[a😀-😂]`,
+			inputFlags:      "gu",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `(?:a|\uD83D[\uDE00-\uDE02])`,
+			wantOutputFlags: "g",
+		},
+		{
+			name:            "同じ後半のサロゲートを持つ前半のサロゲートをまとめる",
+			inputS:          `[\u{10000}-\u{10001}\u{10400}-\u{10401}]`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `(?:[\uD800\uD801][\uDC00\uDC01])`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "否定の文字クラス",
+			inputS:          `[^a]`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      "(?:[\\x00-`b-\\uD7FF\\uDC00-\\uFFFF]|[\\uD800-\\uDBFF][\\uDC00-\\uDFFF]|[\\uD800-\\uDBFF](?![\\uDC00-\\uDFFF]))",
+			wantOutputFlags: "",
+		},
+		{
+			name:            "孤立した前半のサロゲート",
+			inputS:          `\ud800`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `(?:\uD800(?![\uDC00-\uDFFF]))`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "空の文字クラス",
+			inputS:          `[]`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `[]`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "u フラグの i は展開する",
+			inputS:          `k[^s]`,
+			inputFlags:      "iu",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `[Kk\u212A](?:[\x00-RT-rt-\u017E\u0180-\uD7FF\uDC00-\uFFFF]|[\uD800-\uDBFF][\uDC00-\uDFFF]|[\uD800-\uDBFF](?![\uDC00-\uDFFF]))`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "s フラグのドット",
+			inputS:          `a.`,
+			inputFlags:      "is",
+			inputVersion:    downlevel.ES2015,
+			wantOutput:      `a[^]`,
+			wantOutputFlags: "i",
+		},
+		{
+			name:            "u フラグと s フラグのドット",
+			inputS:          `.`,
+			inputFlags:      "su",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `(?:[\x00-\uD7FF\uDC00-\uFFFF]|[\uD800-\uDBFF][\uDC00-\uDFFF]|[\uD800-\uDBFF](?![\uDC00-\uDFFF]))`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "ES2018 では s フラグを残す",
+			inputS:          `.`,
+			inputFlags:      "s",
+			inputVersion:    downlevel.ES2018,
+			wantOutput:      `.`,
+			wantOutputFlags: "s",
+		},
+		{
+			name:            "v フラグは u フラグにする",
+			inputS:          `[😀&]`,
+			inputFlags:      "v",
+			inputVersion:    downlevel.ES2015,
+			wantOutput:      `[&\u{1F600}]`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "v フラグの集合演算は文字クラスにする",
+			inputS:          `[[a-z]--[aeiou]]`,
+			inputFlags:      "v",
+			inputVersion:    downlevel.ES2015,
+			wantOutput:      `[b-df-hj-np-tv-z]`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "v フラグの文字列は長い順の選択にする",
+			inputS:          `[\q{ab|abc|}x-y]+`,
+			inputFlags:      "v",
+			inputVersion:    downlevel.ES2015,
+			wantOutput:      `(?:abc|ab|[xy]|)+`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "i フラグの文字列は畳み込む",
+			inputS:          `[\q{Ab}k]`,
+			inputFlags:      "iv",
+			inputVersion:    downlevel.ES2015,
+			wantOutput:      `(?:ab|[Kk\u212A])`,
+			wantOutputFlags: "iu",
+		},
+		{
+			name:            "u フラグも下げる文字列は大文字小文字を展開する",
+			inputS:          `[\q{Ab|😀x}]`,
+			inputFlags:      "iv",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `(?:[Aa][Bb]|\uD83D\uDE00[Xx])`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "ES2024 では v フラグの集合演算をそのまま書く",
+			inputS:          `[[a-z]&&[^\q{b}]][\q{a|bc}]`,
+			inputFlags:      "v",
+			inputVersion:    downlevel.ES2024,
+			wantOutput:      `[[a-z]&&[^\q{b}]][\q{a|bc}]`,
+			wantOutputFlags: "v",
+		},
+		{
+			name:            "ES2024 では v フラグの文字クラスの記号をエスケープする",
+			inputS:          `[&.]`,
+			inputFlags:      "v",
+			inputVersion:    downlevel.ES2024,
+			wantOutput:      `[\&\.]`,
+			wantOutputFlags: "v",
+		},
+		{
+			name:            "u フラグなしのサロゲートペアと制御文字",
+			inputS:          `😀\0/`,
+			inputFlags:      "",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `\uD83D\uDE00\x00\/`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "u フラグの孤立したサロゲートは結合しない",
+			inputS:          `[\ud801-\ud802\u{dc00}]`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES2015,
+			wantOutput:      `[\u{D801}-\u{D802}\u{DC00}]`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "名前付きグループは番号付きにする",
+			inputS:          `(?<y>a)\k<y>`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES2015,
+			wantOutput:      `(a)\1`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "ES2018 では名前付きグループを残す",
+			inputS:          `(?<y>a)\k<y>`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES2018,
+			wantOutput:      `(?<y>a)\k<y>`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "数字が続く後方参照",
+			inputS:          `(?<y>a)\k<y>0`,
+			inputFlags:      "",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `(a)(?:\1)0`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "プロパティは文字クラスにする",
+			inputS:          `\p{ASCII_Hex_Digit}[\p{ASCII_Hex_Digit}_]\d`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES2015,
+			wantOutput:      `[0-9A-Fa-f][0-9A-F_a-f]\d`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "ES2018 ではプロパティを残す",
+			inputS:          `\P{Lu}[\p{Script=Greek}\s]`,
+			inputFlags:      "u",
+			inputVersion:    downlevel.ES2018,
+			wantOutput:      `\P{Lu}[\p{Script=Greek}\s]`,
+			wantOutputFlags: "u",
+		},
+		{
+			name:            "u フラグの i の \\w は ſ と K を含む",
+			inputS:          `\w\D`,
+			inputFlags:      "iu",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `[0-9A-Z_a-z\u017F\u212A](?:[\x00-\/:-\uD7FF\uDC00-\uFFFF]|[\uD800-\uDBFF][\uDC00-\uDFFF]|[\uD800-\uDBFF](?![\uDC00-\uDFFF]))`,
+			wantOutputFlags: "",
+		},
+		{
+			name:            "グループと表明",
+			inputS:          `^(?:a|b)(?=c)(?!d)\b(?<=e)$`,
+			inputFlags:      "mu",
+			inputVersion:    downlevel.ES5,
+			wantOutput:      `^(?:a|b)(?=c)(?!d)\b(?<=e)$`,
+			wantOutputFlags: "m",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			r, err := downlevel.Transpile(pattern, flags, tt.inputVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Source != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, r.Source)
+			}
+			if r.Flags.String() != tt.wantOutputFlags {
+				t.Errorf("Unexpected flags, expected %s, actual %s", tt.wantOutputFlags, r.Flags)
+			}
+		})
+	}
+}
+
+func TestTranspileError(t *testing.T) {
+	tests := []struct {
+		name         string
+		inputS       string
+		inputFlags   string
+		inputVersion int
+		wantMessages []string
+	}{
+		{
+			name:         "y フラグは ES5 に下げられない",
+			inputS:       `a`,
+			inputFlags:   "y",
+			inputVersion: downlevel.ES5,
+			wantMessages: []string{"0-1: the y flag can't be lowered below ES2015"},
+		},
+		{
+			name:         "d フラグは ES2022 より前に下げられない",
+			inputS:       `ab`,
+			inputFlags:   "dy",
+			inputVersion: downlevel.ES2018,
+			wantMessages: []string{"0-2: the d flag can't be lowered below ES2022"},
+		},
+		{
+			name:         "u フラグの i の単語境界と後方参照は下げられない",
+			inputS:       `(a)\b\1`,
+			inputFlags:   "iu",
+			inputVersion: downlevel.ES5,
+			wantMessages: []string{"3-5: a word boundary can't be lowered with the i and u flags", "5-7: a backreference can't be lowered with the i and u flags"},
+		},
+		{
+			name:         "表にないプロパティ",
+			inputS:       `\p{Emoji}`,
+			inputFlags:   "u",
+			inputVersion: downlevel.ES2015,
+			wantMessages: []string{"0-9: *regexp_ast.UnicodePropertyCharacterSet can't be lowered"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			r, err := downlevel.Transpile(pattern, flags, tt.inputVersion)
+			if r.Source != "" {
+				t.Errorf("Expected no output, actual %s", r.Source)
+			}
+			var diags diagnostic.List
+			if !errors.As(err, &diags) {
+				t.Fatalf("Expected a diagnostic.List, actual %v", err)
+			}
+			if len(diags) != len(tt.wantMessages) {
+				t.Fatalf("Unexpected number of diagnostics, expected %d, actual %d: %v", len(tt.wantMessages), len(diags), diags)
+			}
+			for i, d := range diags {
+				if d.Error() != tt.wantMessages[i] {
+					t.Errorf("Unexpected output, expected %s, actual %s", tt.wantMessages[i], d.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestTranspileResult(t *testing.T) {
+	pattern, flags := parse(t, `(?<a>x)(y)(?<b>z)(?<=a)(?<!b)(?=c)`, "u")
+	r, err := downlevel.Transpile(pattern, flags, downlevel.ES2015)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Names) != 2 || r.Names["a"] != 1 || r.Names["b"] != 3 {
+		t.Errorf("Unexpected Names, expected map[a:1 b:3], actual %v", r.Names)
+	}
+	var locs []regexp_ast.Loc
+	for _, a := range r.Lookbehinds {
+		locs = append(locs, a.Loc)
+	}
+	if len(locs) != 2 || locs[0].Start != 17 || locs[1].Start != 23 {
+		t.Errorf("Unexpected Lookbehinds, expected the ones at 17 and 23, actual %v", locs)
+	}
+
+	r, err = downlevel.Transpile(pattern, flags, downlevel.ES2018)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Lookbehinds) != 0 {
+		t.Errorf("Expected no Lookbehinds in ES2018, actual %v", r.Lookbehinds)
+	}
+}
+
+func TestTranspileWithMap(t *testing.T) {
+	pattern, flags := parse(t, `a😀+`, "u")
+	r, sm, err := downlevel.TranspileWithMap(pattern, flags, downlevel.ES5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := r.Source
+	tests := []struct {
+		name    string
+		inputAt string
+		wantLoc regexp_ast.Loc
+	}{
+		{name: "文字", inputAt: "a", wantLoc: regexp_ast.Loc{Start: 0, End: 1}},
+		{name: "アストラル文字", inputAt: `(?:`, wantLoc: regexp_ast.Loc{Start: 1, End: 3}},
+		{name: "量指定子", inputAt: "+", wantLoc: regexp_ast.Loc{Start: 1, End: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, ok := sm.Lookup(strings.Index(o, tt.inputAt))
+			if !ok || loc.Start != tt.wantLoc.Start || loc.End != tt.wantLoc.End {
+				t.Errorf("Unexpected Loc of %q in %s, expected %v, actual %v", tt.inputAt, o, tt.wantLoc, loc)
+			}
+		})
+	}
+}
+
+// The lowered patterns find the same matches as the original ones. The
+// patterns can't match the empty string, since an empty match advances by a
+// code unit rather than a code point without `u`. The test needs node and is
+// skipped without it.
+func TestTranspileNode(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	tests := []struct {
+		inputS     string
+		inputFlags string
+	}{
+		{inputS: `a.c`, inputFlags: "u"},
+		{inputS: `a.c`, inputFlags: "su"},
+		{inputS: `.+`, inputFlags: "s"},
+		{inputS: `😀{2}|b`, inputFlags: "u"},
+		{inputS: `[^a-c]+`, inputFlags: "u"},
+		{inputS: `[^a-c]+?x`, inputFlags: "iu"},
+		{inputS: `[😀-😂k]+`, inputFlags: "iu"},
+		{inputS: `\ud83d.`, inputFlags: "u"},
+		{inputS: `[\u{10000}-\u{10ffff}]`, inputFlags: "v"},
+		{inputS: `[s-z]+`, inputFlags: "iv"},
+		{inputS: `[\q{abc|ab}c😀]+`, inputFlags: "v"},
+		{inputS: `[\q{bk|ab}s]+`, inputFlags: "iv"},
+		{inputS: `[[a-z😀]&&[^b]]+`, inputFlags: "v"},
+		{inputS: `(?<x>a|b)\k<x>`, inputFlags: "u"},
+		{inputS: `(?:a)(b)?\1c`, inputFlags: "u"},
+		{inputS: `\p{ASCII_Hex_Digit}+`, inputFlags: "u"},
+		{inputS: `[\w😀]+`, inputFlags: "iu"},
+		{inputS: `\S+\b`, inputFlags: "u"},
+		{inputS: `^.|.$`, inputFlags: "mu"},
+	}
+
+	type testCase struct {
+		Source, Flags, Lowered, LoweredFlags string
+	}
+	var cases []testCase
+	for _, tt := range tests {
+		pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+		r, err := downlevel.Transpile(pattern, flags, downlevel.ES5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, testCase{tt.inputS, tt.inputFlags, r.Source, r.Flags.String()})
+	}
+	input, _ := json.Marshal(cases)
+	cmd := exec.Command(node, "-e", `
+const cases = JSON.parse(require("fs").readFileSync(0, "utf8"));
+const inputs = ["abc", "a😀c", "a\nc", "ABK", "Kſ😁x", "😀😀b", "😀\ud83d", "\ud83dx", "sſK"];
+const all = (re, s) => [...s.matchAll(new RegExp(re.source, re.flags + "g"))].map((m) => [m.index, m[0]]);
+console.log(JSON.stringify(cases.map((c) => inputs.map((s) => [
+  all(new RegExp(c.Source, c.Flags), s),
+  all(new RegExp(c.Lowered, c.LoweredFlags), s),
+]))));
+`)
+	cmd.Stdin = strings.NewReader(string(input))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("node failed: %v", err)
+	}
+	var results [][][2]json.RawMessage
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range cases {
+		for j, r := range results[i] {
+			if string(r[0]) != string(r[1]) {
+				t.Errorf("/%s/%s as /%s/%s on input %d: expected %s, actual %s", c.Source, c.Flags, c.Lowered, c.LoweredFlags, j, r[0], r[1])
+			}
+		}
+	}
+}
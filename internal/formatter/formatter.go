@@ -0,0 +1,167 @@
+// Package formatter rewrites patterns into a canonical form, like gofmt does
+// for Go source, so that stylistic differences between equivalent patterns
+// disappear.
+//
+// The canonical form is what the printer emits for the parsed tree: escapes
+// are normalized (`\x41` to `A`, `\u{a}` to `\n`), quantifiers use their
+// shortest spelling (`{0,}` to `*`, `{2,2}` to `{2}`) and classes are sorted,
+// deduplicated and merged into ranges. Minify additionally applies rewrites
+// that only make the text shorter.
+package formatter
+
+import (
+	"sort"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+type Style struct {
+	// Emit the shortest equivalent text instead of the most readable one
+	Minify bool
+
+	// Escape every character outside of ASCII
+	ASCIIOnly bool
+}
+
+// Format parses source and returns it in the canonical form of style.
+func Format(source string, u bool, style Style) (string, error) {
+	p := parser.NewParser(source, u)
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		return "", err
+	}
+	f := &formatter{u: u, style: style}
+	f.pattern(pattern)
+	return printer.PrintWithOptions(pattern, u, printer.Options{ASCIIOnly: style.ASCIIOnly}), nil
+}
+
+type formatter struct {
+	u     bool
+	style Style
+}
+
+func (f *formatter) pattern(pattern *regexp_ast.Pattern) {
+	f.alternatives(pattern.Alternatives)
+}
+
+func (f *formatter) alternatives(alts []*regexp_ast.Alternative) {
+	for _, alt := range alts {
+		for i, el := range alt.Elements {
+			alt.Elements[i] = f.element(el)
+		}
+	}
+}
+
+func (f *formatter) element(el regexp_ast.Element) regexp_ast.Element {
+	switch n := el.(type) {
+	case *regexp_ast.CharacterClass:
+		f.characterClass(n)
+		// [a] -> a
+		if f.style.Minify && !n.Negate && len(n.Elements) == 1 {
+			if c, ok := n.Elements[0].(*regexp_ast.Character); ok {
+				c.Parent = n.Parent
+				c.Loc = n.Loc
+				return c
+			}
+		}
+	case *regexp_ast.Group:
+		f.alternatives(n.Alternatives)
+	case *regexp_ast.CapturingGroup:
+		f.alternatives(n.Alternatives)
+	case *regexp_ast.LookaroundAssertion:
+		f.alternatives(n.Alternatives)
+	case *regexp_ast.Quantifier:
+		if q, ok := f.element(n.Element.(regexp_ast.Element)).(regexp_ast.QuantifiableElement); ok {
+			n.Element = q
+			q.(regexp_ast.Node).SetParent(n)
+		}
+		// x{1} -> x
+		if f.style.Minify && n.Min == 1 && n.Max == 1 {
+			node := n.Element.(regexp_ast.Node)
+			node.SetParent(n.Parent)
+			return n.Element.(regexp_ast.Element)
+		}
+	}
+	return el
+}
+
+type interval struct {
+	min int
+	max int
+}
+
+// characterClass replaces the elements of cc with sorted and merged ones.
+// Which interval is written as a range depends on the style.
+func (f *formatter) characterClass(cc *regexp_ast.CharacterClass) {
+	var intervals []interval
+	for _, el := range cc.Elements {
+		switch n := el.(type) {
+		case *regexp_ast.Character:
+			intervals = append(intervals, interval{n.Value, n.Value})
+		case *regexp_ast.CharacterClassRange:
+			intervals = append(intervals, interval{n.Min.Value, n.Max.Value})
+		default:
+			// Leave classes with other elements as they are
+			return
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].min < intervals[j].min
+	})
+
+	var merged []interval
+	for _, iv := range intervals {
+		if last := len(merged) - 1; last >= 0 && iv.min <= merged[last].max+1 {
+			if iv.max > merged[last].max {
+				merged[last].max = iv.max
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+
+	elements := []regexp_ast.CharacterClassElement{}
+	for _, iv := range merged {
+		if f.useRange(iv) {
+			r := &regexp_ast.CharacterClassRange{Parent: cc, Loc: cc.Loc}
+			r.Min = &regexp_ast.Character{Parent: r, Loc: cc.Loc, Value: iv.min}
+			r.Max = &regexp_ast.Character{Parent: r, Loc: cc.Loc, Value: iv.max}
+			elements = append(elements, r)
+			continue
+		}
+		for cp := iv.min; cp <= iv.max; cp++ {
+			elements = append(elements, &regexp_ast.Character{Parent: cc, Loc: cc.Loc, Value: cp})
+		}
+	}
+	cc.Elements = elements
+}
+
+// useRange returns whether iv should be written as a range rather than as
+// each of its characters.
+func (f *formatter) useRange(iv interval) bool {
+	size := iv.max - iv.min + 1
+	if size <= 2 {
+		return false
+	}
+	if !f.style.Minify {
+		return true
+	}
+	// Compare the printed lengths, since escapes make characters longer
+	opts := printer.Options{ASCIIOnly: f.style.ASCIIOnly}
+	width := func(cp int) int {
+		cc := &regexp_ast.CharacterClass{}
+		cc.Elements = append(cc.Elements, &regexp_ast.Character{Parent: cc, Value: cp})
+		// Without the brackets
+		return len([]rune(printer.PrintWithOptions(cc, f.u, opts))) - 2
+	}
+	if size > 4 {
+		return true
+	}
+	chars := 0
+	for cp := iv.min; cp <= iv.max; cp++ {
+		chars += width(cp)
+	}
+	return width(iv.min)+1+width(iv.max) < chars
+}
@@ -0,0 +1,118 @@
+package formatter_test
+
+import (
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/formatter"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputU     bool
+		inputStyle formatter.Style
+		wantOutput string
+	}{
+		{
+			name:       "不要なエスケープを取り除く",
+			inputS:     `\x41B\u{43}\/`,
+			inputU:     true,
+			wantOutput: `ABC\/`,
+		},
+		{
+			name:       "量指定子を短い形に正規化する",
+			inputS:     `a{0,}b{1,}c{0,1}?d{3,3}`,
+			inputU:     true,
+			wantOutput: `a*b+c??d{3}`,
+		},
+		{
+			name:       "文字クラスを並べ替えて範囲にまとめる",
+			inputS:     `[zcabdx-y]`,
+			inputU:     true,
+			wantOutput: `[a-dx-z]`,
+		},
+		{
+			name:       "2文字の範囲は文字として書く",
+			inputS:     `[a-b0-1]`,
+			inputU:     true,
+			wantOutput: `[01ab]`,
+		},
+		{
+			name:       "重複する範囲をまとめる",
+			inputS:     `[a-fc-kaz-]`,
+			inputU:     true,
+			wantOutput: `[-a-kz]`,
+		},
+		{
+			name:       "ASCII 以外の文字をエスケープする",
+			inputS:     `あ[い-お]`,
+			inputU:     true,
+			inputStyle: formatter.Style{ASCIIOnly: true},
+			wantOutput: `\u3042[\u3044-\u304A]`,
+		},
+		{
+			name:       "minify で1文字の文字クラスと {1} を取り除く",
+			inputS:     `[a]b{1}[.]+[^c]`,
+			inputU:     true,
+			inputStyle: formatter.Style{Minify: true},
+			wantOutput: `ab\.+[^c]`,
+		},
+		{
+			name:       "minify で短くなる場合だけ範囲を使う",
+			inputS:     `[abc][a-d]`,
+			inputU:     true,
+			inputStyle: formatter.Style{Minify: true},
+			wantOutput: `[abc][a-d]`,
+		},
+		{
+			name:       "グループとアサーションの中も整形する",
+			inputS:     `(?:[ba]{0,})(?<n>[c-d]|\x65)(?=[zxy])`,
+			inputU:     true,
+			wantOutput: `(?:[ab]*)(?<n>[cd]|e)(?=[x-z])`,
+		},
+		{
+			name:       "minify で後方参照の後の数字を含む文字クラスを取り除く",
+			inputS:     `(a)\1[0]{1}`,
+			inputU:     true,
+			inputStyle: formatter.Style{Minify: true},
+			wantOutput: `(a)\1\x30`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := formatter.Format(tt.inputS, tt.inputU, tt.inputStyle)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	for _, s := range []string{`[zcabdx-y]+?|\x41{0,}`, `[\^a-]`, `[-a-c^]`, `[\]\\]`, `(a)\1[0]|(?<=[ba])`} {
+		for _, style := range []formatter.Style{{}, {Minify: true}, {ASCIIOnly: true}} {
+			once, err := formatter.Format(s, true, style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			twice, err := formatter.Format(once, true, style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if once != twice {
+				t.Errorf("Format is not idempotent for %q: %q, %q", s, once, twice)
+			}
+		}
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	if _, err := formatter.Format(`[a`, true, formatter.Style{}); err == nil {
+		t.Error("Expected an error")
+	}
+}
@@ -9,16 +9,22 @@ type Lexer struct {
 	 文字コードに関するユーティリティ
 	 ユニコードモードかどうかによって実装が異なる
 	*/
-	cu *char_code_utils.CharCodeUtils
+	cu char_code_utils.CharCodeUtils
 
-	// 現在見ている位置
+	/*
+	 現在見ている位置
+	 ユニコードモードかどうかに関わらず UTF-16 のコードユニット単位
+	*/
 	I int
 
 	// 現在見ている文字の width
 	w int
 
-	// ソースの文字列
-	s string
+	/*
+	 ソースを UTF-16 のコードユニットに一度だけデコードしたもの
+	 文字を読むたびにソース全体をエンコードし直さないようにする
+	*/
+	units []uint16
 
 	/*
 	 現在のコードポイント
@@ -28,29 +34,26 @@ type Lexer struct {
 }
 
 func NewLexer(s string, u bool) *Lexer {
-	var cu char_code_utils.CharCodeUtils
+	t := &Lexer{}
+	t.Reset(s, u)
+	return t
+}
+
+// Reset はソースとモードを差し替えて先頭から読み直す。デコード用のバッファは再利用する。
+func (t *Lexer) Reset(s string, u bool) {
 	if u {
-		cu = &char_code_utils.Unicode{}
+		t.cu = &char_code_utils.Unicode{}
 	} else {
-		cu = &char_code_utils.Legacy{}
-	}
-
-	i := 0
-	cp := cu.At(s, i)
-	w := cu.Width(cp)
-	return &Lexer{
-		cu: &cu,
-		I:  i,
-		w:  w,
-		s:  s,
-		CP: cp,
+		t.cu = &char_code_utils.Legacy{}
 	}
+	t.units = char_code_utils.Encode(s, t.units[:0])
+	t.Rewind(0)
 }
 
 func (t *Lexer) Next() {
 	t.I = t.I + t.w
-	t.CP = (*t.cu).At(t.s, t.I)
-	t.w = (*t.cu).Width(t.CP)
+	t.CP = t.cu.AtUnits(t.units, t.I)
+	t.w = t.cu.Width(t.CP)
 }
 
 func (t *Lexer) Eat(c int) bool {
@@ -61,12 +64,27 @@ func (t *Lexer) Eat(c int) bool {
 	return false
 }
 
+// Peek は現在の文字の次のコードポイントを返す。末尾では -1 を返す。
+func (t *Lexer) Peek() int {
+	return t.cu.AtUnits(t.units, t.I+t.w)
+}
+
+// Eat2 は c1 と c2 が続く場合にだけ両方を読み進める。
+func (t *Lexer) Eat2(c1 int, c2 int) bool {
+	if t.CP == c1 && t.Peek() == c2 {
+		t.Next()
+		t.Next()
+		return true
+	}
+	return false
+}
+
 func (t *Lexer) Match(c int) bool {
 	return t.CP == c
 }
 
 func (t *Lexer) Rewind(i int) {
 	t.I = i
-	t.CP = (*t.cu).At(t.s, t.I)
-	t.w = (*t.cu).Width(t.CP)
+	t.CP = t.cu.AtUnits(t.units, t.I)
+	t.w = t.cu.Width(t.CP)
 }
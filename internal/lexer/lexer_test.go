@@ -1,6 +1,8 @@
 package lexer_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/sosukesuzuki/regexpp-go/internal/lexer"
@@ -129,3 +131,21 @@ func TestEat(t *testing.T) {
 		})
 	}
 }
+
+// 100 KB 程度の生成されたパターンでも文字数に比例した時間で読めることを確認する
+func BenchmarkNext(b *testing.B) {
+	for _, size := range []int{10_000, 100_000, 1_000_000} {
+		s := strings.Repeat("あ𠮟abc|", size/10)
+		for _, u := range []bool{false, true} {
+			b.Run(fmt.Sprintf("size=%d/u=%t", len(s), u), func(b *testing.B) {
+				b.SetBytes(int64(len(s)))
+				for i := 0; i < b.N; i++ {
+					tok := lexer.NewLexer(s, u)
+					for tok.CP != -1 {
+						tok.Next()
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package offset
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// LineStarts returns the offsets, in unit u, at which each line of text
+// starts. Lines are split by ECMAScript LineTerminatorSequence: LF, CR, CRLF,
+// U+2028 and U+2029. The first entry is always 0.
+func LineStarts(text string, u Unit) []int {
+	starts := []int{0}
+	off := 0
+	for i := 0; i < len(text); {
+		r, w := utf8.DecodeRuneInString(text[i:])
+		i += w
+		off += width(r, w, u)
+		switch r {
+		case '\r':
+			if i < len(text) && text[i] == '\n' {
+				i++
+				off++
+			}
+			starts = append(starts, off)
+		case '\n', '\u2028', '\u2029':
+			starts = append(starts, off)
+		}
+	}
+	return starts
+}
+
+// Position returns the 1-based line and the 0-based column of off, given the
+// line starts of the text. The column is in the same unit as lineStarts.
+func Position(lineStarts []int, off int) (line int, column int) {
+	if len(lineStarts) == 0 {
+		return 1, off
+	}
+	i := sort.SearchInts(lineStarts, off+1) - 1
+	if i < 0 {
+		i = 0
+	}
+	return i + 1, off - lineStarts[i]
+}
+
+func width(r rune, w int, u Unit) int {
+	switch u {
+	case CodePoint:
+		return 1
+	case UTF8:
+		return w
+	default:
+		if r > 0xffff {
+			return 2
+		}
+		return 1
+	}
+}
@@ -0,0 +1,139 @@
+// Package offset defines the units that source offsets can be expressed in and
+// converts offsets between them.
+//
+// The offset model of this module is the one of ECMAScript and regexpp: the
+// lexer always counts UTF-16 code units, regardless of the `u` flag. A source
+// string is a Go string and is decoded as UTF-8; each invalid byte decodes to
+// U+FFFD and counts as one code point. Callers that want another unit can ask
+// the parser to convert every Loc, or convert offsets themselves with a
+// Converter.
+package offset
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+type Unit int
+
+const (
+	// UTF-16 code units. This is the unit of JavaScript string indexes and
+	// the default unit of Loc.
+	UTF16 Unit = iota
+	// Unicode code points, i.e. indexes into []rune(source).
+	CodePoint
+	// UTF-8 bytes, i.e. indexes into the Go string.
+	UTF8
+)
+
+func (u Unit) String() string {
+	switch u {
+	case UTF16:
+		return "UTF16"
+	case CodePoint:
+		return "CodePoint"
+	case UTF8:
+		return "UTF8"
+	default:
+		return fmt.Sprintf("Unit(%d)", int(u))
+	}
+}
+
+// Converter converts offsets into one source string between units.
+type Converter struct {
+	// The offset of the k-th code point in each unit. Both slices have
+	// (number of code points + 1) entries; the last one is the length.
+	utf16 []int
+	utf8  []int
+}
+
+func NewConverter(s string) *Converter {
+	c := &Converter{}
+	c.Reset(s)
+	return c
+}
+
+// Reset re-targets the converter to s, reusing its buffers.
+func (c *Converter) Reset(s string) {
+	c.utf16 = c.utf16[:0]
+	c.utf8 = c.utf8[:0]
+	u16 := 0
+	for i := 0; i < len(s); {
+		r, w := utf8.DecodeRuneInString(s[i:])
+		c.utf16 = append(c.utf16, u16)
+		c.utf8 = append(c.utf8, i)
+		u16 += width(r, w, UTF16)
+		i += w
+	}
+	c.utf16 = append(c.utf16, u16)
+	c.utf8 = append(c.utf8, len(s))
+}
+
+// Len returns the length of the source in the given unit.
+func (c *Converter) Len(u Unit) int {
+	return c.table(u, len(c.utf8)-1)
+}
+
+// Convert converts off from one unit to another. An offset that points into
+// the middle of a code point (e.g. between the two halves of a surrogate
+// pair) is rounded down to the start of that code point. Offsets out of range
+// are clamped.
+func (c *Converter) Convert(off int, from Unit, to Unit) int {
+	if from == to {
+		return off
+	}
+	k, _ := c.index(off, from)
+	return c.table(to, k)
+}
+
+// ConvertEnd is like Convert but rounds offsets inside a code point up to the
+// end of it. Use it for the end of a range so that the range never shrinks.
+func (c *Converter) ConvertEnd(off int, from Unit, to Unit) int {
+	if from == to {
+		return off
+	}
+	k, exact := c.index(off, from)
+	if !exact {
+		k++
+	}
+	return c.table(to, k)
+}
+
+// index returns the index of the code point containing off, and whether off
+// is exactly at its start.
+func (c *Converter) index(off int, u Unit) (int, bool) {
+	n := len(c.utf8) - 1
+	if off <= 0 {
+		return 0, true
+	}
+	if u == CodePoint {
+		if off > n {
+			return n, true
+		}
+		return off, true
+	}
+	t := c.utf16
+	if u == UTF8 {
+		t = c.utf8
+	}
+	if off >= t[n] {
+		return n, true
+	}
+	k := sort.SearchInts(t, off)
+	if t[k] == off {
+		return k, true
+	}
+	return k - 1, false
+}
+
+func (c *Converter) table(u Unit, k int) int {
+	switch u {
+	case CodePoint:
+		return k
+	case UTF8:
+		return c.utf8[k]
+	default:
+		return c.utf16[k]
+	}
+}
@@ -0,0 +1,147 @@
+package offset_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputOff   int
+		inputFrom  offset.Unit
+		inputTo    offset.Unit
+		wantOutput int
+	}{
+		{
+			name:       "ASCII のみの場合は単位に関わらず同じオフセットを返す",
+			inputS:     "abc",
+			inputOff:   2,
+			inputFrom:  offset.UTF16,
+			inputTo:    offset.UTF8,
+			wantOutput: 2,
+		},
+		{
+			name:       "`う`の UTF-16 のオフセットを UTF-8 のオフセットに変換する",
+			inputS:     "あいう",
+			inputOff:   2,
+			inputFrom:  offset.UTF16,
+			inputTo:    offset.UTF8,
+			wantOutput: 6,
+		},
+		{
+			name:       "`𠮟`の後ろの UTF-16 のオフセットをコードポイントのオフセットに変換する",
+			inputS:     "あ𠮟い",
+			inputOff:   3,
+			inputFrom:  offset.UTF16,
+			inputTo:    offset.CodePoint,
+			wantOutput: 2,
+		},
+		{
+			name:       "`𠮟`の後ろのコードポイントのオフセットを UTF-16 のオフセットに変換する",
+			inputS:     "あ𠮟い",
+			inputOff:   2,
+			inputFrom:  offset.CodePoint,
+			inputTo:    offset.UTF16,
+			wantOutput: 3,
+		},
+		{
+			name:       "サロゲートペアの途中のオフセットはコードポイントの先頭に切り捨てる",
+			inputS:     "あ𠮟い",
+			inputOff:   2,
+			inputFrom:  offset.UTF16,
+			inputTo:    offset.UTF8,
+			wantOutput: 3,
+		},
+		{
+			name:       "範囲外のオフセットは末尾に丸める",
+			inputS:     "あ𠮟い",
+			inputOff:   100,
+			inputFrom:  offset.UTF8,
+			inputTo:    offset.UTF16,
+			wantOutput: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := offset.NewConverter(tt.inputS)
+			o := c.Convert(tt.inputOff, tt.inputFrom, tt.inputTo)
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected offset, expected %d, actual %d", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestConvertEnd(t *testing.T) {
+	c := offset.NewConverter("あ𠮟い")
+	if o := c.ConvertEnd(2, offset.UTF16, offset.UTF8); o != 7 {
+		t.Errorf("Unexpected offset, expected %d, actual %d", 7, o)
+	}
+	if o := c.ConvertEnd(3, offset.UTF16, offset.UTF8); o != 7 {
+		t.Errorf("Unexpected offset, expected %d, actual %d", 7, o)
+	}
+}
+
+func TestLineStarts(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputU     offset.Unit
+		wantOutput []int
+	}{
+		{
+			name:       "LF, CRLF, CR で行を分割する",
+			inputS:     "a\nb\r\nc\rd",
+			inputU:     offset.UTF16,
+			wantOutput: []int{0, 2, 5, 7},
+		},
+		{
+			name:       "U+2028 と U+2029 で行を分割する",
+			inputS:     "a\u2028b\u2029c",
+			inputU:     offset.UTF8,
+			wantOutput: []int{0, 4, 8},
+		},
+		{
+			name:       "サロゲートペアは UTF-16 で 2 つと数える",
+			inputS:     "𠮟\n𠮟",
+			inputU:     offset.UTF16,
+			wantOutput: []int{0, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := offset.LineStarts(tt.inputS, tt.inputU)
+			if !reflect.DeepEqual(o, tt.wantOutput) {
+				t.Errorf("Unexpected line starts, expected %v, actual %v", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestPosition(t *testing.T) {
+	lines := []int{0, 2, 5, 7}
+	tests := []struct {
+		inputOff   int
+		wantLine   int
+		wantColumn int
+	}{
+		{inputOff: 0, wantLine: 1, wantColumn: 0},
+		{inputOff: 1, wantLine: 1, wantColumn: 1},
+		{inputOff: 2, wantLine: 2, wantColumn: 0},
+		{inputOff: 6, wantLine: 3, wantColumn: 1},
+		{inputOff: 8, wantLine: 4, wantColumn: 1},
+	}
+
+	for _, tt := range tests {
+		line, column := offset.Position(lines, tt.inputOff)
+		if line != tt.wantLine || column != tt.wantColumn {
+			t.Errorf("Unexpected position of %d, expected %d:%d, actual %d:%d", tt.inputOff, tt.wantLine, tt.wantColumn, line, column)
+		}
+	}
+}
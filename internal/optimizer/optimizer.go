@@ -0,0 +1,390 @@
+// Package optimizer rewrites patterns into smaller ones that match exactly
+// the same strings, with the same priorities, under the ECMAScript semantics.
+//
+// The rewrites are:
+//
+//   - `x{1}` to `x` and `x{0}` to nothing,
+//   - sorting, deduplicating and merging class elements into ranges
+//     (`[cab-d]` to `[a-d]`), and `[a]` to `a`,
+//   - dropping an alternative that is equal to an earlier one (`a|b|a` to
+//     `a|b`),
+//   - merging adjacent alternatives that match a single character into one
+//     class (`a|b|[c-e]` to `[a-e]`), leaving out the characters that a `.`
+//     among them matches (`a|.|\n` to `\n|.`),
+//   - unwrapping a non-capturing group of one alternative (`(?:ab)c` to
+//     `abc`, `(?:a)*` to `a*`), and a quantifier of a quantifier when both
+//     are greedy and unbounded (`(?:a*)*` to `a*`, `(?:a+)+` to `a+`).
+//
+// The alternatives of groups and lookarounds are rewritten like the ones of
+// the pattern. Only adjacent alternatives are merged: moving a single
+// character alternative across a longer one would change which of them
+// matches first. Nothing that contains a capturing group is dropped or
+// unwrapped, so that the group numbers stay the same.
+package optimizer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// Optimize returns an optimized copy of pattern, whose Locs are the ones of
+// its printed source. pattern itself is left untouched.
+func Optimize(pattern *regexp_ast.Pattern, flags regexp_ast.Flags) *regexp_ast.Pattern {
+	o := &optimizer{flags: flags}
+	p := regexp_ast.Clone(pattern).(*regexp_ast.Pattern)
+	o.pattern(p)
+	printer.Layout(p, o.unicode())
+	return p
+}
+
+type optimizer struct {
+	flags regexp_ast.Flags
+}
+
+// unicode returns whether the pattern is in unicode mode, with `u` or `v`.
+func (o *optimizer) unicode() bool {
+	return o.flags.Unicode || o.flags.UnicodeSets
+}
+
+// maxCharacter returns the largest character a class can match: a code point
+// in unicode mode and a code unit otherwise.
+func (o *optimizer) maxCharacter() int {
+	if o.unicode() {
+		return unicode_consts.MaxCodePoint
+	}
+	return 0xffff
+}
+
+func (o *optimizer) pattern(pattern *regexp_ast.Pattern) {
+	pattern.Alternatives = o.alternatives(pattern, pattern.Alternatives)
+}
+
+// alternatives returns the optimized alternatives of parent.
+func (o *optimizer) alternatives(parent regexp_ast.Node, alts []*regexp_ast.Alternative) []*regexp_ast.Alternative {
+	seen := map[string]bool{}
+	kept := []*regexp_ast.Alternative{}
+	for _, alt := range alts {
+		o.alternative(alt)
+		// An alternative equal to an earlier one is only tried after the
+		// earlier one failed the same way
+		key := printer.Print(alt, o.unicode())
+		if seen[key] && !hasCapturingGroup(alt) {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, alt)
+	}
+	return o.mergeAlternatives(parent, kept)
+}
+
+func (o *optimizer) alternative(alt *regexp_ast.Alternative) {
+	elements := []regexp_ast.Element{}
+	for _, el := range alt.Elements {
+		el = o.element(el)
+		if el == nil {
+			continue
+		}
+		// (?:ab) -> ab
+		if g, ok := el.(*regexp_ast.Group); ok && len(g.Alternatives) == 1 {
+			for _, inner := range g.Alternatives[0].Elements {
+				inner.(regexp_ast.Node).SetParent(alt)
+				elements = append(elements, inner)
+			}
+			continue
+		}
+		el.(regexp_ast.Node).SetParent(alt)
+		elements = append(elements, el)
+	}
+	alt.Elements = elements
+}
+
+// element returns the optimized el, or nil if it always matches the empty
+// string.
+func (o *optimizer) element(el regexp_ast.Element) regexp_ast.Element {
+	switch n := el.(type) {
+	case *regexp_ast.CharacterClass:
+		return o.characterClass(n)
+	case *regexp_ast.Group:
+		n.Alternatives = o.alternatives(n, n.Alternatives)
+	case *regexp_ast.CapturingGroup:
+		n.Alternatives = o.alternatives(n, n.Alternatives)
+	case *regexp_ast.LookaroundAssertion:
+		n.Alternatives = o.alternatives(n, n.Alternatives)
+	case *regexp_ast.Quantifier:
+		if n.Max == 0 && !hasCapturingGroup(n) {
+			return nil
+		}
+		inner := o.element(n.Element.(regexp_ast.Element))
+		if n.Min == 1 && n.Max == 1 {
+			return inner
+		}
+		switch unwrapped := unwrapGroup(inner).(type) {
+		case *regexp_ast.Quantifier:
+			// (?:a*)* -> a*
+			if merged := mergeQuantifiers(n, unwrapped); merged != nil {
+				return merged
+			}
+		default:
+			// (?:a)* -> a*
+			inner = unwrapped
+		}
+		n.Element = inner.(regexp_ast.QuantifiableElement)
+		inner.(regexp_ast.Node).SetParent(n)
+	}
+	return el
+}
+
+// unwrapGroup returns the only element of a quantified group, if it is a
+// quantifier or can be quantified without the group, or el itself. A
+// lookaround can't, since it can't be quantified in unicode mode.
+func unwrapGroup(el regexp_ast.Element) regexp_ast.Element {
+	g, ok := el.(*regexp_ast.Group)
+	if !ok || len(g.Alternatives) != 1 || len(g.Alternatives[0].Elements) != 1 {
+		return el
+	}
+	switch inner := g.Alternatives[0].Elements[0].(type) {
+	case *regexp_ast.Quantifier:
+		return inner
+	case *regexp_ast.LookaroundAssertion:
+		return el
+	case regexp_ast.QuantifiableElement:
+		return inner.(regexp_ast.Element)
+	}
+	return el
+}
+
+// mergeQuantifiers returns one quantifier equivalent to outer applied to
+// inner, or nil if there is none simpler. Only greedy unbounded quantifiers of
+// at most one minimum are merged: `(?:a*)*`, `(?:a+)*` and `(?:a*)+` are
+// `a*` and `(?:a+)+` is `a+`.
+func mergeQuantifiers(outer *regexp_ast.Quantifier, inner *regexp_ast.Quantifier) *regexp_ast.Quantifier {
+	for _, q := range []*regexp_ast.Quantifier{outer, inner} {
+		if !q.Greety || q.Max != math.MaxInt || q.Min > 1 {
+			return nil
+		}
+	}
+	if hasCapturingGroup(inner) {
+		return nil
+	}
+	inner.Min *= outer.Min
+	return inner
+}
+
+// hasCapturingGroup returns whether node is or contains a capturing group.
+func hasCapturingGroup(node regexp_ast.Node) bool {
+	found := false
+	regexp_ast.Inspect(node, func(n regexp_ast.Node) bool {
+		if _, ok := n.(*regexp_ast.CapturingGroup); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// characterClass canonicalizes the elements of cc, and returns a Character
+// instead if cc matches only one.
+func (o *optimizer) characterClass(cc *regexp_ast.CharacterClass) regexp_ast.Element {
+	set, ok := classIntervals(cc)
+	if !ok {
+		return cc
+	}
+	if !cc.Negate && len(set) == 1 && set[0].min == set[0].max {
+		return &regexp_ast.Character{Value: set[0].min}
+	}
+	cc.Elements = classElements(cc, set)
+	return cc
+}
+
+// mergeAlternatives merges each run of adjacent alternatives that match a
+// single character.
+func (o *optimizer) mergeAlternatives(parent regexp_ast.Node, alts []*regexp_ast.Alternative) []*regexp_ast.Alternative {
+	merged := []*regexp_ast.Alternative{}
+	for i := 0; i < len(alts); {
+		j := i
+		for j < len(alts) && o.isSingleCharacter(alts[j]) {
+			j++
+		}
+		if j-i < 2 {
+			merged = append(merged, alts[i])
+			i++
+			continue
+		}
+		merged = append(merged, o.mergeRun(parent, alts[i:j])...)
+		i = j
+	}
+	return merged
+}
+
+// isSingleCharacter returns whether alt matches exactly one character whose
+// set mergeAlternatives can compute.
+func (o *optimizer) isSingleCharacter(alt *regexp_ast.Alternative) bool {
+	if len(alt.Elements) != 1 {
+		return false
+	}
+	switch n := alt.Elements[0].(type) {
+	case *regexp_ast.Character, *regexp_ast.AnyCharacterSet:
+		return true
+	case *regexp_ast.CharacterClass:
+		_, ok := classIntervals(n)
+		// With `i`, [^S] doesn't match the complement of what [S] matches,
+		// e.g. /[^a]|A/i matches "A" but /[^a]/i doesn't
+		return ok && !(n.Negate && o.flags.IgnoreCase)
+	default:
+		return false
+	}
+}
+
+func (o *optimizer) mergeRun(parent regexp_ast.Node, run []*regexp_ast.Alternative) []*regexp_ast.Alternative {
+	var set []interval
+	var dot *regexp_ast.AnyCharacterSet
+	for _, alt := range run {
+		switch n := alt.Elements[0].(type) {
+		case *regexp_ast.Character:
+			set = append(set, interval{n.Value, n.Value})
+		case *regexp_ast.AnyCharacterSet:
+			dot = n
+		case *regexp_ast.CharacterClass:
+			s, _ := classIntervals(n)
+			if n.Negate {
+				s = complement(s, o.maxCharacter())
+			}
+			set = append(set, s...)
+		}
+	}
+	set = normalize(set)
+	if dot != nil {
+		set = o.notMatchedByDot(set)
+	}
+
+	alts := []*regexp_ast.Alternative{}
+	if len(set) > 0 {
+		alt := &regexp_ast.Alternative{Parent: parent}
+		alt.Elements = []regexp_ast.Element{o.setElement(alt, set)}
+		alts = append(alts, alt)
+	}
+	if dot != nil {
+		alt := &regexp_ast.Alternative{Parent: parent}
+		dot.Parent = alt
+		alt.Elements = []regexp_ast.Element{dot}
+		alts = append(alts, alt)
+	}
+	return alts
+}
+
+// notMatchedByDot returns the characters of the normalized set that `.`
+// doesn't match.
+func (o *optimizer) notMatchedByDot(set []interval) []interval {
+	if o.flags.DotAll {
+		return nil
+	}
+	var rest []interval
+	for _, lt := range []int{
+		unicode_consts.LineFeed,
+		unicode_consts.CarriageReturn,
+		unicode_consts.LineSeparator,
+		unicode_consts.ParagraphSeparator,
+	} {
+		for _, iv := range set {
+			if iv.min <= lt && lt <= iv.max {
+				rest = append(rest, interval{lt, lt})
+			}
+		}
+	}
+	return normalize(rest)
+}
+
+// setElement returns the shortest element that matches exactly set.
+func (o *optimizer) setElement(parent regexp_ast.Node, set []interval) regexp_ast.Element {
+	if len(set) == 1 && set[0].min == set[0].max {
+		return &regexp_ast.Character{Parent: parent, Value: set[0].min}
+	}
+	cc := &regexp_ast.CharacterClass{Parent: parent, UnicodeSets: o.flags.UnicodeSets}
+	if c := complement(set, o.maxCharacter()); !o.flags.IgnoreCase && len(c) < len(set) {
+		cc.Negate = true
+		set = c
+	}
+	cc.Elements = classElements(cc, set)
+	return cc
+}
+
+type interval struct {
+	min int
+	max int
+}
+
+// classIntervals returns the normalized intervals of the elements of cc,
+// ignoring Negate. It returns false if cc has elements other than
+// characters and ranges.
+func classIntervals(cc *regexp_ast.CharacterClass) ([]interval, bool) {
+	var set []interval
+	for _, el := range cc.Elements {
+		switch n := el.(type) {
+		case *regexp_ast.Character:
+			set = append(set, interval{n.Value, n.Value})
+		case *regexp_ast.CharacterClassRange:
+			set = append(set, interval{n.Min.Value, n.Max.Value})
+		default:
+			return nil, false
+		}
+	}
+	return normalize(set), true
+}
+
+// normalize sorts set and merges overlapping and adjacent intervals.
+func normalize(set []interval) []interval {
+	sort.Slice(set, func(i, j int) bool {
+		return set[i].min < set[j].min
+	})
+	merged := []interval{}
+	for _, iv := range set {
+		if last := len(merged) - 1; last >= 0 && iv.min <= merged[last].max+1 {
+			if iv.max > merged[last].max {
+				merged[last].max = iv.max
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// complement returns the intervals in 0..max that are not in the normalized
+// set.
+func complement(set []interval, max int) []interval {
+	c := []interval{}
+	next := 0
+	for _, iv := range set {
+		if iv.min > next {
+			c = append(c, interval{next, iv.min - 1})
+		}
+		next = iv.max + 1
+	}
+	if next <= max {
+		c = append(c, interval{next, max})
+	}
+	return c
+}
+
+// classElements returns the elements of a class matching the normalized set.
+// Intervals of one or two characters are written as characters.
+func classElements(cc *regexp_ast.CharacterClass, set []interval) []regexp_ast.CharacterClassElement {
+	elements := []regexp_ast.CharacterClassElement{}
+	for _, iv := range set {
+		if iv.max-iv.min >= 2 {
+			r := &regexp_ast.CharacterClassRange{Parent: cc}
+			r.Min = &regexp_ast.Character{Parent: r, Value: iv.min}
+			r.Max = &regexp_ast.Character{Parent: r, Value: iv.max}
+			elements = append(elements, r)
+			continue
+		}
+		for cp := iv.min; cp <= iv.max; cp++ {
+			elements = append(elements, &regexp_ast.Character{Parent: cc, Value: cp})
+		}
+	}
+	return elements
+}
@@ -0,0 +1,196 @@
+package optimizer_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/optimizer"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputFlags string
+		wantOutput string
+	}{
+		{
+			name:       "1文字の選択肢を文字クラスにまとめる",
+			inputS:     `a|b|c`,
+			inputFlags: "u",
+			wantOutput: `[a-c]`,
+		},
+		{
+			name:       "文字クラスの選択肢もまとめて範囲にする",
+			inputS:     `a|[b-d]|e|xy`,
+			inputFlags: "u",
+			wantOutput: `[a-e]|xy`,
+		},
+		{
+			name:       "隣り合わない選択肢はまとめない",
+			inputS:     `a|cd|c`,
+			inputFlags: "u",
+			wantOutput: `a|cd|c`,
+		},
+		{
+			name:       "重複する選択肢を取り除く",
+			inputS:     `ab|c+|ab`,
+			inputFlags: "u",
+			wantOutput: `ab|c+`,
+		},
+		{
+			name:       "{1} と {0} を取り除く",
+			inputS:     `a{1}b{0}c{0,0}?d{1,1}?`,
+			inputFlags: "u",
+			wantOutput: `ad`,
+		},
+		{
+			name:       "文字クラスの範囲をまとめる",
+			inputS:     `[c-fa-da][x][^y]+`,
+			inputFlags: "u",
+			wantOutput: `[a-f]x[^y]+`,
+		},
+		{
+			name:       "補集合の方が短ければ否定の文字クラスにする",
+			inputS:     `[^a-c]|b`,
+			inputFlags: "u",
+			wantOutput: `[^ac]`,
+		},
+		{
+			name:       "i フラグでは否定の文字クラスをまとめない",
+			inputS:     `[^a]|A`,
+			inputFlags: "iu",
+			wantOutput: `[^a]|A`,
+		},
+		{
+			name:       "ドットに含まれる選択肢を取り除く",
+			inputS:     `a|.|b`,
+			inputFlags: "u",
+			wantOutput: `.`,
+		},
+		{
+			name:       "改行文字はドットに含まれない",
+			inputS:     `\n|.|b`,
+			inputFlags: "u",
+			wantOutput: `\n|.`,
+		},
+		{
+			name:       "s フラグではドットが改行文字を含む",
+			inputS:     `\n|.|b`,
+			inputFlags: "su",
+			wantOutput: `.`,
+		},
+		{
+			name:       "非ユニコードモードでは補集合が U+FFFF までになる",
+			inputS:     `[^a]|a`,
+			inputFlags: "",
+			wantOutput: `[^]`,
+		},
+		{
+			name:       "v フラグでは孤立サロゲートの組とアストラル文字を区別する",
+			inputS:     `\u{D83D}\u{DE00}|\u{1F600}|\u{D83D}\u{DE00}`,
+			inputFlags: "v",
+			wantOutput: `\u{D83D}\u{DE00}|😀`,
+		},
+		{
+			name:       "v フラグでは構文文字をエスケープしたクラスにまとめる",
+			inputS:     `\(|x|[\-]|[\q{}]|[[a]--a]`,
+			inputFlags: "v",
+			wantOutput: `[\(\-x]|[\q{}]|[[a]--a]`,
+		},
+		{
+			name:       "選択肢が1つの非キャプチャグループを展開する",
+			inputS:     `(?:a)(?:bc)d|(?:)`,
+			inputFlags: "u",
+			wantOutput: `abcd|`,
+		},
+		{
+			name:       "量指定された非キャプチャグループの要素を展開する",
+			inputS:     `(?:a)*(?:[b])+(?:ab)?`,
+			inputFlags: "u",
+			wantOutput: `a*b+(?:ab)?`,
+		},
+		{
+			name:       "入れ子の無制限な量指定子をまとめる",
+			inputS:     `(?:a*)*(?:b+)*(?:c*)+(?:d+)+`,
+			inputFlags: "u",
+			wantOutput: `a*b*c*d+`,
+		},
+		{
+			name:       "非貪欲か有限の量指定子はまとめない",
+			inputS:     `(?:a*?)*(?:b{2,})*(?:c?)*`,
+			inputFlags: "u",
+			wantOutput: `(?:a*?)*(?:b{2,})*(?:c?)*`,
+		},
+		{
+			name:       "グループと先読みの中の選択肢もまとめる",
+			inputS:     `(?:a|b|c)d(?=e|f)(g|h|g)`,
+			inputFlags: "u",
+			wantOutput: `[a-c]d(?=[ef])([gh])`,
+		},
+		{
+			name:       "キャプチャグループを含むものは取り除かない",
+			inputS:     `(a)|(a)|(?:(b)*)*|(c){0}\3`,
+			inputFlags: "u",
+			wantOutput: `(a)|(a)|(?:(b)*)*|(c){0}\3`,
+		},
+		{
+			name:       "量指定された先読みはグループから出さない",
+			inputS:     `(?:(?=a))*`,
+			inputFlags: "",
+			wantOutput: `(?:(?=a))*`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := parser.ParseFlags(tt.inputFlags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u := flags.Unicode || flags.UnicodeSets
+			opts := parser.Options{UnicodeSets: flags.UnicodeSets}
+			p := parser.NewParserWithOptions(tt.inputS, u, opts)
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := printer.Print(pattern, u)
+
+			optimized := optimizer.Optimize(pattern, flags)
+			o := printer.Print(optimized, u)
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+			if err := regexp_ast.Verify(optimized); err != nil {
+				t.Errorf("Optimized tree is invalid: %v", err)
+			}
+			rp := parser.NewParserWithOptions(o, u, opts)
+			reparsed, err := rp.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, got := locs(reparsed), locs(optimized); !reflect.DeepEqual(want, got) {
+				t.Errorf("Unexpected Locs, expected %v, actual %v", want, got)
+			}
+			if after := printer.Print(pattern, u); after != before {
+				t.Errorf("Optimize modified its input: %s", after)
+			}
+			if again := printer.Print(optimizer.Optimize(optimized, flags), u); again != o {
+				t.Errorf("Optimize is not idempotent: %s, then %s", o, again)
+			}
+		})
+	}
+}
+
+func locs(node regexp_ast.Node) []regexp_ast.Loc {
+	var ls []regexp_ast.Loc
+	regexp_ast.Inspect(node, func(n regexp_ast.Node) bool {
+		ls = append(ls, n.GetLoc())
+		return true
+	})
+	return ls
+}
@@ -0,0 +1,145 @@
+package parser
+
+import "github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+
+// The node constructors allocate from Options.Arena when one is given.
+
+func (p *Parser) newPattern() *regexp_ast.Pattern {
+	if a := p.opts.Arena; a != nil {
+		return a.NewPattern()
+	}
+	return &regexp_ast.Pattern{}
+}
+
+func (p *Parser) newAlternative() *regexp_ast.Alternative {
+	if a := p.opts.Arena; a != nil {
+		return a.NewAlternative()
+	}
+	return &regexp_ast.Alternative{}
+}
+
+func (p *Parser) newCharacter() *regexp_ast.Character {
+	if a := p.opts.Arena; a != nil {
+		return a.NewCharacter()
+	}
+	return &regexp_ast.Character{}
+}
+
+func (p *Parser) newCharacterClass() *regexp_ast.CharacterClass {
+	if a := p.opts.Arena; a != nil {
+		return a.NewCharacterClass()
+	}
+	return &regexp_ast.CharacterClass{}
+}
+
+func (p *Parser) newAnyCharacterSet() *regexp_ast.AnyCharacterSet {
+	if a := p.opts.Arena; a != nil {
+		return a.NewAnyCharacterSet()
+	}
+	return &regexp_ast.AnyCharacterSet{}
+}
+
+func (p *Parser) newQuantifier() *regexp_ast.Quantifier {
+	if a := p.opts.Arena; a != nil {
+		return a.NewQuantifier()
+	}
+	return &regexp_ast.Quantifier{}
+}
+
+func (p *Parser) newCharacterClassRange() *regexp_ast.CharacterClassRange {
+	if a := p.opts.Arena; a != nil {
+		return a.NewCharacterClassRange()
+	}
+	return &regexp_ast.CharacterClassRange{}
+}
+
+func (p *Parser) newExpressionCharacterClass() *regexp_ast.ExpressionCharacterClass {
+	if a := p.opts.Arena; a != nil {
+		return a.NewExpressionCharacterClass()
+	}
+	return &regexp_ast.ExpressionCharacterClass{}
+}
+
+func (p *Parser) newClassIntersection() *regexp_ast.ClassIntersection {
+	if a := p.opts.Arena; a != nil {
+		return a.NewClassIntersection()
+	}
+	return &regexp_ast.ClassIntersection{}
+}
+
+func (p *Parser) newClassSubtraction() *regexp_ast.ClassSubtraction {
+	if a := p.opts.Arena; a != nil {
+		return a.NewClassSubtraction()
+	}
+	return &regexp_ast.ClassSubtraction{}
+}
+
+func (p *Parser) newClassStringDisjunction() *regexp_ast.ClassStringDisjunction {
+	if a := p.opts.Arena; a != nil {
+		return a.NewClassStringDisjunction()
+	}
+	return &regexp_ast.ClassStringDisjunction{}
+}
+
+func (p *Parser) newStringAlternative() *regexp_ast.StringAlternative {
+	if a := p.opts.Arena; a != nil {
+		return a.NewStringAlternative()
+	}
+	return &regexp_ast.StringAlternative{}
+}
+
+func (p *Parser) newGroup() *regexp_ast.Group {
+	if a := p.opts.Arena; a != nil {
+		return a.NewGroup()
+	}
+	return &regexp_ast.Group{}
+}
+
+func (p *Parser) newCapturingGroup() *regexp_ast.CapturingGroup {
+	if a := p.opts.Arena; a != nil {
+		return a.NewCapturingGroup()
+	}
+	return &regexp_ast.CapturingGroup{}
+}
+
+func (p *Parser) newLookaroundAssertion() *regexp_ast.LookaroundAssertion {
+	if a := p.opts.Arena; a != nil {
+		return a.NewLookaroundAssertion()
+	}
+	return &regexp_ast.LookaroundAssertion{}
+}
+
+func (p *Parser) newEdgeAssertion() *regexp_ast.EdgeAssertion {
+	if a := p.opts.Arena; a != nil {
+		return a.NewEdgeAssertion()
+	}
+	return &regexp_ast.EdgeAssertion{}
+}
+
+func (p *Parser) newWordBoundaryAssertion() *regexp_ast.WordBoundaryAssertion {
+	if a := p.opts.Arena; a != nil {
+		return a.NewWordBoundaryAssertion()
+	}
+	return &regexp_ast.WordBoundaryAssertion{}
+}
+
+func (p *Parser) newBackreference() *regexp_ast.Backreference {
+	if a := p.opts.Arena; a != nil {
+		return a.NewBackreference()
+	}
+	return &regexp_ast.Backreference{}
+}
+
+func (p *Parser) newEscapeCharacterSet() *regexp_ast.EscapeCharacterSet {
+	if a := p.opts.Arena; a != nil {
+		return a.NewEscapeCharacterSet()
+	}
+	return &regexp_ast.EscapeCharacterSet{}
+}
+
+func (p *Parser) newUnicodePropertyCharacterSet() *regexp_ast.UnicodePropertyCharacterSet {
+	if a := p.opts.Arena; a != nil {
+		return a.NewUnicodePropertyCharacterSet()
+	}
+	return &regexp_ast.UnicodePropertyCharacterSet{}
+}
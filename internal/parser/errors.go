@@ -1,16 +1,37 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
 
 type ParserError struct {
-	msg string
-	err error
+	msg   string
+	err   error
+	index int
+	pos   *regexp_ast.Position
 }
 
 func (e *ParserError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("Error from parser: %s", e.msg)
+	}
 	return fmt.Sprintf("Error from parser: %s (%s)", e.msg, e.err.Error())
 }
 
 func (e *ParserError) Unwrap() error {
 	return e.err
 }
+
+// Index returns the offset in the pattern source where the error was found,
+// in the offset.Unit the parser was configured with.
+func (e *ParserError) Index() int {
+	return e.index
+}
+
+// Position returns the position of the error in the host source, or nil if
+// the parser was not given one.
+func (e *ParserError) Position() *regexp_ast.Position {
+	return e.pos
+}
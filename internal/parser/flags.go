@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+// ParseFlags parses the flags of a regular expression literal, e.g. "gu".
+// The error index is the UTF-16 offset of the offending flag.
+func ParseFlags(source string) (regexp_ast.Flags, error) {
+	var flags regexp_ast.Flags
+	// Every valid flag is ASCII, so the byte offset of the first invalid one
+	// is also its UTF-16 offset
+	for index, c := range source {
+		var flag *bool
+		switch c {
+		case 'd':
+			flag = &flags.HasIndices
+		case 'g':
+			flag = &flags.Global
+		case 'i':
+			flag = &flags.IgnoreCase
+		case 'm':
+			flag = &flags.Multiline
+		case 's':
+			flag = &flags.DotAll
+		case 'u':
+			flag = &flags.Unicode
+		case 'v':
+			flag = &flags.UnicodeSets
+		case 'y':
+			flag = &flags.Sticky
+		default:
+			return regexp_ast.Flags{}, &ParserError{msg: fmt.Sprintf("Invalid flag '%c'", c), index: index}
+		}
+		if *flag {
+			return regexp_ast.Flags{}, &ParserError{msg: fmt.Sprintf("Duplicated flag '%c'", c), index: index}
+		}
+		*flag = true
+	}
+	if flags.Unicode && flags.UnicodeSets {
+		return regexp_ast.Flags{}, &ParserError{msg: "Invalid regular expression flags", index: len(source)}
+	}
+	return flags, nil
+}
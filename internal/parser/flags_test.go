@@ -0,0 +1,49 @@
+package parser_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func TestParseFlags(t *testing.T) {
+	flags, err := parser.ParseFlags("ygimsud")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := regexp_ast.Flags{HasIndices: true, Global: true, IgnoreCase: true, Multiline: true, DotAll: true, Unicode: true, Sticky: true}
+	if flags != want {
+		t.Errorf("Unexpected flags, expected %+v, actual %+v", want, flags)
+	}
+	if s := flags.String(); s != "dgimsuy" {
+		t.Errorf("Unexpected string, expected dgimsuy, actual %s", s)
+	}
+}
+
+func TestParseFlagsError(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantMsg   string
+		wantIndex int
+	}{
+		{name: "不正なフラグ", input: "gx", wantMsg: "Error from parser: Invalid flag 'x'", wantIndex: 1},
+		{name: "重複したフラグ", input: "gig", wantMsg: "Error from parser: Duplicated flag 'g'", wantIndex: 2},
+		{name: "u と v の併用", input: "uv", wantMsg: "Error from parser: Invalid regular expression flags", wantIndex: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseFlags(tt.input)
+			var perr *parser.ParserError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Expected a ParserError, actual %v", err)
+			}
+			if perr.Error() != tt.wantMsg || perr.Index() != tt.wantIndex {
+				t.Errorf("Unexpected error, expected %s at %d, actual %s at %d", tt.wantMsg, tt.wantIndex, perr.Error(), perr.Index())
+			}
+		})
+	}
+}
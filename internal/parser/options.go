@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+type Options struct {
+	// The unit of every Loc in the resulting AST and of ParserError.Index.
+	// The zero value is offset.UTF16, the same as JavaScript and regexpp.
+	//
+	// Without the u flag, an astral character is two Characters, one per
+	// surrogate. In offset.CodePoint and offset.UTF8, the first one covers
+	// the whole character and the second one is empty at its end.
+	OffsetUnit offset.Unit
+
+	// The source that the pattern was extracted from, e.g. a JS file
+	// containing a regex literal. If set, every Loc and ParserError gets a
+	// line/column position in it.
+	Host *HostSource
+
+	// Checks the syntax only. ParsePattern returns a nil Pattern and no node
+	// is allocated.
+	ValidateOnly bool
+
+	// If set, nodes are allocated from the arena instead of the heap.
+	Arena *regexp_ast.Arena
+
+	// Parses the pattern with the v flag: in unicode mode, with the class
+	// set syntax of nested classes, `&&`, `--` and `\q{…}` in character
+	// classes.
+	UnicodeSets bool
+}
+
+// HostSource describes where a pattern is located in a larger text. All
+// offsets are in Options.OffsetUnit.
+type HostSource struct {
+	// The offset in the host of the first character of the pattern.
+	BaseOffset int
+
+	// The offsets at which each line of the host starts, as returned by
+	// offset.LineStarts. Callers parsing many patterns of the same host
+	// should compute this once.
+	LineStarts []int
+
+	// The whole host text. Used to compute the line starts if LineStarts is
+	// nil.
+	Text string
+}
+
+// convertOffsets rewrites the Locs of the AST and the indexes of the errors,
+// which the lexer produces in UTF-16 code units, into p.opts.OffsetUnit, and
+// adds host positions to them.
+func (p *Parser) convertOffsets(source string) {
+	if p.opts.OffsetUnit != offset.UTF16 {
+		c := offset.NewConverter(source)
+		to := p.opts.OffsetUnit
+		if p.pattern != nil {
+			regexp_ast.Inspect(p.pattern, func(n regexp_ast.Node) bool {
+				loc := n.GetLoc()
+				// A Loc that starts inside a code point is the second half
+				// of a surrogate pair, which is moved after the pair so that
+				// the two halves don't overlap
+				loc.Start = c.ConvertEnd(loc.Start, offset.UTF16, to)
+				loc.End = c.ConvertEnd(loc.End, offset.UTF16, to)
+				n.SetLoc(loc)
+				return true
+			})
+		}
+		for _, err := range p.errors {
+			if e, ok := err.(*ParserError); ok {
+				e.index = c.Convert(e.index, offset.UTF16, to)
+			}
+		}
+	}
+
+	if host := p.opts.Host; host != nil {
+		lines := host.LineStarts
+		if lines == nil {
+			lines = offset.LineStarts(host.Text, p.opts.OffsetUnit)
+		}
+		position := func(off int) *regexp_ast.Position {
+			line, column := offset.Position(lines, host.BaseOffset+off)
+			return &regexp_ast.Position{Line: line, Column: column}
+		}
+		if p.pattern != nil {
+			regexp_ast.Inspect(p.pattern, func(n regexp_ast.Node) bool {
+				loc := n.GetLoc()
+				loc.StartPos = position(loc.Start)
+				loc.EndPos = position(loc.End)
+				n.SetLoc(loc)
+				return true
+			})
+		}
+		for _, err := range p.errors {
+			if e, ok := err.(*ParserError); ok {
+				e.pos = position(e.index)
+			}
+		}
+	}
+}
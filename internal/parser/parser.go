@@ -2,7 +2,9 @@ package parser
 
 import (
 	"errors"
+	"fmt"
 	"math"
+	"strings"
 
 	"github.com/sosukesuzuki/regexpp-go/internal/lexer"
 	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
@@ -10,44 +12,111 @@ import (
 )
 
 type Parser struct {
-	u       bool
+	u bool
+	v bool
+	// [N]: `\k` is a named backreference, with the u flag or when the
+	// pattern has a named group
+	n       bool
+	source  string
+	opts    Options
 	lexer   *lexer.Lexer
 	pattern *regexp_ast.Pattern
 	node    regexp_ast.Node
 	errors  []error
-	state   *struct {
-		lastIntValue int
-		lastMaxValue int
-		lastMinValue int
-	}
+	state   parserState
+	// The expression of each class with the v flag that has a set
+	// operation, until the class is turned into an ExpressionCharacterClass
+	expressions map[*regexp_ast.CharacterClass]regexp_ast.ClassSetExpression
+	// The number of capturing groups, counted before parsing
+	groupCount int
+	groupNames map[string]bool
+	// The names of `\k<name>`, checked at the end of the pattern
+	backreferenceNames []string
+	// The nodes to resolve the backreferences with at the end of the pattern
+	capturingGroups []*regexp_ast.CapturingGroup
+	backreferences  []*regexp_ast.Backreference
+}
+
+type parserState struct {
+	lastIntValue int
+	lastMaxValue int
+	lastMinValue int
+	lastStrValue string
+	lastKeyValue string
+	lastValValue string
+	// Whether the last class escape is a property of strings
+	lastMayContainStrings bool
+	// Whether the last assertion is a lookahead that can be quantified
+	// without the u flag
+	lastAssertionIsQuantifiable bool
 }
 
 func NewParser(s string, u bool) Parser {
+	return NewParserWithOptions(s, u, Options{})
+}
+
+func NewParserWithOptions(s string, u bool, opts Options) Parser {
+	u = u || opts.UnicodeSets
 	return Parser{
+		u:       u,
+		v:       opts.UnicodeSets,
+		source:  s,
+		opts:    opts,
 		lexer:   lexer.NewLexer(s, u),
 		pattern: nil,
 		node:    nil,
-		state: &struct {
-			lastIntValue int
-			lastMaxValue int
-			lastMinValue int
-		}{
-			lastIntValue: 0,
-			lastMaxValue: 0,
-			lastMinValue: 0,
-		},
 	}
 }
 
+// Reset prepares the parser for another pattern. The lexer buffer and the
+// error slice are reused, so a single parser can validate many patterns with
+// few allocations.
+func (p *Parser) Reset(s string, u bool, opts Options) {
+	u = u || opts.UnicodeSets
+	p.u = u
+	p.v = opts.UnicodeSets
+	p.source = s
+	p.opts = opts
+	if p.lexer == nil {
+		p.lexer = lexer.NewLexer(s, u)
+	} else {
+		p.lexer.Reset(s, u)
+	}
+	p.pattern = nil
+	p.node = nil
+	for i := range p.errors {
+		p.errors[i] = nil
+	}
+	p.errors = p.errors[:0]
+	p.state = parserState{}
+	for cc := range p.expressions {
+		delete(p.expressions, cc)
+	}
+	for name := range p.groupNames {
+		delete(p.groupNames, name)
+	}
+	p.backreferenceNames = p.backreferenceNames[:0]
+	for i := range p.capturingGroups {
+		p.capturingGroups[i] = nil
+	}
+	p.capturingGroups = p.capturingGroups[:0]
+	for i := range p.backreferences {
+		p.backreferences[i] = nil
+	}
+	p.backreferences = p.backreferences[:0]
+}
+
 func (p *Parser) ParsePattern() (*regexp_ast.Pattern, error) {
 	p.consumePattern()
+	p.convertOffsets(p.source)
 	return p.pattern, errors.Join(p.errors...)
 }
 
 func (p *Parser) raise(msg string) {
 	p.errors = append(p.errors, &ParserError{
-		msg: msg,
-		err: nil,
+		msg:   msg,
+		err:   nil,
+		index: p.lexer.I,
 	})
 }
 
@@ -58,13 +127,75 @@ func (p *Parser) raise(msg string) {
 
 func (p *Parser) consumePattern() {
 	start := p.lexer.I
+	named := false
+	p.groupCount, named = p.countCapturingGroups()
+	p.n = p.u || named
 	p.onPatternEnter(start)
 	p.consumeDisjunction()
+
+	// Report only the first error, like regexpp does
+	if cp := p.lexer.CP; cp != -1 && len(p.errors) == 0 {
+		switch cp {
+		case unicode_consts.RightParenthesis:
+			p.raise("Unmatched ')'")
+		case unicode_consts.RightSquareBracket, unicode_consts.RightCurlyBracket:
+			p.raise("Lone quantifier brackets")
+		default:
+			p.raise(fmt.Sprintf("Unexpected character '%c'", rune(cp)))
+		}
+	}
+	for _, name := range p.backreferenceNames {
+		if !p.groupNames[name] && len(p.errors) == 0 {
+			p.raise("Invalid named capture referenced")
+		}
+	}
+
 	p.onPatternLeave(start, p.lexer.I)
 }
 
+// countCapturingGroups returns the number of capturing groups of the pattern
+// and whether one of them has a name, which decide how `\1` and `\k` are
+// read before the groups are parsed.
+func (p *Parser) countCapturingGroups() (int, bool) {
+	s := p.source
+	count := 0
+	named := false
+	// Classes only nest with the v flag
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			if p.v || depth == 0 {
+				depth++
+			}
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case '(':
+			if depth > 0 {
+				continue
+			}
+			rest := s[i+1:]
+			if !strings.HasPrefix(rest, "?") {
+				count++
+			} else if strings.HasPrefix(rest, "?<") && !strings.HasPrefix(rest, "?<=") && !strings.HasPrefix(rest, "?<!") {
+				count++
+				named = true
+			}
+		}
+	}
+	return count, named
+}
+
 func (p *Parser) onPatternEnter(start int) {
-	pattern := &regexp_ast.Pattern{
+	if p.opts.ValidateOnly {
+		return
+	}
+	pattern := p.newPattern()
+	*pattern = regexp_ast.Pattern{
 		Alternatives: []*regexp_ast.Alternative{},
 		Loc: regexp_ast.Loc{
 			Start: start,
@@ -76,7 +207,31 @@ func (p *Parser) onPatternEnter(start int) {
 }
 
 func (p *Parser) onPatternLeave(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
 	p.node.SetEnd(end)
+
+	// Like regexpp, link the backreferences and the groups once all the
+	// groups are known
+	for _, ref := range p.backreferences {
+		var group *regexp_ast.CapturingGroup
+		if ref.Name != "" {
+			for _, g := range p.capturingGroups {
+				if g.Name == ref.Name {
+					group = g
+					break
+				}
+			}
+		} else if ref.Number <= len(p.capturingGroups) {
+			group = p.capturingGroups[ref.Number-1]
+		}
+		if group == nil {
+			continue
+		}
+		ref.Resolved = group
+		group.References = append(group.References, ref)
+	}
 }
 
 //------------------------------------------------------------------------------
@@ -97,6 +252,14 @@ func (p *Parser) consumeDisjunction() {
 		}
 	}
 
+	// A quantifier that doesn't follow an atom, e.g. `a|*` or `(?<=a)+`
+	if len(p.errors) == 0 {
+		switch p.lexer.CP {
+		case unicode_consts.Asterisk, unicode_consts.PlusSign, unicode_consts.QuestionMark, unicode_consts.LeftCurlyBracket:
+			p.raise("Nothing to repeat")
+		}
+	}
+
 	p.onDisjunctionLeave(start, p.lexer.I)
 }
 
@@ -128,23 +291,38 @@ func (p *Parser) consumeAlternative(index int) {
 }
 
 func (p *Parser) onAlternativeEnter(start int) {
-	parent, ok := p.node.(*regexp_ast.Pattern)
-	if !ok {
-		p.raise("The parent of Alternative must be Pattern")
+	if p.opts.ValidateOnly {
+		return
 	}
-	alt := &regexp_ast.Alternative{
+	alt := p.newAlternative()
+	*alt = regexp_ast.Alternative{
 		Elements: []regexp_ast.Element{},
-		Parent:   parent,
+		Parent:   p.node,
 		Loc: regexp_ast.Loc{
 			Start: start,
 			End:   -1,
 		},
 	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Pattern:
+		parent.Alternatives = append(parent.Alternatives, alt)
+	case *regexp_ast.Group:
+		parent.Alternatives = append(parent.Alternatives, alt)
+	case *regexp_ast.CapturingGroup:
+		parent.Alternatives = append(parent.Alternatives, alt)
+	case *regexp_ast.LookaroundAssertion:
+		parent.Alternatives = append(parent.Alternatives, alt)
+	default:
+		p.raise("The parent of Alternative must be Pattern, Group, CapturingGroup or LookaroundAssertion")
+		return
+	}
 	p.node = alt
-	parent.Alternatives = append(parent.Alternatives, alt)
 }
 
 func (p *Parser) onAlternativeLeave(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
 	p.node.SetEnd(end)
 	p.node = p.node.GetParent()
 }
@@ -155,7 +333,12 @@ func (p *Parser) onAlternativeLeave(start int, end int) {
 //------------------------------------------------------------------------------
 
 func (p *Parser) consumeTerm() bool {
-	return p.consumeAssertion() || (p.consumeAtom() && p.consumeOptionalQuantifier())
+	if p.u {
+		return p.consumeAssertion() || (p.consumeAtom() && p.consumeOptionalQuantifier())
+	}
+	// Annex B: QuantifiableAssertion Quantifier
+	return (p.consumeAssertion() && (!p.state.lastAssertionIsQuantifiable || p.consumeOptionalQuantifier())) ||
+		(p.consumeAtom() && p.consumeOptionalQuantifier())
 }
 
 //------------------------------------------------------------------------------
@@ -163,10 +346,133 @@ func (p *Parser) consumeTerm() bool {
 // https://tc39.es/ecma262/multipage/text-processing.html#prod-Assertion
 //------------------------------------------------------------------------------
 
+// Assertion ::
+//
+//	^
+//	$
+//	\b
+//	\B
+//	(?= Disjunction )
+//	(?! Disjunction )
+//	(?<= Disjunction )
+//	(?<! Disjunction )
 func (p *Parser) consumeAssertion() bool {
+	start := p.lexer.I
+	p.state.lastAssertionIsQuantifiable = false
+
+	if p.lexer.Eat(unicode_consts.CircumflexAccent) {
+		p.onEdgeAssertion(start, p.lexer.I, regexp_ast.EdgeStart)
+		return true
+	}
+	if p.lexer.Eat(unicode_consts.DollarSign) {
+		p.onEdgeAssertion(start, p.lexer.I, regexp_ast.EdgeEnd)
+		return true
+	}
+	if p.lexer.Eat2(unicode_consts.ReverseSolidus, unicode_consts.LatinCapitalLetterB) {
+		p.onWordBoundaryAssertion(start, p.lexer.I, true)
+		return true
+	}
+	if p.lexer.Eat2(unicode_consts.ReverseSolidus, unicode_consts.LatinSmallLetterB) {
+		p.onWordBoundaryAssertion(start, p.lexer.I, false)
+		return true
+	}
+
+	if p.lexer.Eat2(unicode_consts.LeftParenthesis, unicode_consts.QuestionMark) {
+		kind := regexp_ast.Lookahead
+		if p.lexer.Eat(unicode_consts.LessThanSign) {
+			kind = regexp_ast.Lookbehind
+		}
+		negate := p.lexer.Match(unicode_consts.ExclamationMark)
+		if p.lexer.Eat(unicode_consts.EqualsSign) || p.lexer.Eat(unicode_consts.ExclamationMark) {
+			p.onLookaroundAssertionEnter(start, kind, negate)
+			p.consumeDisjunction()
+			if !p.lexer.Eat(unicode_consts.RightParenthesis) {
+				p.raise("Unterminated group")
+			}
+			p.state.lastAssertionIsQuantifiable = kind == regexp_ast.Lookahead && !p.u
+			p.onLookaroundAssertionLeave(start, p.lexer.I)
+			return true
+		}
+		p.lexer.Rewind(start)
+	}
+
 	return false
 }
 
+func (p *Parser) onEdgeAssertion(start int, end int, kind regexp_ast.EdgeKind) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Alternative:
+		node := p.newEdgeAssertion()
+		*node = regexp_ast.EdgeAssertion{
+			Parent: parent,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   end,
+			},
+			Kind: kind,
+		}
+		parent.Elements = append(parent.Elements, node)
+	default:
+		p.raise("The parent of EdgeAssertion must be Alternative")
+	}
+}
+
+func (p *Parser) onWordBoundaryAssertion(start int, end int, negate bool) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Alternative:
+		node := p.newWordBoundaryAssertion()
+		*node = regexp_ast.WordBoundaryAssertion{
+			Parent: parent,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   end,
+			},
+			Negate: negate,
+		}
+		parent.Elements = append(parent.Elements, node)
+	default:
+		p.raise("The parent of WordBoundaryAssertion must be Alternative")
+	}
+}
+
+func (p *Parser) onLookaroundAssertionEnter(start int, kind regexp_ast.LookaroundKind, negate bool) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Alternative:
+		node := p.newLookaroundAssertion()
+		*node = regexp_ast.LookaroundAssertion{
+			Parent: parent,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   -1,
+			},
+			Kind:         kind,
+			Negate:       negate,
+			Alternatives: []*regexp_ast.Alternative{},
+		}
+		parent.Elements = append(parent.Elements, node)
+		p.node = node
+	default:
+		p.raise("The parent of LookaroundAssertion must be Alternative")
+	}
+}
+
+func (p *Parser) onLookaroundAssertionLeave(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	p.node.SetEnd(end)
+	p.node = p.node.GetParent()
+}
+
 //------------------------------------------------------------------------------
 // Atom
 // https://tc39.es/ecma262/multipage/text-processing.html#prod-Atom
@@ -234,6 +540,9 @@ func (p *Parser) consumeQuantifier() bool {
 }
 
 func (p *Parser) onQuantifier(start int, end int, min int, max int, greety bool) bool {
+	if p.opts.ValidateOnly {
+		return true
+	}
 	switch parent := p.node.(type) {
 	case *regexp_ast.Alternative:
 		{
@@ -243,7 +552,12 @@ func (p *Parser) onQuantifier(start int, end int, min int, max int, greety bool)
 			parent.Elements = elements
 
 			if quantifiable, ok := element.(regexp_ast.QuantifiableElement); ok {
-				q := &regexp_ast.Quantifier{
+				// Like regexpp, a Quantifier covers the quantified element too
+				if node, ok := quantifiable.(regexp_ast.Node); ok {
+					start = node.GetLoc().Start
+				}
+				q := p.newQuantifier()
+				*q = regexp_ast.Quantifier{
 					Parent: parent,
 					Loc: regexp_ast.Loc{
 						Start: start,
@@ -282,14 +596,16 @@ func (p *Parser) eatBracedQuantifier() bool {
 			if p.lexer.Eat(unicode_consts.Comma) {
 				if secondDigit := p.eatDecimalDigits(); secondDigit != -1 {
 					p.state.lastMaxValue = secondDigit
+				} else {
+					p.state.lastMaxValue = math.MaxInt
 				}
 			}
 			if p.lexer.Eat(unicode_consts.RightCurlyBracket) {
 				return true
 			}
 			p.raise("Imcomplete Quantifier")
-			p.lexer.Rewind(start)
 		}
+		p.lexer.Rewind(start)
 	}
 	return false
 }
@@ -302,7 +618,7 @@ func (p *Parser) eatBracedQuantifier() bool {
 func (p *Parser) consumePatternCharacter() bool {
 	start := p.lexer.I
 	cp := p.lexer.CP
-	if cp != -1 && !isSyntaxCharacter(cp) {
+	if cp != -1 && !unicode_consts.IsSyntaxCharacter(cp) {
 		p.lexer.Next()
 		p.onCharacter(start, p.lexer.I, cp)
 		return true
@@ -324,15 +640,20 @@ func (p *Parser) consumeDot() bool {
 }
 
 func (p *Parser) onAnyCharacterSet(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
 	switch parent := p.node.(type) {
 	case *regexp_ast.Alternative:
-		parent.Elements = append(parent.Elements, &regexp_ast.AnyCharacterSet{
+		node := p.newAnyCharacterSet()
+		*node = regexp_ast.AnyCharacterSet{
 			Parent: parent,
 			Loc: regexp_ast.Loc{
 				Start: start,
 				End:   end,
 			},
-		})
+		}
+		parent.Elements = append(parent.Elements, node)
 	default:
 		p.raise("The parent of AnyCharacterSet must be Alternative")
 	}
@@ -342,15 +663,114 @@ func (p *Parser) onAnyCharacterSet(start int, end int) {
 // \ AtomEscape
 // https://tc39.es/ecma262/multipage/text-processing.html#prod-Atom
 // ------------------------------------------------------------------------------
+//
+// AtomEscape ::
+//
+//	DecimalEscape
+//	CharacterClassEscape
+//	CharacterEscape
+//	[+N] k GroupName
+//
+// In non-unicode mode, a DecimalEscape greater than the number of groups is a
+// legacy octal escape or an identity escape, as in Annex B.
+// ------------------------------------------------------------------------------
 func (p *Parser) consumeReverseSolidusAtomEscape() bool {
+	start := p.lexer.I
+	if !p.lexer.Eat(unicode_consts.ReverseSolidus) {
+		return false
+	}
+
+	if p.consumeBackreference() ||
+		p.consumeCharacterClassEscape() ||
+		p.consumeCharacterEscape() ||
+		(p.n && p.consumeKGroupName()) {
+		return true
+	}
+
+	// ExtendedAtom :: \ [lookahead = c]
+	if !p.u && p.lexer.Match(unicode_consts.LatinSmallLetterC) {
+		p.state.lastIntValue = unicode_consts.ReverseSolidus
+		p.onCharacter(start, p.lexer.I, p.state.lastIntValue)
+		return true
+	}
+
+	p.raiseInvalidEscape()
+	p.lexer.Rewind(start)
+	return false
+}
+
+func (p *Parser) raiseInvalidEscape() {
+	if p.lexer.CP == -1 {
+		p.raise("\\ at end of pattern")
+	} else {
+		p.raise("Invalid escape")
+	}
+}
+
+// DecimalEscape ::
+//
+//	NonZeroDigit DecimalDigits?
+func (p *Parser) consumeBackreference() bool {
+	start := p.lexer.I
+	if p.lexer.CP == unicode_consts.DigitZero || !unicode_consts.IsDecimalDigit(p.lexer.CP) {
+		return false
+	}
+	n := p.eatDecimalDigits()
+	if n <= p.groupCount {
+		p.onBackreference(start-1, p.lexer.I, n, "")
+		return true
+	}
+	if p.u {
+		p.raise("Invalid escape")
+	}
+	p.lexer.Rewind(start)
+	return false
+}
+
+// k GroupName
+func (p *Parser) consumeKGroupName() bool {
+	start := p.lexer.I
+	if p.lexer.Eat(unicode_consts.LatinSmallLetterK) {
+		if p.eatGroupName() {
+			name := p.state.lastStrValue
+			p.backreferenceNames = append(p.backreferenceNames, name)
+			p.onBackreference(start-1, p.lexer.I, 0, name)
+			return true
+		}
+		p.raise("Invalid named reference")
+		p.lexer.Rewind(start)
+	}
 	return false
 }
 
+func (p *Parser) onBackreference(start int, end int, number int, name string) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Alternative:
+		node := p.newBackreference()
+		*node = regexp_ast.Backreference{
+			Parent: parent,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   end,
+			},
+			Number: number,
+			Name:   name,
+		}
+		parent.Elements = append(parent.Elements, node)
+		p.backreferences = append(p.backreferences, node)
+	default:
+		p.raise("The parent of Backreference must be Alternative")
+	}
+}
+
 // ------------------------------------------------------------------------------
 // CharacterClass ::
 //
-//	[ [lookahead != ^] ClassRanges ]
-//	[ ^ ClassRanges ]
+//	[ [lookahead != ^] ClassContents ]
+//	[ ^ ClassContents ]
 //
 // https://tc39.es/ecma262/multipage/text-processing.html#prod-CharacterClass
 // ------------------------------------------------------------------------------
@@ -359,9 +779,11 @@ func (p *Parser) consumeCharacterClass() bool {
 	if p.lexer.Eat(unicode_consts.LeftSquareBracket) {
 		negate := p.lexer.Eat(unicode_consts.CircumflexAccent)
 		p.onCharacterClassEnter(start, negate)
-		p.consumeClassRanges()
+		mayContainStrings := p.consumeClassContents()
 		if !p.lexer.Eat(unicode_consts.RightSquareBracket) {
-			p.raise("Unterminated character class")
+			p.raiseUnterminatedClass()
+		} else if negate && mayContainStrings {
+			p.raise("Negated character class may contain strings")
 		}
 		p.onCharacterClassLeave(start, p.lexer.I, negate)
 		return true
@@ -369,42 +791,98 @@ func (p *Parser) consumeCharacterClass() bool {
 	return false
 }
 
+// raiseUnterminatedClass reports what is found instead of the `]` of a class.
+// Only a class with the v flag can stop before its end.
+func (p *Parser) raiseUnterminatedClass() {
+	switch {
+	case !p.v || p.lexer.CP == -1:
+		p.raise("Unterminated character class")
+	case p.lexer.CP == unicode_consts.ReverseSolidus:
+		p.lexer.Next()
+		p.raiseInvalidEscape()
+	default:
+		p.raise("Invalid character in character class")
+	}
+}
+
 func (p *Parser) onCharacterClassEnter(start int, negate bool) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	node := p.newCharacterClass()
+	*node = regexp_ast.CharacterClass{
+		Parent: p.node,
+		Loc: regexp_ast.Loc{
+			Start: start,
+			End:   -1,
+		},
+		Negate:      negate,
+		UnicodeSets: p.v,
+		Elements:    []regexp_ast.CharacterClassElement{},
+	}
 	switch parent := p.node.(type) {
 	case *regexp_ast.Alternative:
-		node := &regexp_ast.CharacterClass{
-			Parent: parent,
-			Loc: regexp_ast.Loc{
-				Start: start,
-				End:   -1,
-			},
-			Negate:   negate,
-			Elements: []regexp_ast.CharacterClassElement{},
-		}
-		p.node = node
+		parent.Elements = append(parent.Elements, node)
+	case *regexp_ast.CharacterClass:
 		parent.Elements = append(parent.Elements, node)
 	default:
-		p.raise("The parent of CharacterClass must be Alternative")
+		p.raise("The parent of CharacterClass must be Alternative or CharacterClass")
+		return
 	}
+	p.node = node
 }
 
+// onCharacterClassLeave closes the class. A class with a set operation is
+// replaced with an ExpressionCharacterClass of the operation.
 func (p *Parser) onCharacterClassLeave(start int, end int, negate bool) {
-	node := p.node
-	if cc, ok := node.(*regexp_ast.CharacterClass); ok {
-		if alt, ok := cc.Parent.(*regexp_ast.Alternative); ok {
-			cc.Loc.End = end
-			p.node = alt
-			return
-		}
+	if p.opts.ValidateOnly {
+		return
+	}
+	cc, ok := p.node.(*regexp_ast.CharacterClass)
+	if !ok {
+		p.raise("UnknownError")
+		return
+	}
+	cc.Loc.End = end
+	p.node = cc.Parent
+
+	expression, ok := p.expressions[cc]
+	if !ok {
+		return
+	}
+	delete(p.expressions, cc)
+	if len(cc.Elements) != 0 {
+		p.raise("UnknownError")
+		return
+	}
+	node := p.newExpressionCharacterClass()
+	*node = regexp_ast.ExpressionCharacterClass{
+		Parent:     cc.Parent,
+		Loc:        cc.Loc,
+		Negate:     negate,
+		Expression: expression,
+	}
+	expression.(regexp_ast.Node).SetParent(node)
+	switch parent := cc.Parent.(type) {
+	case *regexp_ast.Alternative:
+		parent.Elements[len(parent.Elements)-1] = node
+	case *regexp_ast.CharacterClass:
+		parent.Elements[len(parent.Elements)-1] = node
 	}
-	p.raise("UnknownError")
 }
 
-// ------------------------------------------------------------------------------
-// ( GroupSpecifier Disjunction )
-// https://tc39.es/ecma262/multipage/text-processing.html#prod-Atom
-// ------------------------------------------------------------------------------
-func (p *Parser) consumeUncapturingGroup() bool {
+// ClassContents ::
+//
+//	[empty]
+//	[~UnicodeSetsMode] NonemptyClassRanges
+//	[+UnicodeSetsMode] ClassSetExpression
+//
+// Returns whether the class may contain strings.
+func (p *Parser) consumeClassContents() bool {
+	if p.v {
+		return p.consumeClassSetExpression()
+	}
+	p.consumeClassRanges()
 	return false
 }
 
@@ -412,62 +890,245 @@ func (p *Parser) consumeUncapturingGroup() bool {
 // (?: Disjunction )
 // https://tc39.es/ecma262/multipage/text-processing.html#prod-Atom
 // ------------------------------------------------------------------------------
-func (p *Parser) consumeCapturingGroup() bool {
-	return false
+func (p *Parser) consumeUncapturingGroup() bool {
+	start := p.lexer.I
+	if !p.lexer.Eat2(unicode_consts.LeftParenthesis, unicode_consts.QuestionMark) {
+		return false
+	}
+	if !p.lexer.Eat(unicode_consts.Colon) {
+		p.lexer.Rewind(start)
+		return false
+	}
+	p.onGroupEnter(start)
+	p.consumeDisjunction()
+	if !p.lexer.Eat(unicode_consts.RightParenthesis) {
+		p.raise("Unterminated group")
+	}
+	p.onGroupLeave(start, p.lexer.I)
+	return true
 }
 
-// ------------------------------------------------------------------------------
-// SourceCharacter
-// https://tc39.es/ecma262/multipage/ecmascript-language-source-code.html#prod-SourceCharacter
-// ------------------------------------------------------------------------------
-func (p *Parser) onCharacter(start int, end int, value int) {
+func (p *Parser) onGroupEnter(start int) {
+	if p.opts.ValidateOnly {
+		return
+	}
 	switch parent := p.node.(type) {
 	case *regexp_ast.Alternative:
-		parent.Elements = append(parent.Elements, &regexp_ast.Character{
-			Parent: parent,
-			Value:  value,
-			Loc: regexp_ast.Loc{
-				Start: start,
-				End:   end,
-			},
-		})
-	case *regexp_ast.CharacterClass:
-		parent.Elements = append(parent.Elements, &regexp_ast.Character{
+		node := p.newGroup()
+		*node = regexp_ast.Group{
 			Parent: parent,
-			Value:  value,
 			Loc: regexp_ast.Loc{
 				Start: start,
-				End:   end,
+				End:   -1,
 			},
-		})
+			Alternatives: []*regexp_ast.Alternative{},
+		}
+		parent.Elements = append(parent.Elements, node)
+		p.node = node
 	default:
-		p.raise("The parent of Character must be Alternative or CharacterClass")
+		p.raise("The parent of Group must be Alternative")
 	}
 }
 
-// ------------------------------------------------------------------------------
-// SyntaxCharacter
-// https://tc39.es/ecma262/multipage/text-processing.html#prod-SyntaxCharacter
-// ------------------------------------------------------------------------------
-func isSyntaxCharacter(cp int) bool {
-	return cp == unicode_consts.CircumflexAccent ||
-		cp == unicode_consts.DollarSign ||
-		cp == unicode_consts.ReverseSolidus ||
-		cp == unicode_consts.FullStop ||
-		cp == unicode_consts.Asterisk ||
-		cp == unicode_consts.PlusSign ||
-		cp == unicode_consts.QuestionMark ||
-		cp == unicode_consts.LeftParenthesis ||
-		cp == unicode_consts.RightParenthesis ||
-		cp == unicode_consts.LeftSquareBracket ||
-		cp == unicode_consts.RightSquareBracket ||
-		cp == unicode_consts.LeftCurlyBracket ||
-		cp == unicode_consts.RightCurlyBracket ||
-		cp == unicode_consts.VerticalLine
+func (p *Parser) onGroupLeave(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	p.node.SetEnd(end)
+	p.node = p.node.GetParent()
 }
 
 // ------------------------------------------------------------------------------
-// DecimalDigit, DecimalDigits
+// ( GroupSpecifier? Disjunction )
+// https://tc39.es/ecma262/multipage/text-processing.html#prod-Atom
+// ------------------------------------------------------------------------------
+func (p *Parser) consumeCapturingGroup() bool {
+	start := p.lexer.I
+	if !p.lexer.Eat(unicode_consts.LeftParenthesis) {
+		return false
+	}
+	name := ""
+	if p.consumeGroupSpecifier() {
+		name = p.state.lastStrValue
+	}
+	p.onCapturingGroupEnter(start, name)
+	p.consumeDisjunction()
+	if !p.lexer.Eat(unicode_consts.RightParenthesis) {
+		p.raise("Unterminated group")
+	}
+	p.onCapturingGroupLeave(start, p.lexer.I)
+	return true
+}
+
+// GroupSpecifier ::
+//
+//	? GroupName
+func (p *Parser) consumeGroupSpecifier() bool {
+	if !p.lexer.Eat(unicode_consts.QuestionMark) {
+		return false
+	}
+	if p.eatGroupName() {
+		name := p.state.lastStrValue
+		if p.groupNames[name] {
+			p.raise("Duplicate capture group name")
+		}
+		if p.groupNames == nil {
+			p.groupNames = map[string]bool{}
+		}
+		p.groupNames[name] = true
+		return true
+	}
+	p.raise("Invalid group")
+	return false
+}
+
+func (p *Parser) onCapturingGroupEnter(start int, name string) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Alternative:
+		node := p.newCapturingGroup()
+		*node = regexp_ast.CapturingGroup{
+			Parent: parent,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   -1,
+			},
+			Name:         name,
+			Alternatives: []*regexp_ast.Alternative{},
+		}
+		parent.Elements = append(parent.Elements, node)
+		p.capturingGroups = append(p.capturingGroups, node)
+		p.node = node
+	default:
+		p.raise("The parent of CapturingGroup must be Alternative")
+	}
+}
+
+func (p *Parser) onCapturingGroupLeave(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	p.node.SetEnd(end)
+	p.node = p.node.GetParent()
+}
+
+// GroupName ::
+//
+//	< RegExpIdentifierName >
+func (p *Parser) eatGroupName() bool {
+	if p.lexer.Eat(unicode_consts.LessThanSign) {
+		if p.eatRegExpIdentifierName() && p.lexer.Eat(unicode_consts.GreaterThanSign) {
+			return true
+		}
+		p.raise("Invalid capture group name")
+	}
+	return false
+}
+
+// RegExpIdentifierName ::
+//
+//	RegExpIdentifierStart
+//	RegExpIdentifierName RegExpIdentifierPart
+func (p *Parser) eatRegExpIdentifierName() bool {
+	if !p.eatRegExpIdentifierStart() {
+		return false
+	}
+	var b strings.Builder
+	b.WriteRune(rune(p.state.lastIntValue))
+	for p.eatRegExpIdentifierPart() {
+		b.WriteRune(rune(p.state.lastIntValue))
+	}
+	p.state.lastStrValue = b.String()
+	return true
+}
+
+// RegExpIdentifierStart ::
+//
+//	IdentifierStartChar
+//	\ RegExpUnicodeEscapeSequence[+UnicodeMode]
+//	[~UnicodeMode] UnicodeLeadSurrogate UnicodeTrailSurrogate
+func (p *Parser) eatRegExpIdentifierStart() bool {
+	return p.eatRegExpIdentifierChar(unicode_consts.IsIdentifierStartChar)
+}
+
+// RegExpIdentifierPart ::
+//
+//	IdentifierPartChar
+//	\ RegExpUnicodeEscapeSequence[+UnicodeMode]
+//	[~UnicodeMode] UnicodeLeadSurrogate UnicodeTrailSurrogate
+func (p *Parser) eatRegExpIdentifierPart() bool {
+	return p.eatRegExpIdentifierChar(unicode_consts.IsIdentifierPartChar)
+}
+
+func (p *Parser) eatRegExpIdentifierChar(valid func(int) bool) bool {
+	start := p.lexer.I
+	cp := p.lexer.CP
+	p.lexer.Next()
+	if cp == unicode_consts.ReverseSolidus && p.eatRegExpUnicodeEscapeSequence(true) {
+		cp = p.state.lastIntValue
+	} else if !p.u && unicode_consts.IsLeadSurrogate(cp) && unicode_consts.IsTrailSurrogate(p.lexer.CP) {
+		cp = unicode_consts.CombineSurrogatePair(cp, p.lexer.CP)
+		p.lexer.Next()
+	}
+	if valid(cp) {
+		p.state.lastIntValue = cp
+		return true
+	}
+	p.lexer.Rewind(start)
+	return false
+}
+
+// ------------------------------------------------------------------------------
+// SourceCharacter
+// https://tc39.es/ecma262/multipage/ecmascript-language-source-code.html#prod-SourceCharacter
+// ------------------------------------------------------------------------------
+func (p *Parser) onCharacter(start int, end int, value int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Alternative:
+		node := p.newCharacter()
+		*node = regexp_ast.Character{
+			Parent: parent,
+			Value:  value,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   end,
+			},
+		}
+		parent.Elements = append(parent.Elements, node)
+	case *regexp_ast.CharacterClass:
+		node := p.newCharacter()
+		*node = regexp_ast.Character{
+			Parent: parent,
+			Value:  value,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   end,
+			},
+		}
+		parent.Elements = append(parent.Elements, node)
+	case *regexp_ast.StringAlternative:
+		node := p.newCharacter()
+		*node = regexp_ast.Character{
+			Parent: parent,
+			Value:  value,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   end,
+			},
+		}
+		parent.Elements = append(parent.Elements, node)
+	default:
+		p.raise("The parent of Character must be Alternative, CharacterClass or StringAlternative")
+	}
+}
+
+// ------------------------------------------------------------------------------
+// DecimalDigit, DecimalDigits
 // https://tc39.es/ecma262/multipage/notational-conventions.html#prod-grammar-notation-DecimalDigit
 //
 // DecimalDigit :: one of
@@ -524,13 +1185,29 @@ func (p *Parser) consumeClassRanges() {
 		}
 		max := p.state.lastIntValue
 
+		// A class escape can't be the end of a range, but Annex B reads
+		// `[\d-z]` as `\d`, `-` and `z` in non-unicode mode
+		if min == -1 || max == -1 {
+			if p.u {
+				p.raise("Invalid character class")
+			}
+			continue
+		}
+
 		p.onCharacterClassRange(rangeStart, p.lexer.I, min, max)
 	}
 }
 
 func (p *Parser) onCharacterClassRange(start int, end int, min int, max int) {
+	if p.opts.ValidateOnly {
+		return
+	}
 	switch parent := p.node.(type) {
 	case *regexp_ast.CharacterClass:
+		if parent.UnicodeSets {
+			p.onClassSetRange(parent, start, end)
+			return
+		}
 		three := parent.Elements[len(parent.Elements)-3 : len(parent.Elements)]
 		if len(three) != 3 {
 			p.raise("UnknownError")
@@ -547,7 +1224,8 @@ func (p *Parser) onCharacterClassRange(start int, end int, min int, max int) {
 		if minChar, ok := minEl.(*regexp_ast.Character); ok {
 			if hyphenChar, ok := hyphenEl.(*regexp_ast.Character); ok && hyphenChar.Value == unicode_consts.HyphenMinus {
 				if maxChar, ok := maxEl.(*regexp_ast.Character); ok {
-					node := &regexp_ast.CharacterClassRange{
+					node := p.newCharacterClassRange()
+					*node = regexp_ast.CharacterClassRange{
 						Parent: parent,
 						Loc: regexp_ast.Loc{
 							Start: start,
@@ -556,6 +1234,8 @@ func (p *Parser) onCharacterClassRange(start int, end int, min int, max int) {
 						Min: minChar,
 						Max: maxChar,
 					}
+					minChar.Parent = node
+					maxChar.Parent = node
 					parent.Elements = append(parent.Elements, node)
 					return
 				}
@@ -567,6 +1247,434 @@ func (p *Parser) onCharacterClassRange(start int, end int, min int, max int) {
 	}
 }
 
+// onClassSetRange turns the last two elements of a class with the v flag
+// into a range. Unlike ClassRanges, the hyphen has no node.
+func (p *Parser) onClassSetRange(parent *regexp_ast.CharacterClass, start int, end int) {
+	n := len(parent.Elements)
+	if n < 2 {
+		p.raise("UnknownError")
+		return
+	}
+	minChar, ok1 := parent.Elements[n-2].(*regexp_ast.Character)
+	maxChar, ok2 := parent.Elements[n-1].(*regexp_ast.Character)
+	if !ok1 || !ok2 {
+		p.raise("UnknownError")
+		return
+	}
+	parent.Elements = parent.Elements[:n-2]
+	node := p.newCharacterClassRange()
+	*node = regexp_ast.CharacterClassRange{
+		Parent: parent,
+		Loc: regexp_ast.Loc{
+			Start: start,
+			End:   end,
+		},
+		Min: minChar,
+		Max: maxChar,
+	}
+	minChar.Parent = node
+	maxChar.Parent = node
+	parent.Elements = append(parent.Elements, node)
+}
+
+// ------------------------------------------------------------------------------
+// ClassSetExpression ::
+//
+//	ClassUnion
+//	ClassIntersection
+//	ClassSubtraction
+//
+// ClassIntersection ::
+//
+//	ClassSetOperand && [lookahead ≠ &] ClassSetOperand
+//	ClassIntersection && [lookahead ≠ &] ClassSetOperand
+//
+// ClassSubtraction ::
+//
+//	ClassSetOperand -- ClassSetOperand
+//	ClassSubtraction -- ClassSetOperand
+//
+// Returns whether the expression may contain strings: a union may if any of
+// its operands may, an intersection if all of them may and a subtraction if
+// its first operand may.
+//
+// https://tc39.es/ecma262/multipage/text-processing.html#prod-ClassSetExpression
+// ------------------------------------------------------------------------------
+func (p *Parser) consumeClassSetExpression() bool {
+	start := p.lexer.I
+	mayContainStrings := false
+
+	if p.consumeClassSetCharacter() {
+		if p.consumeClassSetRangeFromOperator(start) {
+			// A range can only be in a union
+			return p.consumeClassUnionRight(false)
+		}
+	} else if ok, strings := p.consumeClassSetOperand(); ok {
+		mayContainStrings = strings
+	} else {
+		cp := p.lexer.CP
+		if cp == unicode_consts.ReverseSolidus {
+			p.lexer.Next()
+			p.raiseInvalidEscape()
+		} else if cp == p.lexer.Peek() && unicode_consts.IsClassSetReservedDoublePunctuatorCharacter(cp) {
+			p.raise("Invalid set operation in character class")
+		}
+		// The empty class
+		return p.consumeClassUnionRight(false)
+	}
+
+	if p.lexer.Eat2(unicode_consts.Ampersand, unicode_consts.Ampersand) {
+		for p.lexer.CP != unicode_consts.Ampersand {
+			ok, strings := p.consumeClassSetOperand()
+			if !ok {
+				break
+			}
+			p.onClassIntersection(start, p.lexer.I)
+			mayContainStrings = mayContainStrings && strings
+			if p.lexer.Eat2(unicode_consts.Ampersand, unicode_consts.Ampersand) {
+				continue
+			}
+			return mayContainStrings
+		}
+		p.raise("Invalid character in character class")
+		return mayContainStrings
+	}
+
+	if p.lexer.Eat2(unicode_consts.HyphenMinus, unicode_consts.HyphenMinus) {
+		for {
+			if ok, _ := p.consumeClassSetOperand(); !ok {
+				break
+			}
+			p.onClassSubtraction(start, p.lexer.I)
+			if p.lexer.Eat2(unicode_consts.HyphenMinus, unicode_consts.HyphenMinus) {
+				continue
+			}
+			return mayContainStrings
+		}
+		p.raise("Invalid character in character class")
+		return mayContainStrings
+	}
+
+	return p.consumeClassUnionRight(mayContainStrings)
+}
+
+// ClassUnion ::
+//
+//	ClassSetRange ClassUnion?
+//	ClassSetOperand ClassUnion?
+//
+// Consumes the rest of a union whose first operand may contain strings if
+// mayContainStrings is true.
+func (p *Parser) consumeClassUnionRight(mayContainStrings bool) bool {
+	for {
+		start := p.lexer.I
+		if p.consumeClassSetCharacter() {
+			p.consumeClassSetRangeFromOperator(start)
+			continue
+		}
+		ok, strings := p.consumeClassSetOperand()
+		if !ok {
+			break
+		}
+		mayContainStrings = mayContainStrings || strings
+	}
+	return mayContainStrings
+}
+
+// ClassSetRange ::
+//
+//	ClassSetCharacter - ClassSetCharacter
+//
+// Consumes the `-` and the end of a range whose first character was consumed
+// from start.
+func (p *Parser) consumeClassSetRangeFromOperator(start int) bool {
+	currentStart := p.lexer.I
+	min := p.state.lastIntValue
+	if p.lexer.Eat(unicode_consts.HyphenMinus) {
+		if p.consumeClassSetCharacter() {
+			max := p.state.lastIntValue
+			if min > max {
+				p.raise("Range out of order in character class")
+			}
+			p.onCharacterClassRange(start, p.lexer.I, min, max)
+			return true
+		}
+		p.lexer.Rewind(currentStart)
+	}
+	return false
+}
+
+// ClassSetOperand ::
+//
+//	NestedClass
+//	ClassStringDisjunction
+//	ClassSetCharacter
+//
+// Returns whether an operand was consumed, and whether it may contain
+// strings.
+func (p *Parser) consumeClassSetOperand() (bool, bool) {
+	if ok, strings := p.consumeNestedClass(); ok {
+		return true, strings
+	}
+	if ok, strings := p.consumeClassStringDisjunction(); ok {
+		return true, strings
+	}
+	if p.consumeClassSetCharacter() {
+		return true, false
+	}
+	return false, false
+}
+
+func (p *Parser) onClassIntersection(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	node := p.newClassIntersection()
+	*node = regexp_ast.ClassIntersection{
+		Loc: regexp_ast.Loc{
+			Start: start,
+			End:   end,
+		},
+	}
+	p.onClassSetOperation(node, &node.Left, &node.Right)
+}
+
+func (p *Parser) onClassSubtraction(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	node := p.newClassSubtraction()
+	*node = regexp_ast.ClassSubtraction{
+		Loc: regexp_ast.Loc{
+			Start: start,
+			End:   end,
+		},
+	}
+	p.onClassSetOperation(node, &node.Left, &node.Right)
+}
+
+// onClassSetOperation makes node the operation of the last operand of the
+// current class and the operation so far, or the element before the last
+// one for the first operation of the class.
+func (p *Parser) onClassSetOperation(node regexp_ast.ClassSetExpression, left *regexp_ast.ClassSetOperand, right *regexp_ast.ClassSetOperand) {
+	parent, ok := p.node.(*regexp_ast.CharacterClass)
+	if !ok || !parent.UnicodeSets {
+		p.raise("The parent of a class set operation must be CharacterClass with the v flag")
+		return
+	}
+	var l, r regexp_ast.ClassSetOperand
+	if n := len(parent.Elements); n > 0 {
+		r, _ = parent.Elements[n-1].(regexp_ast.ClassSetOperand)
+		parent.Elements = parent.Elements[:n-1]
+	}
+	if expression, ok := p.expressions[parent]; ok {
+		l, _ = expression.(regexp_ast.ClassSetOperand)
+	} else if n := len(parent.Elements); n > 0 {
+		l, _ = parent.Elements[n-1].(regexp_ast.ClassSetOperand)
+		parent.Elements = parent.Elements[:n-1]
+	}
+	if l == nil || r == nil {
+		p.raise("UnknownError")
+		return
+	}
+	*left, *right = l, r
+	n := node.(regexp_ast.Node)
+	n.SetParent(parent)
+	l.(regexp_ast.Node).SetParent(n)
+	r.(regexp_ast.Node).SetParent(n)
+	if p.expressions == nil {
+		p.expressions = map[*regexp_ast.CharacterClass]regexp_ast.ClassSetExpression{}
+	}
+	p.expressions[parent] = node
+}
+
+// NestedClass ::
+//
+//	[ [lookahead ≠ ^] ClassContents ]
+//	[^ ClassContents ]
+//	\ CharacterClassEscape
+//
+// Returns whether a class was consumed, and whether it may contain strings.
+func (p *Parser) consumeNestedClass() (bool, bool) {
+	start := p.lexer.I
+	if p.lexer.Eat(unicode_consts.LeftSquareBracket) {
+		negate := p.lexer.Eat(unicode_consts.CircumflexAccent)
+		p.onCharacterClassEnter(start, negate)
+		mayContainStrings := p.consumeClassContents()
+		if !p.lexer.Eat(unicode_consts.RightSquareBracket) {
+			p.raiseUnterminatedClass()
+		} else if negate && mayContainStrings {
+			p.raise("Negated character class may contain strings")
+		}
+		p.onCharacterClassLeave(start, p.lexer.I, negate)
+		return true, mayContainStrings
+	}
+	if p.lexer.Eat(unicode_consts.ReverseSolidus) {
+		if p.consumeCharacterClassEscape() {
+			return true, p.state.lastMayContainStrings
+		}
+		p.lexer.Rewind(start)
+	}
+	return false, false
+}
+
+// ClassStringDisjunction ::
+//
+//	\q{ ClassStringDisjunctionContents }
+//
+// ClassStringDisjunctionContents ::
+//
+//	ClassString
+//	ClassString | ClassStringDisjunctionContents
+//
+// Returns whether a disjunction was consumed, and whether it may contain
+// strings, i.e. one of its strings is not a single character.
+func (p *Parser) consumeClassStringDisjunction() (bool, bool) {
+	start := p.lexer.I
+	if !p.lexer.Eat(unicode_consts.ReverseSolidus) {
+		return false, false
+	}
+	if !p.lexer.Eat2(unicode_consts.LatinSmallLetterQ, unicode_consts.LeftCurlyBracket) {
+		p.lexer.Rewind(start)
+		return false, false
+	}
+
+	p.onClassStringDisjunctionEnter(start)
+	mayContainStrings := false
+	for {
+		if p.consumeClassString() {
+			mayContainStrings = true
+		}
+		if !p.lexer.Eat(unicode_consts.VerticalLine) {
+			break
+		}
+	}
+	if !p.lexer.Eat(unicode_consts.RightCurlyBracket) {
+		p.raise("Unterminated class string disjunction")
+	}
+	p.onClassStringDisjunctionLeave(start, p.lexer.I)
+	return true, mayContainStrings
+}
+
+func (p *Parser) onClassStringDisjunctionEnter(start int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.CharacterClass:
+		node := p.newClassStringDisjunction()
+		*node = regexp_ast.ClassStringDisjunction{
+			Parent: parent,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   -1,
+			},
+			Alternatives: []*regexp_ast.StringAlternative{},
+		}
+		p.node = node
+		parent.Elements = append(parent.Elements, node)
+	default:
+		p.raise("The parent of ClassStringDisjunction must be CharacterClass")
+	}
+}
+
+func (p *Parser) onClassStringDisjunctionLeave(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	p.node.SetEnd(end)
+	p.node = p.node.GetParent()
+}
+
+// ClassString ::
+//
+//	[empty]
+//	NonEmptyClassString
+//
+// Returns whether the string is not a single character, so that the
+// disjunction may contain strings.
+func (p *Parser) consumeClassString() bool {
+	start := p.lexer.I
+	p.onStringAlternativeEnter(start)
+	count := 0
+	for p.lexer.CP != -1 && p.consumeClassSetCharacter() {
+		count++
+	}
+	p.onStringAlternativeLeave(start, p.lexer.I)
+	return count != 1
+}
+
+func (p *Parser) onStringAlternativeEnter(start int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.ClassStringDisjunction:
+		node := p.newStringAlternative()
+		*node = regexp_ast.StringAlternative{
+			Parent: parent,
+			Loc: regexp_ast.Loc{
+				Start: start,
+				End:   -1,
+			},
+			Elements: []*regexp_ast.Character{},
+		}
+		p.node = node
+		parent.Alternatives = append(parent.Alternatives, node)
+	default:
+		p.raise("The parent of StringAlternative must be ClassStringDisjunction")
+	}
+}
+
+func (p *Parser) onStringAlternativeLeave(start int, end int) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	p.node.SetEnd(end)
+	p.node = p.node.GetParent()
+}
+
+// ClassSetCharacter ::
+//
+//	[lookahead ∉ ClassSetReservedDoublePunctuator] SourceCharacter but not ClassSetSyntaxCharacter
+//	\ CharacterEscape
+//	\ ClassSetReservedPunctuator
+//	\b
+//
+// https://tc39.es/ecma262/multipage/text-processing.html#prod-ClassSetCharacter
+func (p *Parser) consumeClassSetCharacter() bool {
+	start := p.lexer.I
+	cp := p.lexer.CP
+
+	if cp != p.lexer.Peek() || !unicode_consts.IsClassSetReservedDoublePunctuatorCharacter(cp) {
+		if cp != -1 && !unicode_consts.IsClassSetSyntaxCharacter(cp) {
+			p.lexer.Next()
+			p.state.lastIntValue = cp
+			p.onCharacter(start, p.lexer.I, cp)
+			return true
+		}
+	}
+
+	if p.lexer.Eat(unicode_consts.ReverseSolidus) {
+		if p.consumeCharacterEscape() {
+			return true
+		}
+		if cp := p.lexer.CP; unicode_consts.IsClassSetReservedPunctuator(cp) {
+			p.lexer.Next()
+			p.state.lastIntValue = cp
+			p.onCharacter(start, p.lexer.I, cp)
+			return true
+		}
+		if p.lexer.Eat(unicode_consts.LatinSmallLetterB) {
+			p.state.lastIntValue = unicode_consts.Backspace
+			p.onCharacter(start, p.lexer.I, p.state.lastIntValue)
+			return true
+		}
+		p.lexer.Rewind(start)
+	}
+	return false
+}
+
 // ------------------------------------------------------------------------------
 // ClassAtom::
 //
@@ -596,7 +1704,14 @@ func (p *Parser) consumeClassAtom() bool {
 			return true
 		}
 
-		p.raise("Invalid escape")
+		// ClassAtomNoDash :: \ [lookahead = c]
+		if !p.u && p.lexer.Match(unicode_consts.LatinSmallLetterC) {
+			p.state.lastIntValue = unicode_consts.ReverseSolidus
+			p.onCharacter(start, p.lexer.I, p.state.lastIntValue)
+			return true
+		}
+
+		p.raiseInvalidEscape()
 
 		p.lexer.Rewind(start)
 	}
@@ -631,6 +1746,18 @@ func (p *Parser) consumeClassEscape() bool {
 		return true
 	}
 
+	// [~U] c ClassControlLetter
+	if !p.u && p.lexer.Eat(unicode_consts.LatinSmallLetterC) {
+		cp := p.lexer.CP
+		if unicode_consts.IsDecimalDigit(cp) || cp == unicode_consts.LowLine {
+			p.lexer.Next()
+			p.state.lastIntValue = cp % 0x20
+			p.onCharacter(start-1, p.lexer.I, p.state.lastIntValue)
+			return true
+		}
+		p.lexer.Rewind(start)
+	}
+
 	return p.consumeCharacterClassEscape() || p.consumeCharacterEscape()
 }
 
@@ -648,10 +1775,175 @@ func (p *Parser) consumeClassEscape() bool {
 //
 // https://tc39.es/ecma262/multipage/text-processing.html#prod-CharacterClassEscape
 // ------------------------------------------------------------------------------
+//
+// The escape sets lastIntValue to -1, as it is not a character, and
+// lastMayContainStrings to whether it is a property of strings.
 func (p *Parser) consumeCharacterClassEscape() bool {
+	start := p.lexer.I
+	p.state.lastMayContainStrings = false
+
+	for _, escape := range characterClassEscapes {
+		if p.lexer.Eat(escape.cp) {
+			p.state.lastIntValue = -1
+			p.onEscapeCharacterSet(start-1, p.lexer.I, escape.kind, escape.negate)
+			return true
+		}
+	}
+
+	if p.u && (p.lexer.Match(unicode_consts.LatinSmallLetterP) || p.lexer.Match(unicode_consts.LatinCapitalLetterP)) {
+		negate := p.lexer.CP == unicode_consts.LatinCapitalLetterP
+		p.lexer.Next()
+		if p.lexer.Eat(unicode_consts.LeftCurlyBracket) &&
+			p.eatUnicodePropertyValueExpression() &&
+			p.lexer.Eat(unicode_consts.RightCurlyBracket) {
+			strings := p.state.lastMayContainStrings
+			if negate && strings {
+				p.raise("Invalid property name")
+			}
+			p.state.lastIntValue = -1
+			p.onUnicodePropertyCharacterSet(start-1, p.lexer.I, p.state.lastKeyValue, p.state.lastValValue, negate, strings)
+			return true
+		}
+		p.raise("Invalid property name")
+		p.lexer.Rewind(start)
+	}
+
 	return false
 }
 
+var characterClassEscapes = []struct {
+	cp     int
+	kind   regexp_ast.EscapeKind
+	negate bool
+}{
+	{unicode_consts.LatinSmallLetterD, regexp_ast.EscapeDigit, false},
+	{unicode_consts.LatinCapitalLetterD, regexp_ast.EscapeDigit, true},
+	{unicode_consts.LatinSmallLetterS, regexp_ast.EscapeSpace, false},
+	{unicode_consts.LatinCapitalLetterS, regexp_ast.EscapeSpace, true},
+	{unicode_consts.LatinSmallLetterW, regexp_ast.EscapeWord, false},
+	{unicode_consts.LatinCapitalLetterW, regexp_ast.EscapeWord, true},
+}
+
+func (p *Parser) onEscapeCharacterSet(start int, end int, kind regexp_ast.EscapeKind, negate bool) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	node := p.newEscapeCharacterSet()
+	*node = regexp_ast.EscapeCharacterSet{
+		Parent: p.node,
+		Loc: regexp_ast.Loc{
+			Start: start,
+			End:   end,
+		},
+		Kind:   kind,
+		Negate: negate,
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Alternative:
+		parent.Elements = append(parent.Elements, node)
+	case *regexp_ast.CharacterClass:
+		parent.Elements = append(parent.Elements, node)
+	default:
+		p.raise("The parent of EscapeCharacterSet must be Alternative or CharacterClass")
+	}
+}
+
+func (p *Parser) onUnicodePropertyCharacterSet(start int, end int, key string, value string, negate bool, strings bool) {
+	if p.opts.ValidateOnly {
+		return
+	}
+	node := p.newUnicodePropertyCharacterSet()
+	*node = regexp_ast.UnicodePropertyCharacterSet{
+		Parent: p.node,
+		Loc: regexp_ast.Loc{
+			Start: start,
+			End:   end,
+		},
+		Key:     key,
+		Value:   value,
+		Negate:  negate,
+		Strings: strings,
+	}
+	switch parent := p.node.(type) {
+	case *regexp_ast.Alternative:
+		parent.Elements = append(parent.Elements, node)
+	case *regexp_ast.CharacterClass:
+		parent.Elements = append(parent.Elements, node)
+	default:
+		p.raise("The parent of UnicodePropertyCharacterSet must be Alternative or CharacterClass")
+	}
+}
+
+// UnicodePropertyValueExpression ::
+//
+//	UnicodePropertyName = UnicodePropertyValue
+//	LoneUnicodePropertyNameOrValue
+//
+// Sets lastKeyValue and lastValValue to the name and the value as written. A
+// lone General_Category value has the name "General_Category", and a lone
+// binary property no value.
+func (p *Parser) eatUnicodePropertyValueExpression() bool {
+	start := p.lexer.I
+
+	if p.eatUnicodePropertyName() && p.lexer.Eat(unicode_consts.EqualsSign) {
+		key := p.state.lastStrValue
+		if p.eatUnicodePropertyValue() && unicode_consts.IsValidUnicodeProperty(key, p.state.lastStrValue) {
+			p.state.lastKeyValue = key
+			p.state.lastValValue = p.state.lastStrValue
+			return true
+		}
+		return false
+	}
+	p.lexer.Rewind(start)
+
+	if p.eatUnicodePropertyValue() {
+		nameOrValue := p.state.lastStrValue
+		if _, ok := unicode_consts.GeneralCategoryValue(nameOrValue); ok {
+			p.state.lastKeyValue = "General_Category"
+			p.state.lastValValue = nameOrValue
+			return true
+		}
+		if _, ok := unicode_consts.BinaryProperty(nameOrValue); ok {
+			p.state.lastKeyValue = nameOrValue
+			p.state.lastValValue = ""
+			return true
+		}
+		if p.v && unicode_consts.IsPropertyOfStrings(nameOrValue) {
+			p.state.lastKeyValue = nameOrValue
+			p.state.lastValValue = ""
+			p.state.lastMayContainStrings = true
+			return true
+		}
+	}
+	return false
+}
+
+// UnicodePropertyName ::
+//
+//	UnicodePropertyNameCharacters
+func (p *Parser) eatUnicodePropertyName() bool {
+	var b strings.Builder
+	for unicode_consts.IsLatinLetter(p.lexer.CP) || p.lexer.CP == unicode_consts.LowLine {
+		b.WriteByte(byte(p.lexer.CP))
+		p.lexer.Next()
+	}
+	p.state.lastStrValue = b.String()
+	return b.Len() != 0
+}
+
+// UnicodePropertyValue ::
+//
+//	UnicodePropertyValueCharacters
+func (p *Parser) eatUnicodePropertyValue() bool {
+	var b strings.Builder
+	for unicode_consts.IsLatinLetter(p.lexer.CP) || unicode_consts.IsDecimalDigit(p.lexer.CP) || p.lexer.CP == unicode_consts.LowLine {
+		b.WriteByte(byte(p.lexer.CP))
+		p.lexer.Next()
+	}
+	p.state.lastStrValue = b.String()
+	return b.Len() != 0
+}
+
 // ------------------------------------------------------------------------------
 // CharacterEscape ::
 //
@@ -665,5 +1957,221 @@ func (p *Parser) consumeCharacterClassEscape() bool {
 // https://tc39.es/ecma262/multipage/text-processing.html#prod-CharacterEscape
 // ------------------------------------------------------------------------------
 func (p *Parser) consumeCharacterEscape() bool {
+	start := p.lexer.I
+	if p.eatControlEscape() ||
+		p.eatCControlLetter() ||
+		p.eatZero() ||
+		p.eatHexEscapeSequence() ||
+		p.eatRegExpUnicodeEscapeSequence(false) ||
+		(!p.u && p.eatLegacyOctalEscapeSequence()) ||
+		p.eatIdentityEscape() {
+		p.onCharacter(start-1, p.lexer.I, p.state.lastIntValue)
+		return true
+	}
+	return false
+}
+
+// ControlEscape :: one of
+//
+//	f n r t v
+func (p *Parser) eatControlEscape() bool {
+	if p.lexer.Eat(unicode_consts.LatinSmallLetterF) {
+		p.state.lastIntValue = unicode_consts.FormFeed
+		return true
+	}
+	if p.lexer.Eat(unicode_consts.LatinSmallLetterN) {
+		p.state.lastIntValue = unicode_consts.LineFeed
+		return true
+	}
+	if p.lexer.Eat(unicode_consts.LatinSmallLetterR) {
+		p.state.lastIntValue = unicode_consts.CarriageReturn
+		return true
+	}
+	if p.lexer.Eat(unicode_consts.LatinSmallLetterT) {
+		p.state.lastIntValue = unicode_consts.CharacterTabulation
+		return true
+	}
+	if p.lexer.Eat(unicode_consts.LatinSmallLetterV) {
+		p.state.lastIntValue = unicode_consts.LineTabulation
+		return true
+	}
+	return false
+}
+
+// c AsciiLetter
+func (p *Parser) eatCControlLetter() bool {
+	start := p.lexer.I
+	if p.lexer.Eat(unicode_consts.LatinSmallLetterC) {
+		if unicode_consts.IsLatinLetter(p.lexer.CP) {
+			p.state.lastIntValue = p.lexer.CP % 0x20
+			p.lexer.Next()
+			return true
+		}
+		p.lexer.Rewind(start)
+	}
+	return false
+}
+
+// 0 [lookahead ∉ DecimalDigit]
+func (p *Parser) eatZero() bool {
+	start := p.lexer.I
+	if p.lexer.Eat(unicode_consts.DigitZero) {
+		if !unicode_consts.IsDecimalDigit(p.lexer.CP) {
+			p.state.lastIntValue = 0
+			return true
+		}
+		p.lexer.Rewind(start)
+	}
+	return false
+}
+
+// HexEscapeSequence ::
+//
+//	x HexDigit HexDigit
+func (p *Parser) eatHexEscapeSequence() bool {
+	start := p.lexer.I
+	if p.lexer.Eat(unicode_consts.LatinSmallLetterX) {
+		if p.eatFixedHexDigits(2) {
+			return true
+		}
+		if p.u {
+			p.raise("Invalid escape")
+		}
+		p.lexer.Rewind(start)
+	}
+	return false
+}
+
+// RegExpUnicodeEscapeSequence ::
+//
+//	[+U] u HexLeadSurrogate \u HexTrailSurrogate
+//	[+U] u HexLeadSurrogate
+//	[+U] u HexTrailSurrogate
+//	[+U] u HexNonSurrogate
+//	[~U] u Hex4Digits
+//	[+U] u{ CodePoint }
+//
+// Group names read it in unicode mode whatever the flags, with forceU.
+func (p *Parser) eatRegExpUnicodeEscapeSequence(forceU bool) bool {
+	start := p.lexer.I
+	if !p.lexer.Eat(unicode_consts.LatinSmallLetterU) {
+		return false
+	}
+	u := p.u || forceU
+
+	if p.eatFixedHexDigits(4) {
+		lead := p.state.lastIntValue
+		if u && unicode_consts.IsLeadSurrogate(lead) {
+			leadEnd := p.lexer.I
+			if p.lexer.Eat(unicode_consts.ReverseSolidus) &&
+				p.lexer.Eat(unicode_consts.LatinSmallLetterU) &&
+				p.eatFixedHexDigits(4) &&
+				unicode_consts.IsTrailSurrogate(p.state.lastIntValue) {
+				p.state.lastIntValue = unicode_consts.CombineSurrogatePair(lead, p.state.lastIntValue)
+				return true
+			}
+			p.lexer.Rewind(leadEnd)
+			p.state.lastIntValue = lead
+		}
+		return true
+	}
+
+	if u && p.lexer.Eat(unicode_consts.LeftCurlyBracket) {
+		if p.eatHexDigits() &&
+			p.state.lastIntValue <= unicode_consts.MaxCodePoint &&
+			p.lexer.Eat(unicode_consts.RightCurlyBracket) {
+			return true
+		}
+	}
+
+	if u {
+		p.raise("Invalid unicode escape")
+	}
+	p.lexer.Rewind(start)
 	return false
 }
+
+// LegacyOctalEscapeSequence ::
+//
+//	OctalDigit [lookahead ∉ OctalDigit]
+//	ZeroToThree OctalDigit [lookahead ∉ OctalDigit]
+//	FourToSeven OctalDigit
+//	ZeroToThree OctalDigit OctalDigit
+func (p *Parser) eatLegacyOctalEscapeSequence() bool {
+	if !p.eatOctalDigit() {
+		return false
+	}
+	n1 := p.state.lastIntValue
+	if p.eatOctalDigit() {
+		n2 := p.state.lastIntValue
+		if n1 <= 3 && p.eatOctalDigit() {
+			p.state.lastIntValue = n1*64 + n2*8 + p.state.lastIntValue
+		} else {
+			p.state.lastIntValue = n1*8 + n2
+		}
+	} else {
+		p.state.lastIntValue = n1
+	}
+	return true
+}
+
+// IdentityEscape ::
+//
+//	[+U] SyntaxCharacter
+//	[+U] /
+//	[~U] SourceCharacter but not c, nor k with [+N]
+func (p *Parser) eatIdentityEscape() bool {
+	cp := p.lexer.CP
+	if p.u {
+		if unicode_consts.IsSyntaxCharacter(cp) || cp == unicode_consts.Solidus {
+			p.state.lastIntValue = cp
+			p.lexer.Next()
+			return true
+		}
+		return false
+	}
+	if cp != -1 && cp != unicode_consts.LatinSmallLetterC && (!p.n || cp != unicode_consts.LatinSmallLetterK) {
+		p.state.lastIntValue = cp
+		p.lexer.Next()
+		return true
+	}
+	return false
+}
+
+func (p *Parser) eatOctalDigit() bool {
+	if unicode_consts.IsOctalDigit(p.lexer.CP) {
+		p.state.lastIntValue = p.lexer.CP - unicode_consts.DigitZero
+		p.lexer.Next()
+		return true
+	}
+	return false
+}
+
+func (p *Parser) eatFixedHexDigits(length int) bool {
+	start := p.lexer.I
+	p.state.lastIntValue = 0
+	for i := 0; i < length; i++ {
+		cp := p.lexer.CP
+		if !unicode_consts.IsHexDigit(cp) {
+			p.lexer.Rewind(start)
+			return false
+		}
+		p.state.lastIntValue = 16*p.state.lastIntValue + unicode_consts.DecimalToDigit(cp)
+		p.lexer.Next()
+	}
+	return true
+}
+
+func (p *Parser) eatHexDigits() bool {
+	start := p.lexer.I
+	p.state.lastIntValue = 0
+	for unicode_consts.IsHexDigit(p.lexer.CP) {
+		p.state.lastIntValue = 16*p.state.lastIntValue + unicode_consts.DecimalToDigit(p.lexer.CP)
+		// Stop accumulating once it's out of range to avoid overflow
+		if p.state.lastIntValue > unicode_consts.MaxCodePoint {
+			p.state.lastIntValue = unicode_consts.MaxCodePoint + 1
+		}
+		p.lexer.Next()
+	}
+	return p.lexer.I != start
+}
@@ -2,13 +2,19 @@ package parser_test
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/sosukesuzuki/regexpp-go/internal/ast_dump"
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
 	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
 )
 
 const fixtures = "./fixtures"
@@ -35,19 +41,40 @@ func TestParsePattern(t *testing.T) {
 			t.Error("Failed to read input.txt file")
 		}
 		input := string(bytes)
-		parser := parser.NewParser(input, true)
+		// flags.txt selects the flags to parse with, `u` by default
+		var opts parser.Options
+		if flags, err := os.ReadFile(filepath.Join(fixtureDirPath, "flags.txt")); err == nil {
+			f, err := parser.ParseFlags(strings.TrimSpace(string(flags)))
+			if err != nil {
+				t.Errorf("%s: invalid flags.txt (%s)", fixtureDirPath, err.Error())
+			}
+			opts.UnicodeSets = f.UnicodeSets
+		}
+		parser := parser.NewParserWithOptions(input, true, opts)
 		pattern, err := parser.ParsePattern()
 		if err != nil {
 			t.Errorf("%s: (%s)", fixtureDirPath, err.Error())
 		}
+		if err := regexp_ast.Verify(pattern); err != nil {
+			t.Errorf("%s: invalid tree (%s)", fixtureDirPath, err.Error())
+		}
 		outputPath := filepath.Join(fixtureDirPath, "output.json")
+		dumpPath := filepath.Join(fixtureDirPath, "output.txt")
+		dump := ast_dump.Sprint(pattern, 0)
 		if u {
 			j, err := json.MarshalIndent(pattern, "", "  ")
 			if err != nil {
 				t.Errorf(err.Error())
 			}
 			os.WriteFile(outputPath, j, 0660)
+			os.WriteFile(dumpPath, []byte(dump), 0660)
 		} else {
+			if want, err := os.ReadFile(dumpPath); err != nil {
+				t.Errorf("%s: Failed to read output.txt file", fixtureDirPath)
+			} else if string(want) != dump {
+				t.Errorf("%s: Diff\nexpected:\n%s\nactual:\n%s", fixtureDirPath, want, dump)
+			}
+
 			bytes1, err := ioutil.ReadFile(outputPath)
 			if err != nil {
 				t.Error("Failed to read output.json file")
@@ -69,3 +96,479 @@ func TestParsePattern(t *testing.T) {
 		}
 	}
 }
+
+func TestOffsetUnit(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputS    string
+		inputU    bool
+		inputUnit offset.Unit
+		wantLocs  []regexp_ast.Loc
+	}{
+		{
+			name:      "ユニコードモードで、UTF-16 のオフセットを返す",
+			inputS:    "a𠮟b",
+			inputU:    true,
+			inputUnit: offset.UTF16,
+			wantLocs:  []regexp_ast.Loc{{Start: 0, End: 1}, {Start: 1, End: 3}, {Start: 3, End: 4}},
+		},
+		{
+			name:      "ユニコードモードで、コードポイントのオフセットを返す",
+			inputS:    "a𠮟b",
+			inputU:    true,
+			inputUnit: offset.CodePoint,
+			wantLocs:  []regexp_ast.Loc{{Start: 0, End: 1}, {Start: 1, End: 2}, {Start: 2, End: 3}},
+		},
+		{
+			name:      "ユニコードモードで、UTF-8 のオフセットを返す",
+			inputS:    "a𠮟b",
+			inputU:    true,
+			inputUnit: offset.UTF8,
+			wantLocs:  []regexp_ast.Loc{{Start: 0, End: 1}, {Start: 1, End: 5}, {Start: 5, End: 6}},
+		},
+		{
+			name:      "非ユニコードモードで、サロゲートペアの半分ずつを含む UTF-8 のオフセットを返す",
+			inputS:    "a𠮟b",
+			inputU:    false,
+			inputUnit: offset.UTF8,
+			wantLocs:  []regexp_ast.Loc{{Start: 0, End: 1}, {Start: 1, End: 5}, {Start: 5, End: 5}, {Start: 5, End: 6}},
+		},
+		{
+			name:      "非ユニコードモードで、サロゲートペアの半分ずつを含むコードポイントのオフセットを返す",
+			inputS:    "a𠮟b",
+			inputU:    false,
+			inputUnit: offset.CodePoint,
+			wantLocs:  []regexp_ast.Loc{{Start: 0, End: 1}, {Start: 1, End: 2}, {Start: 2, End: 2}, {Start: 2, End: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParserWithOptions(tt.inputS, tt.inputU, parser.Options{OffsetUnit: tt.inputUnit})
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			elements := pattern.Alternatives[0].Elements
+			if len(elements) != len(tt.wantLocs) {
+				t.Fatalf("Unexpected number of elements, expected %d, actual %d", len(tt.wantLocs), len(elements))
+			}
+			for i, el := range elements {
+				loc := el.(regexp_ast.Node).GetLoc()
+				if loc != tt.wantLocs[i] {
+					t.Errorf("Unexpected Loc, expected %v, actual %v", tt.wantLocs[i], loc)
+				}
+			}
+			if err := regexp_ast.Verify(pattern); err != nil {
+				t.Errorf("Invalid tree (%s)", err.Error())
+			}
+		})
+	}
+}
+
+func TestOffsetUnitNested(t *testing.T) {
+	// Every node is converted, including the ones in groups and in classes
+	// with the v flag
+	source := "(?<n>𠮟)[\\q{𠮟}&&𠮟]"
+	p := parser.NewParserWithOptions(source, true, parser.Options{OffsetUnit: offset.UTF8, UnicodeSets: true})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locs []regexp_ast.Loc
+	regexp_ast.Inspect(pattern, func(n regexp_ast.Node) bool {
+		if c, ok := n.(*regexp_ast.Character); ok {
+			locs = append(locs, c.Loc)
+		}
+		return true
+	})
+	want := []regexp_ast.Loc{{Start: 5, End: 9}, {Start: 14, End: 18}, {Start: 21, End: 25}}
+	if !reflect.DeepEqual(locs, want) {
+		t.Errorf("Unexpected output, expected %v, actual %v", want, locs)
+	}
+	if err := regexp_ast.Verify(pattern); err != nil {
+		t.Errorf("Invalid tree (%s)", err.Error())
+	}
+}
+
+func TestHostSource(t *testing.T) {
+	host := "const re = [\n  /foo/,\n  /a[b-c]/u,\n];\n"
+	base := strings.Index(host, "a[b-c]")
+	p := parser.NewParserWithOptions("a[b-c]", true, parser.Options{
+		Host: &parser.HostSource{
+			BaseOffset: base,
+			Text:       host,
+		},
+	})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []regexp_ast.Position{{Line: 3, Column: 3}, {Line: 3, Column: 4}}
+	for i, el := range pattern.Alternatives[0].Elements {
+		loc := el.(regexp_ast.Node).GetLoc()
+		if loc.StartPos == nil || *loc.StartPos != want[i] {
+			t.Errorf("Unexpected position, expected %v, actual %v", want[i], loc.StartPos)
+		}
+	}
+	if end := pattern.Loc.EndPos; end == nil || *end != (regexp_ast.Position{Line: 3, Column: 9}) {
+		t.Errorf("Unexpected end position of Pattern, actual %v", end)
+	}
+}
+
+// Machine-generated alternations can be hundreds of kilobytes long. Parsing
+// time should grow linearly with the size of the pattern.
+func BenchmarkParsePattern(b *testing.B) {
+	for _, size := range []int{10_000, 100_000} {
+		var sb strings.Builder
+		for i := 0; sb.Len() < size; i++ {
+			fmt.Fprintf(&sb, "word%d[a-z0-9]+|", i)
+		}
+		s := sb.String()
+		b.Run(fmt.Sprintf("size=%d", len(s)), func(b *testing.B) {
+			b.SetBytes(int64(len(s)))
+			for i := 0; i < b.N; i++ {
+				p := parser.NewParser(s, true)
+				if _, err := p.ParsePattern(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := parser.NewParser("a+", true)
+	if _, err := p.ParsePattern(); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Reset("[A-Z]|b", false, parser.Options{})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parser.NewParser("[A-Z]|b", false)
+	wantPattern, _ := want.ParsePattern()
+	if !reflect.DeepEqual(pattern, wantPattern) {
+		t.Error("Reset parser returned a different AST from a new parser")
+	}
+}
+
+func TestResetValidateOnlyAllocs(t *testing.T) {
+	sources := []string{"a+b*?|[A-Za-z0-9_-]", "a{11,}.+?b{0,20}?c{5}?", "あい𠮟"}
+	p := parser.NewParser("", true)
+	opts := parser.Options{ValidateOnly: true}
+	// Grow the buffers first
+	for _, s := range sources {
+		p.Reset(s, true, opts)
+		p.ParsePattern()
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, s := range sources {
+			p.Reset(s, true, opts)
+			if pattern, err := p.ParsePattern(); pattern != nil || err != nil {
+				t.Fatalf("Unexpected result: %v, %v", pattern, err)
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Unexpected allocations, expected 0, actual %f", allocs)
+	}
+}
+
+func TestArena(t *testing.T) {
+	tests := []struct {
+		inputS string
+		inputV bool
+	}{
+		{inputS: "a+|[b-c]."},
+		{inputS: `(?<n>a)(?:b)\k<n>\1^$\b\B(?=c)(?<!d)\d\p{L}`},
+		{inputS: `[[\q{ab|c}d]&&\w&&[e--\p{L}]]`, inputV: true},
+	}
+
+	for _, tt := range tests {
+		arena := &regexp_ast.Arena{}
+		p := parser.NewParserWithOptions(tt.inputS, true, parser.Options{Arena: arena, UnicodeSets: tt.inputV})
+		pattern, err := p.ParsePattern()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := parser.NewParserWithOptions(tt.inputS, true, parser.Options{UnicodeSets: tt.inputV})
+		wantPattern, _ := want.ParsePattern()
+		if !reflect.DeepEqual(pattern, wantPattern) {
+			t.Errorf("%s: Arena-backed parser returned a different AST", tt.inputS)
+		}
+	}
+}
+
+func BenchmarkReset(b *testing.B) {
+	s := "a{11,}.+?b{0,20}?c{5}?|[A-Za-z0-9_-]+"
+	for _, tt := range []struct {
+		name string
+		opts parser.Options
+	}{
+		{name: "ast", opts: parser.Options{}},
+		{name: "arena", opts: parser.Options{Arena: &regexp_ast.Arena{}}},
+		{name: "validate", opts: parser.Options{ValidateOnly: true}},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			p := parser.NewParser("", true)
+			for i := 0; i < b.N; i++ {
+				if tt.opts.Arena != nil && i%1000 == 0 {
+					tt.opts.Arena.Reset()
+				}
+				p.Reset(s, true, tt.opts)
+				p.ParsePattern()
+			}
+		})
+	}
+}
+
+func TestCharacterEscape(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputU     bool
+		wantValues []int
+	}{
+		{
+			name:       "非ユニコードモードで、レガシーな8進数エスケープを読む",
+			inputS:     `\1\07\101\8`,
+			inputU:     false,
+			wantValues: []int{0o1, 0o7, 0o101, '8'},
+		},
+		{
+			name:       "非ユニコードモードで、不完全なエスケープを文字として読む",
+			inputS:     `\x4\u12\k\-`,
+			inputU:     false,
+			wantValues: []int{'x', '4', 'u', '1', '2', 'k', '-'},
+		},
+		{
+			name:       "非ユニコードモードで、`\\c` の後に英字がない場合はバックスラッシュとして読む",
+			inputS:     `\c1`,
+			inputU:     false,
+			wantValues: []int{'\\', 'c', '1'},
+		},
+		{
+			name:       "非ユニコードモードで、サロゲートペアのエスケープを別々の文字として読む",
+			inputS:     `\ud83d\ude00`,
+			inputU:     false,
+			wantValues: []int{0xd83d, 0xde00},
+		},
+		{
+			name:       "ユニコードモードで、サロゲートペアのエスケープを1つの文字として読む",
+			inputS:     `\ud83d\ude00`,
+			inputU:     true,
+			wantValues: []int{0x1f600},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParser(tt.inputS, tt.inputU)
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []int
+			for _, el := range pattern.Alternatives[0].Elements {
+				values = append(values, el.(*regexp_ast.Character).Value)
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("Unexpected values, expected %v, actual %v", tt.wantValues, values)
+			}
+		})
+	}
+}
+
+func TestParsePatternError(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputS    string
+		inputU    bool
+		wantIndex int
+	}{
+		{name: "ユニコードモードで、不正なエスケープ", inputS: `a\-`, inputU: true, wantIndex: 2},
+		{name: "ユニコードモードで、不正な16進数エスケープ", inputS: `\x4`, inputU: true, wantIndex: 2},
+		{name: "ユニコードモードで、範囲外のコードポイント", inputS: `\u{110000}`, inputU: true, wantIndex: 9},
+		{name: "末尾のバックスラッシュ", inputS: `a\`, inputU: false, wantIndex: 2},
+		{name: "対応しない閉じ括弧", inputS: `a)`, inputU: false, wantIndex: 1},
+		{name: "繰り返す対象がない量指定子", inputS: `a|*`, inputU: false, wantIndex: 2},
+		{name: "閉じていない文字クラス", inputS: `[a`, inputU: true, wantIndex: 2},
+		{name: "閉じていないグループ", inputS: `(a`, inputU: true, wantIndex: 2},
+		{name: "不正なグループ", inputS: `(?a)`, inputU: false, wantIndex: 2},
+		{name: "量指定子のついた後読み", inputS: `(?<=a)*`, inputU: false, wantIndex: 6},
+		{name: "ユニコードモードで、量指定子のついた先読み", inputS: `(?=a)*`, inputU: true, wantIndex: 5},
+		{name: "重複したグループ名", inputS: `(?<a>x)(?<a>y)`, inputU: false, wantIndex: 12},
+		{name: "存在しないグループ名への後方参照", inputS: `(?<a>x)\k<b>`, inputU: false, wantIndex: 12},
+		{name: "ユニコードモードで、存在しないグループへの後方参照", inputS: `(a)\2`, inputU: true, wantIndex: 5},
+		{name: "ユニコードモードで、不正なプロパティ名", inputS: `\p{Foo}`, inputU: true, wantIndex: 6},
+		{name: "ユニコードモードで、範囲の端の文字クラスエスケープ", inputS: `[\d-z]`, inputU: true, wantIndex: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParser(tt.inputS, tt.inputU)
+			_, err := p.ParsePattern()
+			var perr *parser.ParserError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Expected a ParserError, actual %v", err)
+			}
+			if perr.Index() != tt.wantIndex {
+				t.Errorf("Unexpected index of %q, expected %d, actual %d", perr.Error(), tt.wantIndex, perr.Index())
+			}
+		})
+	}
+}
+
+func TestUnicodeSetsError(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputS    string
+		wantMsg   string
+		wantIndex int
+	}{
+		{name: "文字列を含みうる否定クラス", inputS: `[^\q{ab}]`, wantMsg: "Negated character class may contain strings", wantIndex: 9},
+		{name: "文字列を含みうる入れ子の否定クラス", inputS: `[[^\q{a|bc}]]`, wantMsg: "Negated character class may contain strings", wantIndex: 12},
+		{name: "差の左辺が文字列を含みうる否定クラス", inputS: `[^[\q{ab}--\q{ab}]]`, wantMsg: "Negated character class may contain strings", wantIndex: 19},
+		{name: "全ての項が文字列を含みうる積の否定クラス", inputS: `[^\q{ab}&&[\q{ab}c]]`, wantMsg: "Negated character class may contain strings", wantIndex: 20},
+		{name: "空文字列を含みうる否定クラス", inputS: `[^\q{}]`, wantMsg: "Negated character class may contain strings", wantIndex: 7},
+		{name: "範囲の後の積", inputS: `[a-z&&b]`, wantMsg: "Invalid character in character class", wantIndex: 4},
+		{name: "積と差の混在", inputS: `[a&&b--c]`, wantMsg: "Invalid character in character class", wantIndex: 5},
+		{name: "予約された二重の区切り文字", inputS: `[!!]`, wantMsg: "Invalid set operation in character class", wantIndex: 1},
+		{name: "エスケープされていない構文文字", inputS: `[a(]`, wantMsg: "Invalid character in character class", wantIndex: 2},
+		{name: "逆順の範囲", inputS: `[z-a]`, wantMsg: "Range out of order in character class", wantIndex: 4},
+		{name: "閉じていない文字列の選言", inputS: `[\q{a]`, wantMsg: "Unterminated class string disjunction", wantIndex: 5},
+		{name: "閉じていない入れ子のクラス", inputS: `[[a]`, wantMsg: "Unterminated character class", wantIndex: 4},
+		{name: "否定された文字列のプロパティ", inputS: `\P{RGI_Emoji}`, wantMsg: "Invalid property name", wantIndex: 13},
+		{name: "文字列のプロパティを含む否定クラス", inputS: `[^\p{Basic_Emoji}]`, wantMsg: "Negated character class may contain strings", wantIndex: 18},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, validateOnly := range []bool{false, true} {
+				p := parser.NewParserWithOptions(tt.inputS, false, parser.Options{UnicodeSets: true, ValidateOnly: validateOnly})
+				_, err := p.ParsePattern()
+				var perr *parser.ParserError
+				if !errors.As(err, &perr) {
+					t.Fatalf("Expected a ParserError, actual %v", err)
+				}
+				if !strings.Contains(perr.Error(), tt.wantMsg) || perr.Index() != tt.wantIndex {
+					t.Errorf("Unexpected error, expected %q at %d, actual %q at %d", tt.wantMsg, tt.wantIndex, perr.Error(), perr.Index())
+				}
+			}
+		})
+	}
+}
+
+func TestUnicodeSetsNegatedClass(t *testing.T) {
+	// Negated classes that can't contain strings, although some of their
+	// operands can
+	for _, s := range []string{`[^\q{a|b}]`, `[^[\q{ab}&&a]]`, `[^a--\q{ab}]`, `[^[^a]]`, `[^]`, `[^\p{L}&&\p{RGI_Emoji}]`} {
+		p := parser.NewParserWithOptions(s, false, parser.Options{UnicodeSets: true})
+		pattern, err := p.ParsePattern()
+		if err != nil {
+			t.Errorf("%s: %s", s, err.Error())
+			continue
+		}
+		if err := regexp_ast.Verify(pattern); err != nil {
+			t.Errorf("%s: Invalid tree (%s)", s, err.Error())
+		}
+	}
+}
+
+func TestBackreference(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputU     bool
+		wantGroups []int
+	}{
+		{name: "番号による後方参照", inputS: `(a)(b)\2`, inputU: false, wantGroups: []int{2}},
+		{name: "名前による後方参照", inputS: `(a)(?<n>b)\k<n>`, inputU: true, wantGroups: []int{2}},
+		{name: "グループより前の後方参照", inputS: `\1(a)`, inputU: false, wantGroups: []int{1}},
+		{name: "グループの数より大きい番号は8進数エスケープ", inputS: `(a)\2`, inputU: false, wantGroups: nil},
+		{name: "名前つきグループがなければ \\k は文字", inputS: `\k<n>`, inputU: false, wantGroups: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParser(tt.inputS, tt.inputU)
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var groups []*regexp_ast.CapturingGroup
+			var refs []*regexp_ast.Backreference
+			for _, el := range pattern.Alternatives[0].Elements {
+				switch n := el.(type) {
+				case *regexp_ast.CapturingGroup:
+					groups = append(groups, n)
+				case *regexp_ast.Backreference:
+					refs = append(refs, n)
+				}
+			}
+			var got []int
+			for _, ref := range refs {
+				for i, g := range groups {
+					if ref.Resolved == g {
+						got = append(got, i+1)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantGroups) {
+				t.Errorf("Unexpected output, expected %v, actual %v", tt.wantGroups, got)
+			}
+			for _, ref := range refs {
+				if g := ref.Resolved; g == nil || len(g.References) != 1 || g.References[0] != ref {
+					t.Errorf("%s: References of the group don't have the backreference", tt.inputS)
+				}
+			}
+		})
+	}
+}
+
+func TestUnicodeProperty(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputS    string
+		inputV    bool
+		wantKey   string
+		wantValue string
+		wantOK    bool
+	}{
+		{name: "一般カテゴリの値", inputS: `\p{Lu}`, wantKey: "General_Category", wantValue: "Lu", wantOK: true},
+		{name: "一般カテゴリの別名", inputS: `\p{gc=Letter}`, wantKey: "gc", wantValue: "Letter", wantOK: true},
+		{name: "スクリプトの別名", inputS: `\P{scx=Grek}`, wantKey: "scx", wantValue: "Grek", wantOK: true},
+		{name: "二値プロパティ", inputS: `\p{Alpha}`, wantKey: "Alpha", wantOK: true},
+		{name: "二値プロパティには値がない", inputS: `\p{Alpha=Y}`, wantOK: false},
+		{name: "存在しないスクリプト", inputS: `\p{sc=Lu}`, wantOK: false},
+		{name: "v フラグなしの文字列のプロパティ", inputS: `\p{RGI_Emoji}`, wantOK: false},
+		{name: "v フラグつきの文字列のプロパティ", inputS: `\p{RGI_Emoji}`, inputV: true, wantKey: "RGI_Emoji", wantOK: true},
+		{name: "否定された文字列のプロパティ", inputS: `\P{RGI_Emoji}`, inputV: true, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParserWithOptions(tt.inputS, true, parser.Options{UnicodeSets: tt.inputV})
+			pattern, err := p.ParsePattern()
+			if (err == nil) != tt.wantOK {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !tt.wantOK {
+				return
+			}
+			set, ok := pattern.Alternatives[0].Elements[0].(*regexp_ast.UnicodePropertyCharacterSet)
+			if !ok {
+				t.Fatalf("Unexpected node %T", pattern.Alternatives[0].Elements[0])
+			}
+			if set.Key != tt.wantKey || set.Value != tt.wantValue || set.Strings != (tt.wantKey == "RGI_Emoji") {
+				t.Errorf("Unexpected output, expected %s=%s, actual %s=%s", tt.wantKey, tt.wantValue, set.Key, set.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+// Package printer turns regexp_ast trees back into ECMAScript pattern source.
+//
+// The output is guaranteed to round-trip: parsing Print(node, u) in the same
+// mode yields a tree that is structurally equal to node (Locs aside). To get
+// there, characters are escaped depending on where they appear: syntax
+// characters and a digit after a backreference like `\1` outside classes,
+// `\`, `]`, a leading `^` and an inner `-` inside classes, every syntax
+// character and punctuator inside classes with the v flag, and control
+// characters, lone surrogates and other unprintable characters everywhere.
+//
+// A tree that the parser can't produce in the given mode, e.g. a Character
+// above U+FFFF in non-unicode mode, is printed as is.
+package printer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// Print returns the pattern source of node. u selects the escapes of the
+// unicode mode.
+func Print(node regexp_ast.Node, u bool) string {
+	return PrintWithOptions(node, u, Options{})
+}
+
+type Options struct {
+	// Escape every character outside of ASCII, so that the output is plain
+	// ASCII.
+	ASCIIOnly bool
+}
+
+func PrintWithOptions(node regexp_ast.Node, u bool, opts Options) string {
+	p := &printer{u: u, opts: opts}
+	p.node(node)
+	return p.sb.String()
+}
+
+// Layout prints node like Print and sets the Loc of every node in the tree to
+// its range in the returned source, in UTF-16 code units. It gives synthetic
+// trees the same Locs as parsing the printed source would.
+func Layout(node regexp_ast.Node, u bool) string {
+	p := &printer{u: u, spans: map[regexp_ast.Node]regexp_ast.Loc{}}
+	p.node(node)
+	source := p.sb.String()
+	c := offset.NewConverter(source)
+	for n, span := range p.spans {
+		n.SetLoc(regexp_ast.Loc{
+			Start: c.Convert(span.Start, offset.UTF8, offset.UTF16),
+			End:   c.Convert(span.End, offset.UTF8, offset.UTF16),
+		})
+	}
+	return source
+}
+
+type printer struct {
+	u    bool
+	opts Options
+	sb   strings.Builder
+	// The byte range of each printed node, recorded only by Layout
+	spans map[regexp_ast.Node]regexp_ast.Loc
+}
+
+func (p *printer) record(node regexp_ast.Node, start int) {
+	if p.spans != nil {
+		p.spans[node] = regexp_ast.Loc{Start: start, End: p.sb.Len()}
+	}
+}
+
+func (p *printer) node(node regexp_ast.Node) {
+	start := p.sb.Len()
+	defer p.record(node, start)
+
+	switch n := node.(type) {
+	case *regexp_ast.Pattern:
+		p.alternatives(n.Alternatives)
+	case *regexp_ast.Alternative:
+		for i, el := range n.Elements {
+			// A digit after `\1` would extend the number of the backreference
+			if c, ok := el.(*regexp_ast.Character); ok && i > 0 && unicode_consts.IsDecimalDigit(c.Value) {
+				if b, ok := n.Elements[i-1].(*regexp_ast.Backreference); ok && b.Name == "" {
+					elStart := p.sb.Len()
+					p.sb.WriteString(escapeCodePoint(c.Value, p.u))
+					p.record(c, elStart)
+					continue
+				}
+			}
+			p.node(el.(regexp_ast.Node))
+		}
+	case *regexp_ast.Group:
+		p.sb.WriteString("(?:")
+		p.alternatives(n.Alternatives)
+		p.sb.WriteByte(')')
+	case *regexp_ast.CapturingGroup:
+		p.sb.WriteByte('(')
+		if n.Name != "" {
+			p.sb.WriteString("?<" + n.Name + ">")
+		}
+		p.alternatives(n.Alternatives)
+		p.sb.WriteByte(')')
+	case *regexp_ast.LookaroundAssertion:
+		p.sb.WriteString("(?")
+		if n.Kind == regexp_ast.Lookbehind {
+			p.sb.WriteByte('<')
+		}
+		if n.Negate {
+			p.sb.WriteByte('!')
+		} else {
+			p.sb.WriteByte('=')
+		}
+		p.alternatives(n.Alternatives)
+		p.sb.WriteByte(')')
+	case *regexp_ast.EdgeAssertion:
+		if n.Kind == regexp_ast.EdgeStart {
+			p.sb.WriteByte('^')
+		} else {
+			p.sb.WriteByte('$')
+		}
+	case *regexp_ast.WordBoundaryAssertion:
+		if n.Negate {
+			p.sb.WriteString(`\B`)
+		} else {
+			p.sb.WriteString(`\b`)
+		}
+	case *regexp_ast.Backreference:
+		if n.Name != "" {
+			p.sb.WriteString(`\k<` + n.Name + ">")
+		} else {
+			fmt.Fprintf(&p.sb, `\%d`, n.Number)
+		}
+	case *regexp_ast.Character:
+		if inClassSet(n.Parent) {
+			p.classSetCharacter(n.Value)
+		} else if _, ok := n.Parent.(*regexp_ast.CharacterClass); ok {
+			p.classCharacter(n.Value, false, false)
+		} else if _, ok := n.Parent.(*regexp_ast.CharacterClassRange); ok {
+			p.classCharacter(n.Value, false, true)
+		} else {
+			p.character(n.Value)
+		}
+	case *regexp_ast.CharacterClass:
+		p.sb.WriteByte('[')
+		if n.Negate {
+			p.sb.WriteByte('^')
+		}
+		for i, el := range n.Elements {
+			switch el := el.(type) {
+			case *regexp_ast.Character:
+				if n.UnicodeSets {
+					p.node(el)
+					break
+				}
+				first := i == 0 && !n.Negate
+				last := i == len(n.Elements)-1
+				elStart := p.sb.Len()
+				p.classCharacter(el.Value, first, el.Value == unicode_consts.HyphenMinus && (i != 0 && !last))
+				p.record(el, elStart)
+			default:
+				p.node(el.(regexp_ast.Node))
+			}
+		}
+		p.sb.WriteByte(']')
+	case *regexp_ast.CharacterClassRange:
+		minStart := p.sb.Len()
+		p.classCharacter(n.Min.Value, false, true)
+		p.record(n.Min, minStart)
+		p.sb.WriteByte('-')
+		maxStart := p.sb.Len()
+		p.classCharacter(n.Max.Value, false, true)
+		p.record(n.Max, maxStart)
+	case *regexp_ast.AnyCharacterSet:
+		p.sb.WriteByte('.')
+	case *regexp_ast.EscapeCharacterSet:
+		letter := 'd'
+		switch n.Kind {
+		case regexp_ast.EscapeSpace:
+			letter = 's'
+		case regexp_ast.EscapeWord:
+			letter = 'w'
+		}
+		if n.Negate {
+			letter = unicode.ToUpper(letter)
+		}
+		p.sb.WriteByte('\\')
+		p.sb.WriteRune(letter)
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		if n.Negate {
+			p.sb.WriteString(`\P{`)
+		} else {
+			p.sb.WriteString(`\p{`)
+		}
+		// A lone General_Category value is parsed with the key added
+		switch {
+		case n.Value == "":
+			p.sb.WriteString(n.Key)
+		case n.Key == "General_Category":
+			p.sb.WriteString(n.Value)
+		default:
+			p.sb.WriteString(n.Key + "=" + n.Value)
+		}
+		p.sb.WriteByte('}')
+	case *regexp_ast.Quantifier:
+		p.node(n.Element.(regexp_ast.Node))
+		p.quantifier(n.Min, n.Max, n.Greety)
+	case *regexp_ast.ExpressionCharacterClass:
+		p.sb.WriteByte('[')
+		if n.Negate {
+			p.sb.WriteByte('^')
+		}
+		p.node(n.Expression.(regexp_ast.Node))
+		p.sb.WriteByte(']')
+	case *regexp_ast.ClassIntersection:
+		p.node(n.Left.(regexp_ast.Node))
+		p.sb.WriteString("&&")
+		p.node(n.Right.(regexp_ast.Node))
+	case *regexp_ast.ClassSubtraction:
+		p.node(n.Left.(regexp_ast.Node))
+		p.sb.WriteString("--")
+		p.node(n.Right.(regexp_ast.Node))
+	case *regexp_ast.ClassStringDisjunction:
+		p.sb.WriteString(`\q{`)
+		for i, alt := range n.Alternatives {
+			if i > 0 {
+				p.sb.WriteByte('|')
+			}
+			p.node(alt)
+		}
+		p.sb.WriteByte('}')
+	case *regexp_ast.StringAlternative:
+		for _, c := range n.Elements {
+			p.node(c)
+		}
+	default:
+		panic(fmt.Sprintf("printer: unknown node %T", node))
+	}
+}
+
+func (p *printer) alternatives(alts []*regexp_ast.Alternative) {
+	for i, alt := range alts {
+		if i > 0 {
+			p.sb.WriteByte('|')
+		}
+		p.node(alt)
+	}
+}
+
+func (p *printer) quantifier(min int, max int, greedy bool) {
+	switch {
+	case min == 0 && max == math.MaxInt:
+		p.sb.WriteByte('*')
+	case min == 1 && max == math.MaxInt:
+		p.sb.WriteByte('+')
+	case min == 0 && max == 1:
+		p.sb.WriteByte('?')
+	case max == math.MaxInt:
+		fmt.Fprintf(&p.sb, "{%d,}", min)
+	case min == max:
+		fmt.Fprintf(&p.sb, "{%d}", min)
+	default:
+		fmt.Fprintf(&p.sb, "{%d,%d}", min, max)
+	}
+	if !greedy {
+		p.sb.WriteByte('?')
+	}
+}
+
+// character prints a character outside of classes.
+func (p *printer) character(cp int) {
+	if unicode_consts.IsSyntaxCharacter(cp) || cp == unicode_consts.Solidus {
+		p.sb.WriteByte('\\')
+		p.sb.WriteRune(rune(cp))
+		return
+	}
+	p.escapable(cp)
+}
+
+// classCharacter prints a character inside a class. first is whether it is
+// the first thing after `[`, where `^` would negate the class. escapeHyphen
+// is whether a `-` would be read as a range.
+func (p *printer) classCharacter(cp int, first bool, escapeHyphen bool) {
+	switch {
+	case cp == unicode_consts.ReverseSolidus || cp == unicode_consts.RightSquareBracket:
+		p.sb.WriteByte('\\')
+		p.sb.WriteRune(rune(cp))
+	case cp == unicode_consts.CircumflexAccent && first:
+		p.sb.WriteString(`\^`)
+	case cp == unicode_consts.HyphenMinus && escapeHyphen:
+		p.sb.WriteString(`\-`)
+	case cp == unicode_consts.Backspace:
+		p.sb.WriteString(`\b`)
+	default:
+		p.escapable(cp)
+	}
+}
+
+// inClassSet returns whether a Character with parent is in a class with the
+// v flag.
+func inClassSet(parent regexp_ast.Node) bool {
+	switch parent := parent.(type) {
+	case *regexp_ast.CharacterClass:
+		return parent.UnicodeSets
+	case *regexp_ast.CharacterClassRange:
+		return inClassSet(parent.Parent)
+	case *regexp_ast.ClassIntersection, *regexp_ast.ClassSubtraction, *regexp_ast.StringAlternative:
+		return true
+	default:
+		return false
+	}
+}
+
+// classSetCharacter prints a character inside a class with the v flag. Every
+// syntax character and punctuator is escaped, so that none of them can start
+// a nested class, a range or a set operation.
+func (p *printer) classSetCharacter(cp int) {
+	switch {
+	case unicode_consts.IsClassSetSyntaxCharacter(cp) ||
+		unicode_consts.IsClassSetReservedPunctuator(cp) ||
+		unicode_consts.IsClassSetReservedDoublePunctuatorCharacter(cp):
+		p.sb.WriteByte('\\')
+		p.sb.WriteRune(rune(cp))
+	case cp == unicode_consts.Backspace:
+		p.sb.WriteString(`\b`)
+	default:
+		p.escapable(cp)
+	}
+}
+
+// escapable prints cp literally if it is printable and as an escape sequence
+// otherwise.
+func (p *printer) escapable(cp int) {
+	switch cp {
+	case unicode_consts.CharacterTabulation:
+		p.sb.WriteString(`\t`)
+	case unicode_consts.LineFeed:
+		p.sb.WriteString(`\n`)
+	case unicode_consts.LineTabulation:
+		p.sb.WriteString(`\v`)
+	case unicode_consts.FormFeed:
+		p.sb.WriteString(`\f`)
+	case unicode_consts.CarriageReturn:
+		p.sb.WriteString(`\r`)
+	default:
+		if isSurrogate(cp) || !unicode.IsPrint(rune(cp)) || (p.opts.ASCIIOnly && cp > unicode.MaxASCII) {
+			p.sb.WriteString(escapeCodePoint(cp, p.u))
+		} else {
+			p.sb.WriteRune(rune(cp))
+		}
+	}
+}
+
+// escapeCodePoint returns the hex escape sequence of cp. `\x00` is used
+// rather than `\0` so that a following digit can't extend it.
+func escapeCodePoint(cp int, u bool) string {
+	switch {
+	case cp <= 0xff:
+		return fmt.Sprintf(`\x%02X`, cp)
+	case u && (cp > 0xffff || isSurrogate(cp)):
+		// A `\uXXXX` lead surrogate followed by a `\uXXXX` trail surrogate
+		// would be read as one code point in unicode mode.
+		return fmt.Sprintf(`\u{%X}`, cp)
+	case cp > 0xffff:
+		lead, trail := utf16.EncodeRune(rune(cp))
+		return fmt.Sprintf(`\u%04X\u%04X`, lead, trail)
+	default:
+		return fmt.Sprintf(`\u%04X`, cp)
+	}
+}
+
+func isSurrogate(cp int) bool {
+	return cp >= unicode_consts.MinLeadSurrogate && cp <= unicode_consts.MaxTrailSurrogate
+}
@@ -0,0 +1,202 @@
+package printer_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/ast_dump"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func TestPrint(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputU     bool
+		wantOutput string
+	}{
+		{name: "量指定子を短い形で出力する", inputS: "a{0,}b{1,}?c{0,1}d{2,2}e{2,}f{2,3}?", inputU: true, wantOutput: "a*b+?c?d{2}e{2,}f{2,3}?"},
+		{name: "エスケープを実際の文字として出力する", inputS: `\x41B\u{43}`, inputU: true, wantOutput: "ABC"},
+		{name: "構文文字をエスケープする", inputS: `\^\$\\\.\*\+\?\(\)\[\]\{\}\|\/`, inputU: true, wantOutput: `\^\$\\\.\*\+\?\(\)\[\]\{\}\|\/`},
+		{name: "制御文字をエスケープする", inputS: `\0\t\n\v\f\r\cA`, inputU: false, wantOutput: `\x00\t\n\v\f\r\x01`},
+		{name: "文字クラスの中の構文文字はエスケープしない", inputS: `[.*+?(){}|$]`, inputU: true, wantOutput: `[.*+?(){}|$]`},
+		{name: "文字クラスの先頭の ^ をエスケープする", inputS: `[\^a^]`, inputU: true, wantOutput: `[\^a^]`},
+		{name: "文字クラスの途中の - をエスケープする", inputS: `[-a\-b-]`, inputU: true, wantOutput: `[-a\-b-]`},
+		{name: "文字クラスの中のバックスペース", inputS: `[\b]`, inputU: true, wantOutput: `[\b]`},
+		{name: "ユニコードモードで、孤立したサロゲートを波括弧でエスケープする", inputS: `\ud83d\u{de00}`, inputU: true, wantOutput: `\u{D83D}\u{DE00}`},
+		{name: "非ユニコードモードで、サロゲートを \\u でエスケープする", inputS: `😀`, inputU: false, wantOutput: `\uD83D\uDE00`},
+		{name: "ユニコードモードで、サロゲートペアを1文字として出力する", inputS: `😀`, inputU: true, wantOutput: `😀`},
+		{name: "グループとアサーションを出力する", inputS: `^(?:a|(?<n>b)|(c))(?=d)(?!e)(?<=f)(?<!g)\b\B$`, inputU: true, wantOutput: `^(?:a|(?<n>b)|(c))(?=d)(?!e)(?<=f)(?<!g)\b\B$`},
+		{name: "後方参照の後の数字をエスケープする", inputS: `(a)\1\x30\k<n>0(?<n>)`, inputU: false, wantOutput: `(a)\1\x30\k<n>0(?<n>)`},
+		{name: "エスケープ文字集合とプロパティを出力する", inputS: `\d\D\s\S\w\W\p{General_Category=L}\P{gc=Lu}\p{Script=Hira}\p{ASCII}`, inputU: true, wantOutput: `\d\D\s\S\w\W\p{L}\P{gc=Lu}\p{Script=Hira}\p{ASCII}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.NewParser(tt.inputS, tt.inputU)
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := printer.Print(pattern, tt.inputU)
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+			assertRoundTrip(t, pattern, tt.inputU)
+		})
+	}
+}
+
+func TestPrintRoundTrip(t *testing.T) {
+	var values []int
+	for cp := 0; cp < 0x100; cp++ {
+		values = append(values, cp)
+	}
+	values = append(values, 0x2028, 0x2029, 0xfeff, 0xd83d, 0xde00, 0xdbff, 0xdc00, 0xffff)
+
+	for _, u := range []bool{false, true} {
+		if u {
+			values = append(values, 0x1f600, 0xe0001, 0x10ffff)
+		}
+
+		// すべての文字を文字クラスの外に並べる
+		pattern := newPattern()
+		alt := pattern.Alternatives[0]
+		for _, v := range values {
+			appendElement(alt, &regexp_ast.Character{Value: v})
+		}
+		assertRoundTrip(t, pattern, u)
+
+		// それぞれの文字を文字クラスの中の様々な位置に置く
+		for _, v := range values {
+			for _, negate := range []bool{false, true} {
+				for _, neighbors := range [][]int{{}, {'a'}, {'-'}, {'^'}, {'a', '-'}, {'-', 'a'}} {
+					pattern := newPattern()
+					cc := &regexp_ast.CharacterClass{Negate: negate}
+					appendElement(pattern.Alternatives[0], cc)
+					for _, n := range append([]int{v}, neighbors...) {
+						appendClassElement(cc, &regexp_ast.Character{Value: n})
+					}
+					for _, n := range append(append([]int{}, neighbors...), v) {
+						appendClassElement(cc, &regexp_ast.Character{Value: n})
+					}
+					r := &regexp_ast.CharacterClassRange{
+						Min: &regexp_ast.Character{Value: v},
+						Max: &regexp_ast.Character{Value: v},
+					}
+					r.Min.Parent = r
+					r.Max.Parent = r
+					appendClassElement(cc, r)
+					assertRoundTrip(t, pattern, u)
+				}
+			}
+		}
+	}
+}
+
+func TestPrintQuantifierRoundTrip(t *testing.T) {
+	for _, minmax := range [][2]int{{0, math.MaxInt}, {1, math.MaxInt}, {0, 1}, {0, 0}, {1, 1}, {3, math.MaxInt}, {2, 5}} {
+		for _, greedy := range []bool{false, true} {
+			pattern := newPattern()
+			c := &regexp_ast.Character{Value: '0'}
+			q := &regexp_ast.Quantifier{Min: minmax[0], Max: minmax[1], Greety: greedy, Element: c}
+			c.Parent = q
+			appendElement(pattern.Alternatives[0], q)
+			// 量指定子の後の数字が量指定子の一部として読まれないこと
+			appendElement(pattern.Alternatives[0], &regexp_ast.Character{Value: '1'})
+			assertRoundTrip(t, pattern, true)
+		}
+	}
+}
+
+func newPattern() *regexp_ast.Pattern {
+	pattern := &regexp_ast.Pattern{}
+	pattern.Alternatives = []*regexp_ast.Alternative{{Parent: pattern}}
+	return pattern
+}
+
+func appendElement(alt *regexp_ast.Alternative, el regexp_ast.Element) {
+	el.(regexp_ast.Node).SetParent(alt)
+	alt.Elements = append(alt.Elements, el)
+}
+
+func appendClassElement(cc *regexp_ast.CharacterClass, el regexp_ast.CharacterClassElement) {
+	el.(regexp_ast.Node).SetParent(cc)
+	cc.Elements = append(cc.Elements, el)
+}
+
+func assertRoundTrip(t *testing.T, pattern *regexp_ast.Pattern, u bool) {
+	t.Helper()
+	assertRoundTripWithOptions(t, pattern, u, parser.Options{})
+}
+
+func assertRoundTripWithOptions(t *testing.T, pattern *regexp_ast.Pattern, u bool, opts parser.Options) {
+	t.Helper()
+	source := printer.Print(pattern, u)
+	p := parser.NewParserWithOptions(source, u, opts)
+	reparsed, err := p.ParsePattern()
+	if err != nil {
+		t.Errorf("Failed to parse %q (u=%t): %s", source, u, err)
+		return
+	}
+	want := ast_dump.Sprint(pattern, ast_dump.SExpr|ast_dump.NoOffsets)
+	got := ast_dump.Sprint(reparsed, ast_dump.SExpr|ast_dump.NoOffsets)
+	if want != got {
+		t.Errorf("Round trip of %q (u=%t) changed the AST\nexpected: %s\nactual:   %s", source, u, want, got)
+	}
+}
+
+func TestPrintUnicodeSets(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		wantOutput string
+	}{
+		{name: "集合演算と文字列の選言を出力する", inputS: `[[a-z]&&[^aeiou]&&\q{b|}][\q{abc}x-z]`, wantOutput: `[[a-z]&&[^aeiou]&&\q{b|}][\q{abc}x-z]`},
+		{name: "構文文字と区切り文字をエスケープする", inputS: `[\(\)\[\]\{\}\/\-\\\|\&\!\^\$\.\b]`, wantOutput: `[\(\)\[\]\{\}\/\-\\\|\&\!\^\$\.\b]`},
+		{name: "エスケープした文字を実際の文字として出力する", inputS: `[\x41--\q{\u{42}}--\x43]`, wantOutput: `[A--\q{B}--C]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := parser.Options{UnicodeSets: true}
+			p := parser.NewParserWithOptions(tt.inputS, true, opts)
+			pattern, err := p.ParsePattern()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output := printer.Print(pattern, true); output != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, output)
+			}
+			assertRoundTripWithOptions(t, pattern, true, opts)
+		})
+	}
+}
+
+func TestPrintParserFixtures(t *testing.T) {
+	inputs, err := filepath.Glob("../parser/fixtures/*/input.txt")
+	if err != nil || len(inputs) == 0 {
+		t.Fatal("Failed to find parser fixtures")
+	}
+	for _, input := range inputs {
+		bytes, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var opts parser.Options
+		if flags, err := os.ReadFile(filepath.Join(filepath.Dir(input), "flags.txt")); err == nil {
+			opts.UnicodeSets = strings.Contains(string(flags), "v")
+		}
+		p := parser.NewParserWithOptions(string(bytes), true, opts)
+		pattern, err := p.ParsePattern()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertRoundTripWithOptions(t, pattern, true, opts)
+	}
+}
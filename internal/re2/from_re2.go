@@ -0,0 +1,269 @@
+package re2
+
+import (
+	"fmt"
+	"math"
+	"regexp/syntax"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/builder"
+	"github.com/sosukesuzuki/regexpp-go/internal/case_folding"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// FromRE2 parses expr in the syntax of Go's regexp package and returns an
+// ECMAScript pattern and flags that match the same strings.
+//
+// The flags always include `u`, since Go matches code points. regexp/syntax
+// already expands `\pN`, `\d` and POSIX classes like `[[:alpha:]]` into
+// ranges, and `.` into a class, so they come out as classes. Case-insensitive
+// parts become the `i` flag when the whole expression is case-insensitive,
+// and classes of case variants otherwise.
+//
+// Captures keep their indices and names, and nested alternations, quantified
+// sequences and nested quantifiers become groups. `\A` and `\z` become `^`
+// and `$`, which match the same without `m`. `^` and `$` of `(?m)` only see
+// `\n` as a line terminator, so they become lookarounds like `(?:^|(?<=\n))`
+// rather than the `m` flag. `\b` of Go has no ſ and Kelvin sign, so an
+// expression with word boundaries doesn't get the `i` flag.
+func FromRE2(expr string) (*regexp_ast.Pattern, regexp_ast.Flags, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, regexp_ast.Flags{}, err
+	}
+	c := &fromRE2{b: builder.New(true)}
+	c.ignoreCase = ignoresCase(re)
+	alts := c.alternatives(re)
+	if c.err != nil {
+		return nil, regexp_ast.Flags{}, c.err
+	}
+	flags := regexp_ast.Flags{Unicode: true, IgnoreCase: c.ignoreCase}
+	return c.b.Pattern(alts...), flags, nil
+}
+
+type fromRE2 struct {
+	b          *builder.Builder
+	ignoreCase bool
+	err        error
+}
+
+func (c *fromRE2) fail(re *syntax.Regexp, format string, args ...any) {
+	if c.err == nil {
+		c.err = fmt.Errorf("re2: %s: %s", re, fmt.Sprintf(format, args...))
+	}
+}
+
+func (c *fromRE2) alternatives(re *syntax.Regexp) []*regexp_ast.Alternative {
+	if re.Op != syntax.OpAlternate {
+		return []*regexp_ast.Alternative{c.alternative(re)}
+	}
+	var alts []*regexp_ast.Alternative
+	for _, sub := range re.Sub {
+		alts = append(alts, c.alternative(sub))
+	}
+	return alts
+}
+
+func (c *fromRE2) alternative(re *syntax.Regexp) *regexp_ast.Alternative {
+	var elements []regexp_ast.Element
+	var concat func(re *syntax.Regexp)
+	concat = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpConcat:
+			for _, sub := range re.Sub {
+				concat(sub)
+			}
+		case syntax.OpEmptyMatch:
+		case syntax.OpLiteral:
+			for _, r := range re.Rune {
+				elements = append(elements, c.literal(r, re.Flags&syntax.FoldCase != 0).(regexp_ast.Element))
+			}
+		default:
+			if el := c.element(re); el != nil {
+				elements = append(elements, el)
+			}
+		}
+	}
+	concat(re)
+	return c.b.Alt(elements...)
+}
+
+// element returns the element matching re, which is a group if re matches a
+// sequence.
+func (c *fromRE2) element(re *syntax.Regexp) regexp_ast.Element {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if len(re.Rune) == 1 {
+			return c.literal(re.Rune[0], re.Flags&syntax.FoldCase != 0).(regexp_ast.Element)
+		}
+		return c.b.Group(c.alternatives(re)...)
+	case syntax.OpCharClass:
+		return c.class(pairs(re.Rune))
+	case syntax.OpAnyCharNotNL:
+		return c.b.NegatedClass(c.b.Char('\n'))
+	case syntax.OpAnyChar:
+		return c.b.NegatedClass()
+	case syntax.OpNoMatch:
+		return c.b.Class()
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		return c.repeat(re)
+	case syntax.OpCapture:
+		if re.Name == "" {
+			return c.b.Capture(c.alternatives(re.Sub[0])...)
+		}
+		if !isGroupName(re.Name) {
+			c.fail(re, "%q is not an ECMAScript group name", re.Name)
+			return nil
+		}
+		return c.b.NamedCapture(re.Name, c.alternatives(re.Sub[0])...)
+	case syntax.OpBeginText:
+		return c.b.Start()
+	case syntax.OpEndText:
+		return c.b.End()
+	case syntax.OpBeginLine:
+		newline := c.b.Lookbehind(false, c.b.Alt(c.b.Char('\n')))
+		return c.b.Group(c.b.Alt(c.b.Start()), c.b.Alt(newline))
+	case syntax.OpEndLine:
+		newline := c.b.Lookahead(false, c.b.Alt(c.b.Char('\n')))
+		return c.b.Group(c.b.Alt(c.b.End()), c.b.Alt(newline))
+	case syntax.OpWordBoundary:
+		return c.b.WordBoundary(false)
+	case syntax.OpNoWordBoundary:
+		return c.b.WordBoundary(true)
+	case syntax.OpConcat, syntax.OpAlternate, syntax.OpEmptyMatch:
+		return c.b.Group(c.alternatives(re)...)
+	default:
+		c.fail(re, "unsupported operator %v", re.Op)
+	}
+	return nil
+}
+
+// isGroupName returns whether name, which Go accepts, is a GroupName of
+// ECMAScript, that is, it doesn't start with a digit.
+func isGroupName(name string) bool {
+	return unicode_consts.IsIdentifierStartChar(int(name[0]))
+}
+
+func (c *fromRE2) repeat(re *syntax.Regexp) regexp_ast.Element {
+	min, max := re.Min, re.Max
+	switch re.Op {
+	case syntax.OpStar:
+		min, max = 0, -1
+	case syntax.OpPlus:
+		min, max = 1, -1
+	case syntax.OpQuest:
+		min, max = 0, 1
+	}
+	if max == -1 {
+		max = math.MaxInt
+	}
+	sub := c.element(re.Sub[0])
+	if sub == nil {
+		return nil
+	}
+	el, ok := sub.(regexp_ast.QuantifiableElement)
+	if !ok {
+		// A quantifier or an assertion
+		el = c.b.Group(c.b.Alt(sub))
+	}
+	q := c.b.Repeat(el, min, max)
+	if re.Flags&syntax.NonGreedy != 0 {
+		c.b.Lazy(q)
+	}
+	return q
+}
+
+// literal returns r, or a class of its case variants if it is matched
+// case-insensitively in a part of the expression.
+func (c *fromRE2) literal(r rune, foldCase bool) regexp_ast.QuantifiableElement {
+	if foldCase && !c.ignoreCase {
+		if variants := case_folding.Variants(int(r), true); len(variants) > 1 {
+			var elements []regexp_ast.CharacterClassElement
+			for _, v := range variants {
+				elements = append(elements, c.b.Char(rune(v)))
+			}
+			return c.b.Class(elements...)
+		}
+	}
+	if foldCase {
+		// regexp/syntax keeps the smallest variant, e.g. `A` for `(?i)a`
+		return c.b.Char(rune(case_folding.Canonicalize(int(r), true)))
+	}
+	return c.b.Char(r)
+}
+
+// class returns a class matching set, negated if that is shorter.
+func (c *fromRE2) class(set []interval) *regexp_ast.CharacterClass {
+	negate := false
+	if comp := complement(set); len(comp) < len(set) {
+		negate = true
+		set = comp
+	}
+	var elements []regexp_ast.CharacterClassElement
+	for _, iv := range set {
+		if iv.min == iv.max {
+			elements = append(elements, c.b.Char(rune(iv.min)))
+		} else {
+			elements = append(elements, c.b.Range(rune(iv.min), rune(iv.max)))
+		}
+	}
+	if negate {
+		return c.b.NegatedClass(elements...)
+	}
+	return c.b.Class(elements...)
+}
+
+func pairs(runes []rune) []interval {
+	set := make([]interval, 0, len(runes)/2)
+	for i := 0; i+1 < len(runes); i += 2 {
+		set = append(set, interval{int(runes[i]), int(runes[i+1])})
+	}
+	return set
+}
+
+// ignoresCase returns whether re matches case-insensitively as a whole, so
+// that it can be translated with the `i` flag: every literal has
+// syntax.FoldCase, at least one literal has a case variant, every class
+// already contains the case variants of its characters, and there is no
+// word boundary, which `i` would change.
+func ignoresCase(re *syntax.Regexp) bool {
+	folds, ok := false, true
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpLiteral:
+			if re.Flags&syntax.FoldCase == 0 {
+				ok = false
+			}
+			for _, r := range re.Rune {
+				if len(case_folding.Variants(int(r), true)) > 1 {
+					folds = true
+				}
+			}
+		case syntax.OpCharClass:
+			set := normalize(pairs(re.Rune))
+			if !sameSet(unicodeClosure(set), set) {
+				ok = false
+			}
+		case syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+			ok = false
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	return folds && ok
+}
+
+func sameSet(a []interval, b []interval) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package re2_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/re2"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func TestFromRE2(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantFlags  string
+	}{
+		{name: "文字と量指定子", input: `ab+c{2,3}?d*`, wantOutput: `ab+c{2,3}?d*`, wantFlags: "u"},
+		{name: "選択", input: `foo|bar`, wantOutput: `foo|bar`, wantFlags: "u"},
+		{name: "1文字の選択は文字クラスになる", input: `a|b|c`, wantOutput: `[a-c]`, wantFlags: "u"},
+		{name: "ドットは改行以外の文字クラスになる", input: `a.(?s:.)`, wantOutput: `a[^\n][^]`, wantFlags: "u"},
+		{name: "POSIX 文字クラス", input: `[[:digit:][:upper:]]`, wantOutput: `[0-9A-Z]`, wantFlags: "u"},
+		{name: "\\pN の短縮形", input: `\pN`, wantOutput: "", wantFlags: "u"},
+		{name: "否定の方が短い文字クラス", input: `[^a-z]`, wantOutput: `[^a-z]`, wantFlags: "u"},
+		{name: "式全体の (?i) は i フラグになる", input: `(?i)ab`, wantOutput: `ab`, wantFlags: "iu"},
+		{name: "一部の (?i) は大文字小文字の文字クラスになる", input: `a(?i:k)`, wantOutput: `a[KkK]`, wantFlags: "u"},
+		{name: "アストラルの文字", input: `😀+`, wantOutput: `😀+`, wantFlags: "u"},
+		{name: "キャプチャ", input: `(a)(?P<name>b|c)`, wantOutput: `(a)(?<name>[b-c])`, wantFlags: "u"},
+		{name: "テキストの先頭と末尾", input: `\Aa\z|^b$`, wantOutput: `^a$|^b$`, wantFlags: "u"},
+		{name: "単語境界", input: `a\b\B`, wantOutput: `a\b\B`, wantFlags: "u"},
+		{name: "単語境界があれば i フラグにしない", input: `(?i)a\b`, wantOutput: `[Aa]\b`, wantFlags: "u"},
+		{name: "入れ子の選択はグループになる", input: `a(?:bc|d)`, wantOutput: `a(?:bc|d)`, wantFlags: "u"},
+		{name: "連続の量指定子はグループになる", input: `(?:ab)*`, wantOutput: `(?:ab)*`, wantFlags: "u"},
+		{name: "入れ子の量指定子はグループになる", input: `(?:a+){2}`, wantOutput: `(?:a+){2}`, wantFlags: "u"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags, err := re2.FromRE2(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flags.String() != tt.wantFlags {
+				t.Errorf("Unexpected flags, expected %s, actual %s", tt.wantFlags, flags)
+			}
+			if err := regexp_ast.Verify(pattern); err != nil {
+				t.Errorf("Invalid tree: %v", err)
+			}
+			o := printer.Print(pattern, true)
+			if tt.wantOutput != "" && o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+
+			// Translating back gives an expression that matches the same
+			// strings as the original
+			back, err := re2.Transpile(pattern, flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := regexp.MustCompile(`^(?:` + tt.input + `)$`)
+			got := regexp.MustCompile(`^(?:` + back + `)$`)
+			for _, s := range []string{"", "a", "ab", "AB", "aK", "ak", "aK", "abbcc", "abccd", "foo", "bar", "b", "\n", "a\nx", "ax\n", "5", "Q", "١", "z", "😀😀", "abc", "ad", "abab", "aaa", "a b"} {
+				if want.MatchString(s) != got.MatchString(s) {
+					t.Errorf("%q matches differently, %s and %s", s, tt.input, back)
+				}
+			}
+		})
+	}
+}
+
+// `^` and `$` of `(?m)` become lookarounds, which Transpile can't translate
+// back.
+func TestFromRE2Multiline(t *testing.T) {
+	pattern, flags, err := re2.FromRE2(`(?m)^a$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := regexp_ast.Verify(pattern); err != nil {
+		t.Errorf("Invalid tree: %v", err)
+	}
+	want := `(?:^|(?<=\n))a(?:$|(?=\n))`
+	if o := printer.Print(pattern, flags.Unicode); o != want {
+		t.Errorf("Unexpected output, expected %s, actual %s", want, o)
+	}
+}
+
+func TestFromRE2Error(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "数字で始まるグループ名", input: `(?P<1a>x)`, wantErr: `re2: (?P<1a>x): "1a" is not an ECMAScript group name`},
+		{name: "入れ子の量指定子", input: `a**`, wantErr: ""},
+		{name: "構文エラー", input: `(`, wantErr: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, _, err := re2.FromRE2(tt.input)
+			if err == nil {
+				t.Fatalf("Expected an error, actual %s", printer.Print(pattern, true))
+			}
+			if tt.wantErr != "" && err.Error() != tt.wantErr {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,611 @@
+// Package re2 translates ECMAScript patterns into the RE2 syntax of Go's
+// regexp package.
+//
+// The translation keeps the ECMAScript semantics where they differ from Go's:
+//
+//   - `.` doesn't match line terminators (\n, \r, U+2028 and U+2029) unless
+//     the `s` flag is set,
+//   - with `i` and `u`, characters match by simple case folding, like `(?i)`
+//     does in Go; with `i` but not `u`, they match by the legacy uppercase
+//     rules instead, so every character is expanded into its case variants,
+//   - `[]` matches nothing and `[^]` matches any character,
+//   - a surrogate pair written as two `\uXXXX` escapes without `u` matches
+//     one code point,
+//   - `\s` matches the ECMAScript white space and line terminators, and a
+//     property is written as `\p{…}` if Go knows it by a name,
+//     or as a class of the characters of Go's unicode tables otherwise,
+//   - a class with `v` matches its strings as alternatives, longest first.
+//
+// Go matches UTF-8 text by code points, so lone surrogates can't be matched
+// at all and are reported. Without `u`, ECMAScript matches `.` and negated
+// classes against UTF-16 code units; the translation matches them against
+// whole code points, which only differs for characters outside the BMP.
+//
+// With `m`, `^` and `$` of Go only see `\n` as a line terminator, not `\r`,
+// U+2028 or U+2029. `\b` of Go doesn't see ſ and the Kelvin sign as word
+// characters, which `\b` with `i` and `u` does. Lookarounds, backreferences,
+// properties that Go has no table of and group names that aren't ASCII are
+// reported, since RE2 has none of them.
+//
+// The flags g, d and y concern the RegExp API rather than the pattern and are
+// ignored.
+package re2
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/case_folding"
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/source_map"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// The largest repeat count that Go's regexp/syntax accepts
+const maxRepeat = 1000
+
+// Transpile returns the RE2 syntax of pattern under flags. If a part of
+// pattern can't be translated, the error is a diagnostic.List with one
+// Diagnostic per unsupported node, and the returned string is empty.
+func Transpile(pattern *regexp_ast.Pattern, flags regexp_ast.Flags) (string, error) {
+	return transpile(pattern, flags, nil)
+}
+
+// TranspileWithMap is like Transpile, and also returns the Loc of the node
+// behind each part of the output, e.g. to report an error that regexp
+// reports in the output at the original pattern.
+func TranspileWithMap(pattern *regexp_ast.Pattern, flags regexp_ast.Flags) (string, *source_map.Map, error) {
+	sm := &source_map.Map{}
+	s, err := transpile(pattern, flags, sm)
+	if err != nil {
+		return "", nil, err
+	}
+	return s, sm, nil
+}
+
+func transpile(pattern *regexp_ast.Pattern, flags regexp_ast.Flags, sm *source_map.Map) (string, error) {
+	t := &transpiler{flags: flags, sm: sm}
+	if t.foldCase() {
+		t.sb.WriteString("(?i)")
+	}
+	t.pattern(pattern)
+	if err := t.diags.Err(); err != nil {
+		return "", err
+	}
+	return t.sb.String(), nil
+}
+
+type transpiler struct {
+	flags regexp_ast.Flags
+	sb    strings.Builder
+	diags diagnostic.List
+	// The map of the output, or nil
+	sm *source_map.Map
+}
+
+// record maps the output written since start to loc.
+func (t *transpiler) record(loc regexp_ast.Loc, start int) {
+	t.sm.Add(start, t.sb.Len(), loc)
+}
+
+func (t *transpiler) unicode() bool {
+	return t.flags.Unicode || t.flags.UnicodeSets
+}
+
+// foldCase returns whether `(?i)` has the semantics of the `i` flag.
+func (t *transpiler) foldCase() bool {
+	return t.flags.IgnoreCase && t.unicode()
+}
+
+// legacyCase returns whether characters have to be expanded into the
+// variants that the `i` flag without `u` matches.
+func (t *transpiler) legacyCase() bool {
+	return t.flags.IgnoreCase && !t.unicode()
+}
+
+func (t *transpiler) pattern(pattern *regexp_ast.Pattern) {
+	defer t.record(pattern.Loc, t.sb.Len())
+	t.alternatives(pattern.Alternatives)
+}
+
+func (t *transpiler) alternatives(alts []*regexp_ast.Alternative) {
+	for i, alt := range alts {
+		if i > 0 {
+			t.sb.WriteByte('|')
+		}
+		t.alternative(alt)
+	}
+}
+
+func (t *transpiler) alternative(alt *regexp_ast.Alternative) {
+	defer t.record(alt.Loc, t.sb.Len())
+	for i := 0; i < len(alt.Elements); i++ {
+		switch lead, trail := regexp_ast.SurrogatePairAt(alt.Elements, i); {
+		case trail != nil:
+			start := t.sb.Len()
+			t.character(lead, unicode_consts.CombineSurrogatePair(lead.Value, trail.Value))
+			t.record(regexp_ast.Loc{Start: lead.Loc.Start, End: trail.Loc.End}, start)
+			i++
+		case lead != nil:
+			// Reported by quantifier
+		default:
+			t.element(alt.Elements[i])
+		}
+	}
+}
+
+func (t *transpiler) element(el regexp_ast.Element) {
+	defer t.record(el.(regexp_ast.Node).GetLoc(), t.sb.Len())
+	switch n := el.(type) {
+	case *regexp_ast.Character:
+		t.character(n, n.Value)
+	case *regexp_ast.CharacterClass:
+		if n.UnicodeSets {
+			t.unicodeSetsClass(n)
+		} else {
+			t.characterClass(n)
+		}
+	case *regexp_ast.ExpressionCharacterClass:
+		t.unicodeSetsClass(n)
+	case *regexp_ast.AnyCharacterSet:
+		if t.flags.DotAll {
+			t.sb.WriteString(`(?s:.)`)
+		} else {
+			t.sb.WriteString(`[^\n\r\x{2028}\x{2029}]`)
+		}
+	case *regexp_ast.EscapeCharacterSet:
+		t.escape(n)
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		t.property(n)
+	case *regexp_ast.Quantifier:
+		t.quantifier(n)
+	case *regexp_ast.Group:
+		t.sb.WriteString("(?:")
+		t.alternatives(n.Alternatives)
+		t.sb.WriteByte(')')
+	case *regexp_ast.CapturingGroup:
+		t.capturingGroup(n)
+	case *regexp_ast.EdgeAssertion:
+		t.edge(n)
+	case *regexp_ast.WordBoundaryAssertion:
+		if n.Negate {
+			t.sb.WriteString(`\B`)
+		} else {
+			t.sb.WriteString(`\b`)
+		}
+	case *regexp_ast.LookaroundAssertion:
+		t.diags.Add(n, "RE2 doesn't support lookarounds")
+	case *regexp_ast.Backreference:
+		t.diags.Add(n, "RE2 doesn't support backreferences")
+	default:
+		t.diags.Add(el.(regexp_ast.Node), "%T is not supported by RE2", el)
+	}
+}
+
+func (t *transpiler) capturingGroup(g *regexp_ast.CapturingGroup) {
+	if g.Name == "" {
+		t.sb.WriteByte('(')
+	} else if checkGroupName(g, &t.diags) {
+		fmt.Fprintf(&t.sb, "(?P<%s>", g.Name)
+	}
+	t.alternatives(g.Alternatives)
+	t.sb.WriteByte(')')
+}
+
+// checkGroupName reports g and returns false if RE2 doesn't accept its name.
+func checkGroupName(g *regexp_ast.CapturingGroup, diags *diagnostic.List) bool {
+	for _, r := range g.Name {
+		if r != '_' && (r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			diags.Add(g, "RE2 group names are limited to ASCII letters, digits and _")
+			return false
+		}
+	}
+	return true
+}
+
+func (t *transpiler) edge(a *regexp_ast.EdgeAssertion) {
+	switch {
+	case a.Kind == regexp_ast.EdgeStart && t.flags.Multiline:
+		t.sb.WriteString(`(?m:^)`)
+	case a.Kind == regexp_ast.EdgeStart:
+		t.sb.WriteByte('^')
+	case t.flags.Multiline:
+		t.sb.WriteString(`(?m:$)`)
+	default:
+		// `$` of Go is `\z` without `m`
+		t.sb.WriteByte('$')
+	}
+}
+
+func (t *transpiler) escape(e *regexp_ast.EscapeCharacterSet) {
+	if e.Kind == regexp_ast.EscapeSpace {
+		// `\s` of Go is ASCII only
+		t.set(e)
+		return
+	}
+	// `\d` and `\w` of Go are ASCII like the ECMAScript ones, and `(?i)\w`
+	// has ſ and the Kelvin sign like `\w` with `i` and `u`
+	letter := map[regexp_ast.EscapeKind]rune{regexp_ast.EscapeDigit: 'd', regexp_ast.EscapeWord: 'w'}[e.Kind]
+	if e.Negate {
+		letter = unicode.ToUpper(letter)
+	}
+	t.sb.WriteByte('\\')
+	t.sb.WriteRune(letter)
+}
+
+// The names of the tables of Go's unicode package in `\p{…}` of RE2
+var propertyNames = func() map[*unicode.RangeTable]string {
+	names := map[*unicode.RangeTable]string{}
+	for _, m := range []map[string]*unicode.RangeTable{unicode.Categories, unicode.Scripts} {
+		for name, table := range m {
+			names[table] = name
+		}
+	}
+	return names
+}()
+
+func (t *transpiler) property(p *regexp_ast.UnicodePropertyCharacterSet) {
+	tables, ok := unicode_consts.PropertyTables(p.Key, p.Value)
+	// `(?i)\P{…}` of Go is the complement of the case variants, while with
+	// `i` ECMAScript matches the case variants of the complement
+	if ok && len(tables) == 1 && propertyNames[tables[0]] != "" && !(p.Negate && t.foldCase()) {
+		if p.Negate {
+			t.sb.WriteString(`\P{`)
+		} else {
+			t.sb.WriteString(`\p{`)
+		}
+		t.sb.WriteString(propertyNames[tables[0]])
+		t.sb.WriteByte('}')
+		return
+	}
+	t.set(p)
+}
+
+// set writes a class of the characters of node.
+func (t *transpiler) set(node regexp_ast.Node) {
+	s, ok := charset.OfNode(node, t.setFlags())
+	if !ok {
+		t.diags.Add(node, "Go has no table of the property")
+		return
+	}
+	t.class(false, intervalsOfSet(s))
+}
+
+// setFlags returns the flags to get the characters of an escape or a
+// property by charset. They are code points even without `u`, like all the
+// characters that the translation matches, and the legacy case variants are
+// added by the callers.
+func (t *transpiler) setFlags() regexp_ast.Flags {
+	return regexp_ast.Flags{Unicode: true, IgnoreCase: t.foldCase()}
+}
+
+// unicodeSetsClass writes a class with `v`, whose strings are alternatives
+// before its characters.
+func (t *transpiler) unicodeSetsClass(node regexp_ast.Node) {
+	strs, chars, ok := unicodeSets(node, t.flags, &t.diags)
+	if !ok {
+		return
+	}
+	if len(strs) == 0 {
+		t.class(false, chars)
+		return
+	}
+	t.sb.WriteString("(?:")
+	n := 0
+	next := func() {
+		if n > 0 {
+			t.sb.WriteByte('|')
+		}
+		n++
+	}
+	for _, s := range strs {
+		if s != "" {
+			next()
+			for _, r := range s {
+				t.character(node, int(r))
+			}
+		}
+	}
+	if len(chars) > 0 {
+		next()
+		t.class(false, chars)
+	}
+	if strs[len(strs)-1] == "" {
+		next()
+	}
+	t.sb.WriteByte(')')
+}
+
+// unicodeSets returns the strings of a class with `v`, longest first since
+// the class matches the longest one that it can, and its characters. It
+// reports the class and returns false if RE2 can't match it.
+func unicodeSets(node regexp_ast.Node, flags regexp_ast.Flags, diags *diagnostic.List) ([]string, []interval, bool) {
+	c, ok := charset.ClassSetOf(node, flags)
+	if !ok {
+		diags.Add(node, "Go has no table of a property of the class")
+		return nil, nil, false
+	}
+	ok = true
+	regexp_ast.Inspect(node, func(n regexp_ast.Node) bool {
+		if s, isString := n.(*regexp_ast.StringAlternative); isString {
+			for _, el := range s.Elements {
+				if isSurrogate(el.Value) {
+					diags.Add(el, "a lone surrogate can't be matched in UTF-8")
+					ok = false
+				}
+			}
+		}
+		return true
+	})
+	chars := intervalsOfSet(c.Chars)
+	if len(chars) == 0 && !c.Chars.IsEmpty() {
+		diags.Add(node, "a class of surrogates alone can't be matched in UTF-8")
+		ok = false
+	}
+	strs := c.Strings()
+	sort.SliceStable(strs, func(i, j int) bool {
+		return utf8.RuneCountInString(strs[i]) > utf8.RuneCountInString(strs[j])
+	})
+	return strs, chars, ok
+}
+
+func (t *transpiler) quantifier(q *regexp_ast.Quantifier) {
+	if !checkQuantifier(q, &t.diags) {
+		return
+	}
+	t.element(q.Element.(regexp_ast.Element))
+	switch {
+	case q.Min == 0 && q.Max == math.MaxInt:
+		t.sb.WriteByte('*')
+	case q.Min == 1 && q.Max == math.MaxInt:
+		t.sb.WriteByte('+')
+	case q.Min == 0 && q.Max == 1:
+		t.sb.WriteByte('?')
+	case q.Max == math.MaxInt:
+		fmt.Fprintf(&t.sb, "{%d,}", q.Min)
+	case q.Min == q.Max:
+		fmt.Fprintf(&t.sb, "{%d}", q.Min)
+	default:
+		fmt.Fprintf(&t.sb, "{%d,%d}", q.Min, q.Max)
+	}
+	if !q.Greety {
+		t.sb.WriteByte('?')
+	}
+}
+
+// checkQuantifier reports q and returns false if RE2 can't express it.
+func checkQuantifier(q *regexp_ast.Quantifier, diags *diagnostic.List) bool {
+	if c, ok := q.Element.(*regexp_ast.Character); ok && unicode_consts.IsTrailSurrogate(c.Value) {
+		if prev := previousElement(q); prev != nil && unicode_consts.IsLeadSurrogate(prev.Value) {
+			diags.Add(q, "a quantifier on the second half of a surrogate pair can't be expressed in UTF-8")
+			return false
+		}
+	}
+	if q.Min > maxRepeat || (q.Max != math.MaxInt && q.Max > maxRepeat) {
+		diags.Add(q, "RE2 doesn't support repeat counts above %d", maxRepeat)
+		return false
+	}
+	return true
+}
+
+// previousElement returns the Character right before q in its alternative.
+func previousElement(q *regexp_ast.Quantifier) *regexp_ast.Character {
+	alt, ok := q.Parent.(*regexp_ast.Alternative)
+	if !ok {
+		return nil
+	}
+	for i, el := range alt.Elements {
+		if el == regexp_ast.Element(q) && i > 0 {
+			c, _ := alt.Elements[i-1].(*regexp_ast.Character)
+			return c
+		}
+	}
+	return nil
+}
+
+func (t *transpiler) character(node regexp_ast.Node, cp int) {
+	if isSurrogate(cp) {
+		t.diags.Add(node, "a lone surrogate can't be matched in UTF-8")
+		return
+	}
+	if t.legacyCase() {
+		if variants := case_folding.Variants(cp, false); len(variants) > 1 {
+			t.class(false, intervalsOf(variants))
+			return
+		}
+	}
+	if strings.ContainsRune(`\.+*?()|[]{}^$`, rune(cp)) {
+		t.sb.WriteByte('\\')
+		t.sb.WriteRune(rune(cp))
+		return
+	}
+	t.literal(cp)
+}
+
+func (t *transpiler) characterClass(cc *regexp_ast.CharacterClass) {
+	set := classSet(cc, t.setFlags(), &t.diags)
+	if t.legacyCase() {
+		set = legacyClosure(set)
+	}
+	t.class(cc.Negate, set)
+}
+
+// classSet returns the characters of the elements of cc, ignoring Negate,
+// and reports the elements that RE2 can't match. Escapes and properties are
+// evaluated by charset under flags. Surrogates are left out of the set, since
+// UTF-8 text has none; that only fails a class that isn't negated and has an
+// element of surrogates alone.
+func classSet(cc *regexp_ast.CharacterClass, flags regexp_ast.Flags, diags *diagnostic.List) []interval {
+	var set []interval
+	for _, el := range cc.Elements {
+		switch n := el.(type) {
+		case *regexp_ast.Character:
+			if isSurrogate(n.Value) {
+				if !cc.Negate {
+					diags.Add(n, "a lone surrogate can't be matched in UTF-8")
+				}
+				continue
+			}
+			set = append(set, interval{n.Value, n.Value})
+		case *regexp_ast.CharacterClassRange:
+			if isSurrogate(n.Min.Value) && isSurrogate(n.Max.Value) {
+				if !cc.Negate {
+					diags.Add(n, "a range of surrogates can't be matched in UTF-8")
+				}
+				continue
+			}
+			set = append(set, withoutSurrogates(interval{n.Min.Value, n.Max.Value})...)
+		case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+			s, ok := charset.OfNode(n.(regexp_ast.Node), flags)
+			if !ok {
+				diags.Add(n.(regexp_ast.Node), "Go has no table of the property")
+				continue
+			}
+			set = append(set, intervalsOfSet(s)...)
+		default:
+			diags.Add(el.(regexp_ast.Node), "%T is not supported by RE2", el)
+		}
+	}
+	return set
+}
+
+// class writes a class matching set, or its complement if negate.
+func (t *transpiler) class(negate bool, set []interval) {
+	if len(set) == 0 {
+		// Go doesn't accept `[]` and `[^]`
+		if negate {
+			t.sb.WriteString(`[\x00-\x{10FFFF}]`)
+		} else {
+			t.sb.WriteString(`[^\x00-\x{10FFFF}]`)
+		}
+		return
+	}
+	t.sb.WriteByte('[')
+	if negate {
+		t.sb.WriteByte('^')
+	}
+	for _, iv := range set {
+		t.classCharacter(iv.min)
+		if iv.max == iv.min+1 {
+			t.classCharacter(iv.max)
+		} else if iv.max != iv.min {
+			t.sb.WriteByte('-')
+			t.classCharacter(iv.max)
+		}
+	}
+	t.sb.WriteByte(']')
+}
+
+func (t *transpiler) classCharacter(cp int) {
+	if strings.ContainsRune(`\]-[^`, rune(cp)) {
+		t.sb.WriteByte('\\')
+		t.sb.WriteRune(rune(cp))
+		return
+	}
+	t.literal(cp)
+}
+
+func (t *transpiler) literal(cp int) {
+	switch cp {
+	case '\t':
+		t.sb.WriteString(`\t`)
+	case '\n':
+		t.sb.WriteString(`\n`)
+	case '\f':
+		t.sb.WriteString(`\f`)
+	case '\r':
+		t.sb.WriteString(`\r`)
+	default:
+		if unicode.IsPrint(rune(cp)) {
+			t.sb.WriteRune(rune(cp))
+		} else {
+			fmt.Fprintf(&t.sb, `\x{%X}`, cp)
+		}
+	}
+}
+
+type interval struct {
+	min int
+	max int
+}
+
+// intervalsOfSet returns the intervals of s without surrogates.
+func intervalsOfSet(s charset.Set) []interval {
+	var set []interval
+	for _, r := range s.Ranges() {
+		set = append(set, withoutSurrogates(interval{r.Min, r.Max})...)
+	}
+	return set
+}
+
+func intervalsOf(cps []int) []interval {
+	set := make([]interval, len(cps))
+	for i, cp := range cps {
+		set[i] = interval{cp, cp}
+	}
+	return normalize(set)
+}
+
+// legacyClosure adds the case variants of every character in set under the
+// `i` flag without `u`.
+func legacyClosure(set []interval) []interval {
+	closure := set
+	for _, iv := range set {
+		for cp := iv.min; cp <= iv.max && cp <= 0xffff; cp++ {
+			for _, v := range case_folding.Variants(cp, false) {
+				if v != cp {
+					closure = append(closure, interval{v, v})
+				}
+			}
+		}
+	}
+	return normalize(closure)
+}
+
+// normalize sorts set and merges overlapping and adjacent intervals.
+func normalize(set []interval) []interval {
+	sort.Slice(set, func(i, j int) bool {
+		return set[i].min < set[j].min
+	})
+	merged := []interval{}
+	for _, iv := range set {
+		if last := len(merged) - 1; last >= 0 && iv.min <= merged[last].max+1 {
+			if iv.max > merged[last].max {
+				merged[last].max = iv.max
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// withoutSurrogates returns the parts of iv below and above the surrogates.
+func withoutSurrogates(iv interval) []interval {
+	var set []interval
+	if iv.min < unicode_consts.MinLeadSurrogate {
+		max := iv.max
+		if max >= unicode_consts.MinLeadSurrogate {
+			max = unicode_consts.MinLeadSurrogate - 1
+		}
+		set = append(set, interval{iv.min, max})
+	}
+	if iv.max > unicode_consts.MaxTrailSurrogate {
+		min := iv.min
+		if min <= unicode_consts.MaxTrailSurrogate {
+			min = unicode_consts.MaxTrailSurrogate + 1
+		}
+		set = append(set, interval{min, iv.max})
+	}
+	return set
+}
+
+func isSurrogate(cp int) bool {
+	return cp >= unicode_consts.MinLeadSurrogate && cp <= unicode_consts.MaxTrailSurrogate
+}
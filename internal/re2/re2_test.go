@@ -0,0 +1,370 @@
+package re2_test
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/re2"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func parse(t *testing.T, source string, flags string) (*regexp_ast.Pattern, regexp_ast.Flags) {
+	t.Helper()
+	f, err := parser.ParseFlags(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewParserWithOptions(source, f.Unicode || f.UnicodeSets, parser.Options{UnicodeSets: f.UnicodeSets})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pattern, f
+}
+
+func TestTranspile(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputFlags string
+		wantOutput string
+		// Strings that the ECMAScript pattern matches entirely, and ones
+		// it doesn't
+		wantMatch    []string
+		wantNotMatch []string
+	}{
+		{
+			name:         "文字と量指定子",
+			inputS:       `a+b{2,3}?c*|d`,
+			inputFlags:   "u",
+			wantOutput:   `a+b{2,3}?c*|d`,
+			wantMatch:    []string{"abb", "aabbbccc", "d"},
+			wantNotMatch: []string{"ab", "bb"},
+		},
+		{
+			name:         "メタ文字をエスケープする",
+			inputS:       `\$\^\.\(\)\{\}`,
+			inputFlags:   "u",
+			wantOutput:   `\$\^\.\(\)\{\}`,
+			wantMatch:    []string{"$^.(){}"},
+			wantNotMatch: []string{"$^x(){}"},
+		},
+		{
+			name:         "ドットは行終端文字にマッチしない",
+			inputS:       `a.b`,
+			inputFlags:   "u",
+			wantOutput:   `a[^\n\r\x{2028}\x{2029}]b`,
+			wantMatch:    []string{"axb", "a😀b"},
+			wantNotMatch: []string{"a\nb", "a\rb", "a b"},
+		},
+		{
+			name:         "s フラグではドットが行終端文字にマッチする",
+			inputS:       `a.b`,
+			inputFlags:   "su",
+			wantOutput:   `a(?s:.)b`,
+			wantMatch:    []string{"a\nb", "a b"},
+			wantNotMatch: []string{"ab"},
+		},
+		{
+			name:         "文字クラスの特殊文字をエスケープする",
+			inputS:       `[\]\-^\\][^a-c]`,
+			inputFlags:   "u",
+			wantOutput:   `[\]\-\^\\][^a-c]`,
+			wantMatch:    []string{"]x", "-d", "^z", "\\e"},
+			wantNotMatch: []string{"]a", "xd"},
+		},
+		{
+			name:         "空の文字クラスと否定の空の文字クラス",
+			inputS:       `[^]|[]`,
+			inputFlags:   "",
+			wantOutput:   `[\x00-\x{10FFFF}]|[^\x00-\x{10FFFF}]`,
+			wantMatch:    []string{"a", "\n"},
+			wantNotMatch: []string{""},
+		},
+		{
+			name:         "u フラグでは i を単純な大文字小文字の畳み込みにする",
+			inputS:       `k[a-c]`,
+			inputFlags:   "iu",
+			wantOutput:   `(?i)k[a-c]`,
+			wantMatch:    []string{"KB", "Kb"},
+			wantNotMatch: []string{"kd"},
+		},
+		{
+			name:         "u フラグなしの i は大文字への変換で展開する",
+			inputS:       `k[a-c]σ`,
+			inputFlags:   "i",
+			wantOutput:   `[Kk][A-Ca-c][Σςσ]`,
+			wantMatch:    []string{"kBσ", "Kcς", "kAΣ"},
+			wantNotMatch: []string{"kb", "kdσ"},
+		},
+		{
+			name:         "u フラグなしの i では ſ が s にマッチしない",
+			inputS:       `s`,
+			inputFlags:   "i",
+			wantOutput:   `[Ss]`,
+			wantMatch:    []string{"S"},
+			wantNotMatch: []string{"ſ"},
+		},
+		{
+			name:         "u フラグなしのサロゲートペアを1つのコードポイントにする",
+			inputS:       `\ud83d\ude00x`,
+			inputFlags:   "",
+			wantOutput:   `😀x`,
+			wantMatch:    []string{"😀x"},
+			wantNotMatch: []string{"x"},
+		},
+		{
+			name:         "サロゲートを跨ぐ範囲はサロゲートを除く",
+			inputS:       `[\0-\u{10FFFF}]`,
+			inputFlags:   "u",
+			wantOutput:   `[\x{0}-\x{D7FF}\x{E000}-\x{10FFFF}]`,
+			wantMatch:    []string{"a", "\uffff", "😀"},
+			wantNotMatch: []string{"", "ab"},
+		},
+		{
+			name:         "否定の文字クラスのサロゲートは報告しない",
+			inputS:       `[^\u0080-\uFFFF][^\ud800]`,
+			inputFlags:   "",
+			wantOutput:   `[^\x{80}-\x{D7FF}\x{E000}-\x{FFFF}][\x00-\x{10FFFF}]`,
+			wantMatch:    []string{"ab", "a😀"},
+			wantNotMatch: []string{"éa"},
+		},
+		{
+			name:         "数字と単語文字のエスケープはそのまま",
+			inputS:       `\d\w\D\W`,
+			inputFlags:   "u",
+			wantOutput:   `\d\w\D\W`,
+			wantMatch:    []string{"1ax-"},
+			wantNotMatch: []string{"1a1-", "1éx-"},
+		},
+		{
+			name:         "u と i の \\w は ſ とケルビン記号にマッチする",
+			inputS:       `\w`,
+			inputFlags:   "iu",
+			wantOutput:   `(?i)\w`,
+			wantMatch:    []string{"ſ", "K"},
+			wantNotMatch: []string{"é"},
+		},
+		{
+			name:         "空白文字は ECMAScript の空白文字と行終端文字",
+			inputS:       `\s\S`,
+			inputFlags:   "",
+			wantOutput:   `[\t-\r \x{A0}\x{1680}\x{2000}-\x{200A}\x{2028}\x{2029}\x{202F}\x{205F}\x{3000}\x{FEFF}][\x{0}-\x{8}\x{E}-\x{1F}!-\x{9F}¡-ᙿᚁ-\x{1FFF}\x{200B}-‧\x{202A}-\x{202E}‰-⁞\x{2060}-⿿、-\x{D7FF}\x{E000}-\x{FEFE}\x{FF00}-\x{10FFFF}]`,
+			wantMatch:    []string{"\u00a0a", "\ufeff😀"},
+			wantNotMatch: []string{"a ", "\u3000\u3000"},
+		},
+		{
+			name:         "プロパティ",
+			inputS:       `\p{Lu}\P{sc=Hira}\p{AHex}`,
+			inputFlags:   "u",
+			wantOutput:   `\p{Lu}\P{Hiragana}[0-9A-Fa-f]`,
+			wantMatch:    []string{"Aaf"},
+			wantNotMatch: []string{"aaf", "Aあf", "Aag"},
+		},
+		{
+			name:         "u と i の否定のプロパティは補集合の大文字と小文字",
+			inputS:       `\P{Ll}`,
+			inputFlags:   "iu",
+			wantMatch:    []string{"a", "A"},
+			wantNotMatch: []string{"ĸ"},
+		},
+		{
+			name:         "文字クラスのエスケープとプロパティ",
+			inputS:       `[\da-f][^\W\d]`,
+			inputFlags:   "u",
+			wantOutput:   `[0-9a-f][^\x{0}-/:-@\[-\^` + "`" + `{-\x{D7FF}\x{E000}-\x{10FFFF}0-9]`,
+			wantMatch:    []string{"fa", "0_"},
+			wantNotMatch: []string{"gA", "00", "0-"},
+		},
+		{
+			name:         "グループとアサーション",
+			inputS:       `^(?:ab)+(c)(?<n>d)\b\B$`,
+			inputFlags:   "u",
+			wantOutput:   `^(?:ab)+(c)(?P<n>d)\b\B$`,
+			wantNotMatch: []string{"abcd"},
+		},
+		{
+			name:         "m フラグの行頭と行末",
+			inputS:       `^a$\n^b$`,
+			inputFlags:   "m",
+			wantOutput:   `(?m:^)a(?m:$)\n(?m:^)b(?m:$)`,
+			wantMatch:    []string{"a\nb"},
+			wantNotMatch: []string{"ab"},
+		},
+		{
+			name:         "v フラグの文字列は長い順の選択",
+			inputS:       `[\q{abc|d|}x]`,
+			inputFlags:   "v",
+			wantOutput:   `(?:abc|[dx]|)`,
+			wantMatch:    []string{"abc", "d", "x", ""},
+			wantNotMatch: []string{"ab"},
+		},
+		{
+			name:         "v フラグの集合演算",
+			inputS:       `[\w--[a-z_]][[a-z]&&[^aeiou]&&[a-d]]`,
+			inputFlags:   "v",
+			wantOutput:   `[0-9A-Z][b-d]`,
+			wantMatch:    []string{"Zb"},
+			wantNotMatch: []string{"za", "Za"},
+		},
+		{
+			name:         "v と i の文字列",
+			inputS:       `[\q{AB}c]`,
+			inputFlags:   "iv",
+			wantOutput:   `(?i)(?:ab|[Cc])`,
+			wantMatch:    []string{"aB", "C"},
+			wantNotMatch: []string{"a"},
+		},
+		{
+			name:         "制御文字と表示できない文字",
+			inputS:       `\t\0​`,
+			inputFlags:   "u",
+			wantOutput:   `\t\x{0}\x{200B}`,
+			wantMatch:    []string{"\t\x00​"},
+			wantNotMatch: []string{"\t0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			o, err := re2.Transpile(pattern, flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantOutput != "" && o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+			re, err := regexp.Compile(`^(?:` + o + `)$`)
+			if err != nil {
+				t.Fatalf("Go rejected the output: %v", err)
+			}
+			for _, s := range tt.wantMatch {
+				if !re.MatchString(s) {
+					t.Errorf("Expected %q to match", s)
+				}
+			}
+			for _, s := range tt.wantNotMatch {
+				if re.MatchString(s) {
+					t.Errorf("Expected %q not to match", s)
+				}
+			}
+		})
+	}
+}
+
+func TestTranspileError(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputFlags string
+		wantLocs   []regexp_ast.Loc
+	}{
+		{
+			name:       "孤立したサロゲート",
+			inputS:     `a\ud800b[\udc00]`,
+			inputFlags: "",
+			wantLocs:   []regexp_ast.Loc{{Start: 1, End: 7}, {Start: 9, End: 15}},
+		},
+		{
+			name:       "サロゲートの範囲",
+			inputS:     `[\ud800-\udfff]`,
+			inputFlags: "u",
+			wantLocs:   []regexp_ast.Loc{{Start: 1, End: 14}},
+		},
+		{
+			name:       "サロゲートだけの範囲",
+			inputS:     `[a\ud800-\udbff][\udc00-\ue000]`,
+			inputFlags: "",
+			wantLocs:   []regexp_ast.Loc{{Start: 2, End: 15}},
+		},
+		{
+			name:       "サロゲートペアの後半への量指定子",
+			inputS:     `\ud83d\ude00+`,
+			inputFlags: "",
+			wantLocs:   []regexp_ast.Loc{{Start: 6, End: 13}},
+		},
+		{
+			name:       "先読みと後読みと後方参照",
+			inputS:     `(?=a)(?<!b)(c)\1`,
+			inputFlags: "",
+			wantLocs:   []regexp_ast.Loc{{Start: 0, End: 5}, {Start: 5, End: 11}, {Start: 14, End: 16}},
+		},
+		{
+			name:       "ASCII 以外のグループ名",
+			inputS:     `(?<π>a)(?<x>b)`,
+			inputFlags: "",
+			wantLocs:   []regexp_ast.Loc{{Start: 0, End: 7}},
+		},
+		{
+			name:       "Go に表のないプロパティ",
+			inputS:     `\p{Emoji}[\p{Emoji}][\p{RGI_Emoji}--\q{x}]`,
+			inputFlags: "v",
+			wantLocs:   []regexp_ast.Loc{{Start: 0, End: 9}, {Start: 9, End: 20}, {Start: 20, End: 42}},
+		},
+		{
+			name:       "1000 を超える繰り返し回数",
+			inputS:     `a{1001}b{2,5000}c{1000,}`,
+			inputFlags: "u",
+			wantLocs:   []regexp_ast.Loc{{Start: 0, End: 7}, {Start: 7, End: 16}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			o, err := re2.Transpile(pattern, flags)
+			if o != "" {
+				t.Errorf("Expected no output, actual %s", o)
+			}
+			var diags diagnostic.List
+			if !errors.As(err, &diags) {
+				t.Fatalf("Expected a diagnostic.List, actual %v", err)
+			}
+			if len(diags) != len(tt.wantLocs) {
+				t.Fatalf("Unexpected number of diagnostics, expected %d, actual %d: %v", len(tt.wantLocs), len(diags), diags)
+			}
+			for i, d := range diags {
+				if d.Loc.Start != tt.wantLocs[i].Start || d.Loc.End != tt.wantLocs[i].End {
+					t.Errorf("Unexpected Loc, expected %v, actual %v", tt.wantLocs[i], d.Loc)
+				}
+			}
+			var d *diagnostic.Diagnostic
+			if !errors.As(err, &d) {
+				t.Errorf("Expected errors.As to find a Diagnostic")
+			}
+		})
+	}
+}
+
+func TestTranspileWithMap(t *testing.T) {
+	pattern, flags := parse(t, `a.+|\ud83d\ude00`, "")
+	o, sm, err := re2.TranspileWithMap(pattern, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		inputAt string
+		wantLoc regexp_ast.Loc
+	}{
+		{name: "文字", inputAt: "a", wantLoc: regexp_ast.Loc{Start: 0, End: 1}},
+		{name: "ドット", inputAt: `[^\n`, wantLoc: regexp_ast.Loc{Start: 1, End: 2}},
+		{name: "量指定子", inputAt: "+", wantLoc: regexp_ast.Loc{Start: 1, End: 3}},
+		{name: "選択", inputAt: "|", wantLoc: regexp_ast.Loc{Start: 0, End: 16}},
+		{name: "サロゲートペア", inputAt: "😀", wantLoc: regexp_ast.Loc{Start: 4, End: 16}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, ok := sm.Lookup(strings.Index(o, tt.inputAt))
+			if !ok || loc.Start != tt.wantLoc.Start || loc.End != tt.wantLoc.End {
+				t.Errorf("Unexpected Loc of %q in %s, expected %v, actual %v", tt.inputAt, o, tt.wantLoc, loc)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package re2
+
+import (
+	"math"
+	"regexp/syntax"
+	"sort"
+	"sync"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/case_folding"
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// ToSyntax builds the regexp/syntax tree of pattern under flags directly,
+// with the same semantics and diagnostics as Transpile. The tree is
+// simplified, so it can be compiled with syntax.Compile without printing and
+// parsing it again.
+//
+// Classes are built with their case variants already added, since
+// regexp/syntax only applies syntax.FoldCase to literals. Capturing groups
+// are captures with the same indices and names as in the pattern, so
+// CapNames of the tree, and SubexpNames of a regexp of its String, list
+// them like the groups of the ECMAScript match.
+func ToSyntax(pattern *regexp_ast.Pattern, flags regexp_ast.Flags) (*syntax.Regexp, error) {
+	b := &syntaxBuilder{transpiler: transpiler{flags: flags}}
+	re := b.pattern(pattern)
+	if err := b.diags.Err(); err != nil {
+		return nil, err
+	}
+	return re.Simplify(), nil
+}
+
+type syntaxBuilder struct {
+	transpiler
+	// The number of capturing groups so far
+	captures int
+}
+
+func (b *syntaxBuilder) pattern(pattern *regexp_ast.Pattern) *syntax.Regexp {
+	return b.alternatives(pattern.Alternatives)
+}
+
+func (b *syntaxBuilder) alternatives(alts []*regexp_ast.Alternative) *syntax.Regexp {
+	if len(alts) == 1 {
+		return b.alternative(alts[0])
+	}
+	re := &syntax.Regexp{Op: syntax.OpAlternate}
+	for _, alt := range alts {
+		re.Sub = append(re.Sub, b.alternative(alt))
+	}
+	return re
+}
+
+func (b *syntaxBuilder) alternative(alt *regexp_ast.Alternative) *syntax.Regexp {
+	var subs []*syntax.Regexp
+	for i := 0; i < len(alt.Elements); i++ {
+		switch lead, trail := regexp_ast.SurrogatePairAt(alt.Elements, i); {
+		case trail != nil:
+			subs = append(subs, b.character(lead, unicode_consts.CombineSurrogatePair(lead.Value, trail.Value)))
+			i++
+		case lead != nil:
+			// Reported by quantifier
+		default:
+			if re := b.element(alt.Elements[i]); re != nil {
+				subs = append(subs, re)
+			}
+		}
+	}
+	switch len(subs) {
+	case 0:
+		return &syntax.Regexp{Op: syntax.OpEmptyMatch}
+	case 1:
+		return subs[0]
+	default:
+		return &syntax.Regexp{Op: syntax.OpConcat, Sub: subs}
+	}
+}
+
+// element returns the tree of el, or nil if el was reported.
+func (b *syntaxBuilder) element(el regexp_ast.Element) *syntax.Regexp {
+	switch n := el.(type) {
+	case *regexp_ast.Character:
+		return b.character(n, n.Value)
+	case *regexp_ast.CharacterClass:
+		if n.UnicodeSets {
+			return b.unicodeSetsClass(n)
+		}
+		set := classSet(n, b.setFlags(), &b.diags)
+		return b.class(n.Negate, set)
+	case *regexp_ast.ExpressionCharacterClass:
+		return b.unicodeSetsClass(n)
+	case *regexp_ast.AnyCharacterSet:
+		if b.flags.DotAll {
+			return &syntax.Regexp{Op: syntax.OpAnyChar}
+		}
+		lineTerminators := []interval{
+			{unicode_consts.LineFeed, unicode_consts.LineFeed},
+			{unicode_consts.CarriageReturn, unicode_consts.CarriageReturn},
+			{unicode_consts.LineSeparator, unicode_consts.ParagraphSeparator},
+		}
+		return charClass(complement(lineTerminators))
+	case *regexp_ast.EscapeCharacterSet, *regexp_ast.UnicodePropertyCharacterSet:
+		s, ok := charset.OfNode(n.(regexp_ast.Node), b.setFlags())
+		if !ok {
+			b.diags.Add(n.(regexp_ast.Node), "Go has no table of the property")
+			return nil
+		}
+		return b.class(false, intervalsOfSet(s))
+	case *regexp_ast.Quantifier:
+		return b.quantifier(n)
+	case *regexp_ast.Group:
+		return b.alternatives(n.Alternatives)
+	case *regexp_ast.CapturingGroup:
+		b.captures++
+		re := &syntax.Regexp{Op: syntax.OpCapture, Cap: b.captures}
+		if n.Name != "" && checkGroupName(n, &b.diags) {
+			re.Name = n.Name
+		}
+		re.Sub = []*syntax.Regexp{b.alternatives(n.Alternatives)}
+		return re
+	case *regexp_ast.EdgeAssertion:
+		switch {
+		case n.Kind == regexp_ast.EdgeStart && b.flags.Multiline:
+			return &syntax.Regexp{Op: syntax.OpBeginLine}
+		case n.Kind == regexp_ast.EdgeStart:
+			return &syntax.Regexp{Op: syntax.OpBeginText}
+		case b.flags.Multiline:
+			return &syntax.Regexp{Op: syntax.OpEndLine}
+		default:
+			return &syntax.Regexp{Op: syntax.OpEndText}
+		}
+	case *regexp_ast.WordBoundaryAssertion:
+		if n.Negate {
+			return &syntax.Regexp{Op: syntax.OpNoWordBoundary}
+		}
+		return &syntax.Regexp{Op: syntax.OpWordBoundary}
+	case *regexp_ast.LookaroundAssertion:
+		b.diags.Add(n, "RE2 doesn't support lookarounds")
+		return nil
+	case *regexp_ast.Backreference:
+		b.diags.Add(n, "RE2 doesn't support backreferences")
+		return nil
+	default:
+		b.diags.Add(el.(regexp_ast.Node), "%T is not supported by RE2", el)
+		return nil
+	}
+}
+
+// unicodeSetsClass returns the tree of a class with `v`, whose strings are
+// alternatives before its characters.
+func (b *syntaxBuilder) unicodeSetsClass(node regexp_ast.Node) *syntax.Regexp {
+	strs, chars, ok := unicodeSets(node, b.flags, &b.diags)
+	if !ok {
+		return nil
+	}
+	re := &syntax.Regexp{Op: syntax.OpAlternate}
+	for _, s := range strs {
+		if s != "" {
+			lit := &syntax.Regexp{Op: syntax.OpLiteral, Rune: []rune(s)}
+			if b.foldCase() {
+				lit.Flags |= syntax.FoldCase
+			}
+			re.Sub = append(re.Sub, lit)
+		}
+	}
+	if len(chars) > 0 || len(strs) == 0 {
+		re.Sub = append(re.Sub, charClass(chars))
+	}
+	if len(strs) > 0 && strs[len(strs)-1] == "" {
+		re.Sub = append(re.Sub, &syntax.Regexp{Op: syntax.OpEmptyMatch})
+	}
+	if len(re.Sub) == 1 {
+		return re.Sub[0]
+	}
+	return re
+}
+
+func (b *syntaxBuilder) quantifier(q *regexp_ast.Quantifier) *syntax.Regexp {
+	if !checkQuantifier(q, &b.diags) {
+		return nil
+	}
+	sub := b.element(q.Element.(regexp_ast.Element))
+	if sub == nil {
+		return nil
+	}
+	re := &syntax.Regexp{Sub: []*syntax.Regexp{sub}, Min: q.Min, Max: q.Max}
+	if q.Max == math.MaxInt {
+		re.Max = -1
+	}
+	switch {
+	case q.Min == 0 && q.Max == math.MaxInt:
+		re.Op = syntax.OpStar
+	case q.Min == 1 && q.Max == math.MaxInt:
+		re.Op = syntax.OpPlus
+	case q.Min == 0 && q.Max == 1:
+		re.Op = syntax.OpQuest
+	default:
+		re.Op = syntax.OpRepeat
+	}
+	if !q.Greety {
+		re.Flags |= syntax.NonGreedy
+	}
+	return re
+}
+
+func (b *syntaxBuilder) character(node regexp_ast.Node, cp int) *syntax.Regexp {
+	if isSurrogate(cp) {
+		b.diags.Add(node, "a lone surrogate can't be matched in UTF-8")
+		return nil
+	}
+	if b.legacyCase() {
+		if variants := case_folding.Variants(cp, false); len(variants) > 1 {
+			return charClass(intervalsOf(variants))
+		}
+	}
+	re := &syntax.Regexp{Op: syntax.OpLiteral, Rune: []rune{rune(cp)}}
+	if b.foldCase() {
+		re.Flags |= syntax.FoldCase
+	}
+	return re
+}
+
+func (b *syntaxBuilder) class(negate bool, set []interval) *syntax.Regexp {
+	switch {
+	case b.legacyCase():
+		set = legacyClosure(set)
+	case b.foldCase():
+		set = unicodeClosure(set)
+	default:
+		set = normalize(set)
+	}
+	if negate {
+		set = complement(set)
+	}
+	return charClass(set)
+}
+
+func charClass(set []interval) *syntax.Regexp {
+	if len(set) == 0 {
+		return &syntax.Regexp{Op: syntax.OpNoMatch}
+	}
+	re := &syntax.Regexp{Op: syntax.OpCharClass}
+	for _, iv := range set {
+		re.Rune = append(re.Rune, rune(iv.min), rune(iv.max))
+	}
+	return re
+}
+
+// unicodeClosure adds the case variants of every character in set under the
+// `i` flag with `u`. Only the cased characters in set are looked at, so that
+// a large class like `[^a]` doesn't take a lookup per code point.
+func unicodeClosure(set []interval) []interval {
+	closure := set
+	cased := casedCharacters()
+	for _, iv := range set {
+		for i := sort.SearchInts(cased, iv.min); i < len(cased) && cased[i] <= iv.max; i++ {
+			for _, v := range case_folding.Variants(cased[i], true) {
+				if v != cased[i] {
+					closure = append(closure, interval{v, v})
+				}
+			}
+		}
+	}
+	return normalize(closure)
+}
+
+var (
+	casedOnce sync.Once
+	cased     []int
+)
+
+// casedCharacters returns the code points with case variants under the `i`
+// flag with `u`, in ascending order.
+func casedCharacters() []int {
+	casedOnce.Do(func() {
+		for cp := 0; cp <= unicode_consts.MaxCodePoint; cp++ {
+			if len(case_folding.Variants(cp, true)) > 1 {
+				cased = append(cased, cp)
+			}
+		}
+	})
+	return cased
+}
+
+// complement returns the code points that are not in the normalized set.
+func complement(set []interval) []interval {
+	c := []interval{}
+	next := 0
+	for _, iv := range set {
+		if iv.min > next {
+			c = append(c, interval{next, iv.min - 1})
+		}
+		next = iv.max + 1
+	}
+	if next <= unicode_consts.MaxCodePoint {
+		c = append(c, interval{next, unicode_consts.MaxCodePoint})
+	}
+	return c
+}
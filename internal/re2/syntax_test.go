@@ -0,0 +1,195 @@
+package re2_test
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/re2"
+)
+
+func TestToSyntax(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputS     string
+		inputFlags string
+		wantOutput string
+	}{
+		{name: "文字と量指定子", inputS: `a+b{2,3}?c*|d|`, inputFlags: "u", wantOutput: `a+bbb??c*|d|(?:)`},
+		{name: "ドット", inputS: `.`, inputFlags: "u", wantOutput: `[^\n\r\x{2028}\x{2029}]`},
+		{name: "s フラグのドット", inputS: `.`, inputFlags: "su", wantOutput: `(?s:.)`},
+		{name: "空の文字クラス", inputS: `[]`, inputFlags: "u", wantOutput: `[^\x00-\x{10FFFF}]`},
+		{name: "否定の文字クラス", inputS: `[^b-da]`, inputFlags: "u", wantOutput: `[^a-d]`},
+		{name: "u フラグの i", inputS: `k[s]`, inputFlags: "iu", wantOutput: `(?i:k[Ssſ])`},
+		{name: "u フラグなしの i", inputS: `k[s]`, inputFlags: "i", wantOutput: `[Kk][Ss]`},
+		{name: "u フラグなしのサロゲートペア", inputS: `😀`, inputFlags: "", wantOutput: `😀`},
+		{name: "グループ", inputS: `(a)(?:bc|d)(?<n>e)`, inputFlags: "u", wantOutput: `(a)(?:bc|d)(?P<n>e)`},
+		{name: "アサーション", inputS: `^\b$`, inputFlags: "", wantOutput: `\A\b\z`},
+		{name: "m フラグのアサーション", inputS: `^\B$`, inputFlags: "m", wantOutput: `(?m:^\B$)`},
+		{name: "エスケープ", inputS: `\d\w`, inputFlags: "iu", wantOutput: `[0-9][0-9A-Z_a-zſK]`},
+		{name: "プロパティ", inputS: `\p{AHex}`, inputFlags: "u", wantOutput: `[0-9A-Fa-f]`},
+		{name: "v フラグの文字列", inputS: `[\q{abc|d|}x]`, inputFlags: "v", wantOutput: `abc|[dx]|(?:)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+			re, err := re2.ToSyntax(pattern, flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o := re.String(); o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+			if _, err := syntax.Compile(re); err != nil {
+				t.Errorf("Failed to compile the tree: %v", err)
+			}
+		})
+	}
+}
+
+// The tree matches the same strings as the output of Transpile.
+func TestToSyntaxTranspile(t *testing.T) {
+	sources := []string{`a.b`, `[^a-c]+x`, `k|[a-z]{2}`, `[^k]`, `ſ|σ`, `\t[\0-\x1f]?`, `(a)(?<x>[bk])?\W*`, `^\s|[\S\d]$`}
+	inputs := []string{"", "a", "k", "K", "K", "s", "S", "ſ", "Σ", "ς", "axb", "a\nb", "xx", "dx", "ab", "\t", "\t\x05", "a b"}
+	for _, source := range sources {
+		for _, flags := range []string{"", "u", "i", "iu", "s", "isu"} {
+			pattern, f := parse(t, source, flags)
+			s, err := re2.Transpile(pattern, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree, err := re2.ToSyntax(pattern, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := regexp.MustCompile(`^(?:` + s + `)$`)
+			got := regexp.MustCompile(`^(?:` + tree.String() + `)$`)
+			for _, input := range inputs {
+				if want.MatchString(input) != got.MatchString(input) {
+					t.Errorf("/%s/%s: %q matches differently, %s and %s", source, flags, input, s, tree)
+				}
+			}
+			if names := got.SubexpNames(); !reflect.DeepEqual(names, want.SubexpNames()) || !reflect.DeepEqual(names, tree.CapNames()) {
+				t.Errorf("/%s/%s: Unexpected captures %v", source, flags, names)
+			}
+		}
+	}
+}
+
+// The tree compiles as it is, and the program matches the same strings as
+// the ECMAScript pattern.
+func TestToSyntaxCompile(t *testing.T) {
+	tests := []struct {
+		inputS       string
+		inputFlags   string
+		wantMatch    []string
+		wantNotMatch []string
+	}{
+		{inputS: `a{2,3}`, inputFlags: "u", wantMatch: []string{"aa", "aaa"}, wantNotMatch: []string{"a", "aaaa"}},
+		{inputS: `[a-c]{2,}?x|y{0,2}`, inputFlags: "", wantMatch: []string{"abx", "cccx", "", "yy"}, wantNotMatch: []string{"ax", "yyy"}},
+		{inputS: `k.`, inputFlags: "iu", wantMatch: []string{"Kx", "\u212Ay"}, wantNotMatch: []string{"k\n", "k"}},
+		{inputS: `.+`, inputFlags: "su", wantMatch: []string{"\n\r", "😀"}, wantNotMatch: []string{""}},
+	}
+
+	for _, tt := range tests {
+		pattern, flags := parse(t, tt.inputS, tt.inputFlags)
+		re, err := re2.ToSyntax(pattern, flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog, err := syntax.Compile(re)
+		if err != nil {
+			t.Fatalf("/%s/%s: Failed to compile the tree: %v", tt.inputS, tt.inputFlags, err)
+		}
+		for _, s := range tt.wantMatch {
+			if !fullMatch(prog, s) {
+				t.Errorf("/%s/%s: Expected %q to match", tt.inputS, tt.inputFlags, s)
+			}
+		}
+		for _, s := range tt.wantNotMatch {
+			if fullMatch(prog, s) {
+				t.Errorf("/%s/%s: Expected %q not to match", tt.inputS, tt.inputFlags, s)
+			}
+		}
+	}
+}
+
+// fullMatch runs prog on the whole of s, following every thread at once.
+func fullMatch(prog *syntax.Prog, s string) bool {
+	runes := []rune(s)
+	var add func(set map[uint32]bool, pc uint32, pos int)
+	add = func(set map[uint32]bool, pc uint32, pos int) {
+		if set[pc] {
+			return
+		}
+		set[pc] = true
+		inst := prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			add(set, inst.Out, pos)
+			add(set, inst.Arg, pos)
+		case syntax.InstCapture, syntax.InstNop:
+			add(set, inst.Out, pos)
+		case syntax.InstEmptyWidth:
+			before, after := rune(-1), rune(-1)
+			if pos > 0 {
+				before = runes[pos-1]
+			}
+			if pos < len(runes) {
+				after = runes[pos]
+			}
+			if syntax.EmptyOp(inst.Arg)&^syntax.EmptyOpContext(before, after) == 0 {
+				add(set, inst.Out, pos)
+			}
+		}
+	}
+	threads := map[uint32]bool{}
+	add(threads, uint32(prog.Start), 0)
+	for pos, r := range runes {
+		next := map[uint32]bool{}
+		for pc := range threads {
+			inst := prog.Inst[pc]
+			switch inst.Op {
+			case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+				if inst.MatchRune(r) {
+					add(next, inst.Out, pos+1)
+				}
+			}
+		}
+		threads = next
+	}
+	for pc := range threads {
+		if prog.Inst[pc].Op == syntax.InstMatch {
+			return true
+		}
+	}
+	return false
+}
+
+func TestToSyntaxCaptures(t *testing.T) {
+	pattern, flags := parse(t, `(a)(?<x>b)((?<y>c)|d)`, "u")
+	re, err := re2.ToSyntax(pattern, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"", "", "x", "", "y"}
+	if names := regexp.MustCompile(re.String()).SubexpNames(); !reflect.DeepEqual(names, want) {
+		t.Errorf("Unexpected output, expected %v, actual %v", want, names)
+	}
+}
+
+func TestToSyntaxError(t *testing.T) {
+	pattern, flags := parse(t, `a\ud800b{1001}(?=c)\k<π>(?<π>)`, "")
+	re, err := re2.ToSyntax(pattern, flags)
+	if re != nil {
+		t.Errorf("Expected no tree, actual %s", re)
+	}
+	var diags diagnostic.List
+	if !errors.As(err, &diags) || len(diags) != 5 {
+		t.Fatalf("Expected five diagnostics, actual %v", err)
+	}
+}
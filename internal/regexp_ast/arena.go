@@ -0,0 +1,117 @@
+package regexp_ast
+
+const slabSize = 256
+
+// Arena allocates nodes in slabs, so that the nodes of a batch of patterns
+// are allocated in a few large blocks and released together. After Reset, the
+// memory is reused and every node allocated before must not be used anymore.
+//
+// An Arena is not safe for concurrent use.
+type Arena struct {
+	patterns                     slab[Pattern]
+	alternatives                 slab[Alternative]
+	characters                   slab[Character]
+	characterClasses             slab[CharacterClass]
+	anyCharacterSets             slab[AnyCharacterSet]
+	quantifiers                  slab[Quantifier]
+	characterClassRanges         slab[CharacterClassRange]
+	expressionCharacterClasses   slab[ExpressionCharacterClass]
+	classIntersections           slab[ClassIntersection]
+	classSubtractions            slab[ClassSubtraction]
+	classStringDisjunctions      slab[ClassStringDisjunction]
+	stringAlternatives           slab[StringAlternative]
+	groups                       slab[Group]
+	capturingGroups              slab[CapturingGroup]
+	lookaroundAssertions         slab[LookaroundAssertion]
+	edgeAssertions               slab[EdgeAssertion]
+	wordBoundaryAssertions       slab[WordBoundaryAssertion]
+	backreferences               slab[Backreference]
+	escapeCharacterSets          slab[EscapeCharacterSet]
+	unicodePropertyCharacterSets slab[UnicodePropertyCharacterSet]
+}
+
+func (a *Arena) NewPattern() *Pattern                         { return a.patterns.alloc() }
+func (a *Arena) NewAlternative() *Alternative                 { return a.alternatives.alloc() }
+func (a *Arena) NewCharacter() *Character                     { return a.characters.alloc() }
+func (a *Arena) NewCharacterClass() *CharacterClass           { return a.characterClasses.alloc() }
+func (a *Arena) NewAnyCharacterSet() *AnyCharacterSet         { return a.anyCharacterSets.alloc() }
+func (a *Arena) NewQuantifier() *Quantifier                   { return a.quantifiers.alloc() }
+func (a *Arena) NewCharacterClassRange() *CharacterClassRange { return a.characterClassRanges.alloc() }
+func (a *Arena) NewExpressionCharacterClass() *ExpressionCharacterClass {
+	return a.expressionCharacterClasses.alloc()
+}
+func (a *Arena) NewClassIntersection() *ClassIntersection { return a.classIntersections.alloc() }
+func (a *Arena) NewClassSubtraction() *ClassSubtraction   { return a.classSubtractions.alloc() }
+func (a *Arena) NewClassStringDisjunction() *ClassStringDisjunction {
+	return a.classStringDisjunctions.alloc()
+}
+func (a *Arena) NewStringAlternative() *StringAlternative     { return a.stringAlternatives.alloc() }
+func (a *Arena) NewGroup() *Group                             { return a.groups.alloc() }
+func (a *Arena) NewCapturingGroup() *CapturingGroup           { return a.capturingGroups.alloc() }
+func (a *Arena) NewLookaroundAssertion() *LookaroundAssertion { return a.lookaroundAssertions.alloc() }
+func (a *Arena) NewEdgeAssertion() *EdgeAssertion             { return a.edgeAssertions.alloc() }
+func (a *Arena) NewWordBoundaryAssertion() *WordBoundaryAssertion {
+	return a.wordBoundaryAssertions.alloc()
+}
+func (a *Arena) NewBackreference() *Backreference           { return a.backreferences.alloc() }
+func (a *Arena) NewEscapeCharacterSet() *EscapeCharacterSet { return a.escapeCharacterSets.alloc() }
+func (a *Arena) NewUnicodePropertyCharacterSet() *UnicodePropertyCharacterSet {
+	return a.unicodePropertyCharacterSets.alloc()
+}
+
+// Reset frees every node allocated so far, keeping the slabs for reuse.
+func (a *Arena) Reset() {
+	a.patterns.reset()
+	a.alternatives.reset()
+	a.characters.reset()
+	a.characterClasses.reset()
+	a.anyCharacterSets.reset()
+	a.quantifiers.reset()
+	a.characterClassRanges.reset()
+	a.expressionCharacterClasses.reset()
+	a.classIntersections.reset()
+	a.classSubtractions.reset()
+	a.classStringDisjunctions.reset()
+	a.stringAlternatives.reset()
+	a.groups.reset()
+	a.capturingGroups.reset()
+	a.lookaroundAssertions.reset()
+	a.edgeAssertions.reset()
+	a.wordBoundaryAssertions.reset()
+	a.backreferences.reset()
+	a.escapeCharacterSets.reset()
+	a.unicodePropertyCharacterSets.reset()
+}
+
+type slab[T any] struct {
+	chunks [][]T
+	// The index of the chunk currently allocated from
+	i int
+}
+
+func (s *slab[T]) alloc() *T {
+	var zero T
+	for s.i < len(s.chunks) {
+		c := s.chunks[s.i]
+		if len(c) < cap(c) {
+			c = append(c, zero)
+			s.chunks[s.i] = c
+			return &c[len(c)-1]
+		}
+		s.i++
+	}
+	s.chunks = append(s.chunks, make([]T, 1, slabSize))
+	return &s.chunks[s.i][0]
+}
+
+func (s *slab[T]) reset() {
+	var zero T
+	for i, c := range s.chunks {
+		// Drop the references held by the old nodes
+		for j := range c {
+			c[j] = zero
+		}
+		s.chunks[i] = c[:0]
+	}
+	s.i = 0
+}
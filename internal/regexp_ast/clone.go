@@ -0,0 +1,152 @@
+package regexp_ast
+
+// Clone returns a deep copy of the tree rooted at node. The copy shares
+// nothing with the original; its nodes point to their copied parents, and
+// the root of the copy has no parent. Backreferences and groups are linked to
+// their copies, and a backreference to a group outside the tree is
+// unresolved in the copy.
+func Clone(node Node) Node {
+	cl := &cloner{groups: map[*CapturingGroup]*CapturingGroup{}}
+	copied := cl.clone(node, nil)
+	for _, ref := range cl.backreferences {
+		group, ok := cl.groups[ref.Resolved]
+		if !ok {
+			ref.Resolved = nil
+			continue
+		}
+		ref.Resolved = group
+		group.References = append(group.References, ref)
+	}
+	return copied
+}
+
+type cloner struct {
+	// The copies of the groups by their original
+	groups map[*CapturingGroup]*CapturingGroup
+	// The copied backreferences, still resolved to the original groups
+	backreferences []*Backreference
+}
+
+func (cl *cloner) clone(node Node, parent Node) Node {
+	switch n := node.(type) {
+	case *Pattern:
+		c := &Pattern{Loc: cloneLoc(n.Loc)}
+		c.Alternatives = cl.cloneAlternatives(n.Alternatives, c)
+		return c
+	case *Alternative:
+		c := &Alternative{Parent: parent, Loc: cloneLoc(n.Loc)}
+		c.Elements = make([]Element, len(n.Elements))
+		for i, el := range n.Elements {
+			c.Elements[i] = cl.clone(el.(Node), c).(Element)
+		}
+		return c
+	case *Character:
+		return &Character{Parent: parent, Loc: cloneLoc(n.Loc), Value: n.Value}
+	case *CharacterClass:
+		c := &CharacterClass{Parent: parent, Loc: cloneLoc(n.Loc), Negate: n.Negate, UnicodeSets: n.UnicodeSets}
+		c.Elements = make([]CharacterClassElement, len(n.Elements))
+		for i, el := range n.Elements {
+			c.Elements[i] = cl.clone(el.(Node), c).(CharacterClassElement)
+		}
+		return c
+	case *AnyCharacterSet:
+		return &AnyCharacterSet{Parent: parent, Loc: cloneLoc(n.Loc)}
+	case *Quantifier:
+		c := &Quantifier{Parent: parent, Loc: cloneLoc(n.Loc), Min: n.Min, Max: n.Max, Greety: n.Greety}
+		if n.Element != nil {
+			c.Element = cl.clone(n.Element.(Node), c).(QuantifiableElement)
+		}
+		return c
+	case *CharacterClassRange:
+		c := &CharacterClassRange{Parent: parent, Loc: cloneLoc(n.Loc)}
+		if n.Min != nil {
+			c.Min = cl.clone(n.Min, c).(*Character)
+		}
+		if n.Max != nil {
+			c.Max = cl.clone(n.Max, c).(*Character)
+		}
+		return c
+	case *Group:
+		c := &Group{Parent: parent, Loc: cloneLoc(n.Loc)}
+		c.Alternatives = cl.cloneAlternatives(n.Alternatives, c)
+		return c
+	case *CapturingGroup:
+		c := &CapturingGroup{Parent: parent, Loc: cloneLoc(n.Loc), Name: n.Name}
+		c.Alternatives = cl.cloneAlternatives(n.Alternatives, c)
+		cl.groups[n] = c
+		return c
+	case *LookaroundAssertion:
+		c := &LookaroundAssertion{Parent: parent, Loc: cloneLoc(n.Loc), Kind: n.Kind, Negate: n.Negate}
+		c.Alternatives = cl.cloneAlternatives(n.Alternatives, c)
+		return c
+	case *EdgeAssertion:
+		return &EdgeAssertion{Parent: parent, Loc: cloneLoc(n.Loc), Kind: n.Kind}
+	case *WordBoundaryAssertion:
+		return &WordBoundaryAssertion{Parent: parent, Loc: cloneLoc(n.Loc), Negate: n.Negate}
+	case *Backreference:
+		c := &Backreference{Parent: parent, Loc: cloneLoc(n.Loc), Number: n.Number, Name: n.Name, Resolved: n.Resolved}
+		cl.backreferences = append(cl.backreferences, c)
+		return c
+	case *EscapeCharacterSet:
+		return &EscapeCharacterSet{Parent: parent, Loc: cloneLoc(n.Loc), Kind: n.Kind, Negate: n.Negate}
+	case *UnicodePropertyCharacterSet:
+		return &UnicodePropertyCharacterSet{Parent: parent, Loc: cloneLoc(n.Loc), Key: n.Key, Value: n.Value, Negate: n.Negate, Strings: n.Strings}
+	case *ExpressionCharacterClass:
+		c := &ExpressionCharacterClass{Parent: parent, Loc: cloneLoc(n.Loc), Negate: n.Negate}
+		if n.Expression != nil {
+			c.Expression = cl.clone(n.Expression.(Node), c).(ClassSetExpression)
+		}
+		return c
+	case *ClassIntersection:
+		c := &ClassIntersection{Parent: parent, Loc: cloneLoc(n.Loc)}
+		c.Left, c.Right = cl.cloneOperand(n.Left, c), cl.cloneOperand(n.Right, c)
+		return c
+	case *ClassSubtraction:
+		c := &ClassSubtraction{Parent: parent, Loc: cloneLoc(n.Loc)}
+		c.Left, c.Right = cl.cloneOperand(n.Left, c), cl.cloneOperand(n.Right, c)
+		return c
+	case *ClassStringDisjunction:
+		c := &ClassStringDisjunction{Parent: parent, Loc: cloneLoc(n.Loc)}
+		c.Alternatives = make([]*StringAlternative, len(n.Alternatives))
+		for i, alt := range n.Alternatives {
+			c.Alternatives[i] = cl.clone(alt, c).(*StringAlternative)
+		}
+		return c
+	case *StringAlternative:
+		c := &StringAlternative{Parent: parent, Loc: cloneLoc(n.Loc)}
+		c.Elements = make([]*Character, len(n.Elements))
+		for i, el := range n.Elements {
+			c.Elements[i] = cl.clone(el, c).(*Character)
+		}
+		return c
+	default:
+		return nil
+	}
+}
+
+func (cl *cloner) cloneAlternatives(alts []*Alternative, parent Node) []*Alternative {
+	c := make([]*Alternative, len(alts))
+	for i, alt := range alts {
+		c[i] = cl.clone(alt, parent).(*Alternative)
+	}
+	return c
+}
+
+func (cl *cloner) cloneOperand(operand ClassSetOperand, parent Node) ClassSetOperand {
+	if operand == nil {
+		return nil
+	}
+	return cl.clone(operand.(Node), parent).(ClassSetOperand)
+}
+
+func cloneLoc(loc Loc) Loc {
+	if loc.StartPos != nil {
+		pos := *loc.StartPos
+		loc.StartPos = &pos
+	}
+	if loc.EndPos != nil {
+		pos := *loc.EndPos
+		loc.EndPos = &pos
+	}
+	return loc
+}
@@ -0,0 +1,277 @@
+package regexp_ast
+
+import "fmt"
+
+// The editing helpers below keep Parent fields consistent but leave Locs
+// untouched: after an edit, Locs describe the source the tree was parsed
+// from, not the edited tree. Print the tree, or lay it out again with
+// printer.Layout, to get fresh ones. The links between backreferences and
+// their groups are left untouched as well.
+
+// Replace puts new in the place of old in old's parent. new must be allowed
+// in that place, e.g. a Quantifier can't replace the element of another
+// Quantifier. old is detached from the tree.
+func Replace(old Node, new Node) error {
+	parent := old.GetParent()
+	if parent == nil {
+		return fmt.Errorf("regexp_ast: %T has no parent to be replaced in", old)
+	}
+	switch p := parent.(type) {
+	case *Pattern, *Group, *CapturingGroup, *LookaroundAssertion:
+		alts := alternativesOf(p)
+		alt, ok := new.(*Alternative)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an alternative of %T", new, parent)
+		}
+		i := indexOf(*alts, old)
+		if i < 0 {
+			return errNotChild(old, parent)
+		}
+		(*alts)[i] = alt
+	case *Alternative:
+		el, ok := new.(Element)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an element of Alternative", new)
+		}
+		i := indexOf(p.Elements, old)
+		if i < 0 {
+			return errNotChild(old, parent)
+		}
+		p.Elements[i] = el
+	case *CharacterClass:
+		el, ok := classElement(p, new)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an element of CharacterClass", new)
+		}
+		i := indexOf(p.Elements, old)
+		if i < 0 {
+			return errNotChild(old, parent)
+		}
+		p.Elements[i] = el
+	case *Quantifier:
+		el, ok := new.(QuantifiableElement)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be quantified", new)
+		}
+		if n, ok := p.Element.(Node); !ok || n != old {
+			return errNotChild(old, parent)
+		}
+		p.Element = el
+	case *CharacterClassRange:
+		c, ok := new.(*Character)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an endpoint of CharacterClassRange", new)
+		}
+		switch old {
+		case Node(p.Min):
+			p.Min = c
+		case Node(p.Max):
+			p.Max = c
+		default:
+			return errNotChild(old, parent)
+		}
+	case *ExpressionCharacterClass:
+		e, ok := new.(ClassSetExpression)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be the expression of ExpressionCharacterClass", new)
+		}
+		if n, ok := p.Expression.(Node); !ok || n != old {
+			return errNotChild(old, parent)
+		}
+		p.Expression = e
+	case *ClassIntersection:
+		if err := replaceOperand(p, &p.Left, &p.Right, old, new); err != nil {
+			return err
+		}
+	case *ClassSubtraction:
+		if err := replaceOperand(p, &p.Left, &p.Right, old, new); err != nil {
+			return err
+		}
+	case *ClassStringDisjunction:
+		alt, ok := new.(*StringAlternative)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an alternative of ClassStringDisjunction", new)
+		}
+		i := indexOf(p.Alternatives, old)
+		if i < 0 {
+			return errNotChild(old, parent)
+		}
+		p.Alternatives[i] = alt
+	case *StringAlternative:
+		c, ok := new.(*Character)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an element of StringAlternative", new)
+		}
+		i := indexOf(p.Elements, old)
+		if i < 0 {
+			return errNotChild(old, parent)
+		}
+		p.Elements[i] = c
+	default:
+		return errNotChild(old, parent)
+	}
+	new.SetParent(parent)
+	old.SetParent(nil)
+	return nil
+}
+
+func replaceOperand(parent Node, left, right *ClassSetOperand, old Node, new Node) error {
+	operand, ok := new.(ClassSetOperand)
+	if !ok {
+		return fmt.Errorf("regexp_ast: %T can't be an operand of a class set operation", new)
+	}
+	if n, ok := (*left).(Node); ok && n == old {
+		*left = operand
+	} else if n, ok := (*right).(Node); ok && n == old {
+		*right = operand
+	} else {
+		return errNotChild(old, parent)
+	}
+	return nil
+}
+
+// Remove detaches node from its parent. Only alternatives and the elements
+// of alternatives and classes can be removed; the last alternative of a
+// Pattern or a group can't.
+func Remove(node Node) error {
+	parent := node.GetParent()
+	switch p := parent.(type) {
+	case *Pattern, *Group, *CapturingGroup, *LookaroundAssertion:
+		alts := alternativesOf(p)
+		i := indexOf(*alts, node)
+		if i < 0 {
+			return errNotChild(node, parent)
+		}
+		if len(*alts) == 1 {
+			return fmt.Errorf("regexp_ast: can't remove the last alternative of %T", parent)
+		}
+		*alts = deleteAt(*alts, i)
+	case *Alternative:
+		i := indexOf(p.Elements, node)
+		if i < 0 {
+			return errNotChild(node, parent)
+		}
+		p.Elements = deleteAt(p.Elements, i)
+	case *CharacterClass:
+		i := indexOf(p.Elements, node)
+		if i < 0 {
+			return errNotChild(node, parent)
+		}
+		p.Elements = deleteAt(p.Elements, i)
+	case nil:
+		return fmt.Errorf("regexp_ast: %T has no parent to be removed from", node)
+	default:
+		return fmt.Errorf("regexp_ast: the child of %T can't be removed", parent)
+	}
+	node.SetParent(nil)
+	return nil
+}
+
+// InsertBefore inserts node right before ref, which must be an alternative
+// or an element of an alternative or a class.
+func InsertBefore(ref Node, node Node) error {
+	return insert(ref, node, 0)
+}
+
+// InsertAfter inserts node right after ref, which must be an alternative or
+// an element of an alternative or a class.
+func InsertAfter(ref Node, node Node) error {
+	return insert(ref, node, 1)
+}
+
+func insert(ref Node, node Node, delta int) error {
+	parent := ref.GetParent()
+	switch p := parent.(type) {
+	case *Pattern, *Group, *CapturingGroup, *LookaroundAssertion:
+		alts := alternativesOf(p)
+		alt, ok := node.(*Alternative)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an alternative of %T", node, parent)
+		}
+		i := indexOf(*alts, ref)
+		if i < 0 {
+			return errNotChild(ref, parent)
+		}
+		*alts = insertAt(*alts, i+delta, alt)
+	case *Alternative:
+		el, ok := node.(Element)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an element of Alternative", node)
+		}
+		i := indexOf(p.Elements, ref)
+		if i < 0 {
+			return errNotChild(ref, parent)
+		}
+		p.Elements = insertAt(p.Elements, i+delta, el)
+	case *CharacterClass:
+		el, ok := classElement(p, node)
+		if !ok {
+			return fmt.Errorf("regexp_ast: %T can't be an element of CharacterClass", node)
+		}
+		i := indexOf(p.Elements, ref)
+		if i < 0 {
+			return errNotChild(ref, parent)
+		}
+		p.Elements = insertAt(p.Elements, i+delta, el)
+	case nil:
+		return fmt.Errorf("regexp_ast: %T has no parent to insert into", ref)
+	default:
+		return fmt.Errorf("regexp_ast: %T has only a fixed set of children", parent)
+	}
+	node.SetParent(parent)
+	return nil
+}
+
+// alternativesOf returns the alternatives of a Pattern, a Group, a
+// CapturingGroup or a LookaroundAssertion.
+func alternativesOf(node Node) *[]*Alternative {
+	switch n := node.(type) {
+	case *Pattern:
+		return &n.Alternatives
+	case *Group:
+		return &n.Alternatives
+	case *CapturingGroup:
+		return &n.Alternatives
+	case *LookaroundAssertion:
+		return &n.Alternatives
+	}
+	return nil
+}
+
+// classElement returns node as an element of class. Classes and string
+// disjunctions are only elements of a class with UnicodeSets.
+func classElement(class *CharacterClass, node Node) (CharacterClassElement, bool) {
+	el, ok := node.(CharacterClassElement)
+	if !ok {
+		return nil, false
+	}
+	switch el.(type) {
+	case *CharacterClass, *ExpressionCharacterClass, *ClassStringDisjunction:
+		return el, class.UnicodeSets
+	}
+	return el, true
+}
+
+func errNotChild(node Node, parent Node) error {
+	return fmt.Errorf("regexp_ast: %T is not a child of its parent %T", node, parent)
+}
+
+func indexOf[T any](s []T, node Node) int {
+	for i, el := range s {
+		if n, ok := any(el).(Node); ok && n == node {
+			return i
+		}
+	}
+	return -1
+}
+
+func deleteAt[T any](s []T, i int) []T {
+	return append(s[:i:i], s[i+1:]...)
+}
+
+func insertAt[T any](s []T, i int, v T) []T {
+	r := make([]T, 0, len(s)+1)
+	r = append(r, s[:i]...)
+	r = append(r, v)
+	return append(r, s[i:]...)
+}
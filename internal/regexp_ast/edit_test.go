@@ -0,0 +1,334 @@
+package regexp_ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/builder"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+)
+
+func parse(t *testing.T, source string) *regexp_ast.Pattern {
+	t.Helper()
+	p := parser.NewParser(source, true)
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pattern
+}
+
+func TestEdit(t *testing.T) {
+	b := builder.New(true)
+	tests := []struct {
+		name       string
+		input      string
+		inputEdit  func(pattern *regexp_ast.Pattern) error
+		wantOutput string
+	}{
+		{
+			name:  "要素の置き換え",
+			input: "ab|c",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Replace(pattern.Alternatives[0].Elements[1].(regexp_ast.Node), b.Plus(b.Any()))
+			},
+			wantOutput: "a.+|c",
+		},
+		{
+			name:  "量指定子の要素の置き換え",
+			input: "a*",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				q := pattern.Alternatives[0].Elements[0].(*regexp_ast.Quantifier)
+				return regexp_ast.Replace(q.Element.(regexp_ast.Node), b.Class(b.Range('0', '9')))
+			},
+			wantOutput: "[0-9]*",
+		},
+		{
+			name:  "範囲の端点の置き換え",
+			input: "[a-c]",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				r := pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass).Elements[0].(*regexp_ast.CharacterClassRange)
+				return regexp_ast.Replace(r.Max, b.Char('z'))
+			},
+			wantOutput: "[a-z]",
+		},
+		{
+			name:  "選択肢の削除",
+			input: "a|b|c",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Remove(pattern.Alternatives[1])
+			},
+			wantOutput: "a|c",
+		},
+		{
+			name:  "文字クラスの要素の削除",
+			input: "[abc]",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				class := pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass)
+				return regexp_ast.Remove(class.Elements[2].(regexp_ast.Node))
+			},
+			wantOutput: "[ab]",
+		},
+		{
+			name:  "前後への挿入",
+			input: "b",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				ref := pattern.Alternatives[0].Elements[0].(regexp_ast.Node)
+				if err := regexp_ast.InsertBefore(ref, b.Char('a')); err != nil {
+					return err
+				}
+				return regexp_ast.InsertAfter(ref, b.Char('c'))
+			},
+			wantOutput: "abc",
+		},
+		{
+			name:  "選択肢の挿入",
+			input: "a",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.InsertAfter(pattern.Alternatives[0], b.Alt(b.Char('b')))
+			},
+			wantOutput: "a|b",
+		},
+		{
+			name:  "グループの選択肢の挿入と削除",
+			input: "(?:a|b)(?<=c)",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				group := pattern.Alternatives[0].Elements[0].(*regexp_ast.Group)
+				if err := regexp_ast.Remove(group.Alternatives[0]); err != nil {
+					return err
+				}
+				lookbehind := pattern.Alternatives[0].Elements[1].(*regexp_ast.LookaroundAssertion)
+				return regexp_ast.InsertBefore(lookbehind.Alternatives[0], b.Alt(b.Char('d')))
+			},
+			wantOutput: "(?:b)(?<=d|c)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := parse(t, tt.input)
+			if err := tt.inputEdit(pattern); err != nil {
+				t.Fatal(err)
+			}
+			o := printer.Layout(pattern, true)
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+			if err := regexp_ast.Verify(pattern); err != nil {
+				t.Errorf("Edited tree is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestEditError(t *testing.T) {
+	b := builder.New(true)
+	tests := []struct {
+		name      string
+		input     string
+		inputEdit func(pattern *regexp_ast.Pattern) error
+	}{
+		{
+			name:  "量指定子の量指定",
+			input: "a*",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				q := pattern.Alternatives[0].Elements[0].(*regexp_ast.Quantifier)
+				return regexp_ast.Replace(q.Element.(regexp_ast.Node), b.Star(b.Char('b')))
+			},
+		},
+		{
+			name:  "文字クラスの中の文字クラス",
+			input: "[a]",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				class := pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass)
+				return regexp_ast.InsertBefore(class.Elements[0].(regexp_ast.Node), b.Class())
+			},
+		},
+		{
+			name:  "最後の選択肢の削除",
+			input: "a",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Remove(pattern.Alternatives[0])
+			},
+		},
+		{
+			name:  "量指定子の要素の削除",
+			input: "a*",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				q := pattern.Alternatives[0].Elements[0].(*regexp_ast.Quantifier)
+				return regexp_ast.Remove(q.Element.(regexp_ast.Node))
+			},
+		},
+		{
+			name:  "グループの最後の選択肢の削除",
+			input: "(a)",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				group := pattern.Alternatives[0].Elements[0].(*regexp_ast.CapturingGroup)
+				return regexp_ast.Remove(group.Alternatives[0])
+			},
+		},
+		{
+			name:  "親のないノード",
+			input: "a",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Replace(pattern, b.Pattern())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := parse(t, tt.input)
+			want := regexp_ast.Clone(pattern)
+			if err := tt.inputEdit(pattern); err == nil {
+				t.Errorf("Expected an error")
+			}
+			if !reflect.DeepEqual(pattern, want) {
+				t.Errorf("Failed edit modified the tree")
+			}
+		})
+	}
+}
+
+func TestClone(t *testing.T) {
+	pattern := parse(t, `a[^b-d]*?|.{2,}|\k<n>(?<n>\d(?=\p{L}))|\1`)
+	c := regexp_ast.Clone(pattern).(*regexp_ast.Pattern)
+	if !reflect.DeepEqual(c, pattern) {
+		t.Fatalf("Clone differs from the original")
+	}
+	if err := regexp_ast.Verify(c); err != nil {
+		t.Fatal(err)
+	}
+
+	shared := map[regexp_ast.Node]bool{}
+	regexp_ast.Inspect(pattern, func(n regexp_ast.Node) bool {
+		shared[n] = true
+		return true
+	})
+	regexp_ast.Inspect(c, func(n regexp_ast.Node) bool {
+		if shared[n] {
+			t.Errorf("Clone shares %T with the original", n)
+		}
+		if ref, ok := n.(*regexp_ast.Backreference); ok && shared[ref.Resolved] {
+			t.Errorf("Clone resolves a backreference to the original group")
+		}
+		return true
+	})
+
+	// 木の外のグループへの後方参照は解決されない
+	alt := regexp_ast.Clone(pattern.Alternatives[3]).(*regexp_ast.Alternative)
+	ref := alt.Elements[0].(*regexp_ast.Backreference)
+	if ref.Resolved != nil {
+		t.Errorf("Clone of an alternative resolves a backreference outside of it")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		inputEdit func(pattern *regexp_ast.Pattern)
+	}{
+		{
+			name:  "親の不一致",
+			input: "ab",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				pattern.Alternatives[0].Elements[1].(*regexp_ast.Character).Parent = pattern
+			},
+		},
+		{
+			name:  "ノードの共有",
+			input: "a",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				alt := pattern.Alternatives[0]
+				alt.Elements = append(alt.Elements, alt.Elements[0])
+			},
+		},
+		{
+			name:  "親の範囲外の位置",
+			input: "[a]",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				class := pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass)
+				class.Elements[0].(*regexp_ast.Character).Loc.End = 4
+			},
+		},
+		{
+			name:  "兄弟の位置の逆転",
+			input: "ab",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				els := pattern.Alternatives[0].Elements
+				els[0], els[1] = els[1], els[0]
+			},
+		},
+		{
+			name:  "逆順の範囲",
+			input: "[a-c]",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				r := pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass).Elements[0].(*regexp_ast.CharacterClassRange)
+				r.Min.Value = 'd'
+			},
+		},
+		{
+			name:  "不正な量指定子",
+			input: "a{2,3}",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				pattern.Alternatives[0].Elements[0].(*regexp_ast.Quantifier).Min = 4
+			},
+		},
+		{
+			name:  "nil の要素",
+			input: "ab",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				pattern.Alternatives[0].Elements[1] = (*regexp_ast.Character)(nil)
+			},
+		},
+		{
+			name:  "解決されていない後方参照",
+			input: `(a)\1`,
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				pattern.Alternatives[0].Elements[1].(*regexp_ast.Backreference).Resolved = nil
+			},
+		},
+		{
+			name:  "グループの参照にない後方参照",
+			input: `(a)\1`,
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				pattern.Alternatives[0].Elements[0].(*regexp_ast.CapturingGroup).References = nil
+			},
+		},
+		{
+			name:  "選択肢のない先読み",
+			input: "(?=a)",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				pattern.Alternatives[0].Elements[0].(*regexp_ast.LookaroundAssertion).Alternatives = nil
+			},
+		},
+		{
+			name:  "選択肢のないパターン",
+			input: "a",
+			inputEdit: func(pattern *regexp_ast.Pattern) {
+				pattern.Alternatives = nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := parse(t, tt.input)
+			if err := regexp_ast.Verify(pattern); err != nil {
+				t.Fatalf("Parsed tree is invalid: %v", err)
+			}
+			tt.inputEdit(pattern)
+			if err := regexp_ast.Verify(pattern); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+	for _, node := range []regexp_ast.Node{nil, (*regexp_ast.Pattern)(nil)} {
+		if err := regexp_ast.Verify(node); err == nil || err.Error() != "regexp_ast: the node is nil" {
+			t.Errorf("Unexpected error for %#v: %v", node, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package regexp_ast
+
+// Flags are the flags of a regular expression literal, e.g. `gu` of /a/gu.
+// The field names follow regexpp's Flags node.
+type Flags struct {
+	HasIndices  bool // d
+	Global      bool // g
+	IgnoreCase  bool // i
+	Multiline   bool // m
+	DotAll      bool // s
+	Unicode     bool // u
+	UnicodeSets bool // v
+	Sticky      bool // y
+}
+
+// String returns the flags in the canonical order of
+// RegExp.prototype.flags.
+func (f Flags) String() string {
+	var b []byte
+	for _, flag := range []struct {
+		set bool
+		c   byte
+	}{
+		{f.HasIndices, 'd'},
+		{f.Global, 'g'},
+		{f.IgnoreCase, 'i'},
+		{f.Multiline, 'm'},
+		{f.DotAll, 's'},
+		{f.Unicode, 'u'},
+		{f.UnicodeSets, 'v'},
+		{f.Sticky, 'y'},
+	} {
+		if flag.set {
+			b = append(b, flag.c)
+		}
+	}
+	return string(b)
+}
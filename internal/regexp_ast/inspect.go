@@ -0,0 +1,76 @@
+package regexp_ast
+
+// Inspect traverses the tree rooted at node in depth-first order, calling f
+// for each node before its children. If f returns false, the children of that
+// node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *Pattern:
+		for _, alt := range n.Alternatives {
+			Inspect(alt, f)
+		}
+	case *Alternative:
+		for _, el := range n.Elements {
+			if child, ok := el.(Node); ok {
+				Inspect(child, f)
+			}
+		}
+	case *CharacterClass:
+		for _, el := range n.Elements {
+			if child, ok := el.(Node); ok {
+				Inspect(child, f)
+			}
+		}
+	case *Quantifier:
+		if child, ok := n.Element.(Node); ok {
+			Inspect(child, f)
+		}
+	case *CharacterClassRange:
+		if n.Min != nil {
+			Inspect(n.Min, f)
+		}
+		if n.Max != nil {
+			Inspect(n.Max, f)
+		}
+	case *Group:
+		for _, alt := range n.Alternatives {
+			Inspect(alt, f)
+		}
+	case *CapturingGroup:
+		for _, alt := range n.Alternatives {
+			Inspect(alt, f)
+		}
+	case *LookaroundAssertion:
+		for _, alt := range n.Alternatives {
+			Inspect(alt, f)
+		}
+	case *ExpressionCharacterClass:
+		if child, ok := n.Expression.(Node); ok {
+			Inspect(child, f)
+		}
+	case *ClassIntersection:
+		inspectOperands(n.Left, n.Right, f)
+	case *ClassSubtraction:
+		inspectOperands(n.Left, n.Right, f)
+	case *ClassStringDisjunction:
+		for _, alt := range n.Alternatives {
+			Inspect(alt, f)
+		}
+	case *StringAlternative:
+		for _, c := range n.Elements {
+			Inspect(c, f)
+		}
+	}
+}
+
+func inspectOperands(left ClassSetOperand, right ClassSetOperand, f func(Node) bool) {
+	if child, ok := left.(Node); ok {
+		Inspect(child, f)
+	}
+	if child, ok := right.(Node); ok {
+		Inspect(child, f)
+	}
+}
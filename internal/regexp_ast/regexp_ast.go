@@ -1,84 +1,257 @@
 package regexp_ast
 
+// Loc is a half-open range [Start, End) in the pattern source. Offsets are
+// UTF-16 code units unless the parser was asked for another offset.Unit.
+//
+// StartPos and EndPos are set only when the parser was given the host source
+// that the pattern was extracted from.
 type Loc struct {
-	Start int
-	End   int
+	Start    int
+	End      int
+	StartPos *Position `json:",omitempty"`
+	EndPos   *Position `json:",omitempty"`
+}
+
+// Position is a location in the host source. Line is 1-based and Column is
+// 0-based, in the same unit as Loc.
+type Position struct {
+	Line   int
+	Column int
 }
 
 type Node interface {
 	isNode()
 	GetParent() Node
+	GetLoc() Loc
 	SetEnd(end int)
+	SetLoc(loc Loc)
 	SetParent(parent Node)
 }
 
-func (n *Pattern) isNode()             {}
-func (n *Alternative) isNode()         {}
-func (n *Character) isNode()           {}
-func (n *CharacterClass) isNode()      {}
-func (n *AnyCharacterSet) isNode()     {}
-func (n *Quantifier) isNode()          {}
-func (n *CharacterClassRange) isNode() {}
-
-func (n *Pattern) GetParent() Node             { return nil }
-func (n *Alternative) GetParent() Node         { return n.Parent }
-func (n *Character) GetParent() Node           { return n.Parent }
-func (n *CharacterClass) GetParent() Node      { return n.Parent }
-func (n *AnyCharacterSet) GetParent() Node     { return n.Parent }
-func (n *Quantifier) GetParent() Node          { return n.Parent }
-func (n *CharacterClassRange) GetParent() Node { return n.Parent }
-
-func (n *Pattern) SetParent(parent Node)             {}
-func (n *Alternative) SetParent(parent Node)         { n.Parent = parent }
-func (n *Character) SetParent(parent Node)           { n.Parent = parent }
-func (n *CharacterClass) SetParent(parent Node)      { n.Parent = parent }
-func (n *AnyCharacterSet) SetParent(parent Node)     { n.Parent = parent }
-func (n *Quantifier) SetParent(parent Node)          { n.Parent = parent }
-func (n *CharacterClassRange) SetParent(parent Node) { n.Parent = parent }
-
-func (n *Pattern) SetEnd(end int)             { n.Loc.End = end }
-func (n *Alternative) SetEnd(end int)         { n.Loc.End = end }
-func (n *Character) SetEnd(end int)           { n.Loc.End = end }
-func (n *CharacterClass) SetEnd(end int)      { n.Loc.End = end }
-func (n *AnyCharacterSet) SetEnd(end int)     { n.Loc.End = end }
-func (n *Quantifier) SetEnd(end int)          { n.Loc.End = end }
-func (n *CharacterClassRange) SetEnd(end int) { n.Loc.End = end }
+func (n *Pattern) isNode()                     {}
+func (n *Alternative) isNode()                 {}
+func (n *Character) isNode()                   {}
+func (n *CharacterClass) isNode()              {}
+func (n *AnyCharacterSet) isNode()             {}
+func (n *Quantifier) isNode()                  {}
+func (n *CharacterClassRange) isNode()         {}
+func (n *ExpressionCharacterClass) isNode()    {}
+func (n *ClassIntersection) isNode()           {}
+func (n *ClassSubtraction) isNode()            {}
+func (n *ClassStringDisjunction) isNode()      {}
+func (n *StringAlternative) isNode()           {}
+func (n *Group) isNode()                       {}
+func (n *CapturingGroup) isNode()              {}
+func (n *LookaroundAssertion) isNode()         {}
+func (n *EdgeAssertion) isNode()               {}
+func (n *WordBoundaryAssertion) isNode()       {}
+func (n *Backreference) isNode()               {}
+func (n *EscapeCharacterSet) isNode()          {}
+func (n *UnicodePropertyCharacterSet) isNode() {}
+
+func (n *Pattern) GetParent() Node                     { return nil }
+func (n *Alternative) GetParent() Node                 { return n.Parent }
+func (n *Character) GetParent() Node                   { return n.Parent }
+func (n *CharacterClass) GetParent() Node              { return n.Parent }
+func (n *AnyCharacterSet) GetParent() Node             { return n.Parent }
+func (n *Quantifier) GetParent() Node                  { return n.Parent }
+func (n *CharacterClassRange) GetParent() Node         { return n.Parent }
+func (n *ExpressionCharacterClass) GetParent() Node    { return n.Parent }
+func (n *ClassIntersection) GetParent() Node           { return n.Parent }
+func (n *ClassSubtraction) GetParent() Node            { return n.Parent }
+func (n *ClassStringDisjunction) GetParent() Node      { return n.Parent }
+func (n *StringAlternative) GetParent() Node           { return n.Parent }
+func (n *Group) GetParent() Node                       { return n.Parent }
+func (n *CapturingGroup) GetParent() Node              { return n.Parent }
+func (n *LookaroundAssertion) GetParent() Node         { return n.Parent }
+func (n *EdgeAssertion) GetParent() Node               { return n.Parent }
+func (n *WordBoundaryAssertion) GetParent() Node       { return n.Parent }
+func (n *Backreference) GetParent() Node               { return n.Parent }
+func (n *EscapeCharacterSet) GetParent() Node          { return n.Parent }
+func (n *UnicodePropertyCharacterSet) GetParent() Node { return n.Parent }
+
+func (n *Pattern) SetParent(parent Node)                     {}
+func (n *Alternative) SetParent(parent Node)                 { n.Parent = parent }
+func (n *Character) SetParent(parent Node)                   { n.Parent = parent }
+func (n *CharacterClass) SetParent(parent Node)              { n.Parent = parent }
+func (n *AnyCharacterSet) SetParent(parent Node)             { n.Parent = parent }
+func (n *Quantifier) SetParent(parent Node)                  { n.Parent = parent }
+func (n *CharacterClassRange) SetParent(parent Node)         { n.Parent = parent }
+func (n *ExpressionCharacterClass) SetParent(parent Node)    { n.Parent = parent }
+func (n *ClassIntersection) SetParent(parent Node)           { n.Parent = parent }
+func (n *ClassSubtraction) SetParent(parent Node)            { n.Parent = parent }
+func (n *ClassStringDisjunction) SetParent(parent Node)      { n.Parent = parent }
+func (n *StringAlternative) SetParent(parent Node)           { n.Parent = parent }
+func (n *Group) SetParent(parent Node)                       { n.Parent = parent }
+func (n *CapturingGroup) SetParent(parent Node)              { n.Parent = parent }
+func (n *LookaroundAssertion) SetParent(parent Node)         { n.Parent = parent }
+func (n *EdgeAssertion) SetParent(parent Node)               { n.Parent = parent }
+func (n *WordBoundaryAssertion) SetParent(parent Node)       { n.Parent = parent }
+func (n *Backreference) SetParent(parent Node)               { n.Parent = parent }
+func (n *EscapeCharacterSet) SetParent(parent Node)          { n.Parent = parent }
+func (n *UnicodePropertyCharacterSet) SetParent(parent Node) { n.Parent = parent }
+
+func (n *Pattern) GetLoc() Loc                     { return n.Loc }
+func (n *Alternative) GetLoc() Loc                 { return n.Loc }
+func (n *Character) GetLoc() Loc                   { return n.Loc }
+func (n *CharacterClass) GetLoc() Loc              { return n.Loc }
+func (n *AnyCharacterSet) GetLoc() Loc             { return n.Loc }
+func (n *Quantifier) GetLoc() Loc                  { return n.Loc }
+func (n *CharacterClassRange) GetLoc() Loc         { return n.Loc }
+func (n *ExpressionCharacterClass) GetLoc() Loc    { return n.Loc }
+func (n *ClassIntersection) GetLoc() Loc           { return n.Loc }
+func (n *ClassSubtraction) GetLoc() Loc            { return n.Loc }
+func (n *ClassStringDisjunction) GetLoc() Loc      { return n.Loc }
+func (n *StringAlternative) GetLoc() Loc           { return n.Loc }
+func (n *Group) GetLoc() Loc                       { return n.Loc }
+func (n *CapturingGroup) GetLoc() Loc              { return n.Loc }
+func (n *LookaroundAssertion) GetLoc() Loc         { return n.Loc }
+func (n *EdgeAssertion) GetLoc() Loc               { return n.Loc }
+func (n *WordBoundaryAssertion) GetLoc() Loc       { return n.Loc }
+func (n *Backreference) GetLoc() Loc               { return n.Loc }
+func (n *EscapeCharacterSet) GetLoc() Loc          { return n.Loc }
+func (n *UnicodePropertyCharacterSet) GetLoc() Loc { return n.Loc }
+
+func (n *Pattern) SetLoc(loc Loc)                     { n.Loc = loc }
+func (n *Alternative) SetLoc(loc Loc)                 { n.Loc = loc }
+func (n *Character) SetLoc(loc Loc)                   { n.Loc = loc }
+func (n *CharacterClass) SetLoc(loc Loc)              { n.Loc = loc }
+func (n *AnyCharacterSet) SetLoc(loc Loc)             { n.Loc = loc }
+func (n *Quantifier) SetLoc(loc Loc)                  { n.Loc = loc }
+func (n *CharacterClassRange) SetLoc(loc Loc)         { n.Loc = loc }
+func (n *ExpressionCharacterClass) SetLoc(loc Loc)    { n.Loc = loc }
+func (n *ClassIntersection) SetLoc(loc Loc)           { n.Loc = loc }
+func (n *ClassSubtraction) SetLoc(loc Loc)            { n.Loc = loc }
+func (n *ClassStringDisjunction) SetLoc(loc Loc)      { n.Loc = loc }
+func (n *StringAlternative) SetLoc(loc Loc)           { n.Loc = loc }
+func (n *Group) SetLoc(loc Loc)                       { n.Loc = loc }
+func (n *CapturingGroup) SetLoc(loc Loc)              { n.Loc = loc }
+func (n *LookaroundAssertion) SetLoc(loc Loc)         { n.Loc = loc }
+func (n *EdgeAssertion) SetLoc(loc Loc)               { n.Loc = loc }
+func (n *WordBoundaryAssertion) SetLoc(loc Loc)       { n.Loc = loc }
+func (n *Backreference) SetLoc(loc Loc)               { n.Loc = loc }
+func (n *EscapeCharacterSet) SetLoc(loc Loc)          { n.Loc = loc }
+func (n *UnicodePropertyCharacterSet) SetLoc(loc Loc) { n.Loc = loc }
+
+func (n *Pattern) SetEnd(end int)                     { n.Loc.End = end }
+func (n *Alternative) SetEnd(end int)                 { n.Loc.End = end }
+func (n *Character) SetEnd(end int)                   { n.Loc.End = end }
+func (n *CharacterClass) SetEnd(end int)              { n.Loc.End = end }
+func (n *AnyCharacterSet) SetEnd(end int)             { n.Loc.End = end }
+func (n *Quantifier) SetEnd(end int)                  { n.Loc.End = end }
+func (n *CharacterClassRange) SetEnd(end int)         { n.Loc.End = end }
+func (n *ExpressionCharacterClass) SetEnd(end int)    { n.Loc.End = end }
+func (n *ClassIntersection) SetEnd(end int)           { n.Loc.End = end }
+func (n *ClassSubtraction) SetEnd(end int)            { n.Loc.End = end }
+func (n *ClassStringDisjunction) SetEnd(end int)      { n.Loc.End = end }
+func (n *StringAlternative) SetEnd(end int)           { n.Loc.End = end }
+func (n *Group) SetEnd(end int)                       { n.Loc.End = end }
+func (n *CapturingGroup) SetEnd(end int)              { n.Loc.End = end }
+func (n *LookaroundAssertion) SetEnd(end int)         { n.Loc.End = end }
+func (n *EdgeAssertion) SetEnd(end int)               { n.Loc.End = end }
+func (n *WordBoundaryAssertion) SetEnd(end int)       { n.Loc.End = end }
+func (n *Backreference) SetEnd(end int)               { n.Loc.End = end }
+func (n *EscapeCharacterSet) SetEnd(end int)          { n.Loc.End = end }
+func (n *UnicodePropertyCharacterSet) SetEnd(end int) { n.Loc.End = end }
 
 type Element interface {
 	isElement()
 }
 
-func (n *Character) isElement()       {}
-func (n *CharacterClass) isElement()  {}
-func (n *AnyCharacterSet) isElement() {}
-func (n *Quantifier) isElement()      {}
+func (n *Character) isElement()                   {}
+func (n *CharacterClass) isElement()              {}
+func (n *AnyCharacterSet) isElement()             {}
+func (n *Quantifier) isElement()                  {}
+func (n *ExpressionCharacterClass) isElement()    {}
+func (n *Group) isElement()                       {}
+func (n *CapturingGroup) isElement()              {}
+func (n *LookaroundAssertion) isElement()         {}
+func (n *EdgeAssertion) isElement()               {}
+func (n *WordBoundaryAssertion) isElement()       {}
+func (n *Backreference) isElement()               {}
+func (n *EscapeCharacterSet) isElement()          {}
+func (n *UnicodePropertyCharacterSet) isElement() {}
+
+// Assertion is an element that matches no character: `^`, `$`, `\b`, `\B`
+// and lookarounds.
+type Assertion interface {
+	isAssertion()
+}
+
+func (n *LookaroundAssertion) isAssertion()   {}
+func (n *EdgeAssertion) isAssertion()         {}
+func (n *WordBoundaryAssertion) isAssertion() {}
 
 type CharacterSet interface {
 	isCharacterSet()
 }
 
-func (n *AnyCharacterSet) isCharacterSet() {}
+func (n *AnyCharacterSet) isCharacterSet()             {}
+func (n *EscapeCharacterSet) isCharacterSet()          {}
+func (n *UnicodePropertyCharacterSet) isCharacterSet() {}
 
 type QuantifiableElement interface {
 	isQuantifiableElement()
 }
 
-func (n *Character) isQuantifiableElement()       {}
-func (n *CharacterClass) isQuantifiableElement()  {}
-func (n *AnyCharacterSet) isQuantifiableElement() {}
+func (n *Character) isQuantifiableElement()                   {}
+func (n *CharacterClass) isQuantifiableElement()              {}
+func (n *AnyCharacterSet) isQuantifiableElement()             {}
+func (n *ExpressionCharacterClass) isQuantifiableElement()    {}
+func (n *Group) isQuantifiableElement()                       {}
+func (n *CapturingGroup) isQuantifiableElement()              {}
+func (n *Backreference) isQuantifiableElement()               {}
+func (n *EscapeCharacterSet) isQuantifiableElement()          {}
+func (n *UnicodePropertyCharacterSet) isQuantifiableElement() {}
+
+// Only a lookahead without the u flag, as in Annex B
+func (n *LookaroundAssertion) isQuantifiableElement() {}
 
 type CharacterClassElement interface {
 	isCharacterClassElement()
 }
 
-func (n *Character) isCharacterClassElement()           {}
-func (n *CharacterClassRange) isCharacterClassElement() {}
+func (n *Character) isCharacterClassElement()                   {}
+func (n *CharacterClassRange) isCharacterClassElement()         {}
+func (n *EscapeCharacterSet) isCharacterClassElement()          {}
+func (n *UnicodePropertyCharacterSet) isCharacterClassElement() {}
+
+// Only in a class with UnicodeSets
+func (n *CharacterClass) isCharacterClassElement()           {}
+func (n *ExpressionCharacterClass) isCharacterClassElement() {}
+func (n *ClassStringDisjunction) isCharacterClassElement()   {}
+
+// ClassSetOperand is an operand of `&&` and `--` with the v flag. A
+// ClassIntersection or a ClassSubtraction is only the Left operand of the
+// same kind of expression, for a chain like `a&&b&&c`.
+type ClassSetOperand interface {
+	isClassSetOperand()
+}
+
+func (n *Character) isClassSetOperand()                   {}
+func (n *CharacterClass) isClassSetOperand()              {}
+func (n *ExpressionCharacterClass) isClassSetOperand()    {}
+func (n *ClassStringDisjunction) isClassSetOperand()      {}
+func (n *ClassIntersection) isClassSetOperand()           {}
+func (n *ClassSubtraction) isClassSetOperand()            {}
+func (n *EscapeCharacterSet) isClassSetOperand()          {}
+func (n *UnicodePropertyCharacterSet) isClassSetOperand() {}
+
+// ClassSetExpression is the expression of an ExpressionCharacterClass.
+type ClassSetExpression interface {
+	isClassSetExpression()
+}
+
+func (n *ClassIntersection) isClassSetExpression() {}
+func (n *ClassSubtraction) isClassSetExpression()  {}
 
 type Pattern struct {
 	Loc          Loc
 	Alternatives []*Alternative
 }
 
+// An alternative of a Pattern, a Group, a CapturingGroup or a
+// LookaroundAssertion
 type Alternative struct {
 	Parent   Node `json:"-"`
 	Elements []Element
@@ -92,10 +265,13 @@ type Character struct {
 }
 
 type CharacterClass struct {
-	Parent   Node `json:"-"`
-	Loc      Loc
-	Negate   bool
-	Elements []CharacterClassElement
+	Parent Node `json:"-"`
+	Loc    Loc
+	Negate bool
+	// Whether the class is parsed with the v flag, where its elements may
+	// also be nested classes and string disjunctions
+	UnicodeSets bool `json:",omitempty"`
+	Elements    []CharacterClassElement
 }
 
 // Dot
@@ -120,3 +296,139 @@ type CharacterClassRange struct {
 	Min    *Character
 	Max    *Character
 }
+
+// [a&&b] or [a--b], a class of a set operation with the v flag
+type ExpressionCharacterClass struct {
+	Parent     Node `json:"-"`
+	Loc        Loc
+	Negate     bool
+	Expression ClassSetExpression
+}
+
+// a&&b
+type ClassIntersection struct {
+	Parent Node `json:"-"`
+	Loc    Loc
+	Left   ClassSetOperand
+	Right  ClassSetOperand
+}
+
+// a--b
+type ClassSubtraction struct {
+	Parent Node `json:"-"`
+	Loc    Loc
+	Left   ClassSetOperand
+	Right  ClassSetOperand
+}
+
+// \q{abc|d}
+type ClassStringDisjunction struct {
+	Parent       Node `json:"-"`
+	Loc          Loc
+	Alternatives []*StringAlternative
+}
+
+// A string of a ClassStringDisjunction, which may be empty
+type StringAlternative struct {
+	Parent   Node `json:"-"`
+	Loc      Loc
+	Elements []*Character
+}
+
+// (?:a|b)
+type Group struct {
+	Parent       Node `json:"-"`
+	Loc          Loc
+	Alternatives []*Alternative
+}
+
+// (a|b) or (?<name>a|b)
+type CapturingGroup struct {
+	Parent Node `json:"-"`
+	Loc    Loc
+	// Empty for a group without a name
+	Name         string `json:",omitempty"`
+	Alternatives []*Alternative
+	// The backreferences to the group
+	References []*Backreference `json:"-"`
+}
+
+type LookaroundKind string
+
+const (
+	Lookahead  LookaroundKind = "lookahead"
+	Lookbehind LookaroundKind = "lookbehind"
+)
+
+// (?=a), (?!a), (?<=a) or (?<!a)
+type LookaroundAssertion struct {
+	Parent       Node `json:"-"`
+	Loc          Loc
+	Kind         LookaroundKind
+	Negate       bool
+	Alternatives []*Alternative
+}
+
+type EdgeKind string
+
+const (
+	EdgeStart EdgeKind = "start"
+	EdgeEnd   EdgeKind = "end"
+)
+
+// ^ or $
+type EdgeAssertion struct {
+	Parent Node `json:"-"`
+	Loc    Loc
+	Kind   EdgeKind
+}
+
+// \b, or \B if Negate
+type WordBoundaryAssertion struct {
+	Parent Node `json:"-"`
+	Loc    Loc
+	Negate bool
+}
+
+// \1 or \k<name>
+type Backreference struct {
+	Parent Node `json:"-"`
+	Loc    Loc
+	// The group number of \1, or 0 for \k<name>
+	Number int `json:",omitempty"`
+	// The group name of \k<name>
+	Name string `json:",omitempty"`
+	// The group that is referred to
+	Resolved *CapturingGroup `json:"-"`
+}
+
+type EscapeKind string
+
+const (
+	EscapeDigit EscapeKind = "digit"
+	EscapeSpace EscapeKind = "space"
+	EscapeWord  EscapeKind = "word"
+)
+
+// \d, \s or \w, or \D, \S or \W if Negate
+type EscapeCharacterSet struct {
+	Parent Node `json:"-"`
+	Loc    Loc
+	Kind   EscapeKind
+	Negate bool
+}
+
+// \p{Key=Value} or \p{Key}, or \P{…} if Negate. Key and Value are as
+// written, so they may be aliases, and a lone General_Category value like
+// \p{L} has the Key "General_Category".
+type UnicodePropertyCharacterSet struct {
+	Parent Node `json:"-"`
+	Loc    Loc
+	Key    string
+	// Empty for a lone property name
+	Value  string `json:",omitempty"`
+	Negate bool
+	// Whether it is a property of strings, which matches strings with the v
+	// flag
+	Strings bool `json:",omitempty"`
+}
@@ -0,0 +1,25 @@
+package regexp_ast
+
+import "github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+
+// SurrogatePairAt returns the Characters of the surrogate pair starting at
+// elements[i], which is how `😀` is parsed without the `u` flag. If the
+// second half is quantified, as in `😀+`, it returns only the
+// first half. Otherwise it returns nil, nil.
+func SurrogatePairAt(elements []Element, i int) (*Character, *Character) {
+	lead, ok := elements[i].(*Character)
+	if !ok || !unicode_consts.IsLeadSurrogate(lead.Value) || i+1 >= len(elements) {
+		return nil, nil
+	}
+	switch n := elements[i+1].(type) {
+	case *Character:
+		if unicode_consts.IsTrailSurrogate(n.Value) {
+			return lead, n
+		}
+	case *Quantifier:
+		if trail, ok := n.Element.(*Character); ok && unicode_consts.IsTrailSurrogate(trail.Value) {
+			return lead, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,249 @@
+package regexp_ast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Verify checks the invariants of the tree rooted at node and returns every
+// violation found, joined, or nil. The invariants are:
+//
+//   - every child points to its parent and appears only once in the tree,
+//   - every child is allowed where it is, e.g. a Pattern has at least one
+//     alternative and a Quantifier has an element,
+//   - values are in range: code points are in 0..0x10FFFF, quantifiers have
+//     0 <= Min <= Max and class ranges have Min <= Max,
+//   - every Loc has Start <= End and lies within the Loc of its parent, and
+//     siblings don't overlap and are in order.
+//   - every backreference is resolved to a group that lists it among its
+//     References, and the other way around.
+//
+// The Locs of an edited tree are stale until it is laid out again, so run
+// printer.Layout before Verify if the tree was edited. A nil node is an
+// error.
+func Verify(node Node) error {
+	if isNil(node) {
+		return errors.New("regexp_ast: the node is nil")
+	}
+	v := &verifier{seen: map[Node]bool{}}
+	v.node(node, node.GetParent())
+	return errors.Join(v.errs...)
+}
+
+type verifier struct {
+	seen map[Node]bool
+	errs []error
+}
+
+func (v *verifier) errorf(node Node, format string, args ...any) {
+	loc := node.GetLoc()
+	v.errs = append(v.errs, fmt.Errorf("regexp_ast: %T @%d-%d: %s", node, loc.Start, loc.End, fmt.Sprintf(format, args...)))
+}
+
+func (v *verifier) node(node Node, parent Node) {
+	if v.seen[node] {
+		v.errorf(node, "appears more than once in the tree")
+		return
+	}
+	v.seen[node] = true
+	if node.GetParent() != parent {
+		v.errorf(node, "has parent %T, want %T", node.GetParent(), parent)
+	}
+	loc := node.GetLoc()
+	if loc.Start > loc.End {
+		v.errorf(node, "starts after its end")
+	}
+
+	switch n := node.(type) {
+	case *Pattern:
+		v.alternatives(n, n.Alternatives)
+	case *Alternative:
+		var children []Node
+		for _, el := range n.Elements {
+			if child, ok := el.(Node); ok {
+				children = append(children, child)
+			} else {
+				v.errorf(n, "has an element %T that is not a node", el)
+			}
+		}
+		v.children(n, children)
+	case *Character:
+		if n.Value < 0 || n.Value > 0x10ffff {
+			v.errorf(n, "has value %d out of the code point range", n.Value)
+		}
+	case *CharacterClass:
+		var children []Node
+		for _, el := range n.Elements {
+			switch el.(type) {
+			case *CharacterClass, *ExpressionCharacterClass, *ClassStringDisjunction:
+				if !n.UnicodeSets {
+					v.errorf(n, "has an element %T without UnicodeSets", el)
+				}
+			}
+			if child, ok := el.(Node); ok {
+				children = append(children, child)
+			} else {
+				v.errorf(n, "has an element %T that is not a node", el)
+			}
+		}
+		v.children(n, children)
+	case *AnyCharacterSet:
+	case *Quantifier:
+		if n.Min < 0 || n.Min > n.Max {
+			v.errorf(n, "has invalid bounds {%d,%s}", n.Min, bound(n.Max))
+		}
+		child, ok := n.Element.(Node)
+		if !ok {
+			v.errorf(n, "has no element")
+			return
+		}
+		v.children(n, []Node{child})
+	case *CharacterClassRange:
+		if n.Min == nil || n.Max == nil {
+			v.errorf(n, "is missing an endpoint")
+			return
+		}
+		if n.Min.Value > n.Max.Value {
+			v.errorf(n, "is out of order: %U > %U", n.Min.Value, n.Max.Value)
+		}
+		v.children(n, []Node{n.Min, n.Max})
+	case *Group:
+		v.alternatives(n, n.Alternatives)
+	case *CapturingGroup:
+		for _, ref := range n.References {
+			if ref == nil || ref.Resolved != n {
+				v.errorf(n, "has a reference that isn't resolved to it")
+			}
+		}
+		v.alternatives(n, n.Alternatives)
+	case *LookaroundAssertion:
+		if n.Kind != Lookahead && n.Kind != Lookbehind {
+			v.errorf(n, "has unknown kind %q", n.Kind)
+		}
+		v.alternatives(n, n.Alternatives)
+	case *EdgeAssertion:
+		if n.Kind != EdgeStart && n.Kind != EdgeEnd {
+			v.errorf(n, "has unknown kind %q", n.Kind)
+		}
+	case *WordBoundaryAssertion:
+	case *Backreference:
+		if (n.Number > 0) == (n.Name != "") {
+			v.errorf(n, "must have either a number or a name")
+		}
+		if n.Resolved == nil {
+			v.errorf(n, "is not resolved")
+		} else if indexOf(n.Resolved.References, n) < 0 {
+			v.errorf(n, "is not a reference of the group it is resolved to")
+		}
+	case *EscapeCharacterSet:
+		if n.Kind != EscapeDigit && n.Kind != EscapeSpace && n.Kind != EscapeWord {
+			v.errorf(n, "has unknown kind %q", n.Kind)
+		}
+	case *UnicodePropertyCharacterSet:
+		if n.Key == "" {
+			v.errorf(n, "has no key")
+		}
+	case *ExpressionCharacterClass:
+		child, ok := n.Expression.(Node)
+		if !ok || isNil(child) {
+			v.errorf(n, "has no expression")
+			return
+		}
+		v.children(n, []Node{child})
+	case *ClassIntersection:
+		v.operands(n, n.Left, n.Right)
+	case *ClassSubtraction:
+		v.operands(n, n.Left, n.Right)
+	case *ClassStringDisjunction:
+		var children []Node
+		for _, alt := range n.Alternatives {
+			children = append(children, alt)
+		}
+		v.children(n, children)
+	case *StringAlternative:
+		var children []Node
+		for _, c := range n.Elements {
+			children = append(children, c)
+		}
+		v.children(n, children)
+	default:
+		v.errorf(node, "is an unknown node")
+	}
+}
+
+// alternatives verifies the alternatives of a Pattern, a group or a
+// lookaround, of which there must be at least one.
+func (v *verifier) alternatives(parent Node, alts []*Alternative) {
+	if len(alts) == 0 {
+		v.errorf(parent, "has no alternatives")
+	}
+	var children []Node
+	for _, alt := range alts {
+		if alt != nil {
+			children = append(children, alt)
+		} else {
+			v.errorf(parent, "has a nil alternative")
+		}
+	}
+	v.children(parent, children)
+}
+
+// operands verifies the operands of a ClassIntersection or a
+// ClassSubtraction. Only the left operand may be an expression, and only of
+// the same kind as its parent.
+func (v *verifier) operands(parent Node, left, right ClassSetOperand) {
+	l, lok := left.(Node)
+	r, rok := right.(Node)
+	if !lok || !rok || isNil(l) || isNil(r) {
+		v.errorf(parent, "is missing an operand")
+		return
+	}
+	if _, ok := left.(ClassSetExpression); ok && reflect.TypeOf(left) != reflect.TypeOf(parent) {
+		v.errorf(parent, "has a left operand %T of another operation", left)
+	}
+	if _, ok := right.(ClassSetExpression); ok {
+		v.errorf(parent, "has an expression %T as its right operand", right)
+	}
+	v.children(parent, []Node{l, r})
+}
+
+// children verifies children and checks that their Locs are in order within
+// the Loc of parent.
+func (v *verifier) children(parent Node, children []Node) {
+	ploc := parent.GetLoc()
+	prevEnd := ploc.Start
+	for _, child := range children {
+		if isNil(child) {
+			v.errorf(parent, "has a nil %T", child)
+			continue
+		}
+		if v.seen[child] {
+			v.node(child, parent)
+			continue
+		}
+		loc := child.GetLoc()
+		if loc.Start < prevEnd || loc.End > ploc.End {
+			v.errorf(child, "is not within %T @%d-%d after its previous sibling", parent, ploc.Start, ploc.End)
+		}
+		prevEnd = loc.End
+		v.node(child, parent)
+	}
+}
+
+// isNil returns whether node is nil or a nil pointer of a node type.
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	rv := reflect.ValueOf(node)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+func bound(max int) string {
+	if max == math.MaxInt {
+		return ""
+	}
+	return fmt.Sprint(max)
+}
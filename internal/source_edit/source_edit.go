@@ -0,0 +1,373 @@
+// Package source_edit turns changes to a parsed pattern into text edits of
+// its source, so that a rewrite keeps the formatting of everything it didn't
+// touch, e.g. `\x41` stays `\x41` when an unrelated character is replaced.
+//
+// The usual flow is to clone the parsed pattern, change the clone with
+// regexp_ast.Replace, Remove, InsertBefore and InsertAfter or by setting
+// fields, and pass both trees to Diff without laying out the clone. Nodes
+// that are left untouched keep the Locs of the source, which is how Diff
+// finds them; new and changed nodes are printed.
+package source_edit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/unicode_consts"
+)
+
+// TextEdit replaces the range [Start, End) of a source, in UTF-16 code units
+// like Loc, with NewText.
+type TextEdit struct {
+	Start   int
+	End     int
+	NewText string
+}
+
+// Diff returns the edits that turn source, which original was parsed from
+// with UTF-16 Locs and flags, into a source of edited. The edits are sorted
+// and don't overlap.
+//
+// Only the parts of the source whose nodes changed are replaced. If the
+// edited source wouldn't parse back into edited, e.g. because a printed
+// character would merge with the text around it, the whole source is
+// replaced instead.
+func Diff(source string, original *regexp_ast.Pattern, edited *regexp_ast.Pattern, flags regexp_ast.Flags) []TextEdit {
+	u := flags.Unicode || flags.UnicodeSets
+	d := &differ{source: source, c: offset.NewConverter(source), u: u, originals: map[key]regexp_ast.Node{}}
+	regexp_ast.Inspect(original, func(n regexp_ast.Node) bool {
+		d.originals[keyOf(n)] = n
+		return true
+	})
+	if o, ok := d.original(edited); ok {
+		d.diff(edited, o)
+	} else {
+		d.replace(original.Loc, printer.Print(edited, u))
+	}
+
+	sort.Slice(d.edits, func(i, j int) bool {
+		return d.edits[i].Start < d.edits[j].Start
+	})
+	result, err := Apply(source, d.edits)
+	if err == nil {
+		p := parser.NewParserWithOptions(result, flags.Unicode, parser.Options{UnicodeSets: flags.UnicodeSets})
+		if parsed, err := p.ParsePattern(); err == nil && equal(parsed, edited) {
+			return d.edits
+		}
+	}
+	return []TextEdit{{Start: 0, End: d.c.Len(offset.UTF16), NewText: printer.Print(edited, u)}}
+}
+
+// Apply returns source with edits applied. The edits may come in any order,
+// but must not overlap.
+func Apply(source string, edits []TextEdit) (string, error) {
+	sorted := make([]TextEdit, len(edits))
+	copy(sorted, edits)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	c := offset.NewConverter(source)
+	var sb strings.Builder
+	last := 0
+	for i, e := range sorted {
+		if e.Start > e.End || e.End > c.Len(offset.UTF16) {
+			return "", fmt.Errorf("source_edit: edit %d-%d is out of range", e.Start, e.End)
+		}
+		if i > 0 && e.Start < sorted[i-1].End {
+			return "", fmt.Errorf("source_edit: edits %d-%d and %d-%d overlap", sorted[i-1].Start, sorted[i-1].End, e.Start, e.End)
+		}
+		start := c.Convert(e.Start, offset.UTF16, offset.UTF8)
+		sb.WriteString(source[last:start])
+		sb.WriteString(e.NewText)
+		last = c.ConvertEnd(e.End, offset.UTF16, offset.UTF8)
+	}
+	sb.WriteString(source[last:])
+	return sb.String(), nil
+}
+
+// key identifies an original node. Nodes of different types can share a Loc,
+// e.g. an alternative and its only element.
+type key struct {
+	typ   string
+	start int
+	end   int
+}
+
+func keyOf(n regexp_ast.Node) key {
+	loc := n.GetLoc()
+	return key{fmt.Sprintf("%T", n), loc.Start, loc.End}
+}
+
+type differ struct {
+	source    string
+	c         *offset.Converter
+	u         bool
+	originals map[key]regexp_ast.Node
+	edits     []TextEdit
+}
+
+// original returns the original node that n was cloned from, if any.
+func (d *differ) original(n regexp_ast.Node) (regexp_ast.Node, bool) {
+	o, ok := d.originals[keyOf(n)]
+	return o, ok
+}
+
+// replace adds an edit of loc, unless text is already there.
+func (d *differ) replace(loc regexp_ast.Loc, text string) {
+	if d.source[d.c.Convert(loc.Start, offset.UTF16, offset.UTF8):d.c.ConvertEnd(loc.End, offset.UTF16, offset.UTF8)] == text {
+		return
+	}
+	d.edits = append(d.edits, TextEdit{Start: loc.Start, End: loc.End, NewText: text})
+}
+
+// diff adds the edits that turn the source of o into a source of n.
+func (d *differ) diff(n regexp_ast.Node, o regexp_ast.Node) {
+	if same(n, o) {
+		return
+	}
+	loc := o.GetLoc()
+	switch n := n.(type) {
+	case *regexp_ast.Pattern:
+		o := o.(*regexp_ast.Pattern)
+		d.sequence(nodes(o.Alternatives), nodes(n.Alternatives), loc, "|")
+	case *regexp_ast.Alternative:
+		o := o.(*regexp_ast.Alternative)
+		d.sequence(nodes(o.Elements), nodes(n.Elements), loc, "")
+	case *regexp_ast.Group:
+		d.alternatives(n.Alternatives, o.(*regexp_ast.Group).Alternatives)
+	case *regexp_ast.CapturingGroup:
+		o := o.(*regexp_ast.CapturingGroup)
+		if n.Name != o.Name {
+			d.replace(loc, d.print(n))
+			return
+		}
+		d.alternatives(n.Alternatives, o.Alternatives)
+	case *regexp_ast.LookaroundAssertion:
+		o := o.(*regexp_ast.LookaroundAssertion)
+		if n.Kind != o.Kind || n.Negate != o.Negate {
+			d.replace(loc, d.print(n))
+			return
+		}
+		d.alternatives(n.Alternatives, o.Alternatives)
+	case *regexp_ast.CharacterClass:
+		o := o.(*regexp_ast.CharacterClass)
+		if n.Negate != o.Negate || n.UnicodeSets != o.UnicodeSets {
+			d.replace(loc, d.print(n))
+			return
+		}
+		inner := regexp_ast.Loc{Start: loc.Start + 1, End: loc.End - 1}
+		if n.Negate {
+			inner.Start++
+		}
+		d.sequence(nodes(o.Elements), nodes(n.Elements), inner, "")
+	case *regexp_ast.ExpressionCharacterClass:
+		o := o.(*regexp_ast.ExpressionCharacterClass)
+		if n.Negate != o.Negate {
+			d.replace(loc, d.print(n))
+			return
+		}
+		d.child(n.Expression.(regexp_ast.Node), o.Expression.(regexp_ast.Node))
+	case *regexp_ast.ClassIntersection:
+		o := o.(*regexp_ast.ClassIntersection)
+		d.child(n.Left.(regexp_ast.Node), o.Left.(regexp_ast.Node))
+		d.child(n.Right.(regexp_ast.Node), o.Right.(regexp_ast.Node))
+	case *regexp_ast.ClassSubtraction:
+		o := o.(*regexp_ast.ClassSubtraction)
+		d.child(n.Left.(regexp_ast.Node), o.Left.(regexp_ast.Node))
+		d.child(n.Right.(regexp_ast.Node), o.Right.(regexp_ast.Node))
+	case *regexp_ast.ClassStringDisjunction:
+		o := o.(*regexp_ast.ClassStringDisjunction)
+		// \q{ is 3 code units
+		d.sequence(nodes(o.Alternatives), nodes(n.Alternatives), regexp_ast.Loc{Start: loc.Start + 3, End: loc.End - 1}, "|")
+	case *regexp_ast.StringAlternative:
+		o := o.(*regexp_ast.StringAlternative)
+		d.sequence(nodes(o.Elements), nodes(n.Elements), loc, "")
+	case *regexp_ast.Quantifier:
+		o := o.(*regexp_ast.Quantifier)
+		oel := o.Element.(regexp_ast.Node)
+		if n.Min != o.Min || n.Max != o.Max || n.Greety != o.Greety {
+			text := printer.Print(n, d.u)
+			d.replace(regexp_ast.Loc{Start: oel.GetLoc().End, End: loc.End}, text[len(printer.Print(n.Element.(regexp_ast.Node), d.u)):])
+		}
+		d.child(n.Element.(regexp_ast.Node), oel)
+	case *regexp_ast.CharacterClassRange:
+		o := o.(*regexp_ast.CharacterClassRange)
+		d.child(n.Min, o.Min)
+		d.child(n.Max, o.Max)
+	default:
+		d.replace(loc, d.print(n))
+	}
+}
+
+// alternatives adds the edits that turn the alternatives olds of a group or
+// a lookaround into news. The alternatives span everything between the
+// opening of the group and its `)`.
+func (d *differ) alternatives(news []*regexp_ast.Alternative, olds []*regexp_ast.Alternative) {
+	inner := regexp_ast.Loc{Start: olds[0].Loc.Start, End: olds[len(olds)-1].Loc.End}
+	d.sequence(nodes(olds), nodes(news), inner, "|")
+}
+
+// child adds the edits that turn the source of the original child o into a
+// source of n.
+func (d *differ) child(n regexp_ast.Node, o regexp_ast.Node) {
+	if keyOf(n) == keyOf(o) {
+		d.diff(n, o)
+	} else {
+		d.replace(o.GetLoc(), d.print(n))
+	}
+}
+
+// sequence adds the edits that turn the children olds, which are separated
+// by sep within the range loc, into news. The children of news that were
+// cloned from olds, in the same order, are kept; the text between them is
+// replaced.
+func (d *differ) sequence(olds []regexp_ast.Node, news []regexp_ast.Node, loc regexp_ast.Loc, sep string) {
+	index := map[key]int{}
+	for i, o := range olds {
+		index[keyOf(o)] = i
+	}
+	// The positions of the kept children in news and olds
+	type anchor struct{ n, o int }
+	anchors := []anchor{{-1, -1}}
+	for i, n := range news {
+		if j, ok := index[keyOf(n)]; ok && j > anchors[len(anchors)-1].o {
+			anchors = append(anchors, anchor{i, j})
+		}
+	}
+	anchors = append(anchors, anchor{len(news), len(olds)})
+
+	for k := 1; k < len(anchors); k++ {
+		prev, next := anchors[k-1], anchors[k]
+		if next.o < len(olds) {
+			d.diff(news[next.n], olds[next.o])
+		}
+		if next.n-prev.n == 1 && next.o-prev.o == 1 {
+			continue
+		}
+		gap := loc
+		var parts []string
+		if prev.o >= 0 {
+			gap.Start = olds[prev.o].GetLoc().End
+			parts = append(parts, "")
+		}
+		for _, n := range news[prev.n+1 : next.n] {
+			parts = append(parts, d.print(n))
+		}
+		if next.o < len(olds) {
+			gap.End = olds[next.o].GetLoc().Start
+			parts = append(parts, "")
+		}
+		d.replace(gap, strings.Join(parts, sep))
+	}
+}
+
+// print returns the source of n where it is in the edited tree. A `-` or a
+// `^` in a class is escaped, since it may be read as a range or a negation
+// next to the text around it.
+func (d *differ) print(n regexp_ast.Node) string {
+	if c, ok := n.(*regexp_ast.Character); ok {
+		if _, ok := c.Parent.(*regexp_ast.CharacterClass); ok {
+			switch c.Value {
+			case unicode_consts.HyphenMinus:
+				return `\-`
+			case unicode_consts.CircumflexAccent:
+				return `\^`
+			}
+		}
+	}
+	return printer.Print(n, d.u)
+}
+
+func nodes[T any](list []T) []regexp_ast.Node {
+	ns := make([]regexp_ast.Node, len(list))
+	for i, n := range list {
+		ns[i] = any(n).(regexp_ast.Node)
+	}
+	return ns
+}
+
+// same returns whether n is an unchanged copy of o, with the same Locs.
+func same(n regexp_ast.Node, o regexp_ast.Node) bool {
+	return keyOf(n) == keyOf(o) && sameChildren(n, o, same)
+}
+
+// equal returns whether a and b are structurally equal, ignoring Locs.
+func equal(a regexp_ast.Node, b regexp_ast.Node) bool {
+	return fmt.Sprintf("%T", a) == fmt.Sprintf("%T", b) && sameChildren(a, b, equal)
+}
+
+// sameChildren returns whether the nodes a and b of the same type have the
+// same values, and children for which eq holds.
+func sameChildren(a regexp_ast.Node, b regexp_ast.Node, eq func(a, b regexp_ast.Node) bool) bool {
+	all := func(as []regexp_ast.Node, bs []regexp_ast.Node) bool {
+		if len(as) != len(bs) {
+			return false
+		}
+		for i := range as {
+			if !eq(as[i], bs[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	switch a := a.(type) {
+	case *regexp_ast.Pattern:
+		return all(nodes(a.Alternatives), nodes(b.(*regexp_ast.Pattern).Alternatives))
+	case *regexp_ast.Alternative:
+		return all(nodes(a.Elements), nodes(b.(*regexp_ast.Alternative).Elements))
+	case *regexp_ast.Group:
+		return all(nodes(a.Alternatives), nodes(b.(*regexp_ast.Group).Alternatives))
+	case *regexp_ast.CapturingGroup:
+		b := b.(*regexp_ast.CapturingGroup)
+		return a.Name == b.Name && all(nodes(a.Alternatives), nodes(b.Alternatives))
+	case *regexp_ast.LookaroundAssertion:
+		b := b.(*regexp_ast.LookaroundAssertion)
+		return a.Kind == b.Kind && a.Negate == b.Negate && all(nodes(a.Alternatives), nodes(b.Alternatives))
+	case *regexp_ast.CharacterClass:
+		b := b.(*regexp_ast.CharacterClass)
+		return a.Negate == b.Negate && a.UnicodeSets == b.UnicodeSets && all(nodes(a.Elements), nodes(b.Elements))
+	case *regexp_ast.ExpressionCharacterClass:
+		b := b.(*regexp_ast.ExpressionCharacterClass)
+		return a.Negate == b.Negate && eq(a.Expression.(regexp_ast.Node), b.Expression.(regexp_ast.Node))
+	case *regexp_ast.ClassIntersection:
+		b := b.(*regexp_ast.ClassIntersection)
+		return eq(a.Left.(regexp_ast.Node), b.Left.(regexp_ast.Node)) && eq(a.Right.(regexp_ast.Node), b.Right.(regexp_ast.Node))
+	case *regexp_ast.ClassSubtraction:
+		b := b.(*regexp_ast.ClassSubtraction)
+		return eq(a.Left.(regexp_ast.Node), b.Left.(regexp_ast.Node)) && eq(a.Right.(regexp_ast.Node), b.Right.(regexp_ast.Node))
+	case *regexp_ast.ClassStringDisjunction:
+		return all(nodes(a.Alternatives), nodes(b.(*regexp_ast.ClassStringDisjunction).Alternatives))
+	case *regexp_ast.StringAlternative:
+		return all(nodes(a.Elements), nodes(b.(*regexp_ast.StringAlternative).Elements))
+	case *regexp_ast.CharacterClassRange:
+		b := b.(*regexp_ast.CharacterClassRange)
+		return eq(a.Min, b.Min) && eq(a.Max, b.Max)
+	case *regexp_ast.Quantifier:
+		b := b.(*regexp_ast.Quantifier)
+		return a.Min == b.Min && a.Max == b.Max && a.Greety == b.Greety && eq(a.Element.(regexp_ast.Node), b.Element.(regexp_ast.Node))
+	case *regexp_ast.Character:
+		return a.Value == b.(*regexp_ast.Character).Value
+	case *regexp_ast.AnyCharacterSet:
+		return true
+	case *regexp_ast.EscapeCharacterSet:
+		b := b.(*regexp_ast.EscapeCharacterSet)
+		return a.Kind == b.Kind && a.Negate == b.Negate
+	case *regexp_ast.UnicodePropertyCharacterSet:
+		b := b.(*regexp_ast.UnicodePropertyCharacterSet)
+		return a.Key == b.Key && a.Value == b.Value && a.Negate == b.Negate && a.Strings == b.Strings
+	case *regexp_ast.EdgeAssertion:
+		return a.Kind == b.(*regexp_ast.EdgeAssertion).Kind
+	case *regexp_ast.WordBoundaryAssertion:
+		return a.Negate == b.(*regexp_ast.WordBoundaryAssertion).Negate
+	case *regexp_ast.Backreference:
+		b := b.(*regexp_ast.Backreference)
+		return a.Number == b.Number && a.Name == b.Name
+	default:
+		return false
+	}
+}
@@ -0,0 +1,256 @@
+package source_edit_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/builder"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/source_edit"
+)
+
+func parse(t *testing.T, source string, flags string) (*regexp_ast.Pattern, regexp_ast.Flags) {
+	t.Helper()
+	f, err := parser.ParseFlags(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewParserWithOptions(source, f.Unicode, parser.Options{UnicodeSets: f.UnicodeSets})
+	pattern, err := p.ParsePattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pattern, f
+}
+
+func TestDiff(t *testing.T) {
+	b := builder.New(true)
+	tests := []struct {
+		name       string
+		input      string
+		inputFlags string
+		inputEdit  func(pattern *regexp_ast.Pattern) error
+		wantEdits  []source_edit.TextEdit
+		wantOutput string
+	}{
+		{
+			name:  "変更がなければ編集もない",
+			input: `\x41|b`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return nil
+			},
+			wantEdits:  nil,
+			wantOutput: `\x41|b`,
+		},
+		{
+			name:  "文字の置き換えは他の書き方を変えない",
+			input: `\x41bC`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Replace(pattern.Alternatives[0].Elements[1].(regexp_ast.Node), b.Plus(b.Any()))
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 4, End: 5, NewText: ".+"}},
+			wantOutput: `\x41.+C`,
+		},
+		{
+			name:  "文字の値の変更",
+			input: `[\x41-\x5A]`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				r := pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass).Elements[0].(*regexp_ast.CharacterClassRange)
+				r.Max.Value = 'z'
+				return nil
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 6, End: 10, NewText: "z"}},
+			wantOutput: `[\x41-z]`,
+		},
+		{
+			name:  "選択肢の削除",
+			input: `a|\x62|c`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Remove(pattern.Alternatives[1])
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 1, End: 7, NewText: "|"}},
+			wantOutput: `a|c`,
+		},
+		{
+			name:  "最初の選択肢の削除",
+			input: `a|\x62`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Remove(pattern.Alternatives[0])
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 0, End: 2, NewText: ""}},
+			wantOutput: `\x62`,
+		},
+		{
+			name:  "選択肢の挿入",
+			input: `\x61|c`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.InsertAfter(pattern.Alternatives[1], b.Alt(b.Char('d')))
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 6, End: 6, NewText: "|d"}},
+			wantOutput: `\x61|c|d`,
+		},
+		{
+			name:  "量指定子の変更",
+			input: `\x61{2}`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				pattern.Alternatives[0].Elements[0].(*regexp_ast.Quantifier).Max = 3
+				return nil
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 4, End: 7, NewText: "{2,3}"}},
+			wantOutput: `\x61{2,3}`,
+		},
+		{
+			name:  "文字クラスへの - の挿入はエスケープする",
+			input: `[a\x62]`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				cc := pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass)
+				return regexp_ast.InsertAfter(cc.Elements[0].(regexp_ast.Node), b.Char('-'))
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 2, End: 2, NewText: `\-`}},
+			wantOutput: `[a\-\x62]`,
+		},
+		{
+			name:  "否定の変更は文字クラス全体を書き直す",
+			input: `[a]`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass).Negate = true
+				return nil
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 0, End: 3, NewText: "[^a]"}},
+			wantOutput: `[^a]`,
+		},
+		{
+			name:  "前後と繋がってしまう場合は全体を書き直す",
+			input: `\0a`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Replace(pattern.Alternatives[0].Elements[1].(regexp_ast.Node), b.Char('1'))
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 0, End: 3, NewText: `\x001`}},
+			wantOutput: `\x001`,
+		},
+		{
+			name:       "UTF-16 のオフセット",
+			input:      `😀a`,
+			inputFlags: "u",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Replace(pattern.Alternatives[0].Elements[1].(regexp_ast.Node), b.Char('b'))
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 2, End: 3, NewText: "b"}},
+			wantOutput: `😀b`,
+		},
+		{
+			name:  "グループの中の変更",
+			input: `(?:\x61|b)c`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				return regexp_ast.Replace(pattern.Alternatives[0].Elements[0].(*regexp_ast.Group).Alternatives[1].Elements[0].(regexp_ast.Node), b.Char('d'))
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 8, End: 9, NewText: "d"}},
+			wantOutput: `(?:\x61|d)c`,
+		},
+		{
+			name:  "後読みの否定の変更は後読み全体を書き直す",
+			input: `(?<=\x61)b`,
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				pattern.Alternatives[0].Elements[0].(*regexp_ast.LookaroundAssertion).Negate = true
+				return nil
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 0, End: 9, NewText: "(?<!a)"}},
+			wantOutput: `(?<!a)b`,
+		},
+		{
+			name:       "エスケープと文字クラスエスケープは書き方を変えない",
+			input:      `\p{Letter}\d|\x61`,
+			inputFlags: "u",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				pattern.Alternatives[1].Elements[0].(*regexp_ast.Character).Value = 'b'
+				return nil
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 13, End: 17, NewText: "b"}},
+			wantOutput: `\p{Letter}\d|b`,
+		},
+		{
+			name:       "v フラグの入れ子の文字クラス",
+			input:      `[[\x61]b]`,
+			inputFlags: "v",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				pattern.Alternatives[0].Elements[0].(*regexp_ast.CharacterClass).Elements[1].(*regexp_ast.Character).Value = 'c'
+				return nil
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 7, End: 8, NewText: "c"}},
+			wantOutput: `[[\x61]c]`,
+		},
+		{
+			name:       "集合演算の中の文字列の変更",
+			input:      `[\x61--\q{b|\x63}]`,
+			inputFlags: "v",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				e := pattern.Alternatives[0].Elements[0].(*regexp_ast.ExpressionCharacterClass).Expression.(*regexp_ast.ClassSubtraction)
+				e.Right.(*regexp_ast.ClassStringDisjunction).Alternatives[0].Elements[0].Value = 'd'
+				return nil
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 10, End: 11, NewText: "d"}},
+			wantOutput: `[\x61--\q{d|\x63}]`,
+		},
+		{
+			name:       "集合演算の否定の変更は文字クラス全体を書き直す",
+			input:      `[\x61&&b]`,
+			inputFlags: "v",
+			inputEdit: func(pattern *regexp_ast.Pattern) error {
+				pattern.Alternatives[0].Elements[0].(*regexp_ast.ExpressionCharacterClass).Negate = true
+				return nil
+			},
+			wantEdits:  []source_edit.TextEdit{{Start: 0, End: 9, NewText: "[^a&&b]"}},
+			wantOutput: `[^a&&b]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, flags := parse(t, tt.input, tt.inputFlags)
+			edited := regexp_ast.Clone(original).(*regexp_ast.Pattern)
+			if err := tt.inputEdit(edited); err != nil {
+				t.Fatal(err)
+			}
+			edits := source_edit.Diff(tt.input, original, edited, flags)
+			if !reflect.DeepEqual(edits, tt.wantEdits) {
+				t.Errorf("Unexpected edits, expected %v, actual %v", tt.wantEdits, edits)
+			}
+			o, err := source_edit.Apply(tt.input, edits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o != tt.wantOutput {
+				t.Errorf("Unexpected output, expected %s, actual %s", tt.wantOutput, o)
+			}
+		})
+	}
+}
+
+func TestApplyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputEdits []source_edit.TextEdit
+		wantError  string
+	}{
+		{
+			name:       "重なる編集",
+			inputEdits: []source_edit.TextEdit{{Start: 1, End: 3, NewText: "x"}, {Start: 0, End: 2, NewText: "y"}},
+			wantError:  "source_edit: edits 0-2 and 1-3 overlap",
+		},
+		{
+			name:       "範囲外の編集",
+			inputEdits: []source_edit.TextEdit{{Start: 2, End: 4, NewText: "x"}},
+			wantError:  "source_edit: edit 2-4 is out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := source_edit.Apply("abc", tt.inputEdits)
+			if err == nil || err.Error() != tt.wantError {
+				t.Errorf("Unexpected error, expected %s, actual %v", tt.wantError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Package source_map maps the output of a translator back to the nodes of
+// the pattern it was translated from, so that an error that another engine
+// reports at an offset of the output can be reported at the original Loc.
+package source_map
+
+import "github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+
+// Segment is a range of the output that a node produced.
+type Segment struct {
+	// The byte offsets of the range in the output
+	Start int
+	End   int
+	// The Loc of the node in the original pattern
+	Loc regexp_ast.Loc
+}
+
+// Map is the list of segments of an output. Segments of nested nodes are
+// nested, and a node is added after its children.
+type Map struct {
+	Segments []Segment
+}
+
+// Add appends a segment. It does nothing on a nil Map, so that translators
+// can record segments unconditionally.
+func (m *Map) Add(start int, end int, loc regexp_ast.Loc) {
+	if m != nil {
+		m.Segments = append(m.Segments, Segment{Start: start, End: end, Loc: loc})
+	}
+}
+
+// Lookup returns the Loc of the innermost node whose output contains the
+// byte at off. An empty segment contains its start.
+func (m *Map) Lookup(off int) (regexp_ast.Loc, bool) {
+	found := -1
+	for i, s := range m.Segments {
+		if off < s.Start || off > s.End || (off == s.End && s.Start != s.End) {
+			continue
+		}
+		if found < 0 || s.End-s.Start < m.Segments[found].End-m.Segments[found].Start {
+			found = i
+		}
+	}
+	if found < 0 {
+		return regexp_ast.Loc{}, false
+	}
+	return m.Segments[found].Loc, true
+}
@@ -0,0 +1,39 @@
+package source_map_test
+
+import (
+	"testing"
+
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/source_map"
+)
+
+func TestLookup(t *testing.T) {
+	m := &source_map.Map{}
+	m.Add(0, 1, regexp_ast.Loc{Start: 0, End: 1})
+	m.Add(1, 4, regexp_ast.Loc{Start: 1, End: 2})
+	m.Add(1, 5, regexp_ast.Loc{Start: 1, End: 3})
+	m.Add(6, 6, regexp_ast.Loc{Start: 4, End: 4})
+	m.Add(0, 6, regexp_ast.Loc{Start: 0, End: 4})
+
+	tests := []struct {
+		name      string
+		inputOff  int
+		wantLoc   regexp_ast.Loc
+		wantFound bool
+	}{
+		{name: "最も内側の区間", inputOff: 2, wantLoc: regexp_ast.Loc{Start: 1, End: 2}, wantFound: true},
+		{name: "区間の終わりは含まない", inputOff: 4, wantLoc: regexp_ast.Loc{Start: 1, End: 3}, wantFound: true},
+		{name: "外側の区間", inputOff: 5, wantLoc: regexp_ast.Loc{Start: 0, End: 4}, wantFound: true},
+		{name: "空の区間は始まりを含む", inputOff: 6, wantLoc: regexp_ast.Loc{Start: 4, End: 4}, wantFound: true},
+		{name: "区間の外", inputOff: 7, wantLoc: regexp_ast.Loc{}, wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, found := m.Lookup(tt.inputOff)
+			if loc != tt.wantLoc || found != tt.wantFound {
+				t.Errorf("Unexpected output, expected %v %v, actual %v %v", tt.wantLoc, tt.wantFound, loc, found)
+			}
+		})
+	}
+}
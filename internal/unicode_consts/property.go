@@ -0,0 +1,418 @@
+package unicode_consts
+
+import "unicode"
+
+// The names and values of UnicodePropertyValueExpression, from the tables of
+// https://tc39.es/ecma262/multipage/text-processing.html#sec-runtime-semantics-unicodematchproperty-p
+// and PropertyValueAliases.txt of Unicode 15.1. The first name of each row is
+// the canonical one and the others are its aliases.
+
+var generalCategoryValues = [][]string{
+	{"Cased_Letter", "LC"},
+	{"Close_Punctuation", "Pe"},
+	{"Connector_Punctuation", "Pc"},
+	{"Control", "Cc", "cntrl"},
+	{"Currency_Symbol", "Sc"},
+	{"Dash_Punctuation", "Pd"},
+	{"Decimal_Number", "Nd", "digit"},
+	{"Enclosing_Mark", "Me"},
+	{"Final_Punctuation", "Pf"},
+	{"Format", "Cf"},
+	{"Initial_Punctuation", "Pi"},
+	{"Letter", "L"},
+	{"Letter_Number", "Nl"},
+	{"Line_Separator", "Zl"},
+	{"Lowercase_Letter", "Ll"},
+	{"Mark", "M", "Combining_Mark"},
+	{"Math_Symbol", "Sm"},
+	{"Modifier_Letter", "Lm"},
+	{"Modifier_Symbol", "Sk"},
+	{"Nonspacing_Mark", "Mn"},
+	{"Number", "N"},
+	{"Open_Punctuation", "Ps"},
+	{"Other", "C"},
+	{"Other_Letter", "Lo"},
+	{"Other_Number", "No"},
+	{"Other_Punctuation", "Po"},
+	{"Other_Symbol", "So"},
+	{"Paragraph_Separator", "Zp"},
+	{"Private_Use", "Co"},
+	{"Punctuation", "P", "punct"},
+	{"Separator", "Z"},
+	{"Space_Separator", "Zs"},
+	{"Spacing_Mark", "Mc"},
+	{"Surrogate", "Cs"},
+	{"Symbol", "S"},
+	{"Titlecase_Letter", "Lt"},
+	{"Unassigned", "Cn"},
+	{"Uppercase_Letter", "Lu"},
+}
+
+var scriptValues = [][]string{
+	{"Adlam", "Adlm"},
+	{"Ahom"},
+	{"Anatolian_Hieroglyphs", "Hluw"},
+	{"Arabic", "Arab"},
+	{"Armenian", "Armn"},
+	{"Avestan", "Avst"},
+	{"Balinese", "Bali"},
+	{"Bamum", "Bamu"},
+	{"Bassa_Vah", "Bass"},
+	{"Batak", "Batk"},
+	{"Bengali", "Beng"},
+	{"Bhaiksuki", "Bhks"},
+	{"Bopomofo", "Bopo"},
+	{"Brahmi", "Brah"},
+	{"Braille", "Brai"},
+	{"Buginese", "Bugi"},
+	{"Buhid", "Buhd"},
+	{"Canadian_Aboriginal", "Cans"},
+	{"Carian", "Cari"},
+	{"Caucasian_Albanian", "Aghb"},
+	{"Chakma", "Cakm"},
+	{"Cham"},
+	{"Cherokee", "Cher"},
+	{"Chorasmian", "Chrs"},
+	{"Common", "Zyyy"},
+	{"Coptic", "Copt", "Qaac"},
+	{"Cuneiform", "Xsux"},
+	{"Cypriot", "Cprt"},
+	{"Cypro_Minoan", "Cpmn"},
+	{"Cyrillic", "Cyrl"},
+	{"Deseret", "Dsrt"},
+	{"Devanagari", "Deva"},
+	{"Dives_Akuru", "Diak"},
+	{"Dogra", "Dogr"},
+	{"Duployan", "Dupl"},
+	{"Egyptian_Hieroglyphs", "Egyp"},
+	{"Elbasan", "Elba"},
+	{"Elymaic", "Elym"},
+	{"Ethiopic", "Ethi"},
+	{"Georgian", "Geor"},
+	{"Glagolitic", "Glag"},
+	{"Gothic", "Goth"},
+	{"Grantha", "Gran"},
+	{"Greek", "Grek"},
+	{"Gujarati", "Gujr"},
+	{"Gunjala_Gondi", "Gong"},
+	{"Gurmukhi", "Guru"},
+	{"Han", "Hani"},
+	{"Hangul", "Hang"},
+	{"Hanifi_Rohingya", "Rohg"},
+	{"Hanunoo", "Hano"},
+	{"Hatran", "Hatr"},
+	{"Hebrew", "Hebr"},
+	{"Hiragana", "Hira"},
+	{"Imperial_Aramaic", "Armi"},
+	{"Inherited", "Zinh", "Qaai"},
+	{"Inscriptional_Pahlavi", "Phli"},
+	{"Inscriptional_Parthian", "Prti"},
+	{"Javanese", "Java"},
+	{"Kaithi", "Kthi"},
+	{"Kannada", "Knda"},
+	{"Katakana", "Kana"},
+	{"Katakana_Or_Hiragana", "Hrkt"},
+	{"Kawi"},
+	{"Kayah_Li", "Kali"},
+	{"Kharoshthi", "Khar"},
+	{"Khitan_Small_Script", "Kits"},
+	{"Khmer", "Khmr"},
+	{"Khojki", "Khoj"},
+	{"Khudawadi", "Sind"},
+	{"Lao", "Laoo"},
+	{"Latin", "Latn"},
+	{"Lepcha", "Lepc"},
+	{"Limbu", "Limb"},
+	{"Linear_A", "Lina"},
+	{"Linear_B", "Linb"},
+	{"Lisu"},
+	{"Lycian", "Lyci"},
+	{"Lydian", "Lydi"},
+	{"Mahajani", "Mahj"},
+	{"Makasar", "Maka"},
+	{"Malayalam", "Mlym"},
+	{"Mandaic", "Mand"},
+	{"Manichaean", "Mani"},
+	{"Marchen", "Marc"},
+	{"Masaram_Gondi", "Gonm"},
+	{"Medefaidrin", "Medf"},
+	{"Meetei_Mayek", "Mtei"},
+	{"Mende_Kikakui", "Mend"},
+	{"Meroitic_Cursive", "Merc"},
+	{"Meroitic_Hieroglyphs", "Mero"},
+	{"Miao", "Plrd"},
+	{"Modi"},
+	{"Mongolian", "Mong"},
+	{"Mro", "Mroo"},
+	{"Multani", "Mult"},
+	{"Myanmar", "Mymr"},
+	{"Nabataean", "Nbat"},
+	{"Nag_Mundari", "Nagm"},
+	{"Nandinagari", "Nand"},
+	{"New_Tai_Lue", "Talu"},
+	{"Newa"},
+	{"Nko", "Nkoo"},
+	{"Nushu", "Nshu"},
+	{"Nyiakeng_Puachue_Hmong", "Hmnp"},
+	{"Ogham", "Ogam"},
+	{"Ol_Chiki", "Olck"},
+	{"Old_Hungarian", "Hung"},
+	{"Old_Italic", "Ital"},
+	{"Old_North_Arabian", "Narb"},
+	{"Old_Permic", "Perm"},
+	{"Old_Persian", "Xpeo"},
+	{"Old_Sogdian", "Sogo"},
+	{"Old_South_Arabian", "Sarb"},
+	{"Old_Turkic", "Orkh"},
+	{"Old_Uyghur", "Ougr"},
+	{"Oriya", "Orya"},
+	{"Osage", "Osge"},
+	{"Osmanya", "Osma"},
+	{"Pahawh_Hmong", "Hmng"},
+	{"Palmyrene", "Palm"},
+	{"Pau_Cin_Hau", "Pauc"},
+	{"Phags_Pa", "Phag"},
+	{"Phoenician", "Phnx"},
+	{"Psalter_Pahlavi", "Phlp"},
+	{"Rejang", "Rjng"},
+	{"Runic", "Runr"},
+	{"Samaritan", "Samr"},
+	{"Saurashtra", "Saur"},
+	{"Sharada", "Shrd"},
+	{"Shavian", "Shaw"},
+	{"Siddham", "Sidd"},
+	{"SignWriting", "Sgnw"},
+	{"Sinhala", "Sinh"},
+	{"Sogdian", "Sogd"},
+	{"Sora_Sompeng", "Sora"},
+	{"Soyombo", "Soyo"},
+	{"Sundanese", "Sund"},
+	{"Syloti_Nagri", "Sylo"},
+	{"Syriac", "Syrc"},
+	{"Tagalog", "Tglg"},
+	{"Tagbanwa", "Tagb"},
+	{"Tai_Le", "Tale"},
+	{"Tai_Tham", "Lana"},
+	{"Tai_Viet", "Tavt"},
+	{"Takri", "Takr"},
+	{"Tamil", "Taml"},
+	{"Tangsa", "Tnsa"},
+	{"Tangut", "Tang"},
+	{"Telugu", "Telu"},
+	{"Thaana", "Thaa"},
+	{"Thai"},
+	{"Tibetan", "Tibt"},
+	{"Tifinagh", "Tfng"},
+	{"Tirhuta", "Tirh"},
+	{"Toto"},
+	{"Ugaritic", "Ugar"},
+	{"Unknown", "Zzzz"},
+	{"Vai", "Vaii"},
+	{"Vithkuqi", "Vith"},
+	{"Wancho", "Wcho"},
+	{"Warang_Citi", "Wara"},
+	{"Yezidi", "Yezi"},
+	{"Yi", "Yiii"},
+	{"Zanabazar_Square", "Zanb"},
+}
+
+var binaryProperties = [][]string{
+	{"ASCII"},
+	{"ASCII_Hex_Digit", "AHex"},
+	{"Alphabetic", "Alpha"},
+	{"Any"},
+	{"Assigned"},
+	{"Bidi_Control", "Bidi_C"},
+	{"Bidi_Mirrored", "Bidi_M"},
+	{"Case_Ignorable", "CI"},
+	{"Cased"},
+	{"Changes_When_Casefolded", "CWCF"},
+	{"Changes_When_Casemapped", "CWCM"},
+	{"Changes_When_Lowercased", "CWL"},
+	{"Changes_When_NFKC_Casefolded", "CWKCF"},
+	{"Changes_When_Titlecased", "CWT"},
+	{"Changes_When_Uppercased", "CWU"},
+	{"Dash"},
+	{"Default_Ignorable_Code_Point", "DI"},
+	{"Deprecated", "Dep"},
+	{"Diacritic", "Dia"},
+	{"Emoji"},
+	{"Emoji_Component", "EComp"},
+	{"Emoji_Modifier", "EMod"},
+	{"Emoji_Modifier_Base", "EBase"},
+	{"Emoji_Presentation", "EPres"},
+	{"Extended_Pictographic", "ExtPict"},
+	{"Extender", "Ext"},
+	{"Grapheme_Base", "Gr_Base"},
+	{"Grapheme_Extend", "Gr_Ext"},
+	{"Hex_Digit", "Hex"},
+	{"IDS_Binary_Operator", "IDSB"},
+	{"IDS_Trinary_Operator", "IDST"},
+	{"ID_Continue", "IDC"},
+	{"ID_Start", "IDS"},
+	{"Ideographic", "Ideo"},
+	{"Join_Control", "Join_C"},
+	{"Logical_Order_Exception", "LOE"},
+	{"Lowercase", "Lower"},
+	{"Math"},
+	{"Noncharacter_Code_Point", "NChar"},
+	{"Pattern_Syntax", "Pat_Syn"},
+	{"Pattern_White_Space", "Pat_WS"},
+	{"Quotation_Mark", "QMark"},
+	{"Radical"},
+	{"Regional_Indicator", "RI"},
+	{"Sentence_Terminal", "STerm"},
+	{"Soft_Dotted", "SD"},
+	{"Terminal_Punctuation", "Term"},
+	{"Unified_Ideograph", "UIdeo"},
+	{"Uppercase", "Upper"},
+	{"Variation_Selector", "VS"},
+	{"White_Space", "space"},
+	{"XID_Continue", "XIDC"},
+	{"XID_Start", "XIDS"},
+}
+
+// Only with the v flag
+var propertiesOfStrings = [][]string{
+	{"Basic_Emoji"},
+	{"Emoji_Keycap_Sequence"},
+	{"RGI_Emoji_Modifier_Sequence"},
+	{"RGI_Emoji_Flag_Sequence"},
+	{"RGI_Emoji_Tag_Sequence"},
+	{"RGI_Emoji_ZWJ_Sequence"},
+	{"RGI_Emoji"},
+}
+
+func aliasTable(rows [][]string) map[string]string {
+	m := map[string]string{}
+	for _, row := range rows {
+		for _, name := range row {
+			m[name] = row[0]
+		}
+	}
+	return m
+}
+
+var (
+	generalCategoryAliases = aliasTable(generalCategoryValues)
+	scriptAliases          = aliasTable(scriptValues)
+	binaryPropertyAliases  = aliasTable(binaryProperties)
+	propertyOfStringsNames = aliasTable(propertiesOfStrings)
+)
+
+// GeneralCategoryValue returns the canonical name of a General_Category
+// value, e.g. "Letter" for "L".
+func GeneralCategoryValue(value string) (string, bool) {
+	name, ok := generalCategoryAliases[value]
+	return name, ok
+}
+
+// ScriptValue returns the canonical name of a Script or Script_Extensions
+// value, e.g. "Greek" for "Grek".
+func ScriptValue(value string) (string, bool) {
+	name, ok := scriptAliases[value]
+	return name, ok
+}
+
+// BinaryProperty returns the canonical name of a binary property, e.g.
+// "Alphabetic" for "Alpha".
+func BinaryProperty(name string) (string, bool) {
+	canonical, ok := binaryPropertyAliases[name]
+	return canonical, ok
+}
+
+// IsPropertyOfStrings returns whether name is a property of strings, which
+// may match more than one character and is only valid with the v flag.
+func IsPropertyOfStrings(name string) bool {
+	_, ok := propertyOfStringsNames[name]
+	return ok
+}
+
+// PropertyName returns the canonical name of the name of a property with a
+// value: "General_Category", "Script" or "Script_Extensions".
+func PropertyName(name string) (string, bool) {
+	switch name {
+	case "General_Category", "gc":
+		return "General_Category", true
+	case "Script", "sc":
+		return "Script", true
+	case "Script_Extensions", "scx":
+		return "Script_Extensions", true
+	}
+	return "", false
+}
+
+// IsValidUnicodeProperty returns whether `name=value` is a valid
+// UnicodePropertyValueExpression.
+func IsValidUnicodeProperty(name string, value string) bool {
+	canonical, ok := PropertyName(name)
+	if !ok {
+		return false
+	}
+	if canonical == "General_Category" {
+		_, ok = GeneralCategoryValue(value)
+	} else {
+		_, ok = ScriptValue(value)
+	}
+	return ok
+}
+
+var (
+	asciiTable = &unicode.RangeTable{R16: []unicode.Range16{{Lo: 0, Hi: 0x7f, Stride: 1}}, LatinOffset: 1}
+	anyTable   = &unicode.RangeTable{
+		R16: []unicode.Range16{{Lo: 0, Hi: 0xffff, Stride: 1}},
+		R32: []unicode.Range32{{Lo: 0x10000, Hi: MaxCodePoint, Stride: 1}},
+	}
+)
+
+// The binary properties that Go has no table of but derives from ones it
+// has, by DerivedCoreProperties.txt
+var derivedProperties = map[string][]*unicode.RangeTable{
+	"ASCII":           {asciiTable},
+	"Any":             {anyTable},
+	"Assigned":        {unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Z, unicode.Cc, unicode.Cf, unicode.Co, unicode.Cs},
+	"Alphabetic":      {unicode.Lu, unicode.Ll, unicode.Lt, unicode.Lm, unicode.Lo, unicode.Nl, unicode.Other_Alphabetic},
+	"Cased":           {unicode.Lu, unicode.Ll, unicode.Lt, unicode.Other_Lowercase, unicode.Other_Uppercase},
+	"Grapheme_Extend": {unicode.Me, unicode.Mn, unicode.Other_Grapheme_Extend},
+	"Lowercase":       {unicode.Ll, unicode.Other_Lowercase},
+	"Math":            {unicode.Sm, unicode.Other_Math},
+	"Uppercase":       {unicode.Lu, unicode.Other_Uppercase},
+}
+
+// PropertyTables returns the tables of Go's unicode package whose union has
+// the characters of `\p{key=value}`, or of `\p{key}` if value is empty, and
+// false if Go has none, e.g. for Script_Extensions, Emoji and the properties
+// of strings. Go's tables may be of another Unicode version than the names
+// above.
+func PropertyTables(key string, value string) ([]*unicode.RangeTable, bool) {
+	if value == "" {
+		name, ok := BinaryProperty(key)
+		if !ok {
+			return nil, false
+		}
+		if tables, ok := derivedProperties[name]; ok {
+			return tables, true
+		}
+		t, ok := unicode.Properties[name]
+		return []*unicode.RangeTable{t}, ok
+	}
+	switch name, _ := PropertyName(key); name {
+	case "General_Category":
+		canonical, _ := GeneralCategoryValue(value)
+		for _, row := range generalCategoryValues {
+			// Go's unicode.Categories is keyed by the second name
+			if row[0] == canonical {
+				t, ok := unicode.Categories[row[1]]
+				return []*unicode.RangeTable{t}, ok
+			}
+		}
+	case "Script":
+		canonical, ok := ScriptValue(value)
+		if !ok {
+			return nil, false
+		}
+		t, ok := unicode.Scripts[canonical]
+		return []*unicode.RangeTable{t}, ok
+	}
+	return nil, false
+}
@@ -1,19 +1,49 @@
 package unicode_consts
 
+import "unicode"
+
 const (
 	Eof                 = 0x1A
+	Null                = 0x00
 	Backspace           = 0x08
+	CharacterTabulation = 0x09
+	LineFeed            = 0x0a
+	LineTabulation      = 0x0b
+	FormFeed            = 0x0c
+	CarriageReturn      = 0x0d
 	LatinSmallLetterA   = 0x61 // a
 	LatinSmallLetterB   = 0x62 // b
+	LatinSmallLetterC   = 0x63 // c
+	LatinSmallLetterD   = 0x64 // d
 	LatinSmallLetterF   = 0x66 // f
+	LatinSmallLetterK   = 0x6b // k
+	LatinSmallLetterN   = 0x6e // n
+	LatinSmallLetterP   = 0x70 // p
+	LatinSmallLetterQ   = 0x71 // q
+	LatinSmallLetterR   = 0x72 // r
+	LatinSmallLetterS   = 0x73 // s
+	LatinSmallLetterT   = 0x74 // t
+	LatinSmallLetterU   = 0x75 // u
+	LatinSmallLetterV   = 0x76 // v
+	LatinSmallLetterW   = 0x77 // w
+	LatinSmallLetterX   = 0x78 // x
+	LatinSmallLetterZ   = 0x7a // z
 	LatinCapitalLetterA = 0x41 // A
+	LatinCapitalLetterB = 0x42 // B
+	LatinCapitalLetterD = 0x44 // D
 	LatinCapitalLetterF = 0x46 // F
+	LatinCapitalLetterP = 0x50 // P
+	LatinCapitalLetterS = 0x53 // S
+	LatinCapitalLetterW = 0x57 // W
+	LatinCapitalLetterZ = 0x5a // Z
 	DigitZero           = 0x30 // 0
+	DigitSeven          = 0x37 // 7
 	DigitNine           = 0x39 // 9
 	VerticalLine        = 0x7c // |
 	CircumflexAccent    = 0x5e // ^
 	DollarSign          = 0x24 // $
 	ReverseSolidus      = 0x5c // \
+	Solidus             = 0x2f // /
 	FullStop            = 0x2e // .
 	Asterisk            = 0x2a // *
 	PlusSign            = 0x2b // +
@@ -26,12 +56,199 @@ const (
 	RightCurlyBracket   = 0x7d // {
 	Comma               = 0x2c // ,
 	HyphenMinus         = 0x2d // -
+	LowLine             = 0x5f // _
+	Ampersand           = 0x26 // &
+	ExclamationMark     = 0x21 // !
+	NumberSign          = 0x23 // #
+	PercentSign         = 0x25 // %
+	Colon               = 0x3a // :
+	Semicolon           = 0x3b // ;
+	LessThanSign        = 0x3c // <
+	EqualsSign          = 0x3d // =
+	GreaterThanSign     = 0x3e // >
+	CommercialAt        = 0x40 // @
+	GraveAccent         = 0x60 // `
+	Tilde               = 0x7e // ~
+	ZeroWidthNonJoiner  = 0x200c
+	ZeroWidthJoiner     = 0x200d
+	LineSeparator       = 0x2028
+	ParagraphSeparator  = 0x2029
+	MinLeadSurrogate    = 0xd800
+	MaxLeadSurrogate    = 0xdbff
+	MinTrailSurrogate   = 0xdc00
+	MaxTrailSurrogate   = 0xdfff
+	MaxCodePoint        = 0x10ffff
 )
 
+// SyntaxCharacter :: one of
+//
+//	^ $ \ . * + ? ( ) [ ] { } |
+//
+// https://tc39.es/ecma262/multipage/text-processing.html#prod-SyntaxCharacter
+func IsSyntaxCharacter(code int) bool {
+	return code == CircumflexAccent ||
+		code == DollarSign ||
+		code == ReverseSolidus ||
+		code == FullStop ||
+		code == Asterisk ||
+		code == PlusSign ||
+		code == QuestionMark ||
+		code == LeftParenthesis ||
+		code == RightParenthesis ||
+		code == LeftSquareBracket ||
+		code == RightSquareBracket ||
+		code == LeftCurlyBracket ||
+		code == RightCurlyBracket ||
+		code == VerticalLine
+}
+
+// ClassSetReservedDoublePunctuator :: one of
+//
+//	&& !! ## $$ %% ** ++ ,, .. :: ;; << == >> ?? @@ ^^ `` ~~
+//
+// Returns whether code doubled is a ClassSetReservedDoublePunctuator.
+//
+// https://tc39.es/ecma262/multipage/text-processing.html#prod-ClassSetReservedDoublePunctuator
+func IsClassSetReservedDoublePunctuatorCharacter(code int) bool {
+	return code == Ampersand ||
+		code == ExclamationMark ||
+		code == NumberSign ||
+		code == DollarSign ||
+		code == PercentSign ||
+		code == Asterisk ||
+		code == PlusSign ||
+		code == Comma ||
+		code == FullStop ||
+		code == Colon ||
+		code == Semicolon ||
+		code == LessThanSign ||
+		code == EqualsSign ||
+		code == GreaterThanSign ||
+		code == QuestionMark ||
+		code == CommercialAt ||
+		code == CircumflexAccent ||
+		code == GraveAccent ||
+		code == Tilde
+}
+
+// ClassSetSyntaxCharacter :: one of
+//
+//	( ) [ ] { } / - \ |
+//
+// https://tc39.es/ecma262/multipage/text-processing.html#prod-ClassSetSyntaxCharacter
+func IsClassSetSyntaxCharacter(code int) bool {
+	return code == LeftParenthesis ||
+		code == RightParenthesis ||
+		code == LeftSquareBracket ||
+		code == RightSquareBracket ||
+		code == LeftCurlyBracket ||
+		code == RightCurlyBracket ||
+		code == Solidus ||
+		code == HyphenMinus ||
+		code == ReverseSolidus ||
+		code == VerticalLine
+}
+
+// ClassSetReservedPunctuator :: one of
+//
+//	& - ! # % , : ; < = > @ ` ~
+//
+// https://tc39.es/ecma262/multipage/text-processing.html#prod-ClassSetReservedPunctuator
+func IsClassSetReservedPunctuator(code int) bool {
+	return code == Ampersand ||
+		code == HyphenMinus ||
+		code == ExclamationMark ||
+		code == NumberSign ||
+		code == PercentSign ||
+		code == Comma ||
+		code == Colon ||
+		code == Semicolon ||
+		code == LessThanSign ||
+		code == EqualsSign ||
+		code == GreaterThanSign ||
+		code == CommercialAt ||
+		code == GraveAccent ||
+		code == Tilde
+}
+
 func IsDecimalDigit(code int) bool {
 	return code >= DigitZero && code <= DigitNine
 }
 
+func IsOctalDigit(code int) bool {
+	return code >= DigitZero && code <= DigitSeven
+}
+
+func IsHexDigit(code int) bool {
+	return IsDecimalDigit(code) ||
+		(code >= LatinSmallLetterA && code <= LatinSmallLetterF) ||
+		(code >= LatinCapitalLetterA && code <= LatinCapitalLetterF)
+}
+
+func IsLatinLetter(code int) bool {
+	return (code >= LatinSmallLetterA && code <= LatinSmallLetterZ) ||
+		(code >= LatinCapitalLetterA && code <= LatinCapitalLetterZ)
+}
+
+func IsLineTerminator(code int) bool {
+	return code == LineFeed ||
+		code == CarriageReturn ||
+		code == LineSeparator ||
+		code == ParagraphSeparator
+}
+
+// IdentifierStartChar ::
+//
+//	UnicodeIDStart
+//	$
+//	_
+//
+// https://tc39.es/ecma262/multipage/ecmascript-language-lexical-grammar.html#prod-IdentifierStartChar
+func IsIdentifierStartChar(code int) bool {
+	return code == DollarSign || code == LowLine || isIDStart(code)
+}
+
+// IdentifierPartChar ::
+//
+//	UnicodeIDContinue
+//	$
+//	<ZWNJ>
+//	<ZWJ>
+//
+// https://tc39.es/ecma262/multipage/ecmascript-language-lexical-grammar.html#prod-IdentifierPartChar
+func IsIdentifierPartChar(code int) bool {
+	return code == DollarSign || code == ZeroWidthNonJoiner || code == ZeroWidthJoiner || isIDContinue(code)
+}
+
+// ID_Start and ID_Continue, as derived in DerivedCoreProperties.txt
+func isIDStart(code int) bool {
+	r := rune(code)
+	if code < 0 || unicode.Is(unicode.Pattern_Syntax, r) || unicode.Is(unicode.Pattern_White_Space, r) {
+		return false
+	}
+	return unicode.In(r, unicode.L, unicode.Nl, unicode.Other_ID_Start)
+}
+
+func isIDContinue(code int) bool {
+	r := rune(code)
+	if code < 0 || unicode.Is(unicode.Pattern_Syntax, r) || unicode.Is(unicode.Pattern_White_Space, r) {
+		return false
+	}
+	return unicode.In(r, unicode.L, unicode.Nl, unicode.Other_ID_Start, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue)
+}
+
+func IsLeadSurrogate(code int) bool {
+	return code >= MinLeadSurrogate && code <= MaxLeadSurrogate
+}
+
+func IsTrailSurrogate(code int) bool {
+	return code >= MinTrailSurrogate && code <= MaxTrailSurrogate
+}
+
+func CombineSurrogatePair(lead int, trail int) int {
+	return (lead-MinLeadSurrogate)*0x400 + (trail - MinTrailSurrogate) + 0x10000
+}
+
 func DecimalToDigit(code int) int {
 	if code >= LatinSmallLetterA && code <= LatinSmallLetterF {
 		return code - LatinSmallLetterA + 10
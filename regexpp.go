@@ -1,8 +1,321 @@
 package regexpp
 
-import "github.com/sosukesuzuki/regexpp-go/internal/parser"
+import (
+	"context"
+	"io"
+	"regexp/syntax"
 
-func ParsePattern(source string, u bool) {
-	parser := parser.NewParser(source, u)
-	parser.ParsePattern()
+	"github.com/sosukesuzuki/regexpp-go/internal/ast_dump"
+	"github.com/sosukesuzuki/regexpp-go/internal/ast_json"
+	"github.com/sosukesuzuki/regexpp-go/internal/batch"
+	"github.com/sosukesuzuki/regexpp-go/internal/builder"
+	"github.com/sosukesuzuki/regexpp-go/internal/cache"
+	"github.com/sosukesuzuki/regexpp-go/internal/case_expansion"
+	"github.com/sosukesuzuki/regexpp-go/internal/charset"
+	"github.com/sosukesuzuki/regexpp-go/internal/diagnostic"
+	"github.com/sosukesuzuki/regexpp-go/internal/dialect"
+	"github.com/sosukesuzuki/regexpp-go/internal/downlevel"
+	"github.com/sosukesuzuki/regexpp-go/internal/formatter"
+	"github.com/sosukesuzuki/regexpp-go/internal/offset"
+	"github.com/sosukesuzuki/regexpp-go/internal/optimizer"
+	"github.com/sosukesuzuki/regexpp-go/internal/parser"
+	"github.com/sosukesuzuki/regexpp-go/internal/printer"
+	"github.com/sosukesuzuki/regexpp-go/internal/re2"
+	"github.com/sosukesuzuki/regexpp-go/internal/regexp_ast"
+	"github.com/sosukesuzuki/regexpp-go/internal/source_edit"
+	"github.com/sosukesuzuki/regexpp-go/internal/source_map"
+)
+
+type Options = parser.Options
+
+type HostSource = parser.HostSource
+
+// Parser can be reused for many patterns with Reset.
+type Parser = parser.Parser
+
+type Arena = regexp_ast.Arena
+
+type Position = regexp_ast.Position
+
+type OffsetUnit = offset.Unit
+
+const (
+	UTF16     = offset.UTF16
+	CodePoint = offset.CodePoint
+	UTF8      = offset.UTF8
+)
+
+type OffsetConverter = offset.Converter
+
+// NewOffsetConverter returns a converter between the offset units of source.
+func NewOffsetConverter(source string) *OffsetConverter {
+	return offset.NewConverter(source)
+}
+
+// LineStarts returns the offsets, in unit u, at which each line of text starts.
+// Use it to fill HostSource.LineStarts once per host file.
+func LineStarts(text string, u OffsetUnit) []int {
+	return offset.LineStarts(text, u)
+}
+
+func ParsePattern(source string, u bool) (*regexp_ast.Pattern, error) {
+	return ParsePatternWithOptions(source, u, Options{})
+}
+
+func ParsePatternWithOptions(source string, u bool, opts Options) (*regexp_ast.Pattern, error) {
+	parser := parser.NewParserWithOptions(source, u, opts)
+	return parser.ParsePattern()
+}
+
+func NewParser(source string, u bool, opts Options) *Parser {
+	parser := parser.NewParserWithOptions(source, u, opts)
+	return &parser
+}
+
+// Cache memoizes parse results. Trees returned from a Cache are shared between
+// callers and must not be mutated.
+type Cache = cache.Cache
+
+type CacheStats = cache.Stats
+
+func NewCache(capacity int) *Cache {
+	return cache.New(capacity)
+}
+
+type Input = batch.Input
+
+type Result = batch.Result
+
+type BatchOptions = batch.Options
+
+type BatchStats = batch.Stats
+
+// ParseAll parses inputs over a pool of goroutines and returns the results in
+// input order.
+func ParseAll(ctx context.Context, inputs []Input, opts BatchOptions) ([]Result, BatchStats, error) {
+	return batch.ParseAll(ctx, inputs, opts)
+}
+
+// MarshalJSON returns the tree rooted at node in the JSON format of
+// mysticatea/regexpp. Locs must be in UTF-16 code units.
+func MarshalJSON(node regexp_ast.Node, source string) ([]byte, error) {
+	return ast_json.Marshal(node, source)
+}
+
+// UnmarshalJSON decodes the output of MarshalJSON back into a tree with parent
+// pointers.
+func UnmarshalJSON(data []byte) (regexp_ast.Node, error) {
+	return ast_json.Unmarshal(data)
+}
+
+type DumpMode = ast_dump.Mode
+
+const (
+	DumpSExpr     = ast_dump.SExpr
+	DumpNoOffsets = ast_dump.NoOffsets
+)
+
+// Fprint writes a human-readable dump of the tree rooted at node.
+func Fprint(w io.Writer, node regexp_ast.Node, mode DumpMode) error {
+	return ast_dump.Fprint(w, node, mode)
+}
+
+// Print returns the pattern source of node. Parsing it in the same mode gives
+// back a structurally equal tree.
+func Print(node regexp_ast.Node, u bool) string {
+	return printer.Print(node, u)
+}
+
+type FormatStyle = formatter.Style
+
+// Format returns source in a canonical form, or its shortest form with
+// style.Minify.
+func Format(source string, u bool, style FormatStyle) (string, error) {
+	return formatter.Format(source, u, style)
+}
+
+type Builder = builder.Builder
+
+// NewBuilder returns a builder of trees with parent links and the Locs of
+// their printed source. u selects the unicode mode.
+func NewBuilder(u bool) *Builder {
+	return builder.New(u)
+}
+
+// Replace, Remove, InsertBefore and InsertAfter edit a tree and keep its
+// parent links consistent. Locs are left as they were.
+func Replace(old regexp_ast.Node, new regexp_ast.Node) error {
+	return regexp_ast.Replace(old, new)
+}
+
+func Remove(node regexp_ast.Node) error {
+	return regexp_ast.Remove(node)
+}
+
+func InsertBefore(ref regexp_ast.Node, node regexp_ast.Node) error {
+	return regexp_ast.InsertBefore(ref, node)
+}
+
+func InsertAfter(ref regexp_ast.Node, node regexp_ast.Node) error {
+	return regexp_ast.InsertAfter(ref, node)
+}
+
+// Clone returns a deep copy of the tree rooted at node.
+func Clone(node regexp_ast.Node) regexp_ast.Node {
+	return regexp_ast.Clone(node)
+}
+
+// Verify checks the parent links, Locs and structure of the tree rooted at
+// node.
+func Verify(node regexp_ast.Node) error {
+	return regexp_ast.Verify(node)
+}
+
+// Layout sets the Locs of the tree rooted at node to the ones of its printed
+// source, and returns the source. Use it after editing a tree.
+func Layout(node regexp_ast.Node, u bool) string {
+	return printer.Layout(node, u)
+}
+
+type Flags = regexp_ast.Flags
+
+// ParseFlags parses the flags of a regular expression literal, e.g. "gu".
+func ParseFlags(source string) (Flags, error) {
+	return parser.ParseFlags(source)
+}
+
+// Optimize returns a smaller copy of pattern that matches the same strings
+// under flags.
+func Optimize(pattern *regexp_ast.Pattern, flags Flags) *regexp_ast.Pattern {
+	return optimizer.Optimize(pattern, flags)
+}
+
+type (
+	Diagnostic     = diagnostic.Diagnostic
+	DiagnosticList = diagnostic.List
+)
+
+// TranspileRE2 returns the syntax of Go's regexp package that matches what
+// pattern matches under flags. The error is a DiagnosticList of the
+// constructs that RE2 can't express.
+func TranspileRE2(pattern *regexp_ast.Pattern, flags Flags) (string, error) {
+	return re2.Transpile(pattern, flags)
+}
+
+// TranspileRE2WithMap is like TranspileRE2, and also maps the output back to
+// the Locs of pattern.
+func TranspileRE2WithMap(pattern *regexp_ast.Pattern, flags Flags) (string, *SourceMap, error) {
+	return re2.TranspileWithMap(pattern, flags)
+}
+
+// ToSyntax builds the regexp/syntax tree that matches what pattern matches
+// under flags, without going through RE2 syntax.
+func ToSyntax(pattern *regexp_ast.Pattern, flags Flags) (*syntax.Regexp, error) {
+	return re2.ToSyntax(pattern, flags)
+}
+
+// FromRE2 converts an expression in the syntax of Go's regexp package into an
+// ECMAScript pattern and flags that match the same strings.
+func FromRE2(expr string) (*regexp_ast.Pattern, Flags, error) {
+	return re2.FromRE2(expr)
+}
+
+// Dialect is the syntax of another regular expression engine. Its Emit
+// method translates a pattern while keeping the ECMAScript semantics.
+type Dialect = dialect.Dialect
+
+var (
+	PCRE2      = dialect.PCRE2
+	Python     = dialect.Python
+	Java       = dialect.Java
+	PostgreSQL = dialect.PostgreSQL
+	MySQL      = dialect.MySQL
+	Lucene     = dialect.Lucene
+)
+
+// The editions accepted by TranspileES.
+const (
+	ES5    = downlevel.ES5
+	ES2015 = downlevel.ES2015
+	ES2018 = downlevel.ES2018
+	ES2022 = downlevel.ES2022
+	ES2024 = downlevel.ES2024
+)
+
+// TranspileResult is the source and the flags of a pattern rewritten by
+// TranspileES, with the indices of the named groups and the lookbehinds that
+// are left as they are.
+type TranspileResult = downlevel.Result
+
+// TranspileES returns a pattern that matches what pattern matches under
+// flags using only the syntax of ecmaVersion, like regexpu. The error is a
+// DiagnosticList of the features that can't be lowered.
+func TranspileES(pattern *regexp_ast.Pattern, flags Flags, ecmaVersion int) (TranspileResult, error) {
+	return downlevel.Transpile(pattern, flags, ecmaVersion)
+}
+
+// ExpandCase returns a copy of pattern that matches without the `i` flag
+// what pattern matches under flags, with the case variants of every
+// character written out, and flags without `i`. The error is a
+// DiagnosticList of the parts that can't be written without `i`.
+func ExpandCase(pattern *regexp_ast.Pattern, flags Flags) (*regexp_ast.Pattern, Flags, error) {
+	return case_expansion.Expand(pattern, flags)
+}
+
+// TranspileESWithMap is like TranspileES, and also maps the output back to
+// the Locs of pattern.
+func TranspileESWithMap(pattern *regexp_ast.Pattern, flags Flags, ecmaVersion int) (TranspileResult, *SourceMap, error) {
+	return downlevel.TranspileWithMap(pattern, flags, ecmaVersion)
+}
+
+type (
+	// SourceMap maps the output of a translator back to the Locs of the
+	// nodes it was translated from.
+	SourceMap     = source_map.Map
+	SourceSegment = source_map.Segment
+	TextEdit      = source_edit.TextEdit
+)
+
+// DiffSource returns the text edits that turn source, which original was
+// parsed from with flags, into a source of edited, a changed clone of original. Only the
+// parts whose nodes changed are replaced.
+func DiffSource(source string, original *regexp_ast.Pattern, edited *regexp_ast.Pattern, flags Flags) []TextEdit {
+	return source_edit.Diff(source, original, edited, flags)
+}
+
+// ApplyEdits returns source with edits applied.
+func ApplyEdits(source string, edits []TextEdit) (string, error) {
+	return source_edit.Apply(source, edits)
+}
+
+type (
+	// CharSet is an immutable set of code points.
+	CharSet   = charset.Set
+	CharRange = charset.Range
+	// ClassSet is what a class matches with `v`: characters and strings.
+	ClassSet = charset.ClassSet
+)
+
+// NewCharSet returns the set of the code points in ranges.
+func NewCharSet(ranges ...CharRange) CharSet {
+	return charset.New(ranges...)
+}
+
+// NewClassSet returns the class set of chars and strs, like `[chars\q{…}]`
+// with `v`.
+func NewClassSet(chars CharSet, strs ...string) ClassSet {
+	return charset.NewClassSet(chars, strs...)
+}
+
+// ClassSetOf returns what node, a class or a part of one, matches under
+// flags, with the strings of classes with `v`.
+func ClassSetOf(node regexp_ast.Node, flags regexp_ast.Flags) (ClassSet, bool) {
+	return charset.ClassSetOf(node, flags)
+}
+
+// CharSetOf returns the characters that node matches under flags, and whether
+// node matches a single character at all. Without `u` or `v`, the characters
+// are UTF-16 code units.
+func CharSetOf(node regexp_ast.Node, flags regexp_ast.Flags) (CharSet, bool) {
+	return charset.OfNode(node, flags)
 }